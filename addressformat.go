@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+)
+
+const (
+	addressHex    = "hex"    // 0x + 20 字节，以太坊格式
+	addressHex41  = "hex41"  // 0x41 + 20 字节
+	addressBase58 = "base58" // T...
+)
+
+var (
+	// METHOD=FORMAT，方法名支持 glob，按顺序匹配第一条；默认不改写
+	addressRules = parseAddressRules(envString("ADDRESS_FORMAT", ""))
+	// 改写为 base58 时只处理这些字段里的 hex 值，避免误伤哈希等其他 20 字节数据
+	addressFields = toSet(splitList(envString("ADDRESS_FIELDS",
+		"address,from,to,creator,contractAddress,owner_address,to_address,contract_address,origin_address")))
+)
+
+type addressRule struct {
+	pattern string
+	format  string
+}
+
+func parseAddressRules(s string) []addressRule {
+	var rules []addressRule
+	for _, item := range splitList(s) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || !validAddressFormat(strings.TrimSpace(parts[1])) {
+			log.Printf("Invalid ADDRESS_FORMAT entry %q, ignored", item)
+			continue
+		}
+		rules = append(rules, addressRule{pattern: strings.TrimSpace(parts[0]), format: strings.TrimSpace(parts[1])})
+	}
+	return rules
+}
+
+func validAddressFormat(f string) bool {
+	return f == addressHex || f == addressHex41 || f == addressBase58
+}
+
+type addressFormatCtxKey struct{}
+
+// withAddressFormat 客户端可以用 X-Address-Format 头覆盖按方法配置的格式
+func withAddressFormat(ctx context.Context, r *http.Request) context.Context {
+	if f := r.Header.Get("X-Address-Format"); validAddressFormat(f) {
+		return context.WithValue(ctx, addressFormatCtxKey{}, f)
+	}
+	return ctx
+}
+
+func addressFormatFor(ctx context.Context, method string) string {
+	if f, ok := ctx.Value(addressFormatCtxKey{}).(string); ok {
+		return f
+	}
+	for _, r := range addressRules {
+		if ok, _ := path.Match(r.pattern, method); ok {
+			return r.format
+		}
+	}
+	return ""
+}
+
+// normalizeRequestAddresses 把参数里的地址改写为目标格式，然后再查缓存和转发
+func normalizeRequestAddresses(ctx context.Context, req JSONRPCRequest) JSONRPCRequest {
+	format := addressFormatFor(ctx, req.Method)
+	if format == "" || len(req.Params) == 0 {
+		return req
+	}
+	params := make([]json.RawMessage, len(req.Params))
+	for i, p := range req.Params {
+		params[i] = p
+		v, ok := decodeJSONNumber(p)
+		if !ok {
+			continue
+		}
+		if out, changed := rewriteAddresses(v, "", format); changed {
+			if b, err := codec.Marshal(out); err == nil {
+				params[i] = b
+			}
+		}
+	}
+	req.Params = params
+	return req
+}
+
+func normalizeResponseAddresses(ctx context.Context, method string, resp *JSONRPCResponse) {
+	format := addressFormatFor(ctx, method)
+	if format == "" || resp.Result == nil {
+		return
+	}
+	raw, err := codec.Marshal(resp.Result)
+	if err != nil {
+		return
+	}
+	v, ok := decodeJSONNumber(raw)
+	if !ok {
+		return
+	}
+	if out, changed := rewriteAddresses(v, "", format); changed {
+		resp.Result = out
+	}
+}
+
+// decodeJSONNumber 保留数字原文，避免大整数经过 float64 丢精度
+func decodeJSONNumber(raw []byte) (interface{}, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func rewriteAddresses(v interface{}, key, format string) (interface{}, bool) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		changed := false
+		for k, child := range node {
+			if out, ok := rewriteAddresses(child, k, format); ok {
+				node[k] = out
+				changed = true
+			}
+		}
+		return node, changed
+	case []interface{}:
+		changed := false
+		for i, child := range node {
+			if out, ok := rewriteAddresses(child, key, format); ok {
+				node[i] = out
+				changed = true
+			}
+		}
+		return node, changed
+	case string:
+		if out, ok := convertAddress(node, key, format); ok && out != node {
+			return out, true
+		}
+	}
+	return v, false
+}
+
+// convertAddress base58 地址带校验和，可以按值识别；hex 地址只在已知的地址字段里识别
+func convertAddress(s, key, format string) (string, bool) {
+	isBase58 := len(s) == 34 && s[0] == 'T'
+	if !isBase58 && !addressFields[key] {
+		return "", false
+	}
+	if len(s) == 44 && strings.HasPrefix(s, "0x41") {
+		s = s[2:]
+	}
+	b, err := translate.DecodeAddress(s)
+	if err != nil {
+		return "", false
+	}
+	switch format {
+	case addressHex:
+		return "0x" + hex.EncodeToString(b[1:]), true
+	case addressHex41:
+		return "0x" + hex.EncodeToString(b), true
+	case addressBase58:
+		return translate.Base58CheckEncode(b), true
+	}
+	return "", false
+}
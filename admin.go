@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// ADMIN_TOKEN 未配置时管理接口整体关闭
+var adminToken = configValue("ADMIN_TOKEN")
+
+func registerAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/capabilities", adminOnly(handleAdminCapabilities))
+	mux.HandleFunc("/admin/usage", adminOnly(handleAdminUsage))
+	mux.HandleFunc("/admin/quotas", adminOnly(handleAdminQuotas))
+	mux.HandleFunc("/admin/snapshot", adminOnly(handleAdminSnapshot))
+	mux.HandleFunc("/admin/cache/invalidate", adminOnly(handleAdminCacheInvalidate))
+	mux.HandleFunc("/admin/cache/entries", adminOnly(handleAdminCacheEntries))
+	mux.HandleFunc("/admin/audit", adminOnly(handleAdminAudit))
+	mux.HandleFunc("/admin/replay", adminOnly(handleAdminReplay))
+	mux.HandleFunc("/admin/events", adminOnly(handleAdminEvents))
+	mux.HandleFunc("/admin/standby", adminOnly(handleAdminStandby))
+	mux.HandleFunc("/admin/promote", adminOnly(handleAdminPromote))
+	mux.HandleFunc("/admin/profile", adminOnly(handleAdminProfile))
+	mux.HandleFunc("/admin/tracestore", adminOnly(handleAdminTraceStore))
+	mux.HandleFunc("/admin/support-bundle", adminOnly(handleAdminSupportBundle))
+	mux.HandleFunc("/admin/chaos", adminOnly(handleAdminChaos))
+	mux.HandleFunc("/admin/reload", adminOnly(handleAdminReload))
+	mux.HandleFunc("/admin/config", adminOnly(handleAdminConfig))
+	mux.HandleFunc("/admin/upstreams", adminOnly(handleAdminUpstreams))
+	mux.HandleFunc("/admin/upstreams/drain", adminOnly(handleAdminUpstreamDrain))
+	mux.HandleFunc("/admin/upstreams/history", adminOnly(handleAdminUpstreamHistory))
+	mux.HandleFunc("/admin/cache", adminOnly(handleAdminCache))
+	mux.HandleFunc("/admin/cache/flush", adminOnly(handleAdminCacheFlush))
+	mux.HandleFunc("/admin/ratelimits", adminOnly(handleAdminRateLimits))
+	mux.HandleFunc("/admin/requests", adminOnly(handleAdminRequests))
+	mux.HandleFunc("/admin/webhooks", adminOnly(handleAdminWebhooks))
+	mux.HandleFunc("/admin/slow", adminOnly(handleAdminSlow))
+}
+
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !isAdminRequest(r) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isAdminRequest(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.Header.Get("X-Admin-Token")
+	}
+	// 标记为 admin 的 API key 也可以访问管理接口；配了 signingSecret 的 key 只能签名调用，不能当 token 用
+	if k := lookupAPIKey(token); k != nil && k.Admin && k.SigningSecret == "" {
+		return true
+	}
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	codec.NewEncoder(w).Encode(v)
+}
+
+func handleAdminCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("refresh") == "1" {
+		jsonrpcUpstreams.probeAll(r.Context())
+	}
+	writeJSON(w, http.StatusOK, jsonrpcUpstreams.capabilityMatrix())
+}
@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"sync/atomic"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// 归档节点：trace/debug 方法和较早区块上的状态查询发往这里，其余请求仍发往普通全节点。
+	// 未配置时所有请求都走 TRON_JSONRPC_ENDPOINT/TRON_REST_ENDPOINT
+	archiveUpstreams     = newArchivePool("jsonrpc", splitList(configValue("TRON_ARCHIVE_JSONRPC_ENDPOINT")))
+	archiveRestUpstreams = newArchivePool("rest", splitList(configValue("TRON_ARCHIVE_REST_ENDPOINT")))
+
+	archiveMethods = splitList(envString("ARCHIVE_METHODS", "debug_*,trace_*,proxy_getTransactionInfoRange"))
+	// 区块参数比最新高度早这么多个区块时发往归档节点，0 表示只按方法路由；需要 BLOCK_WATCH_INTERVAL 提供最新高度
+	archiveBlockAge = int64(envInt("ARCHIVE_BLOCK_AGE", 0))
+	// 归档节点全部不可用时是否改用普通节点（旧区块上的查询可能报错）
+	archiveFallback = envString("ARCHIVE_FALLBACK", "true") == "true"
+
+	archiveHead int64
+)
+
+type archiveCtxKey struct{}
+
+func newArchivePool(kind string, urls []string) *upstreamPool {
+	p := &upstreamPool{kind: kind, role: "archive"}
+	p.setURLs(urls)
+	return p
+}
+
+func init() {
+	events.subscribe("archive", 16, func(e event) {
+		atomic.StoreInt64(&archiveHead, e.Data.(newBlockEvent).Header.Number)
+	}, EventNewBlock)
+}
+
+// archiveRoute 按方法名或区块参数的高度判断是否发往归档节点
+func archiveRoute(req JSONRPCRequest) bool {
+	for _, p := range archiveMethods {
+		if ok, _ := path.Match(p, req.Method); ok {
+			return true
+		}
+	}
+	if archiveBlockAge <= 0 {
+		return false
+	}
+	rule, ok := blockTagRules[req.Method]
+	if !ok || len(req.Params) <= rule.index {
+		return false
+	}
+	num, ok := requestBlockNumber(req.Params[rule.index])
+	return ok && archiveBlockOld(num)
+}
+
+func archiveBlockOld(num int64) bool {
+	head := atomic.LoadInt64(&archiveHead)
+	return archiveBlockAge > 0 && head > 0 && num >= 0 && head-num > archiveBlockAge
+}
+
+// requestBlockNumber 区块参数可以是高度、EIP-1898 对象、blockHash 或 eth_getLogs 的过滤条件（按 fromBlock）
+func requestBlockNumber(raw json.RawMessage) (int64, bool) {
+	if n, err := parseInt64Param(raw); err == nil {
+		return n, true
+	}
+	var obj struct {
+		BlockNumber json.RawMessage `json:"blockNumber"`
+		FromBlock   json.RawMessage `json:"fromBlock"`
+		BlockHash   string          `json:"blockHash"`
+	}
+	if codec.Unmarshal(raw, &obj) != nil {
+		return 0, false
+	}
+	for _, v := range []json.RawMessage{obj.BlockNumber, obj.FromBlock} {
+		if n, err := parseInt64Param(v); err == nil && len(v) > 0 {
+			return n, true
+		}
+	}
+	if obj.BlockHash != "" {
+		if n, ok := headers.numberOf(obj.BlockHash); ok {
+			return n, true
+		}
+		return translate.BlockHashNumber(obj.BlockHash)
+	}
+	return 0, false
+}
+
+// withArchiveRoute 标记整个请求发往归档节点，处理过程中对节点的其他调用（REST、区块头）也跟随，
+// 避免同一个请求的数据来自不同进度的节点
+func withArchiveRoute(ctx context.Context, reqs ...JSONRPCRequest) context.Context {
+	if len(archiveUpstreams.list()) == 0 && len(archiveRestUpstreams.list()) == 0 {
+		return ctx
+	}
+	for _, r := range reqs {
+		if archiveRoute(r) {
+			return context.WithValue(ctx, archiveCtxKey{}, true)
+		}
+	}
+	return ctx
+}
+
+func archiveRouted(ctx context.Context) bool {
+	v, _ := ctx.Value(archiveCtxKey{}).(bool)
+	return v
+}
+
+func (p *upstreamPool) anyAvailable() bool {
+	for _, u := range p.list() {
+		if u.available() && !u.isDraining() && u.circuit() != circuitOpen {
+			return true
+		}
+	}
+	return false
+}
+
+func choosePool(archive bool, archivePool, regular *upstreamPool) *upstreamPool {
+	if !archive || len(archivePool.list()) == 0 {
+		return regular
+	}
+	if archiveFallback && !archivePool.anyAvailable() && regular.anyAvailable() {
+		log.Printf("No archive %s upstream available, using regular nodes", archivePool.kind)
+		return regular
+	}
+	return archivePool
+}
+
+func jsonrpcPool(ctx context.Context) *upstreamPool {
+	if n := networkFrom(ctx); n != nil {
+		return n.jsonrpc
+	}
+	if p := targetPool(ctx, jsonrpcUpstreams, archiveUpstreams); p != nil {
+		return p
+	}
+	return choosePool(archiveRouted(ctx), archiveUpstreams, jsonrpcUpstreams)
+}
+
+// restPool num 为请求涉及的区块高度，未知时传 -1
+func restPool(ctx context.Context, num int64) *upstreamPool {
+	if n := networkFrom(ctx); n != nil {
+		return n.rest
+	}
+	if p := targetPool(ctx, restUpstreams, archiveRestUpstreams); p != nil {
+		return p
+	}
+	return choosePool(archiveRouted(ctx) || archiveBlockOld(num), archiveRestUpstreams, restUpstreams)
+}
+
+// payloadBlockNum 从 REST 请求体中取出 num 字段
+func payloadBlockNum(payload interface{}) int64 {
+	var v interface{}
+	switch p := payload.(type) {
+	case map[string]interface{}:
+		v = p["num"]
+	case map[string]int64:
+		v = p["num"]
+	}
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	}
+	return -1
+}
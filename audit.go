@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	// 内存里保留最近的上游调用，0 表示关闭审计日志
+	auditLogSize    = envInt("AUDIT_LOG_SIZE", 10000)
+	auditLogFile    = configValue("AUDIT_LOG_FILE")
+	auditMaxBody    = envInt("AUDIT_MAX_BODY_BYTES", 64<<10)
+	auditLog        = newAuditRing(auditLogSize)
+	auditFileWriter = openAuditFile(auditLogFile)
+	auditFileLock   sync.Mutex
+)
+
+type requestIDCtxKey struct{}
+
+// withRequestID 沿用客户端的 X-Request-ID，没有时生成一个，并在响应头里返回
+func withRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx, err := withUpstreamTarget(r.Context(), r)
+		if err != nil {
+			writeTargetError(w, err)
+			return
+		}
+		ctx, done := inflight.track(withForwardedHeaders(context.WithValue(ctx, requestIDCtxKey{}, id), r), r, id)
+		defer done()
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// auditEntry 记录一次上游调用的原始请求和响应，请求体超过上限时截断且不能重放
+type auditEntry struct {
+	RequestID  string          `json:"requestId"`
+	Time       time.Time       `json:"time"`
+	Kind       string          `json:"kind"`
+	Path       string          `json:"path,omitempty"`
+	Method     string          `json:"method,omitempty"`
+	Upstream   string          `json:"upstream"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Status     int             `json:"status,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs float64         `json:"durationMs"`
+	Truncated  bool            `json:"truncated,omitempty"`
+}
+
+type auditRing struct {
+	mu      sync.Mutex
+	entries []auditEntry
+	next    int
+	full    bool
+}
+
+func newAuditRing(size int) *auditRing {
+	if size <= 0 {
+		return nil
+	}
+	return &auditRing{entries: make([]auditEntry, size)}
+}
+
+func openAuditFile(path string) *os.File {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Printf("Error opening audit log %s: %v", path, err)
+		return nil
+	}
+	return f
+}
+
+// auditBody 非 JSON 或超过上限的内容不保存
+func auditBody(b []byte) (json.RawMessage, bool) {
+	if len(b) > auditMaxBody || !json.Valid(b) {
+		return nil, len(b) > 0
+	}
+	return append(json.RawMessage(nil), b...), false
+}
+
+// recordAudit 由 upstreamPool.send 在每次上游调用后调用
+func recordAudit(ctx context.Context, kind, path, method string, u *upstream, body []byte, resp *upstreamResponse, err error, d time.Duration) {
+	id := requestIDFromContext(ctx)
+	if id == "" || (auditLog == nil && auditFileWriter == nil) {
+		return
+	}
+	e := auditEntry{RequestID: id, Time: time.Now().UTC(), Kind: kind, Path: path, Method: method,
+		Upstream: u.url, DurationMs: float64(d.Microseconds()) / 1000}
+	var truncated bool
+	e.Request, truncated = auditBody(body)
+	if resp != nil {
+		e.Status = resp.Status
+		var respTruncated bool
+		e.Response, respTruncated = auditBody(resp.Body)
+		truncated = truncated || respTruncated
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.Truncated = truncated
+	if auditLog != nil {
+		auditLog.add(e)
+	}
+	if auditFileWriter != nil {
+		line, _ := json.Marshal(e)
+		auditFileLock.Lock()
+		auditFileWriter.Write(append(line, '\n'))
+		auditFileLock.Unlock()
+	}
+}
+
+func (a *auditRing) add(e auditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[a.next] = e
+	a.next = (a.next + 1) % len(a.entries)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// find 按时间顺序返回某个请求 ID 的所有上游调用，内存中没有时再扫描审计文件
+func (a *auditRing) find(requestID string) []auditEntry {
+	var out []auditEntry
+	if a != nil {
+		a.mu.Lock()
+		n := a.next
+		if a.full {
+			n = len(a.entries)
+		}
+		start := 0
+		if a.full {
+			start = a.next
+		}
+		for i := 0; i < n; i++ {
+			e := a.entries[(start+i)%len(a.entries)]
+			if e.RequestID == requestID {
+				out = append(out, e)
+			}
+		}
+		a.mu.Unlock()
+	}
+	if len(out) > 0 || auditLogFile == "" {
+		return out
+	}
+	f, err := os.Open(auditLogFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*auditMaxBody+64*1024)
+	needle := []byte(`"requestId":` + string(mustJSON(requestID)))
+	for scanner.Scan() {
+		if !bytes.Contains(scanner.Bytes(), needle) {
+			continue
+		}
+		var e auditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.RequestID == requestID {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func mustJSON(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// handleAdminAudit GET /admin/audit?requestId=...
+func handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("requestId")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "requestId is required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, auditLog.find(id))
+}
+
+type replayResult struct {
+	Upstream   string          `json:"upstream"`
+	Status     int             `json:"status,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs float64         `json:"durationMs"`
+}
+
+// handleAdminReplay POST /admin/replay {"requestId": "...", "call": 0, "upstream": "<url 或 host:port>"}
+// call 为该请求的第几次上游调用，默认最后一次；upstream 必须是已配置的同类节点
+func handleAdminReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	var body struct {
+		RequestID string `json:"requestId"`
+		Call      *int   `json:"call"`
+		Upstream  string `json:"upstream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RequestID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "requestId is required"})
+		return
+	}
+	entries := auditLog.find(body.RequestID)
+	if len(entries) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no audit entries for request " + body.RequestID})
+		return
+	}
+	idx := len(entries) - 1
+	if body.Call != nil {
+		idx = *body.Call
+	}
+	if idx < 0 || idx >= len(entries) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "call index out of range"})
+		return
+	}
+	orig := entries[idx]
+	if orig.Request == nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "original request body was not recorded (too large)"})
+		return
+	}
+	// 重放会真实发送到节点，广播交易之类的调用不允许
+	if !idempotent(orig.Method, orig.Path) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "refusing to replay state-changing call"})
+		return
+	}
+	pool := jsonrpcUpstreams
+	switch orig.Kind {
+	case restUpstreams.kind:
+		pool = restUpstreams
+	case grpcUpstreams.kind:
+		pool = grpcUpstreams
+	}
+	target := orig.Upstream
+	if body.Upstream != "" {
+		target = body.Upstream
+	}
+	u := pool.find(target)
+	if u == nil {
+		// 归档节点和普通节点的类型相同
+		for _, p := range allUpstreamPools() {
+			if p.kind == pool.kind && p != pool {
+				if u = p.find(target); u != nil {
+					break
+				}
+			}
+		}
+	}
+	if u == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown " + pool.kind + " upstream " + target})
+		return
+	}
+	start := time.Now()
+	resp, err := u.post(r.Context(), orig.Path, orig.Request)
+	replay := replayResult{Upstream: u.url, DurationMs: msSince(start)}
+	if err != nil {
+		replay.Error = err.Error()
+	} else {
+		replay.Status = resp.Status
+		replay.Response, _ = auditBody(resp.Body)
+	}
+	log.Printf("Admin replay of request %s call %d against %s", body.RequestID, idx, u.url)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"original":  orig,
+		"replay":    replay,
+		"identical": sameResponse(orig.Response, replay.Response),
+	})
+}
+
+func (p *upstreamPool) find(target string) *upstream {
+	for _, u := range p.list() {
+		if u.url == target || u.label == target {
+			return u
+		}
+	}
+	return nil
+}
+
+// sameResponse 按解码后的内容比较，忽略 JSON-RPC id 和字段顺序
+func sameResponse(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	stripIDs(va)
+	stripIDs(vb)
+	return reflect.DeepEqual(va, vb)
+}
+
+func stripIDs(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		delete(node, "id")
+	case []interface{}:
+		for _, item := range node {
+			if m, ok := item.(map[string]interface{}); ok {
+				delete(m, "id")
+			}
+		}
+	}
+}
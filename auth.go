@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	apiKeysFile           = configValue("API_KEYS_FILE")
+	apiKeysReloadInterval = envDuration("API_KEYS_RELOAD_INTERVAL", 10*time.Second)
+
+	apiKeys atomic.Value // map[string]*apiKey
+)
+
+type apiKey struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name"`
+	Methods []string `json:"methods,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+	Admin   bool     `json:"admin,omitempty"`
+	// 客户端兼容 profile，见 profiles.go
+	Profile string `json:"profile,omitempty"`
+	// 每天 / 每月的 compute unit 额度，0 使用 QUOTA_DAILY_UNITS / QUOTA_MONTHLY_UNITS，-1 不限，见 quota.go
+	DailyQuota   int64 `json:"dailyQuota,omitempty"`
+	MonthlyQuota int64 `json:"monthlyQuota,omitempty"`
+	// 设置后这个 key 必须用 X-Request-Signature 签名调用，kid 为 name，见 requestsigning.go
+	SigningSecret string `json:"signingSecret,omitempty"`
+}
+
+type apiKeysConfig struct {
+	Keys []*apiKey `json:"keys"`
+}
+
+func init() {
+	apiKeys.Store(map[string]*apiKey(nil))
+}
+
+// authEnabled 只要配置了 API_KEYS、API_KEYS_FILE 或 REQUEST_SIGNING_KEYS 就要求认证
+func authEnabled() bool {
+	return configValue("API_KEYS") != "" || apiKeysFile != "" || len(requestSigningKeys) > 0
+}
+
+func loadAPIKeys() (map[string]*apiKey, error) {
+	keys := make(map[string]*apiKey)
+	// API_KEYS=key1,key2 形式的 key 不限制方法
+	for _, k := range splitList(configValue("API_KEYS")) {
+		keys[k] = &apiKey{Key: k, Name: "env"}
+	}
+	if apiKeysFile != "" {
+		data, err := os.ReadFile(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		var cfg apiKeysConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, err
+		}
+		for _, k := range cfg.Keys {
+			if k.Key != "" {
+				keys[k.Key] = k
+			}
+		}
+	}
+	return keys, nil
+}
+
+func startAPIKeyReloader() error {
+	if !authEnabled() {
+		return nil
+	}
+	keys, err := loadAPIKeys()
+	if err != nil {
+		return fmt.Errorf("loading API keys: %w", err)
+	}
+	apiKeys.Store(keys)
+	log.Printf("Authentication enabled, %d API key(s) loaded", len(keys))
+	if apiKeysFile == "" {
+		return nil
+	}
+	go func() {
+		var lastMod time.Time
+		if info, err := os.Stat(apiKeysFile); err == nil {
+			lastMod = info.ModTime()
+		}
+		for range time.Tick(apiKeysReloadInterval) {
+			info, err := os.Stat(apiKeysFile)
+			if err != nil || !info.ModTime().After(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			keys, err := loadAPIKeys()
+			if err != nil {
+				// 保留旧配置，避免写了一半的文件把所有人拒之门外
+				log.Printf("Error reloading API keys, keeping previous set: %v", err)
+				continue
+			}
+			apiKeys.Store(keys)
+			log.Printf("Reloaded API keys, %d key(s)", len(keys))
+			events.publish(EventConfigReloaded, configReloadedEvent{Source: "api_keys"})
+		}
+	}()
+	return nil
+}
+
+func lookupAPIKey(key string) *apiKey {
+	if key == "" {
+		return nil
+	}
+	keys, _ := apiKeys.Load().(map[string]*apiKey)
+	for k, v := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return v
+		}
+	}
+	return nil
+}
+
+type apiKeyCtxKey struct{}
+
+func apiKeyFromContext(ctx context.Context) *apiKey {
+	k, _ := ctx.Value(apiKeyCtxKey{}).(*apiKey)
+	return k
+}
+
+func pathAPIKey(p string) string {
+	parts := strings.Split(strings.TrimPrefix(p, "/v1/"), "/")
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "jsonrpc" {
+		return parts[0]
+	}
+	return ""
+}
+
+// requestAPIKey 支持 X-API-Key 头和 /v1/<key>/jsonrpc 路径两种方式
+func requestAPIKey(r *http.Request) string {
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		return pathAPIKey(r.URL.Path)
+	}
+	return ""
+}
+
+func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authEnabled() {
+			next(w, r)
+			return
+		}
+		if sig := r.Header.Get(requestSignatureHeader); sig != "" {
+			key, err := verifyRequestSignature(w, r, sig)
+			if err != nil {
+				log.Printf("Rejected signed request to %s: %v", r.URL.Path, err)
+				rejectSignature(w, err)
+				return
+			}
+			next(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, key)))
+			return
+		}
+		key := lookupAPIKey(requestAPIKey(r))
+		if key == nil || key.SigningSecret != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"unauthorized: missing or invalid API key"}}` + "\n"))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, key)))
+	}
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthRejectsBadKeys(t *testing.T) {
+	t.Setenv("API_KEYS", "good-key")
+	old := apiKeys.Load()
+	apiKeys.Store(map[string]*apiKey{
+		"good-key":   {Key: "good-key", Name: "env"},
+		"signed-key": {Key: "signed-key", Name: "svc", SigningSecret: "s3cret"},
+	})
+	t.Cleanup(func() { apiKeys.Store(old) })
+
+	var seen *apiKey
+	h := authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		seen = apiKeyFromContext(r.Context())
+	})
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"missing key", "/jsonrpc", "", http.StatusUnauthorized},
+		{"unknown key", "/jsonrpc", "bad-key", http.StatusUnauthorized},
+		{"key prefix", "/jsonrpc", "good", http.StatusUnauthorized},
+		{"unknown path key", "/v1/bad-key/jsonrpc", "", http.StatusUnauthorized},
+		// 配了 signingSecret 的 key 必须签名调用
+		{"signing-only key", "/jsonrpc", "signed-key", http.StatusUnauthorized},
+		{"header key", "/jsonrpc", "good-key", http.StatusOK},
+		{"path key", "/v1/good-key/jsonrpc", "", http.StatusOK},
+	}
+	for _, c := range cases {
+		seen = nil
+		r := httptest.NewRequest(http.MethodPost, c.path, nil)
+		if c.header != "" {
+			r.Header.Set("X-API-Key", c.header)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		if rec.Code != c.want {
+			t.Errorf("%s: HTTP %d, want %d", c.name, rec.Code, c.want)
+		}
+		if c.want == http.StatusOK && (seen == nil || seen.Key != "good-key") {
+			t.Errorf("%s: key not passed to the handler: %+v", c.name, seen)
+		}
+		if c.want != http.StatusOK && seen != nil {
+			t.Errorf("%s: handler ran for a rejected request", c.name)
+		}
+	}
+}
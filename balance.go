@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	balanceHistoryMaxPoints   = envInt("BALANCE_HISTORY_MAX_POINTS", 1000)
+	balanceHistoryConcurrency = envInt("BALANCE_HISTORY_CONCURRENCY", 8)
+)
+
+// sampleHeights 按 step 采样区块高度，总是包含 toBlock
+func sampleHeights(from, to, step int64) []int64 {
+	var heights []int64
+	for h := from; h <= to; h += step {
+		heights = append(heights, h)
+	}
+	if heights[len(heights)-1] != to {
+		heights = append(heights, to)
+	}
+	return heights
+}
+
+func handleGetBalanceHistory(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) < 4 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [address, fromBlock, toBlock, step]")
+	}
+	var address string
+	if err := json.Unmarshal(req.Params[0], &address); err != nil || address == "" {
+		return jsonError(req.ID, -32602, "Invalid params: address must be a string")
+	}
+	from, err1 := parseInt64Param(req.Params[1])
+	to, err2 := parseInt64Param(req.Params[2])
+	step, err3 := parseInt64Param(req.Params[3])
+	if err1 != nil || err2 != nil || err3 != nil || from < 0 || to < from || step <= 0 {
+		return jsonError(req.ID, -32602, "Invalid params: require 0 <= fromBlock <= toBlock and step > 0")
+	}
+	if (to-from)/step+2 > int64(balanceHistoryMaxPoints) {
+		return jsonError(req.ID, -32602, fmt.Sprintf("Invalid params: too many sample points (max %d)", balanceHistoryMaxPoints))
+	}
+
+	heights := sampleHeights(from, to, step)
+	log.Printf("Balance history for %s, blocks %d-%d step %d, points: %d", address, from, to, step, len(heights))
+
+	balances := make([]interface{}, len(heights))
+	errs := make([]error, len(heights))
+	workers.run(ctx, len(heights), balanceHistoryConcurrency, func(i int) {
+		balances[i], errs[i] = getBalanceAt(ctx, address, heights[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return jsonError(req.ID, -32603, "Request cancelled")
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("Balance history error at block %d: %v", heights[i], err)
+			return jsonError(req.ID, -32603, fmt.Sprintf("Internal error at block %d: %v", heights[i], err))
+		}
+	}
+	return JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result: map[string]interface{}{
+			"address":  address,
+			"blocks":   heights,
+			"balances": balances,
+		},
+	}
+}
+
+func getBalanceAt(ctx context.Context, address string, height int64) (interface{}, error) {
+	addrParam, _ := json.Marshal(address)
+	blockParam, _ := json.Marshal(translate.HexQuantity(height))
+	req := JSONRPCRequest{
+		Jsonrpc: "2.0",
+		Method:  "eth_getBalance",
+		Params:  []json.RawMessage{addrParam, blockParam},
+		ID:      1,
+	}
+	resp := withCache(ctx, req, forwardAndReturn)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%v", resp.Error)
+	}
+	return resp.Result, nil
+}
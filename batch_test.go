@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testContract = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	testCreator  = "THPvaUhoh2Qn2y9THCZML3H815hhFhn5YC"
+)
+
+// testNode 模拟只认识标准方法的节点：区块 n 的时间戳是 1000+10n，最新高度 100，固化高度 90，
+// 批量请求一律返回 method not found，proxy_* 方法被透传时就会失败
+func testNode(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/wallet/getblockbynum":
+			var p struct{ Num int64 }
+			json.Unmarshal(body, &p)
+			fmt.Fprintf(w, `{"blockID":"%064x","block_header":{"raw_data":{"number":%d,"timestamp":%d}},"transactions":[
+{"txID":"%064x","ret":[{"contractRet":"SUCCESS"}],"raw_data":{"contract":[{"type":"TransferContract","parameter":{"value":{"owner_address":%q,"to_address":%q,"amount":%d}}}]}}]}`,
+				p.Num, p.Num, (1000+10*p.Num)*1000, p.Num, testCreator, testContract, p.Num)
+			return
+		case "/walletsolidity/getnowblock":
+			fmt.Fprintf(w, `{"blockID":"%064x","block_header":{"raw_data":{"number":90}}}`, 90)
+			return
+		case "/wallet/getcontract":
+			fmt.Fprintf(w, `{"origin_address":%q,"contract_address":%q}`, testCreator, testContract)
+			return
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+			var reqs []JSONRPCRequest
+			json.Unmarshal(body, &reqs)
+			out := make([]JSONRPCResponse, len(reqs))
+			for i, req := range reqs {
+				out[i] = jsonError(req.ID, -32601, "method not found")
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req JSONRPCRequest
+		json.Unmarshal(body, &req)
+		id, _ := json.Marshal(req.ID)
+		var tag string
+		if len(req.Params) > 0 {
+			json.Unmarshal(req.Params[len(req.Params)-1], &tag)
+		}
+		if len(req.Params) > 1 && req.Method == "eth_getBlockByNumber" {
+			json.Unmarshal(req.Params[0], &tag)
+		}
+		n := int64(100)
+		if tag != "latest" && tag != "" {
+			fmt.Sscanf(tag, "0x%x", &n)
+		}
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"number":"0x%x","hash":"0x%064x","parentHash":"0x%064x","timestamp":"0x%x"}}`,
+				id, n, n, n-1, 1000+10*n)
+		case "eth_getBalance":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, id, n*100)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, id)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// withTestNode 替换全局的上游、缓存、trace 存储和合约索引，结束后恢复
+func withTestNode(t *testing.T) {
+	srv := testNode(t)
+	oldRPC, oldRest, oldCache, oldTraces, oldCreations := jsonrpcUpstreams, restUpstreams, responseCache, traces, contractCreations
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", []string{srv.URL})
+	restUpstreams = newUpstreamPool("rest", []string{srv.URL})
+	responseCache = newResponseCache(1000, responseCache.ttl)
+	dir := t.TempDir()
+	traces, _ = openTraceStore(dir)
+	contractCreations = &creationIndex{path: filepath.Join(dir, "creations.json"), Contracts: make(map[string]*contractCreation)}
+	headers.dropFrom(0)
+	t.Cleanup(func() {
+		jsonrpcUpstreams, restUpstreams, responseCache, traces, contractCreations = oldRPC, oldRest, oldCache, oldTraces, oldCreations
+		headers.dropFrom(0)
+	})
+}
+
+func postJSONRPC(t *testing.T, body string) []byte {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(handleJSONRPC).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HTTP %d: %s", rec.Code, rec.Body.String())
+	}
+	return rec.Body.Bytes()
+}
+
+// 代理自己实现的方法在批量请求里也要由代理处理，不能透传给节点
+func TestBatchLocalMethods(t *testing.T) {
+	cases := []struct {
+		method string
+		params [2]string
+		check  func(t *testing.T, result json.RawMessage)
+	}{
+		{"proxy_getBlockByTimestamp", [2]string{`[1330]`, `[1335,"after"]`}, func(t *testing.T, result json.RawMessage) {
+			var h struct{ Number, Timestamp int64 }
+			json.Unmarshal(result, &h)
+			if h.Number != 33 && h.Number != 34 || h.Timestamp != 1000+10*h.Number {
+				t.Errorf("block = %s", result)
+			}
+		}},
+		{"proxy_getBalanceHistory", [2]string{`["` + testContract + `",10,20,5]`, `["` + testContract + `",1,2,1]`}, func(t *testing.T, result json.RawMessage) {
+			var h struct {
+				Blocks   []int64
+				Balances []string
+			}
+			json.Unmarshal(result, &h)
+			if len(h.Blocks) == 0 || len(h.Blocks) != len(h.Balances) || h.Balances[0] != fmt.Sprintf("0x%x", h.Blocks[0]*100) {
+				t.Errorf("history = %s", result)
+			}
+		}},
+		{"proxy_getAddressSummary", [2]string{`["` + testContract + `",{"fromBlock":1,"toBlock":3}]`, `["` + testCreator + `",{"fromBlock":5,"toBlock":5}]`}, func(t *testing.T, result json.RawMessage) {
+			var s addressSummary
+			json.Unmarshal(result, &s)
+			if s.TxCount == 0 || s.FirstSeen == nil {
+				t.Errorf("summary = %s", result)
+			}
+		}},
+		{"proxy_getContractCreation", [2]string{`["` + testContract + `"]`, `["0xa614f803b6fd780986a42c78ec9c7f77e6ded13c"]`}, func(t *testing.T, result json.RawMessage) {
+			var c contractCreation
+			json.Unmarshal(result, &c)
+			if c.Address != testContract || c.Creator != testCreator {
+				t.Errorf("creation = %s", result)
+			}
+		}},
+		{"rpc.discover", [2]string{`[]`, `[]`}, func(t *testing.T, result json.RawMessage) {
+			var doc struct{ Methods []interface{} }
+			json.Unmarshal(result, &doc)
+			if len(doc.Methods) == 0 {
+				t.Errorf("document = %.200s", result)
+			}
+		}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.method, func(t *testing.T) {
+			withTestNode(t)
+			body := fmt.Sprintf(`[{"jsonrpc":"2.0","id":1,"method":%q,"params":%s},{"jsonrpc":"2.0","id":2,"method":%q,"params":%s}]`,
+				c.method, c.params[0], c.method, c.params[1])
+			var out []struct {
+				ID     int
+				Result json.RawMessage
+				Error  json.RawMessage
+			}
+			if err := json.Unmarshal(postJSONRPC(t, body), &out); err != nil || len(out) != 2 {
+				t.Fatalf("batch response: %v, %d items", err, len(out))
+			}
+			for _, item := range out {
+				if item.Error != nil || len(item.Result) == 0 || string(item.Result) == "null" {
+					t.Fatalf("item %d: result=%s error=%s", item.ID, item.Result, item.Error)
+				}
+				c.check(t, item.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 场景基准：single / batch / trace-heavy / forward-heavy，全部走 handleJSONRPC，
+// 上游用 httptest 模拟。用法见 Makefile 的 bench、bench-baseline 目标
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+const benchTxID = "abababababababababababababababababababababababababababababababab"
+
+// benchUpstream 同时充当 JSON-RPC 和 REST 节点
+func benchUpstream(b *testing.B) *httptest.Server {
+	bigBlock := benchBigBlock(2000)
+	txInfos := benchTxInfos(2000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/wallet/gettransactioninfobyblocknum" {
+			w.Write(txInfos)
+			return
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			var reqs []JSONRPCRequest
+			json.Unmarshal(body, &reqs)
+			out := make([]JSONRPCResponse, len(reqs))
+			for i, req := range reqs {
+				out[i] = JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: "0x10"}
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req JSONRPCRequest
+		json.Unmarshal(body, &req)
+		id, _ := json.Marshal(req.ID)
+		if req.Method == "eth_getBlockByNumber" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, id, bigBlock)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x10"}`, id)
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+func benchBigBlock(txs int) []byte {
+	list := make([]map[string]interface{}, txs)
+	for i := range list {
+		list[i] = map[string]interface{}{
+			"hash": fmt.Sprintf("0x%064x", i), "from": "0x1111111111111111111111111111111111111111",
+			"to": "0x2222222222222222222222222222222222222222", "value": "0x1", "input": "0x" + strings.Repeat("ab", 68),
+		}
+	}
+	b, _ := json.Marshal(map[string]interface{}{"number": "0x10", "hash": fmt.Sprintf("0x%064x", 16), "transactions": list})
+	return b
+}
+
+func benchTxInfos(n int) []byte {
+	list := make([]map[string]interface{}, n)
+	for i := range list {
+		list[i] = map[string]interface{}{
+			"id": fmt.Sprintf("%064x", i), "blockNumber": 16, "blockTimeStamp": 1700000000000,
+			"internal_transactions": []map[string]interface{}{{"hash": fmt.Sprintf("%064x", i), "caller_address": "41" + strings.Repeat("11", 20)}},
+			"log":                   []map[string]interface{}{{"address": strings.Repeat("a6", 20), "topics": []string{strings.Repeat("dd", 32)}, "data": strings.Repeat("00", 32)}},
+		}
+	}
+	b, _ := json.Marshal(list)
+	return b
+}
+
+// benchTraceFile 生成一个约 2MB 的 callTracer 结果
+func benchTraceFile(b *testing.B) string {
+	dir := b.TempDir()
+	calls := make([]map[string]interface{}, 4000)
+	for i := range calls {
+		calls[i] = map[string]interface{}{
+			"type": "CALL", "from": "0x1111111111111111111111111111111111111111", "to": "0x2222222222222222222222222222222222222222",
+			"input": "0x" + strings.Repeat("cd", 100), "gasUsed": "0x100",
+		}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"type": "CALL", "from": "0x1111111111111111111111111111111111111111", "calls": calls})
+	if err := os.WriteFile(filepath.Join(dir, benchTxID+".json"), data, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return dir
+}
+
+// withBenchEnv 替换全局的上游、缓存和 trace 存储，结束后恢复
+func withBenchEnv(b *testing.B, cacheEntries int) {
+	srv := benchUpstream(b)
+	oldRPC, oldRest, oldCache, oldTraces := jsonrpcUpstreams, restUpstreams, responseCache, traces
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", []string{srv.URL})
+	restUpstreams = newUpstreamPool("rest", []string{srv.URL})
+	responseCache = newResponseCache(cacheEntries, responseCache.ttl)
+	traces, _ = openTraceStore(benchTraceFile(b))
+	b.Cleanup(func() {
+		jsonrpcUpstreams, restUpstreams, responseCache, traces = oldRPC, oldRest, oldCache, oldTraces
+	})
+}
+
+func benchRequest(b *testing.B, body string) {
+	b.Helper()
+	handler := http.HandlerFunc(handleJSONRPC)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("HTTP %d: %s", rec.Code, rec.Body.String())
+		}
+		b.SetBytes(int64(rec.Body.Len()))
+	}
+}
+
+func benchBatch(n int, method string) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":["0x%064x"]}`, i, method, i)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+func BenchmarkSingle(b *testing.B) {
+	b.Run("forward", func(b *testing.B) {
+		withBenchEnv(b, 0)
+		benchRequest(b, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	})
+	b.Run("cached", func(b *testing.B) {
+		withBenchEnv(b, 1000)
+		benchRequest(b, `{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0x01"]}`)
+	})
+}
+
+func BenchmarkBatch(b *testing.B) {
+	for _, n := range []int{10, 100} {
+		n := n
+		b.Run(fmt.Sprintf("forward-%d", n), func(b *testing.B) {
+			withBenchEnv(b, 0)
+			benchRequest(b, benchBatch(n, "eth_getBalance"))
+		})
+		b.Run(fmt.Sprintf("cached-%d", n), func(b *testing.B) {
+			withBenchEnv(b, 10000)
+			benchRequest(b, benchBatch(n, "eth_getTransactionReceipt"))
+		})
+	}
+}
+
+func BenchmarkTraceHeavy(b *testing.B) {
+	for _, stream := range []bool{false, true} {
+		stream := stream
+		name := "buffered"
+		if stream {
+			name = "streamed"
+		}
+		b.Run("file-"+name, func(b *testing.B) {
+			withBenchEnv(b, 0)
+			old := streamResponses
+			streamResponses = stream
+			defer func() { streamResponses = old }()
+			benchRequest(b, `{"jsonrpc":"2.0","id":1,"method":"eth_debugTransactionTrace","params":["0x`+benchTxID+`"]}`)
+		})
+		b.Run("block-"+name, func(b *testing.B) {
+			withBenchEnv(b, 0)
+			old := streamResponses
+			streamResponses = stream
+			defer func() { streamResponses = old }()
+			benchRequest(b, `{"jsonrpc":"2.0","id":1,"method":"debug_traceBlockByHash","params":[16]}`)
+		})
+	}
+}
+
+func BenchmarkForwardHeavy(b *testing.B) {
+	withBenchEnv(b, 0)
+	benchRequest(b, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",true]}`)
+}
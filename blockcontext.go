@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/yourname/proxy/translate"
+)
+
+// blockContext 是 trace 数据对应的区块，客户端把 Hash 作为 eth_call 的
+// {"blockHash": ...} 参数传回即可在同一高度上重放
+type blockContext struct {
+	Number    string `json:"number"`
+	Hash      string `json:"hash"`
+	Timestamp string `json:"timestamp"`
+}
+
+type blockContextCtxKey struct{}
+
+func wantBlockContext(ctx context.Context) bool {
+	v, _ := ctx.Value(blockContextCtxKey{}).(bool)
+	return v
+}
+
+type rpcTxLocation struct {
+	BlockNumber string `json:"blockNumber"`
+	BlockHash   string `json:"blockHash"`
+}
+
+// traceBlockHeight 返回 trace 类请求对应的区块高度
+func traceBlockHeight(ctx context.Context, req JSONRPCRequest) (int64, error) {
+	if len(req.Params) == 0 {
+		return 0, fmt.Errorf("missing params")
+	}
+	switch req.Method {
+	case "debug_traceBlockByHash":
+		if hash, ok := blockHashArg(req); ok {
+			h, err := getHeaderByHash(ctx, hash)
+			return h.Number, err
+		}
+		// 兼容旧接口，参数实际是区块高度
+		return parseInt64Param(req.Params[0])
+	case "debug_traceBlockByNumber":
+		return resolveBlockNumberParam(ctx, req.Params[0])
+	case "eth_debugTransactionTrace", "debug_traceTransaction":
+		txID, err := parseTxIDParam(req.Params[0])
+		if err != nil {
+			return 0, err
+		}
+		result, err := callUpstream(ctx, "eth_getTransactionByHash", "0x"+txID)
+		if err != nil {
+			return 0, err
+		}
+		var tx *rpcTxLocation
+		if err := codec.Unmarshal(result, &tx); err != nil {
+			return 0, err
+		}
+		if tx == nil || tx.BlockNumber == "" {
+			return 0, fmt.Errorf("transaction %s not found", txID)
+		}
+		return translate.ParseQuantity(tx.BlockNumber)
+	}
+	return 0, fmt.Errorf("method %s has no block context", req.Method)
+}
+
+func attachBlockContext(ctx context.Context, req JSONRPCRequest, resp *JSONRPCResponse) {
+	if !wantBlockContext(ctx) || resp.Error != nil {
+		return
+	}
+	switch req.Method {
+	case "debug_traceBlockByHash", "debug_traceBlockByNumber", "debug_traceTransaction", "eth_debugTransactionTrace":
+	default:
+		return
+	}
+	num, err := traceBlockHeight(ctx, req)
+	if err != nil {
+		log.Printf("Block context for %s id=%v unavailable: %v", req.Method, req.ID, err)
+		return
+	}
+	h, err := getHeader(ctx, num)
+	if err != nil {
+		log.Printf("Block context for %s id=%v unavailable: %v", req.Method, req.ID, err)
+		return
+	}
+	ensureProxyMeta(resp).BlockContext = &blockContext{
+		Number:    translate.HexQuantity(h.Number),
+		Hash:      h.Hash,
+		Timestamp: translate.HexQuantity(h.Timestamp),
+	}
+}
+
+func attachBlockContexts(ctx context.Context, reqs []JSONRPCRequest, responses []JSONRPCResponse) {
+	if !wantBlockContext(ctx) || len(reqs) != len(responses) {
+		return
+	}
+	workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+		attachBlockContext(ctx, reqs[i], &responses[i])
+	})
+}
+
+// blockHashParam 解析 EIP-1898 形式的 {"blockHash": "0x..."} 区块参数
+func blockHashParam(raw json.RawMessage) (string, bool) {
+	var obj struct {
+		BlockHash string `json:"blockHash"`
+	}
+	if codec.Unmarshal(raw, &obj) != nil || obj.BlockHash == "" {
+		return "", false
+	}
+	return obj.BlockHash, true
+}
+
+func hasBlockHashParam(reqs []JSONRPCRequest) bool {
+	for _, r := range reqs {
+		if len(r.Params) > 1 {
+			if _, ok := blockHashParam(r.Params[1]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleEthCall 把 blockHash 参数解析为确定的高度。java-tron 的 eth_call 只接受 latest，
+// 所以只有该区块仍是最新块时才改写为 latest，否则按高度转发，由上游报错，
+// 而不是悄悄在另一个高度上执行
+func handleEthCall(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	var hash string
+	if len(req.Params) > 1 {
+		hash, _ = blockHashParam(req.Params[1])
+	}
+	if hash == "" {
+		return callLatestOrForward(ctx, req)
+	}
+	h, err := getHeaderByHash(ctx, hash)
+	if err != nil {
+		return jsonError(req.ID, -32000, "Block context not found: "+err.Error())
+	}
+	canonical, err := fetchHeader(ctx, translate.HexQuantity(h.Number))
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if canonical.Hash != h.Hash {
+		return jsonError(req.ID, -32000, fmt.Sprintf("Block context %s is no longer canonical at height %d", hash, h.Number))
+	}
+	latest, err := getLatestHeader(ctx)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	tag := translate.HexQuantity(h.Number)
+	if latest.Hash == h.Hash {
+		tag = "latest"
+	}
+	tagParam, _ := codec.Marshal(tag)
+	params := append([]json.RawMessage{}, req.Params...)
+	params[1] = tagParam
+	pinned := req
+	pinned.Params = params
+	log.Printf("eth_call pinned to block %d (%s) as %s", h.Number, hash, tag)
+	return callLatestOrForward(ctx, pinned)
+}
+
+// callLatestOrForward 在最新状态上执行的调用本地翻译，其余的交给节点
+func callLatestOrForward(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if triggerCallLocal() && isLatestBlockParam(req.Params, 1) {
+		if resp, ok := localEthCall(ctx, req); ok {
+			return resp
+		}
+	}
+	return forwardAndReturn(ctx, req)
+}
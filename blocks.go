@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// 为 eth_getBlockByNumber/eth_getBlockByHash 的结果补上以太坊工具需要而 java-tron 缺少的字段
+	blockNormalize = envString("BLOCK_NORMALIZE", "true") == "true"
+	// java-tron 返回的 logsBloom 全为 0，有交易的区块按 eth_getLogs 的结果重新计算；
+	// 计算失败时填全 1，过滤时不会漏掉区块，这样的结果不缓存，下次请求重新计算
+	blockDeriveBloom = envString("BLOCK_DERIVE_BLOOM", "true") == "true"
+)
+
+const (
+	zeroHash32      = "0x0000000000000000000000000000000000000000000000000000000000000000"
+	emptyUnclesHash = "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+)
+
+// blockDefaults 缺少或为空时补上的字段
+var blockDefaults = map[string]interface{}{
+	"baseFeePerGas":    "0x0",
+	"difficulty":       "0x0",
+	"totalDifficulty":  "0x0",
+	"extraData":        "0x",
+	"gasLimit":         "0x0",
+	"gasUsed":          "0x0",
+	"size":             "0x0",
+	"nonce":            "0x0000000000000000",
+	"mixHash":          zeroHash32,
+	"sha3Uncles":       emptyUnclesHash,
+	"stateRoot":        zeroHash32,
+	"transactionsRoot": zeroHash32,
+	"receiptsRoot":     zeroHash32,
+	"miner":            "0x0000000000000000000000000000000000000000",
+}
+
+// handleGetBlock 转发后补全区块字段；按高度或 hash 查到的已确认区块头写入区块头缓存，供 hash 解析和时间戳查询复用
+func handleGetBlock(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	resp := forwardAndReturn(ctx, req)
+	if resp.Error != nil {
+		return resp
+	}
+	block, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		return resp
+	}
+	if blockNormalize {
+		if err := normalizeBlock(ctx, block); err != nil {
+			resp.noCache = true
+		}
+	}
+	if concreteBlockRef(req) {
+		if h, ok := headerFromBlock(block); ok {
+			headerCacheFor(ctx).putConfirmed(ctx, h)
+		}
+	}
+	return resp
+}
+
+// concreteBlockRef latest 之类的 tag 对应的区块还可能变化，不缓存区块头
+func concreteBlockRef(req JSONRPCRequest) bool {
+	if len(req.Params) == 0 {
+		return false
+	}
+	var ref string
+	if codec.Unmarshal(req.Params[0], &ref) != nil {
+		return false
+	}
+	if req.Method == "eth_getBlockByHash" {
+		return true
+	}
+	_, err := translate.ParseQuantity(ref)
+	return err == nil
+}
+
+func headerFromBlock(block map[string]interface{}) (blockHeader, bool) {
+	str := func(k string) string {
+		s, _ := block[k].(string)
+		return s
+	}
+	num, err := translate.ParseQuantity(str("number"))
+	if err != nil || str("hash") == "" {
+		return blockHeader{}, false
+	}
+	ts, err := translate.ParseQuantity(str("timestamp"))
+	if err != nil {
+		return blockHeader{}, false
+	}
+	return blockHeader{Number: num, Hash: str("hash"), ParentHash: str("parentHash"), Timestamp: ts}, true
+}
+
+// normalizeBlock 返回的错误表示 logsBloom 没能算出来，填的是全 1 的占位值
+func normalizeBlock(ctx context.Context, block map[string]interface{}) error {
+	for k, v := range blockDefaults {
+		if s, ok := block[k].(string); !ok || s == "" {
+			block[k] = v
+		}
+	}
+	if _, ok := block["uncles"].([]interface{}); !ok {
+		block["uncles"] = []interface{}{}
+	}
+	if _, ok := block["transactions"].([]interface{}); !ok {
+		block["transactions"] = []interface{}{}
+	}
+	bloom, _ := block["logsBloom"].(string)
+	if translate.ValidBloom(bloom) && (!isZeroHex(bloom) || len(block["transactions"].([]interface{})) == 0) {
+		return nil
+	}
+	if len(block["transactions"].([]interface{})) == 0 {
+		block["logsBloom"] = "0x" + strings.Repeat("00", translate.BloomBytes)
+		return nil
+	}
+	if !blockDeriveBloom {
+		if !translate.ValidBloom(bloom) {
+			block["logsBloom"] = "0x" + strings.Repeat("ff", translate.BloomBytes)
+		}
+		return nil
+	}
+	hash, _ := block["hash"].(string)
+	derived, err := deriveBlockBloom(ctx, hash)
+	if err != nil {
+		log.Printf("Cannot derive logsBloom for block %s: %v", hash, err)
+		block["logsBloom"] = "0x" + strings.Repeat("ff", translate.BloomBytes)
+		return err
+	}
+	block["logsBloom"] = derived
+	return nil
+}
+
+func isZeroHex(s string) bool {
+	return strings.Trim(strings.TrimPrefix(s, "0x"), "0") == ""
+}
+
+// deriveBlockBloom 用节点 eth_getLogs 的结果计算区块的 logsBloom
+func deriveBlockBloom(ctx context.Context, blockHash string) (string, error) {
+	result, err := callUpstream(ctx, "eth_getLogs", map[string]string{"blockHash": blockHash})
+	if err != nil {
+		return "", err
+	}
+	var logs []translate.Log
+	if err := json.Unmarshal(result, &logs); err != nil {
+		return "", err
+	}
+	return translate.LogsBloom(logs), nil
+}
+
+// emulateGetBlockByHash 节点不支持按 hash 查询时，从区块头缓存或 TRON 区块 ID（前 8 字节为高度）得到高度，
+// 按高度查询后核对 hash，不一致说明区块已不在主链上，按以太坊的约定返回 null
+func emulateGetBlockByHash(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params")
+	}
+	var hash string
+	if err := codec.Unmarshal(req.Params[0], &hash); err != nil || !translate.IsHexString(hash, 64) {
+		return jsonError(req.ID, -32602, "Invalid params: block hash must be a 32-byte hex string")
+	}
+	num, ok := headers.numberOf(hash)
+	if !ok {
+		if num, ok = translate.BlockHashNumber(hash); !ok {
+			return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: nil}
+		}
+	}
+	byNumber := JSONRPCRequest{Jsonrpc: "2.0", ID: req.ID, Method: "eth_getBlockByNumber", Params: append([]json.RawMessage(nil), req.Params...)}
+	byNumber.Params[0], _ = json.Marshal(translate.HexQuantity(num))
+	resp := forwardAndReturn(ctx, byNumber)
+	if resp.Error != nil {
+		return resp
+	}
+	block, _ := resp.Result.(map[string]interface{})
+	if got, _ := block["hash"].(string); !strings.EqualFold(got, hash) {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: nil}
+	}
+	return resp
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// logsBloom 算不出来时返回全 1 的占位值，但不能写进缓存，节点恢复后要重新计算
+func TestBlockBloomFailureNotCached(t *testing.T) {
+	withTestNode(t)
+	var logsFail int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		id, _ := json.Marshal(req.ID)
+		switch {
+		case req.Method == "eth_getLogs" && atomic.LoadInt32(&logsFail) == 1:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"boom"}}`, id)
+		case req.Method == "eth_getLogs":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":[]}`, id)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"number":"0x10","hash":"0x%064x","timestamp":"0x1","logsBloom":"0x%s","transactions":["0x%064x"]}}`,
+				id, 16, strings.Repeat("00", 256), 1)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", []string{srv.URL})
+
+	get := func() string {
+		var resp struct{ Result struct{ LogsBloom string } }
+		if err := json.Unmarshal(postJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}`), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Result.LogsBloom
+	}
+	if got := get(); got != "0x"+strings.Repeat("ff", 256) {
+		t.Fatalf("logsBloom = %.20s..., want the all-ones placeholder", got)
+	}
+	atomic.StoreInt32(&logsFail, 0)
+	if got := get(); got != "0x"+strings.Repeat("00", 256) {
+		t.Errorf("logsBloom = %.20s... after eth_getLogs recovered, the placeholder was cached", got)
+	}
+}
+
+// 只缓存不高于固化高度的区块头，更高的区块还可能被回滚
+func TestHeaderCacheConfirmedOnly(t *testing.T) {
+	withTestNode(t)
+	solidHead.Lock()
+	solidHead.at = time.Time{}
+	solidHead.Unlock()
+
+	for _, num := range []int64{50, 95} {
+		if _, err := getHeader(context.Background(), num); err != nil {
+			t.Fatalf("getHeader(%d): %v", num, err)
+		}
+	}
+	if _, ok := headers.get(50); !ok {
+		t.Error("confirmed header 50 not cached")
+	}
+	if _, ok := headers.get(95); ok {
+		t.Error("header 95 above the solidified height 90 was cached")
+	}
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// METHOD=INDEX[:DEFAULT]，INDEX 为区块参数的位置；参数缺省时补上 DEFAULT，DEFAULT 为 "-" 时表示节点不接受
+	// 这个参数，客户端带了也去掉。只写 INDEX 时只做 tag 改写
+	blockTagRules = parseBlockTagRules(envString("BLOCK_TAG_DEFAULTS",
+		"eth_call=1:latest,eth_getBalance=1:latest,eth_getCode=1:latest,eth_getStorageAt=2:latest,eth_getTransactionCount=1:latest,"+
+			"eth_estimateGas=1:-,eth_getBlockByNumber=0,eth_getBlockTransactionCountByNumber=0,eth_getTransactionByBlockNumberAndIndex=0,"+
+			"eth_getLogs=0,debug_traceBlockByNumber=0"))
+	// 节点不认识的 tag，改写为最新固化区块的高度
+	solidifiedTags = toSet(splitList(envString("BLOCK_TAG_SOLIDIFIED", "safe,finalized")))
+	// 固化高度大约每 3 秒前进一次，短时间内复用
+	solidifiedHeightTTL = envDuration("BLOCK_TAG_SOLIDIFIED_TTL", 2*time.Second)
+
+	solidHead struct {
+		sync.Mutex
+		height int64
+		at     time.Time
+	}
+)
+
+type blockTagRule struct {
+	index int
+	// 空字符串不补，"-" 去掉
+	def string
+}
+
+func parseBlockTagRules(s string) map[string]blockTagRule {
+	rules := make(map[string]blockTagRule)
+	for _, item := range splitList(s) {
+		method, spec, ok := strings.Cut(item, "=")
+		method = strings.TrimSpace(method)
+		idx, def, _ := strings.Cut(spec, ":")
+		n, err := strconv.Atoi(strings.TrimSpace(idx))
+		if !ok || method == "" || err != nil || n < 0 {
+			log.Printf("Invalid BLOCK_TAG_DEFAULTS entry %q, ignored", item)
+			continue
+		}
+		rules[method] = blockTagRule{index: n, def: strings.TrimSpace(def)}
+	}
+	return rules
+}
+
+func hasBlockTagRule(method string) bool {
+	_, ok := blockTagRules[method]
+	return ok
+}
+
+// applyBlockTagRules 按 BLOCK_TAG_DEFAULTS 补上或去掉区块参数，并把 safe/finalized 改写为固化高度；
+// 已经是具体高度或 blockHash 的参数不变。取不到固化高度时保留原来的 tag，由节点返回错误
+func applyBlockTagRules(ctx context.Context, req JSONRPCRequest) JSONRPCRequest {
+	rule, ok := blockTagRules[req.Method]
+	if !ok {
+		return req
+	}
+	switch {
+	case rule.def == "-" && len(req.Params) > rule.index:
+		req.Params = req.Params[:rule.index:rule.index]
+		return req
+	case rule.def != "" && rule.def != "-" && len(req.Params) == rule.index:
+		tag, _ := json.Marshal(rule.def)
+		req.Params = append(req.Params[:len(req.Params):len(req.Params)], tag)
+	}
+	if len(req.Params) <= rule.index {
+		return req
+	}
+	param, changed := rewriteBlockTags(ctx, req.Params[rule.index])
+	if changed {
+		params := append([]json.RawMessage(nil), req.Params...)
+		params[rule.index] = param
+		req.Params = params
+	}
+	return req
+}
+
+// rewriteBlockTags 参数可以是 tag 字符串，也可以是带 fromBlock/toBlock/blockNumber 的对象（eth_getLogs 的过滤条件）
+func rewriteBlockTags(ctx context.Context, raw json.RawMessage) (json.RawMessage, bool) {
+	var tag string
+	if codec.Unmarshal(raw, &tag) == nil {
+		if !solidifiedTags[tag] {
+			return raw, false
+		}
+		height, err := resolveSolidifiedTag(ctx, tag)
+		if err != nil {
+			return raw, false
+		}
+		out, _ := json.Marshal(translate.HexQuantity(height))
+		return out, true
+	}
+	var obj map[string]json.RawMessage
+	if codec.Unmarshal(raw, &obj) != nil || obj == nil {
+		return raw, false
+	}
+	changed := false
+	for _, field := range []string{"fromBlock", "toBlock", "blockNumber"} {
+		if v, ok := obj[field]; ok {
+			if out, ok := rewriteBlockTags(ctx, v); ok {
+				obj[field], changed = out, true
+			}
+		}
+	}
+	if !changed {
+		return raw, false
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return raw, false
+	}
+	return out, true
+}
+
+func resolveSolidifiedTag(ctx context.Context, tag string) (int64, error) {
+	height, err := getSolidifiedHeight(ctx)
+	if err != nil {
+		log.Printf("Cannot resolve block tag %q to solidified height: %v", tag, err)
+	}
+	return height, err
+}
+
+// getSolidifiedHeight 最新固化区块，配置了 TRON_SOLIDITY_ENDPOINT 时从 solidity 节点取
+func getSolidifiedHeight(ctx context.Context) (int64, error) {
+	if n := networkFrom(ctx); n != nil {
+		return fetchSolidifiedHeight(ctx, n.rest)
+	}
+	solidHead.Lock()
+	defer solidHead.Unlock()
+	if !solidHead.at.IsZero() && time.Since(solidHead.at) < solidifiedHeightTTL {
+		return solidHead.height, nil
+	}
+	pool := restUpstreams
+	if len(solidityUpstreams.list()) > 0 {
+		pool = solidityUpstreams
+	}
+	height, err := fetchSolidifiedHeight(ctx, pool)
+	if err != nil {
+		return 0, err
+	}
+	solidHead.height, solidHead.at = height, time.Now()
+	return height, nil
+}
+
+func fetchSolidifiedHeight(ctx context.Context, pool *upstreamPool) (int64, error) {
+	resp, err := pool.post(ctx, "/walletsolidity/getnowblock", []byte("{}"))
+	if err != nil {
+		return 0, err
+	}
+	var block restBlock
+	if err := codec.Unmarshal(resp.Body, &block); err != nil || block.BlockID == "" {
+		return 0, fmt.Errorf("invalid getnowblock response from %s: %s", resp.Upstream.label, up.Snippet(resp.Body))
+	}
+	return block.BlockHeader.RawData.Number, nil
+}
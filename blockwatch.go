@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+// TRON 出块间隔 3 秒，设为 0 关闭
+var blockWatchInterval = envDuration("BLOCK_WATCH_INTERVAL", 3*time.Second)
+
+// startBlockWatcher 轮询最新区块，发布 NewBlock 和 Reorg 事件
+func startBlockWatcher() {
+	if blockWatchInterval <= 0 || len(jsonrpcUpstreams.list()) == 0 {
+		return
+	}
+	go func() {
+		var last blockHeader
+		for range time.Tick(blockWatchInterval) {
+			ctx, cancel := context.WithTimeout(context.Background(), blockWatchInterval)
+			latest, err := getLatestHeader(ctx)
+			if err == nil && last.Hash != "" {
+				checkReorg(ctx, last, latest)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("Block watcher: %v", err)
+				continue
+			}
+			// 多节点时可能读到落后节点，高度回退不当作新区块
+			if latest.Number > last.Number || (latest.Number == last.Number && latest.Hash != last.Hash) {
+				last = latest
+				events.publish(EventNewBlock, newBlockEvent{Header: latest})
+			}
+		}
+	}()
+}
+
+// checkReorg 比较上次看到的区块在新链上的哈希是否一致
+func checkReorg(ctx context.Context, last, latest blockHeader) {
+	var newHash string
+	switch {
+	case latest.Number == last.Number:
+		newHash = latest.Hash
+	case latest.Number == last.Number+1 && latest.ParentHash == last.Hash:
+		return
+	case latest.Number > last.Number:
+		h, err := fetchHeader(ctx, translate.HexQuantity(last.Number))
+		if err != nil {
+			return
+		}
+		newHash = h.Hash
+	default:
+		return
+	}
+	if newHash != last.Hash {
+		log.Printf("Reorg detected at block %d: %s -> %s", last.Number, last.Hash, newHash)
+		events.publish(EventReorg, reorgEvent{Height: last.Number, OldHash: last.Hash, NewHash: newHash})
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	up "github.com/yourname/proxy/upstream"
+)
+
+// 连续失败达到阈值后熔断，熔断期间不再向该节点发请求（也不作为兜底节点）
+var breakerPolicy = up.BreakerPolicy{
+	Failures:     envInt("BREAKER_FAILURES", 5),
+	OpenDuration: envDuration("BREAKER_OPEN_DURATION", 10*time.Second),
+	OpenMax:      envDuration("BREAKER_OPEN_MAX", 2*time.Minute),
+}
+
+var errCircuitOpen = errors.New("upstream unavailable: circuit open")
+
+const (
+	circuitClosed   = up.CircuitClosed
+	circuitOpen     = up.CircuitOpen
+	circuitHalfOpen = up.CircuitHalfOpen
+)
+
+// circuitLocked 调用方持有 u.mu
+func (u *upstream) circuitLocked(now time.Time) string {
+	return u.breaker.State(breakerPolicy, u.failures, now)
+}
+
+func (u *upstream) circuit() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.circuitLocked(time.Now())
+}
+
+// breakerAllow 半开状态同一时间只放行一个探测请求，probe=true 时调用方必须调用 breakerDone
+func (u *upstream) breakerAllow() (allowed, probe bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.breaker.Allow(breakerPolicy, u.failures, time.Now())
+}
+
+func (u *upstream) breakerDone(probe bool) {
+	if !probe {
+		return
+	}
+	u.mu.Lock()
+	u.breaker.Done()
+	u.mu.Unlock()
+}
+
+// tripLocked 在 markFailure 里调用
+func (u *upstream) tripLocked() {
+	if u.breaker.Trip(breakerPolicy, u.failures, time.Now()) {
+		log.Printf("Circuit opened for upstream %s after %d consecutive failures", u.url, u.failures)
+	}
+}
+
+// circuitOpenAll 所有节点都处于熔断中，用于决定是否返回过期的缓存数据
+func (p *upstreamPool) circuitOpenAll() bool {
+	if !breakerPolicy.Enabled() || len(p.list()) == 0 {
+		return false
+	}
+	for _, u := range p.list() {
+		if u.circuit() != circuitOpen {
+			return false
+		}
+	}
+	return true
+}
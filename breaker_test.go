@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	up "github.com/yourname/proxy/upstream"
+)
+
+// 连续失败后熔断，熔断期间不再请求节点；到期后只放行一个探测请求，成功后关闭
+func TestBreakerOpensAndProbes(t *testing.T) {
+	var failing int32 = 1
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	oldPolicy, oldRecovery, oldAttempts := breakerPolicy, upstreamRecoverySuccesses, upstreamRetryAttempts
+	breakerPolicy = up.BreakerPolicy{Failures: 2, OpenDuration: 200 * time.Millisecond, OpenMax: 200 * time.Millisecond}
+	upstreamRecoverySuccesses, upstreamRetryAttempts = 1, 1
+	t.Cleanup(func() {
+		breakerPolicy, upstreamRecoverySuccesses, upstreamRetryAttempts = oldPolicy, oldRecovery, oldAttempts
+	})
+
+	pool := newUpstreamPool("jsonrpc", []string{srv.URL})
+	u := pool.list()[0]
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	send := func() error {
+		_, err := pool.send(context.Background(), "", "eth_blockNumber", body)
+		return err
+	}
+
+	send()
+	if c := u.circuit(); c != circuitClosed {
+		t.Fatalf("circuit %s after one failure", c)
+	}
+	send()
+	if c := u.circuit(); c != circuitOpen {
+		t.Fatalf("circuit %s after reaching the threshold, want open", c)
+	}
+	before := atomic.LoadInt32(&hits)
+	if err := send(); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("send while open = %v, want errCircuitOpen", err)
+	}
+	if atomic.LoadInt32(&hits) != before {
+		t.Error("open circuit still sent a request to the node")
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if c := u.circuit(); c != circuitHalfOpen {
+		t.Fatalf("circuit %s after the open duration, want half-open", c)
+	}
+	atomic.StoreInt32(&failing, 0)
+	probe := make(chan error, 1)
+	go func() { probe <- send() }()
+	time.Sleep(30 * time.Millisecond)
+	// 探测请求还没返回，其他请求不放行
+	if err := send(); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("send during the probe = %v, want errCircuitOpen", err)
+	}
+	if err := <-probe; err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&hits) - before; n != 1 {
+		t.Errorf("%d requests reached the node while half-open, want 1", n)
+	}
+	if c := u.circuit(); c != circuitClosed {
+		t.Errorf("circuit %s after a successful probe, want closed", c)
+	}
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// proxy_waitForReceipt 的轮询间隔和默认值；TRON 出块间隔 3 秒
+	receiptPollInterval         = envDuration("RECEIPT_POLL_INTERVAL", time.Second)
+	receiptDefaultConfirmations = int64(envInt("RECEIPT_DEFAULT_CONFIRMATIONS", 1))
+	receiptDefaultTimeout       = envDuration("RECEIPT_DEFAULT_TIMEOUT", time.Minute)
+	receiptMaxTimeout           = envDuration("RECEIPT_MAX_TIMEOUT", 5*time.Minute)
+)
+
+type broadcastResult struct {
+	Result  bool   `json:"result"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	TxID    string `json:"txid"`
+}
+
+// handleSendRawTransaction 原始交易是 protobuf 编码的 TRON 交易，通过 /wallet/broadcasthex 广播，
+// 返回 0x 前缀的 txid。java-tron 的 jsonrpc 模块不支持 eth_sendRawTransaction
+func handleSendRawTransaction(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(restUpstreams.list()) == 0 {
+		return forwardAndReturn(ctx, req)
+	}
+	var raw string
+	if len(req.Params) < 1 || codec.Unmarshal(req.Params[0], &raw) != nil {
+		return jsonError(req.ID, -32602, "Invalid params: expected signed transaction hex")
+	}
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "0x"), "0X")
+	if _, err := hex.DecodeString(raw); err != nil || raw == "" {
+		return jsonError(req.ID, -32602, "Invalid params: transaction is not valid hex")
+	}
+	var out broadcastResult
+	if err := callRest(ctx, "/wallet/broadcasthex", map[string]string{"transaction": raw}, &out); err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if !out.Result {
+		if out.Code == "" {
+			out.Code = "OTHER_ERROR"
+		}
+		ue := up.TronCodeError(out.Code, out.Message)
+		log.Printf("Broadcast of %s rejected: %s", out.TxID, ue)
+		return upstreamErrorResponse(ue, req.ID)
+	}
+	log.Printf("Broadcast transaction %s", out.TxID)
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: "0x" + out.TxID}
+}
+
+// handleWaitForReceipt proxy_waitForReceipt(txHash, confirmations?, timeoutSeconds?)
+// 轮询 gettransactioninfobyid 直到交易上链并达到确认数，然后返回 eth_getTransactionReceipt 的结果。
+// 超时返回错误，data 里带当前已知的区块和确认数
+func handleWaitForReceipt(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	var txHash string
+	if len(req.Params) < 1 || codec.Unmarshal(req.Params[0], &txHash) != nil {
+		return jsonError(req.ID, -32602, "Invalid params: expected transaction hash")
+	}
+	txID := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(txHash, "0x"), "0X"))
+	if b, err := hex.DecodeString(txID); err != nil || len(b) != 32 {
+		return jsonError(req.ID, -32602, "Invalid params: transaction hash must be 32 bytes")
+	}
+	confirmations := receiptDefaultConfirmations
+	if len(req.Params) > 1 && string(req.Params[1]) != "null" {
+		n, err := parseInt64Param(req.Params[1])
+		if err != nil || n < 0 {
+			return jsonError(req.ID, -32602, "Invalid params: confirmations must be a non-negative integer")
+		}
+		confirmations = n
+	}
+	timeout := receiptDefaultTimeout
+	if len(req.Params) > 2 && string(req.Params[2]) != "null" {
+		n, err := parseInt64Param(req.Params[2])
+		if err != nil || n <= 0 {
+			return jsonError(req.ID, -32602, "Invalid params: timeout must be a positive number of seconds")
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+	if timeout > receiptMaxTimeout {
+		timeout = receiptMaxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var blockNum, confirmed int64
+	for {
+		if blockNum == 0 {
+			blockNum = transactionBlock(ctx, txID)
+		}
+		if blockNum > 0 {
+			if head, err := getLatestHeader(ctx); err == nil {
+				confirmed = head.Number - blockNum + 1
+			}
+			if confirmed >= confirmations {
+				if receipt, ok := fetchReceipt(ctx, req.ID, txID); ok {
+					return receipt
+				}
+			}
+		}
+		if err := sleepContext(ctx, receiptPollInterval); err != nil {
+			break
+		}
+	}
+	resp := jsonError(req.ID, -32000, fmt.Sprintf("transaction %s not confirmed within %s", txHash, timeout))
+	data := map[string]interface{}{"confirmations": confirmed}
+	if blockNum > 0 {
+		data["blockNumber"] = translate.HexQuantity(blockNum)
+	}
+	resp.Error.(map[string]interface{})["data"] = data
+	return resp
+}
+
+// transactionBlock 返回交易所在区块，还没上链或查询失败时返回 0
+func transactionBlock(ctx context.Context, txID string) int64 {
+	var info struct {
+		ID          string `json:"id"`
+		BlockNumber int64  `json:"blockNumber"`
+	}
+	if err := callRest(ctx, "/wallet/gettransactioninfobyid", map[string]string{"value": txID}, &info); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Polling transaction info for %s failed: %v", txID, err)
+		}
+		return 0
+	}
+	return info.BlockNumber
+}
+
+// fetchReceipt jsonrpc 节点可能比 REST 节点落后，拿不到回执时继续轮询
+func fetchReceipt(ctx context.Context, id interface{}, txID string) (JSONRPCResponse, bool) {
+	p, _ := json.Marshal("0x" + txID)
+	resp := withCache(ctx, JSONRPCRequest{Jsonrpc: "2.0", Method: "eth_getTransactionReceipt", Params: []json.RawMessage{p}, ID: id}, dispatchRequest)
+	if resp.Error != nil || resp.Result == nil {
+		return resp, false
+	}
+	return resp, true
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// 同时处理的行数，也是读取请求体的窗口大小
+	bulkConcurrency = envInt("BULK_CONCURRENCY", 32)
+	bulkMaxLine     = envInt("BULK_MAX_LINE_BYTES", 1<<20)
+	// HTTP/1.1 下先把请求体落盘再处理，限制单个请求的落盘大小和同时落盘的请求数，
+	// 临时文件总量不超过两者之积
+	bulkMaxSpool   = int64(envInt("BULK_MAX_SPOOL_BYTES", 64<<20))
+	bulkSpoolSlots = make(chan struct{}, envInt("BULK_MAX_SPOOLS", 4))
+)
+
+// handleBulk 接收 NDJSON 请求体（每行一个 JSON-RPC 请求），以 NDJSON 流式返回，
+// 输出顺序为完成顺序，客户端按 id 对应。
+// HTTP/1.1 的 net/http 服务端在开始写响应后不能继续读请求体，所以先落盘到临时文件；
+// HTTP/2 可以边读边写，直接处理
+func handleBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := withDebugFlag(withAddressFormat(r.Context(), r), r)
+	var body io.Reader = r.Body
+	if r.ProtoMajor < 2 {
+		select {
+		case bulkSpoolSlots <- struct{}{}:
+			defer func() { <-bulkSpoolSlots }()
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many bulk requests in progress", http.StatusServiceUnavailable)
+			return
+		}
+		f, err := spoolBody(r.Body)
+		if err != nil {
+			log.Printf("Bulk request spool error: %v", err)
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		body = f
+	}
+
+	start := time.Now()
+	nw := newNDJSONWriter(w)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), bulkMaxLine)
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	lines := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		lines++
+		// 限流时等待令牌而不是报错，回填客户端会被自然降速
+		if err := waitForTokens(ctx, 1); err != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		req, errResp := parseBulkLine(ctx, line)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if errResp != nil {
+				nw.write(*errResp)
+				return
+			}
+			resp := handleSingleRequest(ctx, req)
+			// 通知照常执行，但不返回结果
+			if !req.Notification {
+				nw.write(resp)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := scanner.Err(); err != nil {
+		nw.write(jsonError(nil, -32600, "Invalid bulk body: "+err.Error()))
+	}
+	log.Printf("Bulk request finished, lines: %d, took %s", lines, time.Since(start))
+}
+
+// parseBulkLine 和单个请求的解析规则一致：没有 id 的是通知
+func parseBulkLine(ctx context.Context, line []byte) (JSONRPCRequest, *JSONRPCResponse) {
+	var raw interface{}
+	if err := codec.Unmarshal(line, &raw); err != nil {
+		resp := jsonError(nil, -32700, "Parse error: invalid JSON on bulk line")
+		return JSONRPCRequest{}, &resp
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		resp := jsonError(nil, -32600, "Invalid Request: bulk line must be an object")
+		return JSONRPCRequest{}, &resp
+	}
+	req, err := parseSingleRequest(obj)
+	if err != nil {
+		resp := jsonError(obj["id"], -32600, "Invalid Request: malformed request object")
+		return JSONRPCRequest{}, &resp
+	}
+	if !strictRequests(ctx) {
+		req.Jsonrpc = "2.0"
+	}
+	return req, nil
+}
+
+func spoolBody(r io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "proxy-bulk-*.ndjson")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, bulkMaxSpool+1))
+	if err == nil && n > bulkMaxSpool {
+		err = fmt.Errorf("bulk body exceeds %d bytes", bulkMaxSpool)
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// waitForTokens 按请求扣减限流令牌，不足时等待
+func waitForTokens(ctx context.Context, n int) error {
+	for {
+		ok, wait, _ := chargeItems(ctx, n)
+		if ok {
+			return nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
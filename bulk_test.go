@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 没有 id 的行是通知，不返回结果；不是对象的行按 Invalid Request 处理
+func TestBulkNotificationsAndInvalidLines(t *testing.T) {
+	withTestNode(t)
+	body := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","0x2"]}`,
+		`{"jsonrpc":"2.0","method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","0x3"]}`,
+		`[1,2]`,
+		`{not json`,
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handleBulk(rec, req)
+
+	codes := map[int]int{}
+	results := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var resp struct {
+			ID     interface{}
+			Result string
+			Error  *struct{ Code int }
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		if resp.Error != nil {
+			codes[resp.Error.Code]++
+			continue
+		}
+		results++
+		if resp.Result != "0xc8" {
+			t.Errorf("result = %q, want 0xc8", resp.Result)
+		}
+	}
+	if results != 1 || codes[-32600] != 1 || codes[-32700] != 1 || len(codes) != 2 {
+		t.Errorf("results = %d, errors = %v; the notification must not be answered", results, codes)
+	}
+}
+
+func TestBulkSpoolSlots(t *testing.T) {
+	old := bulkSpoolSlots
+	bulkSpoolSlots = make(chan struct{}, 1)
+	bulkSpoolSlots <- struct{}{}
+	t.Cleanup(func() { bulkSpoolSlots = old })
+
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`))
+	rec := httptest.NewRecorder()
+	handleBulk(rec, req)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("HTTP %d with all spool slots taken, want 503 with Retry-After", rec.Code)
+	}
+}
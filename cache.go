@@ -0,0 +1,554 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var responseCache = newResponseCache(
+	envInt("CACHE_MAX_ENTRIES", 10000),
+	envDuration("CACHE_TTL", time.Hour),
+)
+
+func init() {
+	// 回滚后清掉该高度及以上的条目，共享缓存由 restcache.go 的订阅一并清除
+	events.subscribe("response-cache", 64, func(e event) {
+		from := e.Data.(reorgEvent).Height
+		removed := responseCache.invalidate(cacheInvalidation{FromBlock: &from})
+		removed += diskCache.invalidate(cacheInvalidation{FromBlock: &from})
+		if removed > 0 {
+			log.Printf("Reorg at block %d, dropped %d cached responses", from, removed)
+		}
+	}, EventReorg)
+}
+
+type cacheEntry struct {
+	key        string
+	method     string
+	paramsHash string
+	result     interface{}
+	expires    time.Time
+	stored     time.Time
+	// 用于按区块或交易定向清除，未知时 block 为 -1、tx 为空
+	block int64
+	tx    string
+}
+
+type cacheMethodStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+type cacheStats struct {
+	Entries   int                         `json:"entries"`
+	MaxSize   int                         `json:"maxEntries"`
+	Evictions uint64                      `json:"evictions"`
+	Methods   map[string]cacheMethodStats `json:"methods"`
+}
+
+// lruCache 按 (method, params) 缓存不可变的 JSON-RPC 结果
+type lruCache struct {
+	mu        sync.Mutex
+	max       int
+	ttl       time.Duration
+	ll        *list.List
+	items     map[string]*list.Element
+	evictions uint64
+	stats     map[string]*cacheMethodStats
+}
+
+func newResponseCache(max int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		max:   max,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		stats: make(map[string]*cacheMethodStats),
+	}
+}
+
+func (c *lruCache) enabled() bool {
+	return c.max > 0
+}
+
+func paramsHash(params []json.RawMessage) string {
+	h := sha256.New()
+	for _, p := range params {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, p); err != nil {
+			h.Write(p)
+		} else {
+			h.Write(buf.Bytes())
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func cacheKey(method, hash string) string {
+	return method + ":" + hash
+}
+
+func (c *lruCache) methodStats(method string) *cacheMethodStats {
+	s, ok := c.stats[method]
+	if !ok {
+		s = &cacheMethodStats{}
+		c.stats[method] = s
+	}
+	return s
+}
+
+func (c *lruCache) get(method string, params []json.RawMessage) (interface{}, bool) {
+	key := cacheKey(method, paramsHash(params))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			c.ll.MoveToFront(el)
+			c.methodStats(method).Hits++
+			return e.result, true
+		}
+		// 过期条目留在 LRU 里，上游熔断时还可以作为兜底
+	}
+	c.methodStats(method).Misses++
+	return nil, false
+}
+
+// getStale 忽略过期时间，只在上游不可用时使用，同时返回写入时间
+func (c *lruCache) getStale(method string, params []json.RawMessage) (interface{}, time.Time, bool) {
+	key := cacheKey(method, paramsHash(params))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		return e.result, e.stored, true
+	}
+	return nil, time.Time{}, false
+}
+
+// has 只判断是否有未过期的条目，不计入命中统计
+func (c *lruCache) has(method string, params []json.RawMessage) bool {
+	key := cacheKey(method, paramsHash(params))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	return ok && time.Now().Before(el.Value.(*cacheEntry).expires)
+}
+
+func (c *lruCache) put(method string, params []json.RawMessage, result interface{}) {
+	block, tx := cacheEntryRefs(method, params, result)
+	c.store(method, params, result, finalityTTL(c.ttl, block), block, tx)
+}
+
+// blockFinalized 按区块监听看到的最新高度判断是否已固化，未开启区块监听时都按未固化处理
+func blockFinalized(block int64) bool {
+	head := atomic.LoadInt64(&restHead)
+	return head > 0 && head-block >= restCacheConfirmations
+}
+
+// finalityTTL 和 REST 缓存一致，还没固化的区块只缓存 REST_CACHE_RECENT_TTL
+func finalityTTL(ttl time.Duration, block int64) time.Duration {
+	if block >= 0 && !blockFinalized(block) {
+		return restCacheRecentTTL
+	}
+	return ttl
+}
+
+// putTTL 条目的有效期不同于缓存默认值时使用，例如 REST 缓存里尚未固化的区块
+func (c *lruCache) putTTL(method string, params []json.RawMessage, result interface{}, ttl time.Duration, block int64) {
+	c.store(method, params, result, ttl, block, "")
+}
+
+func (c *lruCache) store(method string, params []json.RawMessage, result interface{}, ttl time.Duration, block int64, tx string) {
+	hash := paramsHash(params)
+	key := cacheKey(method, hash)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.result = result
+		e.stored = time.Now()
+		e.expires = e.stored.Add(ttl)
+		e.block, e.tx = block, tx
+		c.ll.MoveToFront(el)
+		return
+	}
+	now := time.Now()
+	e := &cacheEntry{key: key, method: method, paramsHash: hash, result: result, expires: now.Add(ttl), stored: now, block: block, tx: tx}
+	c.items[key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.max {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+func (c *lruCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// cacheEntryRefs 找出缓存条目对应的区块高度和交易，只用于失效，不影响查找
+func cacheEntryRefs(method string, params []json.RawMessage, result interface{}) (int64, string) {
+	param := func(i int) json.RawMessage {
+		if len(params) > i {
+			return params[i]
+		}
+		return nil
+	}
+	switch method {
+	case "eth_getBlockByNumber", "debug_traceBlockByNumber", "debug_traceBlockByHash", "proxy_blockActivity":
+		if n, err := parseInt64Param(param(0)); err == nil {
+			return n, ""
+		}
+	case "eth_getBalance":
+		if n, err := parseInt64Param(param(1)); err == nil {
+			return n, ""
+		}
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt", "debug_traceTransaction":
+		block := int64(-1)
+		if m, ok := result.(map[string]interface{}); ok {
+			if s, ok := m["blockNumber"].(string); ok {
+				if n, err := translate.ParseQuantity(s); err == nil {
+					block = n
+				}
+			}
+		}
+		if txID, err := parseTxIDParam(param(0)); err == nil {
+			return block, txID
+		}
+		return block, ""
+	}
+	return -1, ""
+}
+
+// cacheInvalidation 各条件同时满足的条目才会被删除
+type cacheInvalidation struct {
+	Method     string `json:"method"`
+	ParamsHash string `json:"paramsHash"`
+	FromBlock  *int64 `json:"fromBlock"`
+	ToBlock    *int64 `json:"toBlock"`
+	TxID       string `json:"txId"`
+}
+
+func (q cacheInvalidation) empty() bool {
+	return q.Method == "" && q.ParamsHash == "" && q.FromBlock == nil && q.ToBlock == nil && q.TxID == ""
+}
+
+func (q cacheInvalidation) matches(e *cacheEntry) bool {
+	if q.Method != "" && e.method != q.Method {
+		return false
+	}
+	if q.ParamsHash != "" && e.paramsHash != q.ParamsHash {
+		return false
+	}
+	if q.FromBlock != nil || q.ToBlock != nil {
+		if e.block < 0 {
+			return false
+		}
+		if q.FromBlock != nil && e.block < *q.FromBlock {
+			return false
+		}
+		if q.ToBlock != nil && e.block > *q.ToBlock {
+			return false
+		}
+	}
+	if q.TxID != "" && e.tx != q.TxID {
+		return false
+	}
+	return true
+}
+
+func (c *lruCache) invalidate(q cacheInvalidation) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if q.matches(el.Value.(*cacheEntry)) {
+			c.removeElement(el)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+type cacheEntryInfo struct {
+	Method     string    `json:"method"`
+	ParamsHash string    `json:"paramsHash"`
+	Block      int64     `json:"block,omitempty"`
+	TxID       string    `json:"txId,omitempty"`
+	Expires    time.Time `json:"expires"`
+}
+
+func (c *lruCache) entries(q cacheInvalidation, limit int) []cacheEntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := []cacheEntryInfo{}
+	for el := c.ll.Front(); el != nil && len(out) < limit; el = el.Next() {
+		e := el.Value.(*cacheEntry)
+		if !q.matches(e) {
+			continue
+		}
+		info := cacheEntryInfo{Method: e.method, ParamsHash: e.paramsHash, TxID: e.tx, Expires: e.expires}
+		if e.block >= 0 {
+			info.Block = e.block
+		}
+		out = append(out, info)
+	}
+	return out
+}
+
+func (c *lruCache) Stats() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := cacheStats{Entries: c.ll.Len(), MaxSize: c.max, Evictions: c.evictions, Methods: make(map[string]cacheMethodStats)}
+	for m, ms := range c.stats {
+		s.Methods[m] = *ms
+	}
+	return s
+}
+
+// isCacheableRequest 只缓存结果不会再变化的请求
+func isCacheableRequest(req JSONRPCRequest) bool {
+	switch req.Method {
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt", "debug_traceBlockByHash", "debug_traceTransaction":
+		return len(req.Params) > 0
+	case "eth_getBlockByNumber", "debug_traceBlockByNumber":
+		return hasConcreteBlockParam(req, 0)
+	case "eth_getBalance":
+		return hasConcreteBlockParam(req, 1)
+	}
+	return false
+}
+
+// "latest"/"pending" 等 tag 不可缓存，只接受具体区块号
+func hasConcreteBlockParam(req JSONRPCRequest, idx int) bool {
+	if len(req.Params) <= idx {
+		return false
+	}
+	_, err := parseInt64Param(req.Params[idx])
+	return err == nil
+}
+
+// withCache 命中缓存直接返回，否则调用 fn（相同的并发请求合并为一次）并缓存成功且非空的结果
+func withCache(ctx context.Context, req JSONRPCRequest, fn func(context.Context, JSONRPCRequest) JSONRPCResponse) JSONRPCResponse {
+	// 其他网络的区块高度和默认网络重叠，不使用缓存
+	if (!responseCache.enabled() && !diskCache.usable(req.Method) && !sharedCache.usable()) || !isCacheableRequest(req) || networkFrom(ctx) != nil {
+		debugFrom(ctx).cache(req.Method, "bypass")
+		return coalesce(ctx, req, fn)
+	}
+	if result, ok := responseCache.get(req.Method, req.Params); ok {
+		debugFrom(ctx).cache(req.Method, "hit")
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+	}
+	if raw, ok := sharedCache.get(ctx, req.Method, req.Params); ok {
+		var result interface{}
+		if codec.Unmarshal(raw, &result) == nil {
+			debugFrom(ctx).cache(req.Method, "shared")
+			responseCache.put(req.Method, req.Params, result)
+			return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+		}
+	}
+	if raw, ok := diskCache.get(req.Method, req.Params); ok {
+		var result interface{}
+		if codec.Unmarshal(raw, &result) == nil {
+			debugFrom(ctx).cache(req.Method, "disk")
+			responseCache.put(req.Method, req.Params, result)
+			return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+		}
+	}
+	debugFrom(ctx).cache(req.Method, "miss")
+	resp := coalesce(ctx, req, fn)
+	if shouldServeStale(resp) {
+		if stale, ok := staleResponse(ctx, req, resp); ok {
+			return stale
+		}
+	}
+	if resp.Error == nil && resp.Result != nil && !isFallback(resp) && !resp.noCache {
+		storeCached(ctx, req, resp.Result)
+	}
+	return resp
+}
+
+// storeCached 把结果写入内存、磁盘和共享缓存，流式路径写完响应后也用它填缓存
+func storeCached(ctx context.Context, req JSONRPCRequest, result interface{}) {
+	responseCache.put(req.Method, req.Params, result)
+	publishCacheFill(req)
+	if diskCache.usable(req.Method) || sharedCache.usable() {
+		if raw, err := codec.Marshal(result); err == nil {
+			block, tx := cacheEntryRefs(req.Method, req.Params, result)
+			if blockFinalized(block) {
+				diskCache.put(ctx, req.Method, req.Params, raw, block, tx)
+			}
+			sharedCache.put(req.Method, req.Params, raw, finalityTTL(responseCache.ttl, block), block, tx)
+		}
+	}
+}
+
+// handleAdminCacheInvalidate POST /admin/cache/invalidate
+// {"method": "...", "fromBlock": N, "toBlock": N, "paramsHash": "...", "params": [...], "txId": "..."}
+// params 会按缓存的方式计算 paramsHash；txId 同时清除对应的 trace 缓存
+func handleAdminCacheInvalidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	var body struct {
+		cacheInvalidation
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	q := body.cacheInvalidation
+	if body.Params != nil {
+		q.ParamsHash = paramsHash(body.Params)
+	}
+	if q.TxID != "" {
+		raw, _ := codec.Marshal(q.TxID)
+		txID, err := parseTxIDParam(raw)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		q.TxID = txID
+	}
+	if q.empty() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "at least one of method, fromBlock, toBlock, paramsHash, params, txId is required"})
+		return
+	}
+	removed := responseCache.invalidate(q)
+	diskRemoved := diskCache.invalidate(q)
+	sharedRemoved := sharedCache.invalidate(r.Context(), q)
+	sharedCache.broadcast(r.Context(), "jsonrpc", q)
+	traceRemoved := false
+	if q.TxID != "" {
+		traceRemoved = traceCache.remove(q.TxID)
+	}
+	log.Printf("Admin cache invalidation %+v removed %d entries (disk cache: %d, shared cache: %d, trace cache: %t)", q, removed, diskRemoved, sharedRemoved, traceRemoved)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"removed": removed, "diskCacheRemoved": diskRemoved, "sharedCacheRemoved": sharedRemoved, "traceCacheRemoved": traceRemoved})
+}
+
+// handleAdminCacheEntries GET /admin/cache/entries?method=...&limit=100，用来查 paramsHash
+func handleAdminCacheEntries(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	q := cacheInvalidation{Method: r.URL.Query().Get("method")}
+	writeJSON(w, http.StatusOK, responseCache.entries(q, limit))
+}
+
+func idKey(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// hasDuplicateIDs 批量里是否有两个请求（不含通知）使用相同的 id
+func hasDuplicateIDs(reqs []JSONRPCRequest) bool {
+	seen := make(map[string]bool, len(reqs))
+	for _, r := range reqs {
+		if r.Notification {
+			continue
+		}
+		k := idKey(r.ID)
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+	}
+	return false
+}
+
+// forwardBatchWithCache 批量透传时先用缓存应答，只把未命中的请求转发到下游
+func forwardBatchWithCache(ctx context.Context, reqs []JSONRPCRequest, originalArr []interface{}) []JSONRPCResponse {
+	// 转发的响应按 id 对回请求，id 重复时无法确定对应关系，整批原样转发不走缓存
+	if (!responseCache.enabled() && !sharedCache.usable()) || networkFrom(ctx) != nil || hasDuplicateIDs(reqs) {
+		return forwardBatchToJSONRPC(ctx, reqs, originalArr)
+	}
+	responses := make([]JSONRPCResponse, len(reqs))
+	filled := make([]bool, len(reqs))
+	var missReqs []JSONRPCRequest
+	var missRaw []interface{}
+	pending := make(map[string]int)
+	for i, r := range reqs {
+		if isCacheableRequest(r) {
+			if result, ok := responseCache.get(r.Method, r.Params); ok {
+				debugFrom(ctx).cache(r.Method, "hit")
+				responses[i] = JSONRPCResponse{Jsonrpc: "2.0", ID: r.ID, Result: result}
+				filled[i] = true
+				continue
+			}
+			if raw, ok := sharedCache.get(ctx, r.Method, r.Params); ok {
+				var result interface{}
+				if codec.Unmarshal(raw, &result) == nil {
+					debugFrom(ctx).cache(r.Method, "shared")
+					responseCache.put(r.Method, r.Params, result)
+					responses[i] = JSONRPCResponse{Jsonrpc: "2.0", ID: r.ID, Result: result}
+					filled[i] = true
+					continue
+				}
+			}
+			debugFrom(ctx).cache(r.Method, "miss")
+		}
+		missReqs = append(missReqs, r)
+		missRaw = append(missRaw, originalArr[i])
+		// 通知没有响应，id 为 null 的错误响应不能算到它头上
+		if !r.Notification {
+			pending[idKey(r.ID)] = i
+		}
+	}
+	if len(missReqs) == 0 {
+		log.Printf("Batch fully served from cache, items: %d", len(reqs))
+		return responses
+	}
+
+	var extra []JSONRPCResponse
+	for _, resp := range forwardBatchToJSONRPC(ctx, missReqs, missRaw) {
+		i, ok := pending[idKey(resp.ID)]
+		if !ok {
+			extra = append(extra, resp)
+			continue
+		}
+		delete(pending, idKey(resp.ID))
+		if shouldServeStale(resp) && isCacheableRequest(reqs[i]) {
+			if stale, ok := staleResponse(ctx, reqs[i], resp); ok {
+				resp = stale
+			}
+		}
+		responses[i] = resp
+		filled[i] = true
+		if resp.Error == nil && resp.Result != nil && !isFallback(resp) && !isStale(resp) && isCacheableRequest(reqs[i]) {
+			responseCache.put(reqs[i].Method, reqs[i].Params, resp.Result)
+			publishCacheFill(reqs[i])
+			if raw, err := codec.Marshal(resp.Result); err == nil && sharedCache.usable() {
+				block, tx := cacheEntryRefs(reqs[i].Method, reqs[i].Params, resp.Result)
+				sharedCache.put(reqs[i].Method, reqs[i].Params, raw, finalityTTL(responseCache.ttl, block), block, tx)
+			}
+		}
+	}
+
+	out := make([]JSONRPCResponse, 0, len(reqs)+len(extra))
+	for i := range responses {
+		if filled[i] {
+			out = append(out, responses[i])
+		}
+	}
+	return append(out, extra...)
+}
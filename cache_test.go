@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheFinality(t *testing.T) {
+	oldCache, oldHead := responseCache, atomic.LoadInt64(&restHead)
+	responseCache = newResponseCache(100, time.Hour)
+	t.Cleanup(func() {
+		responseCache = oldCache
+		atomic.StoreInt64(&restHead, oldHead)
+	})
+	atomic.StoreInt64(&restHead, 1000)
+
+	params := func(block string) []json.RawMessage {
+		return []json.RawMessage{json.RawMessage(`"` + block + `"`), json.RawMessage(`false`)}
+	}
+	responseCache.put("eth_getBlockByNumber", params("0x64"), "old")  // 100，已固化
+	responseCache.put("eth_getBlockByNumber", params("0x3e7"), "new") // 999，未固化
+	expires := func(p []json.RawMessage) time.Duration {
+		el := responseCache.items[cacheKey("eth_getBlockByNumber", paramsHash(p))]
+		return time.Until(el.Value.(*cacheEntry).expires)
+	}
+	if d := expires(params("0x64")); d < 50*time.Minute {
+		t.Errorf("finalized block TTL = %s, want CACHE_TTL", d)
+	}
+	if d := expires(params("0x3e7")); d > restCacheRecentTTL {
+		t.Errorf("recent block TTL = %s, want at most %s", d, restCacheRecentTTL)
+	}
+
+	// 回滚时删除该高度及以上的条目
+	responseCache.store("eth_getBalance", []json.RawMessage{json.RawMessage(`"0x1"`), json.RawMessage(`"0x3e6"`)}, "0x1", time.Hour, 998, "")
+	events.publish(EventReorg, reorgEvent{Height: 998})
+	deadline := time.Now().Add(2 * time.Second)
+	for responseCache.Stats().Entries != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := responseCache.get("eth_getBlockByNumber", params("0x64")); !ok {
+		t.Error("block below the reorg height was dropped")
+	}
+	if n := responseCache.Stats().Entries; n != 1 {
+		t.Errorf("%d entries left after reorg, want 1", n)
+	}
+}
+
+// 批量里 id 重复时，响应不能按 id 记到别的请求的参数下面
+func TestBatchDuplicateIDs(t *testing.T) {
+	withTestNode(t)
+	// 节点按交易哈希原样返回
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&reqs)
+		out := make([]JSONRPCResponse, len(reqs))
+		for i, req := range reqs {
+			var hash string
+			json.Unmarshal(req.Params[0], &hash)
+			out[i] = JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: map[string]string{"hash": hash}}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", []string{srv.URL})
+
+	hashA := "0x" + strings.Repeat("a", 64)
+	hashB := "0x" + strings.Repeat("b", 64)
+	postJSONRPC(t, `[{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionByHash","params":["`+hashA+`"]},`+
+		`{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionByHash","params":["`+hashB+`"]}]`)
+
+	params := []json.RawMessage{json.RawMessage(`"` + hashB + `"`)}
+	if result, ok := responseCache.get("eth_getTransactionByHash", params); ok {
+		if tx, _ := result.(map[string]interface{}); tx["hash"] != hashB {
+			t.Fatalf("cached result for B = %v", result)
+		}
+	}
+	var out []struct{ Result struct{ Hash string } }
+	if err := json.Unmarshal(postJSONRPC(t, `[{"jsonrpc":"2.0","id":7,"method":"eth_getTransactionByHash","params":["`+hashB+`"]}]`), &out); err != nil || len(out) != 1 {
+		t.Fatalf("batch response: %v, %d items", err, len(out))
+	}
+	if out[0].Result.Hash != hashB {
+		t.Errorf("call for B returned %q", out[0].Result.Hash)
+	}
+}
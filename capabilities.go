@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var capabilityProbeInterval = envDuration("CAPABILITY_PROBE_INTERVAL", 10*time.Minute)
+
+var errMethodUnsupported = errors.New("method not supported by any upstream")
+
+// 探测用的最小参数，只关心节点是否返回 "method not found"
+var capabilityProbes = map[string]string{
+	"debug_traceTransaction":   `["0x0000000000000000000000000000000000000000000000000000000000000000",{"tracer":"callTracer"}]`,
+	"debug_traceBlockByNumber": `["0x0",{"tracer":"callTracer"}]`,
+	"debug_traceBlockByHash":   `["0x0000000000000000000000000000000000000000000000000000000000000000",{"tracer":"callTracer"}]`,
+	"eth_getBlockReceipts":     `["0x0"]`,
+	"eth_getBlockByHash":       `["0x0000000000000000000000000000000000000000000000000000000000000000",false]`,
+	"eth_getLogs":              `[{"fromBlock":"latest","toBlock":"latest"}]`,
+	"eth_newFilter":            `[{"fromBlock":"latest","toBlock":"latest"}]`,
+}
+
+func init() {
+	for _, m := range splitList(configValue("CAPABILITY_PROBE_METHODS")) {
+		if _, ok := capabilityProbes[m]; !ok {
+			capabilityProbes[m] = `[]`
+		}
+	}
+}
+
+type capabilitySet struct {
+	Methods   map[string]bool `json:"methods"`
+	CheckedAt time.Time       `json:"checkedAt"`
+}
+
+type upstreamCapabilities struct {
+	mu   sync.Mutex
+	caps *capabilitySet
+}
+
+// supports 未探测过的方法视为支持
+func (u *upstream) supports(method string) bool {
+	u.caps.mu.Lock()
+	defer u.caps.mu.Unlock()
+	if method == "" || u.caps.caps == nil {
+		return true
+	}
+	ok, probed := u.caps.caps.Methods[method]
+	return !probed || ok
+}
+
+func (u *upstream) capabilitiesStale() bool {
+	u.caps.mu.Lock()
+	defer u.caps.mu.Unlock()
+	return u.caps.caps == nil || time.Since(u.caps.caps.CheckedAt) > capabilityProbeInterval
+}
+
+func isMethodNotFound(rpcErr map[string]interface{}) bool {
+	if code, ok := rpcErr["code"].(float64); ok && code == -32601 {
+		return true
+	}
+	msg, _ := rpcErr["message"].(string)
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "does not exist") || strings.Contains(msg, "not found") && strings.Contains(msg, "method") ||
+		strings.Contains(msg, "not supported") || strings.Contains(msg, "not available")
+}
+
+func (u *upstream) probeCapabilities(ctx context.Context) {
+	set := &capabilitySet{Methods: make(map[string]bool), CheckedAt: time.Now()}
+	for method, params := range capabilityProbes {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":%s}`, method, params)
+		resp, err := u.post(ctx, "", []byte(body))
+		if err != nil || resp.Status/100 != 2 {
+			// 节点故障时保留上次结果
+			return
+		}
+		var rpcResp struct {
+			Error map[string]interface{} `json:"error"`
+		}
+		if err := json.Unmarshal(resp.Body, &rpcResp); err != nil {
+			return
+		}
+		set.Methods[method] = rpcResp.Error == nil || !isMethodNotFound(rpcResp.Error)
+	}
+	u.caps.mu.Lock()
+	u.caps.caps = set
+	u.caps.mu.Unlock()
+	var unsupported []string
+	for m, ok := range set.Methods {
+		if !ok {
+			unsupported = append(unsupported, m)
+		}
+	}
+	log.Printf("Probed capabilities of %s, unsupported: %v", u.url, unsupported)
+}
+
+func (p *upstreamPool) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, u := range p.list() {
+		node := u
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node.probeCapabilities(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *upstreamPool) capabilityMatrix() map[string]*capabilitySet {
+	out := make(map[string]*capabilitySet, len(p.list()))
+	for _, u := range p.list() {
+		u.caps.mu.Lock()
+		out[u.url] = u.caps.caps
+		u.caps.mu.Unlock()
+	}
+	return out
+}
+
+// emulatedMethods 在没有任何节点支持时由代理自行组合实现
+var emulatedMethods map[string]func(context.Context, JSONRPCRequest) JSONRPCResponse
+
+func init() {
+	emulatedMethods = map[string]func(context.Context, JSONRPCRequest) JSONRPCResponse{
+		"eth_getBlockReceipts": emulateGetBlockReceipts,
+		"eth_getBlockByHash":   emulateGetBlockByHash,
+	}
+}
+
+func emulateGetBlockReceipts(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params")
+	}
+	var blockRef string
+	if err := json.Unmarshal(req.Params[0], &blockRef); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: block must be a string")
+	}
+	method := "eth_getBlockByNumber"
+	if len(blockRef) == 66 {
+		method = "eth_getBlockByHash"
+	}
+	result, err := callUpstream(ctx, method, blockRef, false)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	var block *struct {
+		Transactions []string `json:"transactions"`
+	}
+	if err := json.Unmarshal(result, &block); err != nil {
+		return jsonError(req.ID, -32603, "Invalid block from upstream")
+	}
+	if block == nil {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: nil}
+	}
+	if len(block.Transactions) == 0 {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: []interface{}{}}
+	}
+
+	reqs := make([]JSONRPCRequest, len(block.Transactions))
+	arr := make([]interface{}, len(block.Transactions))
+	for i, h := range block.Transactions {
+		p, _ := json.Marshal(h)
+		reqs[i] = JSONRPCRequest{Jsonrpc: "2.0", Method: "eth_getTransactionReceipt", Params: []json.RawMessage{p}, ID: i}
+		arr[i] = reqs[i]
+	}
+	byID := make(map[string]JSONRPCResponse, len(reqs))
+	for _, r := range forwardBatchToJSONRPC(ctx, reqs, arr) {
+		byID[idKey(r.ID)] = r
+	}
+	receipts := make([]interface{}, len(reqs))
+	for i := range reqs {
+		// 批量响应里的数字 id 解码为 float64
+		r, ok := byID[idKey(float64(i))]
+		if !ok {
+			return jsonError(req.ID, -32603, "Missing receipt in upstream response")
+		}
+		if r.Error != nil {
+			return jsonError(req.ID, -32603, fmt.Sprintf("Receipt error: %v", r.Error))
+		}
+		receipts[i] = r.Result
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: receipts}
+}
@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// CHAOS_ENABLED=1 才允许通过 /admin/chaos 配置故障注入，生产环境保持关闭
+	chaosAllowed = envString("CHAOS_ENABLED", "") == "1"
+
+	metricChaosInjected = newCounterVec("proxy_chaos_injected_total",
+		"Faults injected into upstream calls by the chaos layer, by fault type.", "fault")
+
+	chaos = &chaosState{}
+)
+
+// chaosRule 匹配的上游调用按各自的概率注入故障。method 是 JSON-RPC 方法或 REST 路径的 glob，
+// 空或 * 匹配全部；kind 限定上游类型（jsonrpc、rest、solidity、grpc）
+type chaosRule struct {
+	Method string `json:"method"`
+	Kind   string `json:"kind,omitempty"`
+	// 固定延迟加 [0, jitter) 的随机延迟，latencyRate 为 0 时每次都延迟
+	LatencyMs       int        `json:"latencyMs,omitempty"`
+	LatencyJitterMs int        `json:"latencyJitterMs,omitempty"`
+	LatencyRate     float64    `json:"latencyRate,omitempty"`
+	ErrorRate       float64    `json:"errorRate,omitempty"`
+	Upstream5xxRate float64    `json:"upstream5xxRate,omitempty"`
+	TruncateRate    float64    `json:"truncateRate,omitempty"`
+	ResetRate       float64    `json:"resetRate,omitempty"`
+	DurationSeconds int        `json:"durationSeconds,omitempty"`
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty"`
+}
+
+type chaosState struct {
+	mu    sync.Mutex
+	rules []chaosRule
+}
+
+var errChaosReset = fmt.Errorf("chaos: %w", syscall.ECONNRESET)
+
+func (s *chaosState) set(rules []chaosRule) {
+	now := time.Now()
+	for i := range rules {
+		if rules[i].DurationSeconds > 0 {
+			at := now.Add(time.Duration(rules[i].DurationSeconds) * time.Second)
+			rules[i].ExpiresAt = &at
+		}
+	}
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+}
+
+// active 返回未过期的规则，顺便清掉过期的
+func (s *chaosState) active() []chaosRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rules) == 0 {
+		return nil
+	}
+	now := time.Now()
+	kept := s.rules[:0]
+	for _, r := range s.rules {
+		if r.ExpiresAt == nil || now.Before(*r.ExpiresAt) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) < len(s.rules) {
+		log.Printf("Chaos: %d rule(s) expired, %d active", len(s.rules)-len(kept), len(kept))
+	}
+	s.rules = kept
+	return append([]chaosRule(nil), kept...)
+}
+
+func (s *chaosState) match(kind, target string) *chaosRule {
+	for _, r := range s.active() {
+		if r.Kind != "" && r.Kind != kind {
+			continue
+		}
+		if r.Method == "" || r.Method == "*" {
+			return &r
+		}
+		if ok, _ := path.Match(r.Method, target); ok {
+			return &r
+		}
+	}
+	return nil
+}
+
+func chaosHit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// chaosPost 包在 u.post 外面，按第一条匹配的规则依次决定延迟、连接重置、5xx、
+// JSON-RPC 错误和截断响应。故障发生在上游调用这一层，重试、熔断和降级逻辑照常生效
+func chaosPost(ctx context.Context, u *upstream, path, method string, body []byte) (*upstreamResponse, error) {
+	if !chaosAllowed {
+		return u.post(ctx, path, body)
+	}
+	target := method
+	if target == "" {
+		target = path
+	}
+	rule := chaos.match(u.kind, target)
+	if rule == nil {
+		return u.post(ctx, path, body)
+	}
+	if rule.LatencyMs > 0 || rule.LatencyJitterMs > 0 {
+		if rule.LatencyRate == 0 || chaosHit(rule.LatencyRate) {
+			d := time.Duration(rule.LatencyMs) * time.Millisecond
+			if rule.LatencyJitterMs > 0 {
+				d += time.Duration(rand.Intn(rule.LatencyJitterMs)) * time.Millisecond
+			}
+			metricChaosInjected.inc("latency")
+			if err := sleepContext(ctx, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch {
+	case chaosHit(rule.ResetRate):
+		metricChaosInjected.inc("reset")
+		return nil, errChaosReset
+	case chaosHit(rule.Upstream5xxRate):
+		metricChaosInjected.inc("upstream_5xx")
+		return &upstreamResponse{Body: []byte("chaos: injected upstream failure"), Status: http.StatusServiceUnavailable, Upstream: u}, nil
+	case chaosHit(rule.ErrorRate):
+		metricChaosInjected.inc("error")
+		return &upstreamResponse{Body: chaosErrorBody(u.kind, body), Status: http.StatusOK, Upstream: u}, nil
+	}
+	resp, err := u.post(ctx, path, body)
+	if err == nil && len(resp.Body) > 1 && chaosHit(rule.TruncateRate) {
+		metricChaosInjected.inc("truncate")
+		truncated := *resp
+		truncated.Body = resp.Body[:rand.Intn(len(resp.Body)-1)+1]
+		return &truncated, nil
+	}
+	return resp, err
+}
+
+// chaosErrorBody 节点自己返回的错误格式：JSON-RPC error 对象，REST 为 {"Error": "..."}
+func chaosErrorBody(kind string, reqBody []byte) []byte {
+	if kind != "jsonrpc" {
+		b, _ := json.Marshal(map[string]string{"Error": "chaos: injected error"})
+		return b
+	}
+	var req JSONRPCRequest
+	if codec.Unmarshal(reqBody, &req) != nil {
+		// 批量请求
+		var batch []JSONRPCRequest
+		codec.Unmarshal(reqBody, &batch)
+		out := make([]JSONRPCResponse, len(batch))
+		for i, r := range batch {
+			out[i] = jsonError(r.ID, -32000, "chaos: injected error")
+		}
+		b, _ := codec.Marshal(out)
+		return b
+	}
+	b, _ := codec.Marshal(jsonError(req.ID, -32000, "chaos: injected error"))
+	return b
+}
+
+// handleAdminChaos GET 返回当前规则；PUT/POST {"rules": [...]} 替换全部规则；DELETE 清空
+func handleAdminChaos(w http.ResponseWriter, r *http.Request) {
+	if !chaosAllowed {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "fault injection is disabled, set CHAOS_ENABLED=1"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var body struct {
+			Rules []chaosRule `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		if err := validateChaosRules(body.Rules); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		chaos.set(body.Rules)
+		log.Printf("Chaos: %d rule(s) installed by %s", len(body.Rules), clientIP(r))
+	case http.MethodDelete:
+		chaos.set(nil)
+		log.Printf("Chaos: rules cleared by %s", clientIP(r))
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET, PUT or DELETE required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rules": chaos.active()})
+}
+
+func validateChaosRules(rules []chaosRule) error {
+	for i, r := range rules {
+		if _, err := path.Match(r.Method, ""); err != nil {
+			return fmt.Errorf("rule %d: invalid method pattern %q", i, r.Method)
+		}
+		for _, rate := range []float64{r.LatencyRate, r.ErrorRate, r.Upstream5xxRate, r.TruncateRate, r.ResetRate} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("rule %d: rates must be between 0 and 1", i)
+			}
+		}
+		if r.LatencyMs < 0 || r.LatencyJitterMs < 0 || r.DurationSeconds < 0 {
+			return fmt.Errorf("rule %d: latency and duration must not be negative", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// proxy 独立运行的入口，配置全部来自环境变量和 CONFIG_FILE
+package main
+
+import (
+	"log"
+
+	"github.com/yourname/proxy"
+)
+
+func main() {
+	// 设置log前缀和输出选项
+	log.SetPrefix("[proxy] ")
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+
+	p, err := proxy.New(proxy.Config{})
+	if err != nil {
+		log.Fatalf("Startup error: %v", err)
+	}
+	err = p.ListenAndServe()
+	p.Close()
+	if err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// 同时到达的相同 (method, params) 请求只发一次上游，结果分给所有等待者。
+	// 只适用于只读方法；新区块出现时大量客户端同时请求 latest 区块就是典型场景
+	coalesceMethods = toSet(splitList(envString("COALESCE_METHODS",
+		"eth_blockNumber,eth_chainId,eth_gasPrice,eth_getBlockByNumber,eth_getBlockByHash,eth_getBalance,eth_getCode,"+
+			"eth_getStorageAt,eth_call,eth_estimateGas,eth_getLogs,eth_getTransactionByHash,eth_getTransactionReceipt,"+
+			"debug_traceTransaction,debug_traceBlockByNumber,debug_traceBlockByHash")))
+
+	coalescer = &requestCoalescer{calls: make(map[string]*coalescedCall)}
+
+	metricCoalesced = newCounterVec("proxy_coalesced_requests_total",
+		"Requests answered by sharing an identical in-flight upstream call, by method.", "method")
+)
+
+type requestCoalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done chan struct{}
+	resp JSONRPCResponse
+	// 发起者的 ctx 被取消或 fn panic 时为 false，等待者自己再请求一次
+	shared bool
+}
+
+func coalesceKey(ctx context.Context, req JSONRPCRequest) string {
+	key := cacheKey(req.Method, paramsHash(req.Params))
+	// 快速通道使用独立的上游连接池，不和普通请求合并
+	if isExpress(ctx) {
+		key = "express:" + key
+	}
+	if n := networkFrom(ctx); n != nil {
+		key = n.name + ":" + key
+	}
+	if label := upstreamTarget(ctx); label != "" {
+		key = "upstream=" + label + ":" + key
+	}
+	return key
+}
+
+// coalesce 第一个请求调用 fn，期间到达的相同请求等待它的结果并换上自己的 ID
+func coalesce(ctx context.Context, req JSONRPCRequest, fn func(context.Context, JSONRPCRequest) JSONRPCResponse) JSONRPCResponse {
+	if !coalesceMethods[req.Method] {
+		return fn(ctx, req)
+	}
+	key := coalesceKey(ctx, req)
+	c := coalescer
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return fn(ctx, req)
+		}
+		if !call.shared {
+			return fn(ctx, req)
+		}
+		metricCoalesced.inc(methodLabel(req.Method))
+		debugFrom(ctx).cache(req.Method, "coalesced")
+		resp := call.resp
+		resp.ID = req.ID
+		return resp
+	}
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.resp = fn(ctx, req)
+	call.shared = ctx.Err() == nil
+	return call.resp
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sort"
+)
+
+// jsonCodec 请求解析、转发、响应编码等热路径上的 JSON 编解码。
+// 默认 encoding/json；用 -tags jsoniter 编译后默认切到 jsoniter，JSON_CODEC=std 可在运行时切回
+type jsonCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) jsonEncoder
+	NewDecoder(r io.Reader) jsonDecoder
+}
+
+type jsonEncoder interface {
+	Encode(v interface{}) error
+}
+
+type jsonDecoder interface {
+	Decode(v interface{}) error
+}
+
+var (
+	jsonCodecs = map[string]jsonCodec{"std": stdCodec{}}
+	// 编译进来的非标准实现，未设置 JSON_CODEC 时优先使用
+	preferredCodec = "std"
+
+	codec     jsonCodec = stdCodec{}
+	codecName           = "std"
+)
+
+// registerJSONCodec 由带 build tag 的文件在包变量初始化阶段调用，早于 init 里的选择
+func registerJSONCodec(name string, c jsonCodec) bool {
+	jsonCodecs[name] = c
+	preferredCodec = name
+	return true
+}
+
+func init() {
+	name := configValue("JSON_CODEC")
+	if name == "" {
+		name = preferredCodec
+	}
+	c, ok := jsonCodecs[name]
+	if !ok {
+		available := make([]string, 0, len(jsonCodecs))
+		for n := range jsonCodecs {
+			available = append(available, n)
+		}
+		sort.Strings(available)
+		log.Printf("JSON_CODEC=%q is not compiled in (available: %v), using std", name, available)
+		return
+	}
+	codec, codecName = c, name
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (stdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (stdCodec) NewEncoder(w io.Writer) jsonEncoder         { return json.NewEncoder(w) }
+func (stdCodec) NewDecoder(r io.Reader) jsonDecoder         { return json.NewDecoder(r) }
@@ -0,0 +1,23 @@
+//go:build jsoniter
+
+package proxy
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// 与 encoding/json 行为一致的配置：map 键排序、转义 HTML、尊重 MarshalJSON 和 RawMessage
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var _ = registerJSONCodec("jsoniter", jsoniterCodec{})
+
+type jsoniterCodec struct{}
+
+func (jsoniterCodec) Marshal(v interface{}) ([]byte, error) { return jsoniterAPI.Marshal(v) }
+func (jsoniterCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniterAPI.Unmarshal(data, v)
+}
+func (jsoniterCodec) NewEncoder(w io.Writer) jsonEncoder { return jsoniterAPI.NewEncoder(w) }
+func (jsoniterCodec) NewDecoder(r io.Reader) jsonDecoder { return jsoniterAPI.NewDecoder(r) }
@@ -0,0 +1,264 @@
+package proxy
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// COMPRESS_RESPONSES=off 关闭响应压缩；小于 COMPRESS_MIN_BYTES 的响应不压缩
+	compressResponses = envString("COMPRESS_RESPONSES", "on") == "on"
+	compressMinBytes  = envInt("COMPRESS_MIN_BYTES", 1024)
+	compressLevel     = envInt("COMPRESS_LEVEL", gzip.DefaultCompression)
+	// 压缩请求体解压后的上限，防止压缩炸弹
+	requestMaxDecompressed = int64(envInt("REQUEST_MAX_DECOMPRESSED_BYTES", 64<<20))
+	// 向上游声明 Accept-Encoding: gzip, deflate 并透明解压
+	upstreamCompression = envString("UPSTREAM_COMPRESSION", "on") == "on"
+
+	metricCompressed = newCounterVec("proxy_compressed_total",
+		"Compressed payloads by direction (request, response, upstream) and encoding.", "direction", "encoding")
+)
+
+// withCompression 包在最外层：解压请求体，按 Accept-Encoding 压缩响应。
+// 响应签名在内层，签名覆盖的是未压缩的内容
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc != "" && enc != "identity" {
+			body, err := decompressReader(enc, r.Body)
+			if err != nil {
+				status := http.StatusBadRequest
+				if _, ok := err.(*unsupportedEncodingError); ok {
+					status = http.StatusUnsupportedMediaType
+				}
+				writeJSON(w, status, map[string]string{"error": err.Error()})
+				return
+			}
+			metricCompressed.inc("request", enc)
+			r.Body = http.MaxBytesReader(w, body, requestMaxDecompressed)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+		}
+		enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if !compressResponses || enc == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: enc, status: http.StatusOK}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func decompressReader(enc string, body io.ReadCloser) (io.ReadCloser, error) {
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{zr, body}, nil
+	case "deflate":
+		// HTTP 的 deflate 应该是 zlib 格式，但不少客户端直接发原始 deflate 流，两种都接受
+		br := bufio.NewReader(body)
+		if head, err := br.Peek(2); err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			return readCloser{zr, body}, nil
+		}
+		return readCloser{flate.NewReader(br), body}, nil
+	}
+	return nil, &unsupportedEncodingError{enc}
+}
+
+type unsupportedEncodingError struct{ enc string }
+
+func (e *unsupportedEncodingError) Error() string {
+	return "unsupported Content-Encoding " + e.enc
+}
+
+// negotiateEncoding 优先 gzip，其次 deflate；q=0 表示客户端不接受
+func negotiateEncoding(accept string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				q, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch name {
+		case "gzip", "x-gzip", "*":
+			gzipOK = true
+		case "deflate":
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return "gzip"
+	case deflateOK:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter 先缓冲到 COMPRESS_MIN_BYTES 再决定是否压缩；Flush 时立即开始压缩，流式响应照常逐段输出
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	started  bool
+	zw       io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.started {
+		return
+	}
+	c.status = status
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.started {
+		if c.zw != nil {
+			return c.zw.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= compressMinBytes {
+		if err := c.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (c *compressWriter) Flush() {
+	if !c.started {
+		c.start(true)
+	}
+	if f, ok := c.zw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) start(compress bool) error {
+	c.started = true
+	h := c.Header()
+	bodyless := c.status < 200 || c.status == http.StatusNoContent || c.status == http.StatusNotModified
+	if compress && !bodyless && h.Get("Content-Encoding") == "" && !strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		h.Set("Content-Encoding", c.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+		if c.encoding == "gzip" {
+			c.zw, _ = gzip.NewWriterLevel(c.ResponseWriter, compressLevel)
+		} else {
+			c.zw, _ = zlib.NewWriterLevel(c.ResponseWriter, compressLevel)
+		}
+		metricCompressed.inc("response", c.encoding)
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	buf := c.buf
+	c.buf = nil
+	var err error
+	if c.zw != nil {
+		_, err = c.zw.Write(buf)
+	} else {
+		_, err = c.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (c *compressWriter) finish() {
+	if !c.started {
+		c.start(len(c.buf) >= compressMinBytes)
+	}
+	if c.zw != nil {
+		c.zw.Close()
+	}
+}
+
+// decompressTransport 显式声明 Accept-Encoding 后 http.Transport 不再自动解 gzip，这里统一处理 gzip 和 deflate
+type decompressTransport struct {
+	base http.RoundTripper
+}
+
+func withUpstreamDecompression(rt http.RoundTripper) http.RoundTripper {
+	if !upstreamCompression {
+		return rt
+	}
+	return &decompressTransport{base: rt}
+}
+
+func (t *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	enc := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if enc != "gzip" && enc != "deflate" {
+		return resp, nil
+	}
+	metricCompressed.inc("upstream", enc)
+	resp.Body = &lazyDecompressBody{enc: enc, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// lazyDecompressBody 第一次读取时才创建解压器，空响应体不会因为缺少 gzip 头而报错
+type lazyDecompressBody struct {
+	enc  string
+	body io.ReadCloser
+	zr   io.Reader
+	err  error
+}
+
+func (b *lazyDecompressBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		var rc io.ReadCloser
+		rc, b.err = decompressReader(b.enc, b.body)
+		b.zr = rc
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+func (b *lazyDecompressBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// CONFIG_FILE 为 KEY=VALUE 格式的配置文件，其中的值优先于环境变量；
+	// SIGHUP 或 POST /admin/reload 时重新读取，见 reload.go
+	configFile    = os.Getenv("CONFIG_FILE")
+	configOverlay = newConfigOverlay()
+	// configErrors 包初始化时读取配置出错不直接退出，由 New 返回
+	configErrors []error
+	// embeddedConfig 嵌入时由 New 根据 Config 设置，优先于 CONFIG_FILE，重新加载时保留；之后只读
+	embeddedConfig = map[string]string{}
+
+	// dataDir 存放本地持久化文件（未配置 STORAGE_DSN 时使用）
+	dataDir = envString("PROXY_DATA_DIR", "data")
+)
+
+func newConfigOverlay() *atomic.Value {
+	v := &atomic.Value{}
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		configErrors = append(configErrors, fmt.Errorf("loading CONFIG_FILE: %w", err))
+		values = map[string]string{}
+	}
+	v.Store(values)
+	return v
+}
+
+// readConfigFile 支持 # 注释、export 前缀和成对的引号
+func readConfigFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+	if path == "" {
+		return values, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		values[k] = v
+	}
+	return values, sc.Err()
+}
+
+// configValue 依次使用 New 传入的 Config、配置文件和环境变量
+func configValue(key string) string {
+	if v, ok := embeddedConfig[key]; ok {
+		return v
+	}
+	if values, _ := configOverlay.Load().(map[string]string); values != nil {
+		if v, ok := values[key]; ok {
+			return v
+		}
+	}
+	return os.Getenv(key)
+}
+
+func envInt(key string, def int) int {
+	v := configValue(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := configValue(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+func envString(key, def string) string {
+	if v := configValue(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	v := configValue(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %g", key, v, def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,373 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// CONTRACT_SCAN_INTERVAL 后台增量扫描 trace 补充合约创建索引的间隔，0 关闭扫描；查询时不再扫描
+	contractScanInterval = envDuration("CONTRACT_SCAN_INTERVAL", time.Minute)
+	// 查不到或只查到创建者的地址，这段时间内不再向节点查询
+	contractLookupTTL = envDuration("CONTRACT_LOOKUP_TTL", 10*time.Minute)
+)
+
+var contractCreations = &creationIndex{
+	path:      filepath.Join(dataDir, "contract-creations.json"),
+	Contracts: make(map[string]*contractCreation),
+}
+
+func init() {
+	registerTraceArtifact("contract_creations", contractCreations.rebuild)
+}
+
+type contractCreation struct {
+	Address         string `json:"address"`
+	AddressHex      string `json:"addressHex"`
+	Creator         string `json:"creator,omitempty"`
+	TransactionHash string `json:"transactionHash,omitempty"`
+	Type            string `json:"type,omitempty"`
+}
+
+// creationIndex 记录从 trace 文件中发现的 CREATE/CREATE2，结果不可变，持久化到磁盘
+type creationIndex struct {
+	mu        sync.Mutex
+	path      string
+	loaded    bool
+	Contracts map[string]*contractCreation `json:"contracts"`
+	LastScan  time.Time                    `json:"lastScan"`
+
+	// 已写入数据库的记录，用于只保存有变化的条目
+	persisted map[string]contractCreation
+	// 最近一次向节点查询的时间，不完整的结果在 contractLookupTTL 内直接返回
+	checked map[string]time.Time
+	// 有未保存的变化，由后台循环批量写入
+	dirty bool
+	// 扫描和重建互斥，不占用 mu
+	scanMu sync.Mutex
+}
+
+func (ci *creationIndex) load() {
+	if ci.loaded {
+		return
+	}
+	ci.loaded = true
+	if store != nil {
+		ci.loadFromStore()
+		return
+	}
+	data, err := os.ReadFile(ci.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading contract creation index: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, ci); err != nil {
+		log.Printf("Invalid contract creation index %s: %v", ci.path, err)
+	}
+	if ci.Contracts == nil {
+		ci.Contracts = make(map[string]*contractCreation)
+	}
+}
+
+func (ci *creationIndex) loadFromStore() {
+	ctx := context.Background()
+	ci.persisted = make(map[string]contractCreation)
+	rows, err := store.query(ctx, "SELECT address_hex, address, creator, transaction_hash, type FROM contract_creations")
+	if err != nil {
+		log.Printf("Error loading contract creations from storage: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := &contractCreation{}
+		if err := rows.Scan(&c.AddressHex, &c.Address, &c.Creator, &c.TransactionHash, &c.Type); err != nil {
+			log.Printf("Error loading contract creations from storage: %v", err)
+			return
+		}
+		ci.Contracts[c.AddressHex] = c
+		ci.persisted[c.AddressHex] = *c
+	}
+	if lastScan, ok := loadState(ctx, "contract_creations.last_scan"); ok {
+		ci.LastScan, _ = time.Parse(time.RFC3339Nano, lastScan)
+	}
+}
+
+func (ci *creationIndex) saveToStore() error {
+	ctx := context.Background()
+	for key, c := range ci.Contracts {
+		if ci.persisted[key] == *c {
+			continue
+		}
+		if _, err := store.exec(ctx, `INSERT INTO contract_creations (address_hex, address, creator, transaction_hash, type)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (address_hex) DO UPDATE SET address = excluded.address, creator = excluded.creator,
+				transaction_hash = excluded.transaction_hash, type = excluded.type`,
+			c.AddressHex, c.Address, c.Creator, c.TransactionHash, c.Type); err != nil {
+			return err
+		}
+		ci.persisted[key] = *c
+	}
+	return saveState(ctx, "contract_creations.last_scan", ci.LastScan.Format(time.RFC3339Nano))
+}
+
+func (ci *creationIndex) save() {
+	if store != nil {
+		if err := ci.saveToStore(); err != nil {
+			log.Printf("Error saving contract creations to storage: %v", err)
+		}
+		return
+	}
+	data, err := json.Marshal(ci)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(ci.path), 0o755)
+	}
+	if err == nil {
+		tmp := ci.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, ci.path)
+		}
+	}
+	if err != nil {
+		log.Printf("Error saving contract creation index: %v", err)
+	}
+}
+
+// rebuild 从全部 trace 文件重新生成索引，扫描期间旧索引继续提供服务
+func (ci *creationIndex) rebuild(ctx context.Context) error {
+	ci.scanMu.Lock()
+	defer ci.scanMu.Unlock()
+	contracts := make(map[string]*contractCreation)
+	lastScan, err := scanTraces(ctx, time.Time{}, contracts)
+	if err != nil {
+		return err
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.loaded = true
+	ci.Contracts, ci.LastScan = contracts, lastScan
+	ci.dirty = false
+	if store != nil {
+		if _, err := store.exec(ctx, "DELETE FROM contract_creations"); err != nil {
+			return err
+		}
+		ci.persisted = make(map[string]contractCreation)
+		return ci.saveToStore()
+	}
+	ci.save()
+	return nil
+}
+
+// scan 增量扫描上次扫描之后修改过的 trace，只在合并结果时持有 mu
+func (ci *creationIndex) scan(ctx context.Context) {
+	ci.scanMu.Lock()
+	defer ci.scanMu.Unlock()
+	ci.mu.Lock()
+	ci.load()
+	since := ci.LastScan
+	ci.mu.Unlock()
+
+	found := make(map[string]*contractCreation)
+	lastScan, err := scanTraces(ctx, since, found)
+	if err != nil {
+		return
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	for key, c := range found {
+		old, ok := ci.Contracts[key]
+		if ok && old.TransactionHash != "" {
+			continue
+		}
+		merged := *c
+		// 创建者以链上合约信息为准
+		if ok && old.Creator != "" {
+			merged.Creator = old.Creator
+		}
+		ci.Contracts[key] = &merged
+	}
+	ci.LastScan = lastScan
+	if len(found) > 0 {
+		ci.dirty = true
+	}
+}
+
+// flush 保存积累的变化，并清理过期的查询记录
+func (ci *creationIndex) flush() {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	for key, at := range ci.checked {
+		if time.Since(at) >= contractLookupTTL {
+			delete(ci.checked, key)
+		}
+	}
+	if ci.dirty {
+		ci.save()
+		ci.dirty = false
+	}
+}
+
+func startContractIndexer() {
+	interval := contractScanInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ctx := context.Background()
+		for {
+			if contractScanInterval > 0 {
+				contractCreations.scan(ctx)
+			}
+			contractCreations.flush()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// scanTraces 把 since 之后修改过的 trace 里的 CREATE/CREATE2 收集到 out，返回本次扫描的开始时间
+func scanTraces(ctx context.Context, since time.Time, out map[string]*contractCreation) (time.Time, error) {
+	started := time.Now()
+	found := 0
+	ids, err := traces.List(ctx, since)
+	if err != nil {
+		log.Printf("Error listing traces in %s: %v", traces, err)
+		return time.Time{}, err
+	}
+	for _, txID := range ids {
+		if err := ctx.Err(); err != nil {
+			// 未扫描完，不推进 LastScan
+			return time.Time{}, err
+		}
+		data, err := traces.Get(ctx, txID)
+		if err != nil {
+			continue
+		}
+		var trace interface{}
+		if json.Unmarshal(data, &trace) != nil {
+			continue
+		}
+		found += collectCreateFrames(trace, txID, out)
+	}
+	log.Printf("Scanned trace dir for contract creations, new: %d, took %s", found, time.Since(started))
+	return started, nil
+}
+
+func collectCreateFrames(v interface{}, txID string, out map[string]*contractCreation) int {
+	found := 0
+	switch node := v.(type) {
+	case map[string]interface{}:
+		typ, _ := node["type"].(string)
+		if t := strings.ToUpper(typ); t == "CREATE" || t == "CREATE2" {
+			to, _ := node["to"].(string)
+			from, _ := node["from"].(string)
+			if key, err := translate.ToEthAddress(to); err == nil {
+				if _, ok := out[key]; !ok {
+					c := &contractCreation{AddressHex: key, TransactionHash: "0x" + strings.TrimPrefix(txID, "0x"), Type: strings.ToUpper(typ)}
+					c.Address, _ = translate.ToBase58Address(key)
+					c.Creator, _ = translate.ToBase58Address(from)
+					out[key] = c
+					found++
+				}
+			}
+		}
+		for _, child := range node {
+			found += collectCreateFrames(child, txID, out)
+		}
+	case []interface{}:
+		for _, child := range node {
+			found += collectCreateFrames(child, txID, out)
+		}
+	}
+	return found
+}
+
+type restContractInfo struct {
+	OriginAddress   string `json:"origin_address"`
+	ContractAddress string `json:"contract_address"`
+}
+
+// lookupContractCreation 只读索引，缺创建者时向节点查询；节点请求不持有锁，结果由后台批量保存
+func lookupContractCreation(ctx context.Context, address string) (*contractCreation, error) {
+	key, err := translate.ToEthAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	ci := contractCreations
+	ci.mu.Lock()
+	ci.load()
+	known := ci.Contracts[key]
+	if known != nil && known.TransactionHash != "" && known.Creator != "" {
+		ci.mu.Unlock()
+		return known, nil
+	}
+	if at, ok := ci.checked[key]; ok && time.Since(at) < contractLookupTTL {
+		ci.mu.Unlock()
+		return known, nil
+	}
+	ci.mu.Unlock()
+
+	c := contractCreation{AddressHex: key}
+	c.Address, _ = translate.ToBase58Address(key)
+	if known != nil {
+		c = *known
+	}
+	// 创建者以链上合约信息为准
+	var info restContractInfo
+	if err := callRest(ctx, "/wallet/getcontract", map[string]interface{}{"value": c.Address, "visible": true}, &info); err != nil {
+		// 节点出错不记入 checked，下次请求重试
+		log.Printf("REST getcontract error for %s: %v", c.Address, err)
+		return known, nil
+	}
+	if info.OriginAddress != "" {
+		c.Creator = info.OriginAddress
+	}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	if ci.checked == nil {
+		ci.checked = make(map[string]time.Time)
+	}
+	ci.checked[key] = time.Now()
+	// 查询期间后台扫描可能已经补上了交易
+	if cur := ci.Contracts[key]; cur != nil && c.TransactionHash == "" {
+		c.TransactionHash, c.Type = cur.TransactionHash, cur.Type
+	}
+	if c.Creator == "" && c.TransactionHash == "" {
+		return nil, nil
+	}
+	if cur := ci.Contracts[key]; cur == nil || *cur != c {
+		ci.Contracts[key] = &c
+		ci.dirty = true
+	}
+	return &c, nil
+}
+
+func handleGetContractCreation(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [address]")
+	}
+	var address string
+	if err := json.Unmarshal(req.Params[0], &address); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: address must be a string")
+	}
+	if _, err := translate.DecodeAddress(address); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	c, err := lookupContractCreation(ctx, address)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if c == nil {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: nil}
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: c}
+}
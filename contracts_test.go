@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestContractCreationLookupCaching(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// 只认识 testContract，其余地址返回空对象
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), testContract) {
+			fmt.Fprintf(w, `{"origin_address":%q,"contract_address":%q}`, testCreator, testContract)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	withTestNode(t)
+	restUpstreams = newUpstreamPool("rest", []string{srv.URL})
+	ctx := context.Background()
+
+	// 未知地址：结果为空，TTL 内不再访问节点
+	unknown := "0x1111111111111111111111111111111111111111"
+	for i := 0; i < 3; i++ {
+		if c, err := lookupContractCreation(ctx, unknown); c != nil || err != nil {
+			t.Fatalf("unknown lookup = %+v, %v", c, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("unknown address: %d REST calls, want 1", n)
+	}
+
+	// 只有创建者的部分结果同样缓存
+	for i := 0; i < 3; i++ {
+		c, _ := lookupContractCreation(ctx, testContract)
+		if c == nil || c.Creator != testCreator || c.TransactionHash != "" {
+			t.Fatalf("partial lookup = %+v", c)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("partial result: %d REST calls, want 2", n)
+	}
+
+	// 后台扫描补上交易后返回完整结果，创建者保留节点给出的值
+	txID := "abababababababababababababababababababababababababababababababab"
+	trace := `{"type":"CALL","calls":[{"type":"CREATE","from":"0x2222222222222222222222222222222222222222","to":"0xa614f803b6fd780986a42c78ec9c7f77e6ded13c"}]}`
+	local := baseTraceStore(traces).String()
+	if err := os.WriteFile(filepath.Join(local, txID+".json"), []byte(trace), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	contractCreations.scan(ctx)
+	c, _ := lookupContractCreation(ctx, testContract)
+	if c == nil || c.TransactionHash != "0x"+txID || c.Type != "CREATE" || c.Creator != testCreator {
+		t.Fatalf("after scan = %+v", c)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("complete entry: %d REST calls, want 2", n)
+	}
+
+	contractCreations.flush()
+	if _, err := os.Stat(contractCreations.path); err != nil {
+		t.Fatalf("index not saved: %v", err)
+	}
+}
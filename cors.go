@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// CORS_ALLOWED_ORIGINS 为空时不处理 CORS；支持 * 和 https://*.example.com 形式的子域名通配
+	corsOrigins     = splitList(configValue("CORS_ALLOWED_ORIGINS"))
+	corsHeaders     = envString("CORS_ALLOWED_HEADERS", "Content-Type, Authorization, X-API-Key, X-Request-ID, X-Debug, X-Proxy-Block-Context, X-Proxy-Pin-Latest")
+	corsExpose      = envString("CORS_EXPOSE_HEADERS", "X-Request-ID, Retry-After, X-Proxy-Pinned-Block, "+signatureHeader)
+	corsMaxAge      = envDuration("CORS_MAX_AGE", 10*time.Minute)
+	corsCredentials = envString("CORS_ALLOW_CREDENTIALS", "off") == "on"
+)
+
+func corsOriginAllowed(origin string) bool {
+	for _, o := range corsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		if i := strings.Index(o, "*."); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) && len(origin) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withCORS 包在客户端入口（/jsonrpc、/v1、/wallet，之后的 /ws 也一样）的最外层：
+// 浏览器的预检请求不带 API key，OPTIONS 在鉴权之前直接应答
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(corsOrigins) == 0 || origin == "" {
+			next(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := corsOriginAllowed(origin)
+		if allowed {
+			// 允许携带凭证时不能返回 *，回显具体的 Origin
+			if corsCredentials || len(corsOrigins) > 1 || corsOrigins[0] != "*" {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if corsCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "origin not allowed"})
+				return
+			}
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", corsHeaders)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if allowed && corsExpose != "" {
+			h.Set("Access-Control-Expose-Headers", corsExpose)
+		}
+		next(w, r)
+	}
+}
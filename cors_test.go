@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 浏览器的预检请求不带 API key，要在鉴权之前应答
+func TestCORSPreflight(t *testing.T) {
+	withTestNode(t)
+	t.Setenv("API_KEYS", "good-key")
+	oldKeys, oldOrigins := apiKeys.Load(), corsOrigins
+	apiKeys.Store(map[string]*apiKey{"good-key": {Key: "good-key", Name: "env"}})
+	corsOrigins = []string{"https://*.example.com"}
+	t.Cleanup(func() { apiKeys.Store(oldKeys); corsOrigins = oldOrigins })
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/jsonrpc", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		r.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+		rec := httptest.NewRecorder()
+		newServeMux().ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := preflight("https://app.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight: HTTP %d %s", rec.Code, rec.Body)
+	}
+	h := rec.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if h.Get("Access-Control-Allow-Methods") == "" || h.Get("Access-Control-Allow-Headers") != corsHeaders || h.Get("Access-Control-Max-Age") == "" {
+		t.Errorf("preflight headers incomplete: %v", h)
+	}
+	if vary := h.Values("Vary"); len(vary) != 3 {
+		t.Errorf("Vary = %v", vary)
+	}
+
+	for _, origin := range []string{"https://example.com.evil.test", "https://example.com", "http://app.example.com"} {
+		rec := preflight(origin)
+		if rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("preflight from %s: HTTP %d, Allow-Origin %q", origin, rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	// 实际请求仍要鉴权，错误响应也带 CORS 头，浏览器才能读到
+	r := httptest.NewRequest(http.MethodPost, "/jsonrpc", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	newServeMux().ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without a key: HTTP %d", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("CORS headers missing on the actual response: %v", rec.Header())
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type debugCtxKey struct{}
+type debugInfoCtxKey struct{}
+
+// debugInfo 是 X-Debug: true 时附加在 _proxy.debug 里的排查信息，并发的子请求都会写入
+type debugInfo struct {
+	mu        sync.Mutex
+	start     time.Time
+	Scope     string              `json:"scope"`
+	TotalMs   float64             `json:"totalMs"`
+	Stages    []debugStage        `json:"stages,omitempty"`
+	Cache     []debugCacheLookup  `json:"cache,omitempty"`
+	Trace     string              `json:"traceSource,omitempty"`
+	Upstreams []debugUpstreamCall `json:"upstreams,omitempty"`
+	Retries   int                 `json:"retries"`
+	// 只开了慢请求日志时也会收集，但不附加到响应里
+	show bool
+}
+
+type debugStage struct {
+	Name string  `json:"name"`
+	Ms   float64 `json:"ms"`
+}
+
+type debugCacheLookup struct {
+	Method string `json:"method"`
+	Result string `json:"result"`
+}
+
+type debugUpstreamCall struct {
+	Kind     string  `json:"kind"`
+	Upstream string  `json:"upstream"`
+	Method   string  `json:"method,omitempty"`
+	Attempt  int     `json:"attempt"`
+	Status   int     `json:"status,omitempty"`
+	Ms       float64 `json:"ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// debugRequested 只对 admin key 或管理令牌生效，避免普通调用方看到上游地址
+func debugRequested(r *http.Request) bool {
+	if r.Header.Get("X-Debug") != "true" {
+		return false
+	}
+	if k := apiKeyFromContext(r.Context()); k != nil && k.Admin {
+		return true
+	}
+	return isAdminRequest(r)
+}
+
+func withDebugFlag(ctx context.Context, r *http.Request) context.Context {
+	if debugRequested(r) {
+		return context.WithValue(ctx, debugCtxKey{}, true)
+	}
+	return ctx
+}
+
+// startDebug 客户端要求调试信息或开启了慢请求日志时为本次请求创建收集器
+func startDebug(ctx context.Context, scope string) (context.Context, *debugInfo) {
+	show, _ := ctx.Value(debugCtxKey{}).(bool)
+	if !show && !slowLog.enabled() {
+		return ctx, nil
+	}
+	d := &debugInfo{start: time.Now(), Scope: scope, show: show}
+	return context.WithValue(ctx, debugInfoCtxKey{}, d), d
+}
+
+func debugFrom(ctx context.Context) *debugInfo {
+	d, _ := ctx.Value(debugInfoCtxKey{}).(*debugInfo)
+	return d
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+func (d *debugInfo) stage(name string, start time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.Stages = append(d.Stages, debugStage{Name: name, Ms: msSince(start)})
+	d.mu.Unlock()
+}
+
+func (d *debugInfo) cache(method, result string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.Cache = append(d.Cache, debugCacheLookup{Method: method, Result: result})
+	d.mu.Unlock()
+}
+
+func (d *debugInfo) traceSource(src string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.Trace = src
+	d.mu.Unlock()
+}
+
+func (d *debugInfo) upstream(call debugUpstreamCall) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.Upstreams = append(d.Upstreams, call)
+	if call.Attempt > 1 {
+		d.Retries++
+	}
+	d.mu.Unlock()
+}
+
+// attach 把调试信息放到响应的 _proxy.debug 里
+func (d *debugInfo) attach(resp *JSONRPCResponse) {
+	if d == nil || !d.show {
+		return
+	}
+	d.mu.Lock()
+	d.TotalMs = msSince(d.start)
+	d.mu.Unlock()
+	ensureProxyMeta(resp).Debug = d
+}
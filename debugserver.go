@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 单独的管理端口，提供 /debug/pprof/、/debug/snapshot 和全部 /admin/* 接口；为空时不启动。
+	// 同样需要 ADMIN_TOKEN
+	adminListenAddr = envString("ADMIN_LISTEN_ADDR", "")
+
+	// net/http/pprof 和 grpc 引入的 x/net/trace 在 init 时把 /debug/ 页面注册到 DefaultServeMux，
+	// 对外端口用自己的 ServeMux（见 newServeMux），这些页面只在管理端口上提供
+	debugMux = http.DefaultServeMux
+)
+
+func startAdminServer() {
+	if adminListenAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/", adminOnly(debugMux.ServeHTTP))
+	mux.HandleFunc("/debug/snapshot", adminOnly(handleDebugSnapshot))
+	registerAdminRoutes(mux)
+	go func() {
+		log.Printf("Admin server started on %s", adminListenAddr)
+		if err := http.ListenAndServe(adminListenAddr, mux); err != nil {
+			log.Printf("Admin server error: %v", err)
+		}
+	}()
+}
+
+type runtimeSnapshot struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Memory     struct {
+		HeapAlloc    uint64    `json:"heapAlloc"`
+		HeapInuse    uint64    `json:"heapInuse"`
+		HeapIdle     uint64    `json:"heapIdle"`
+		HeapReleased uint64    `json:"heapReleased"`
+		HeapObjects  uint64    `json:"heapObjects"`
+		StackInuse   uint64    `json:"stackInuse"`
+		Sys          uint64    `json:"sys"`
+		TotalAlloc   uint64    `json:"totalAlloc"`
+		NumGC        uint32    `json:"numGC"`
+		PauseTotalMs float64   `json:"pauseTotalMs"`
+		LastGC       time.Time `json:"lastGC,omitempty"`
+	} `json:"memory"`
+	// 代理自身持有的大块内存
+	TraceCacheBytes      int64  `json:"traceCacheBytes"`
+	ResponseCacheEntries int    `json:"responseCacheEntries"`
+	InflightRequests     int64  `json:"inflightRequests"`
+	GoroutineDump        string `json:"goroutineDump,omitempty"`
+}
+
+// handleDebugSnapshot GET /debug/snapshot?gc=1&goroutines=1：gc=1 先执行一次 GC，
+// goroutines=1 附上全部 goroutine 的调用栈
+func handleDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("gc") == "1" {
+		runtime.GC()
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s := runtimeSnapshot{Time: time.Now().UTC(), Goroutines: runtime.NumGoroutine(), GOMAXPROCS: runtime.GOMAXPROCS(0)}
+	s.Memory.HeapAlloc, s.Memory.HeapInuse, s.Memory.HeapIdle, s.Memory.HeapReleased = ms.HeapAlloc, ms.HeapInuse, ms.HeapIdle, ms.HeapReleased
+	s.Memory.HeapObjects, s.Memory.StackInuse, s.Memory.Sys, s.Memory.TotalAlloc = ms.HeapObjects, ms.StackInuse, ms.Sys, ms.TotalAlloc
+	s.Memory.NumGC, s.Memory.PauseTotalMs = ms.NumGC, float64(ms.PauseTotalNs)/1e6
+	if ms.LastGC > 0 {
+		s.Memory.LastGC = time.Unix(0, int64(ms.LastGC)).UTC()
+	}
+	s.TraceCacheBytes = traceCache.Stats().Bytes
+	s.ResponseCacheEntries = responseCache.Stats().Entries
+	s.InflightRequests = atomic.LoadInt64(&metricInflight)
+	if r.URL.Query().Get("goroutines") == "1" {
+		var buf bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&buf, 2)
+		s.GoroutineDump = buf.String()
+	}
+	writeJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// traceOptions 是 geth debug_trace* 的第二个参数
+type traceOptions struct {
+	Tracer       string          `json:"tracer"`
+	Timeout      string          `json:"timeout"`
+	TracerConfig json.RawMessage `json:"tracerConfig"`
+
+	raw json.RawMessage
+}
+
+var blockHashPattern = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{64}$`)
+
+func parseTraceOptions(params []json.RawMessage, idx int) (traceOptions, error) {
+	var opts traceOptions
+	if len(params) <= idx || string(params[idx]) == "null" {
+		return opts, nil
+	}
+	if err := codec.Unmarshal(params[idx], &opts); err != nil {
+		return opts, fmt.Errorf("trace options must be an object")
+	}
+	if opts.Timeout != "" {
+		if _, err := time.ParseDuration(opts.Timeout); err != nil {
+			return opts, fmt.Errorf("invalid timeout %q", opts.Timeout)
+		}
+	}
+	opts.raw = params[idx]
+	return opts, nil
+}
+
+// fromStore trace 存储中保存的是 callTracer 格式，只有不带 tracerConfig 的 callTracer 请求可以直接使用
+func (o traceOptions) fromStore() bool {
+	cfg := strings.TrimSpace(string(o.TracerConfig))
+	return o.Tracer == "callTracer" && (cfg == "" || cfg == "null" || cfg == "{}")
+}
+
+func (o traceOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	d, err := time.ParseDuration(o.Timeout)
+	if o.Timeout == "" || err != nil {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// traceTransaction 优先读 trace 存储，缺失或 tracer 不匹配时交给节点的 debug_traceTransaction
+func traceTransaction(ctx context.Context, txID string, opts traceOptions) (json.RawMessage, error) {
+	if opts.fromStore() {
+		data, err := loadTrace(ctx, txID)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	params := []interface{}{"0x" + txID}
+	if opts.raw != nil {
+		params = append(params, opts.raw)
+	}
+	result, err := callUpstream(ctx, "debug_traceTransaction", params...)
+	if err != nil {
+		return nil, err
+	}
+	if string(result) == "null" {
+		return nil, fmt.Errorf("transaction %s not found", txID)
+	}
+	return result, nil
+}
+
+func traceError(ctx context.Context, id interface{}, err error) JSONRPCResponse {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return jsonError(id, -32000, "execution timeout")
+	}
+	if errors.Is(err, errInvalidTraceJSON) {
+		return jsonError(id, -32603, "Invalid JSON in trace file")
+	}
+	if errors.Is(err, errResponseTooLarge) {
+		return jsonError(id, errCodeResponseTooLarge, err.Error())
+	}
+	return jsonError(id, -32000, err.Error())
+}
+
+func handleDebugTraceTransaction(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [txHash, options]")
+	}
+	txID, err := parseTxIDParam(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	opts, err := parseTraceOptions(req.Params, 1)
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	tctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+	result, err := traceTransaction(tctx, txID, opts)
+	if err != nil {
+		return traceError(tctx, req.ID, err)
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+}
+
+func handleDebugTraceBlockByNumber(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [blockNumber, options]")
+	}
+	num, err := resolveBlockNumberParam(ctx, req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	return traceBlock(ctx, req, num)
+}
+
+// handleDebugTraceBlockByHash 参数是区块哈希时按 geth 语义处理，
+// 否则沿用旧接口（参数为区块高度，返回 gettransactioninfobyblocknum 的结果）；旧接口带了 tracer 参数时按高度返回 trace
+func handleDebugTraceBlockByHash(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	hash, ok := blockHashArg(req)
+	if !ok {
+		if !legacyTracerRequested(req) {
+			return handleGetTransactionInfoByBlockNum(ctx, req)
+		}
+		num, err := parseInt64Param(req.Params[0])
+		if err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+		}
+		return traceBlock(ctx, req, num)
+	}
+	var block restBlock
+	if err := callRest(ctx, "/wallet/getblockbyid", map[string]interface{}{"value": strings.TrimPrefix(hash, "0x"), "visible": true}, &block); err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if block.BlockID == "" {
+		return jsonError(req.ID, -32000, "block "+hash+" not found")
+	}
+	return traceBlock(ctx, req, block.BlockHeader.RawData.Number)
+}
+
+func legacyTracerRequested(req JSONRPCRequest) bool {
+	opts, err := parseTraceOptions(req.Params, 1)
+	return err == nil && opts.Tracer != ""
+}
+
+func blockHashArg(req JSONRPCRequest) (string, bool) {
+	if len(req.Params) == 0 {
+		return "", false
+	}
+	var s string
+	if codec.Unmarshal(req.Params[0], &s) != nil || !blockHashPattern.MatchString(s) {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+// resolveBlockNumberParam 支持区块号和 latest/earliest 等 tag
+func resolveBlockNumberParam(ctx context.Context, raw json.RawMessage) (int64, error) {
+	var tag string
+	if codec.Unmarshal(raw, &tag) == nil {
+		if solidifiedTags[tag] {
+			return getSolidifiedHeight(ctx)
+		}
+		switch tag {
+		case "latest", "pending", "safe", "finalized":
+			h, err := getLatestHeader(ctx)
+			return h.Number, err
+		case "earliest":
+			return 0, nil
+		}
+	}
+	return parseInt64Param(raw)
+}
+
+type txTraceResult struct {
+	TxHash string          `json:"txHash"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// traceBlock 按 geth 格式返回 [{txHash, result}]，单笔失败只影响该条目
+func traceBlock(ctx context.Context, req JSONRPCRequest, num int64) JSONRPCResponse {
+	opts, err := parseTraceOptions(req.Params, 1)
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	tctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+	block, err := getRestBlockByNum(tctx, num)
+	if err != nil {
+		return traceError(tctx, req.ID, err)
+	}
+	if opts.fromStore() {
+		tctx = prefetchTxInfos(tctx, block, num)
+	}
+	results := make([]txTraceResult, len(block.Transactions))
+	workers.run(tctx, len(block.Transactions), batchConcurrency, func(i int) {
+		txID := strings.ToLower(block.Transactions[i].TxID)
+		results[i].TxHash = "0x" + txID
+		trace, err := traceTransaction(tctx, txID, opts)
+		if err != nil {
+			results[i].Error = err.Error()
+			return
+		}
+		results[i].Result = trace
+	})
+	if tctx.Err() != nil {
+		return traceError(tctx, req.ID, tctx.Err())
+	}
+	log.Printf("Traced block %d, transactions: %d", num, len(results))
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: results}
+}
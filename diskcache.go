@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// DISK_CACHE_PATH 为 bbolt 文件路径，未配置时关闭。只保存已固化区块的数据，
+	// 重启后回填同一段历史区块不用再请求上游
+	diskCachePath = configValue("DISK_CACHE_PATH")
+	// 比最新区块早这么多个块的条目会被清理，0 不清理；默认约 30 天
+	diskCacheMaxBlockAge = int64(envInt("DISK_CACHE_MAX_BLOCK_AGE", 864000))
+	diskCachePruneEvery  = envDuration("DISK_CACHE_PRUNE_INTERVAL", 10*time.Minute)
+	// JSON-RPC 方法或 REST 路径
+	diskCacheMethods = toSet(splitList(envString("DISK_CACHE_METHODS",
+		"eth_getBlockByNumber,eth_getTransactionReceipt,eth_getTransactionByHash,"+
+			"/wallet/getblockbynum,/wallet/gettransactioninfobyblocknum,/walletsolidity/getblockbynum,/walletsolidity/gettransactioninfobyblocknum")))
+
+	diskCache *boltCache
+
+	metricDiskCache = newCounterVec("proxy_disk_cache_requests_total",
+		"Disk cache lookups by method and result (hit, miss).", "method", "result")
+)
+
+var (
+	diskEntriesBucket = []byte("entries")
+	// 键为 8 字节大端区块高度 + 条目键，按高度顺序遍历用于清理
+	diskBlocksBucket = []byte("blocks")
+)
+
+type boltCache struct {
+	db *bolt.DB
+	// Stats().KeyN 要遍历整个 bucket，条目数单独计数
+	count  int64
+	pruned int64
+
+	// 未开启区块监听时缓存最新高度，避免每次写入都请求上游
+	headMu   sync.Mutex
+	head     int64
+	headTime time.Time
+}
+
+type diskEntry struct {
+	Block  int64           `json:"b"`
+	Tx     string          `json:"t,omitempty"`
+	Result json.RawMessage `json:"r"`
+}
+
+func startDiskCache() error {
+	if diskCachePath == "" {
+		return nil
+	}
+	db, err := bolt.Open(diskCachePath, 0o644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("opening disk cache %s: %w", diskCachePath, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(diskEntriesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(diskBlocksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("initializing disk cache %s: %w", diskCachePath, err)
+	}
+	c := &boltCache{db: db}
+	db.View(func(tx *bolt.Tx) error {
+		c.count = int64(tx.Bucket(diskEntriesBucket).Stats().KeyN)
+		return nil
+	})
+	diskCache = c
+	log.Printf("Disk cache enabled at %s (%d entries)", diskCachePath, c.count)
+	if diskCacheMaxBlockAge > 0 && diskCachePruneEvery > 0 {
+		go func() {
+			for range time.Tick(diskCachePruneEvery) {
+				diskCache.prune(context.Background())
+			}
+		}()
+	}
+	return nil
+}
+
+func closeDiskCache() {
+	if diskCache != nil {
+		diskCache.db.Close()
+	}
+}
+
+// usable nil 时表示未开启
+func (c *boltCache) usable(method string) bool {
+	return c != nil && diskCacheMethods[method]
+}
+
+func (c *boltCache) get(method string, params []json.RawMessage) (json.RawMessage, bool) {
+	if !c.usable(method) {
+		return nil, false
+	}
+	key := []byte(cacheKey(method, paramsHash(params)))
+	var e diskEntry
+	found := false
+	c.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(diskEntriesBucket).Get(key); v != nil {
+			found = json.Unmarshal(v, &e) == nil
+		}
+		return nil
+	})
+	if !found {
+		metricDiskCache.inc(methodLabel(method), "miss")
+		return nil, false
+	}
+	metricDiskCache.inc(methodLabel(method), "hit")
+	return e.Result, true
+}
+
+// put 只写入已固化区块的数据，block 未知时不写
+func (c *boltCache) put(ctx context.Context, method string, params []json.RawMessage, result []byte, block int64, txID string) {
+	if !c.usable(method) || block < 0 {
+		return
+	}
+	head := c.latest(ctx)
+	if head == 0 || head-block < restCacheConfirmations {
+		return
+	}
+	key := []byte(cacheKey(method, paramsHash(params)))
+	value, err := json.Marshal(diskEntry{Block: block, Tx: txID, Result: result})
+	if err != nil {
+		return
+	}
+	added := false
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		entries, blocks := tx.Bucket(diskEntriesBucket), tx.Bucket(diskBlocksBucket)
+		var old diskEntry
+		if v := entries.Get(key); v == nil {
+			added = true
+		} else if json.Unmarshal(v, &old) == nil && old.Block != block {
+			if err := blocks.Delete(blockIndexKey(old.Block, key)); err != nil {
+				return err
+			}
+		}
+		if err := entries.Put(key, value); err != nil {
+			return err
+		}
+		return blocks.Put(blockIndexKey(block, key), nil)
+	})
+	if err != nil {
+		log.Printf("Error writing disk cache entry %s: %v", key, err)
+		return
+	}
+	if added {
+		atomic.AddInt64(&c.count, 1)
+	}
+}
+
+func blockIndexKey(block int64, key []byte) []byte {
+	out := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(out, uint64(block))
+	return append(out, key...)
+}
+
+func (c *boltCache) latest(ctx context.Context) int64 {
+	if head := atomic.LoadInt64(&restHead); head > 0 {
+		return head
+	}
+	c.headMu.Lock()
+	defer c.headMu.Unlock()
+	if time.Since(c.headTime) < 3*time.Second {
+		return c.head
+	}
+	h, err := getLatestHeader(ctx)
+	if err != nil {
+		return c.head
+	}
+	c.head, c.headTime = h.Number, time.Now()
+	return c.head
+}
+
+// prune 删除早于 最新高度 - DISK_CACHE_MAX_BLOCK_AGE 的条目
+func (c *boltCache) prune(ctx context.Context) {
+	head := c.latest(ctx)
+	if head == 0 {
+		return
+	}
+	cutoff := head - diskCacheMaxBlockAge
+	removed := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		entries, blocks := tx.Bucket(diskEntriesBucket), tx.Bucket(diskBlocksBucket)
+		cur := blocks.Cursor()
+		for k, _ := cur.First(); k != nil && int64(binary.BigEndian.Uint64(k[:8])) < cutoff; k, _ = cur.First() {
+			if err := entries.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := cur.Delete(); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error pruning disk cache: %v", err)
+		return
+	}
+	if removed > 0 {
+		atomic.AddInt64(&c.count, -int64(removed))
+		atomic.AddInt64(&c.pruned, int64(removed))
+		log.Printf("Pruned %d disk cache entries older than block %d", removed, cutoff)
+	}
+}
+
+// invalidate 与内存缓存的条件一致，需要遍历全部条目，只用于管理接口
+func (c *boltCache) invalidate(q cacheInvalidation) int {
+	if c == nil {
+		return 0
+	}
+	removed := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		entries, blocks := tx.Bucket(diskEntriesBucket), tx.Bucket(diskBlocksBucket)
+		var keys [][]byte
+		var heights []int64
+		entries.ForEach(func(k, v []byte) error {
+			var e diskEntry
+			if json.Unmarshal(v, &e) != nil {
+				return nil
+			}
+			method, hash := splitCacheKey(string(k))
+			if q.matches(&cacheEntry{method: method, paramsHash: hash, block: e.Block, tx: e.Tx}) {
+				keys = append(keys, append([]byte(nil), k...))
+				heights = append(heights, e.Block)
+			}
+			return nil
+		})
+		for i, k := range keys {
+			if err := entries.Delete(k); err != nil {
+				return err
+			}
+			if err := blocks.Delete(blockIndexKey(heights[i], k)); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error invalidating disk cache: %v", err)
+		return 0
+	}
+	atomic.AddInt64(&c.count, -int64(removed))
+	return removed
+}
+
+// splitCacheKey 方法名（REST 路径）里不会有冒号，paramsHash 是十六进制
+func splitCacheKey(key string) (method, hash string) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return key, ""
+	}
+	return key[:i], key[i+1:]
+}
+
+type diskCacheStats struct {
+	Path    string `json:"path"`
+	Entries int64  `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	Pruned  int64  `json:"pruned"`
+}
+
+func (c *boltCache) Stats() *diskCacheStats {
+	if c == nil {
+		return nil
+	}
+	s := &diskCacheStats{Path: diskCachePath, Entries: atomic.LoadInt64(&c.count), Pruned: atomic.LoadInt64(&c.pruned)}
+	c.db.View(func(tx *bolt.Tx) error {
+		s.Bytes = tx.Size()
+		return nil
+	})
+	return s
+}
+
+func writeDiskCacheMetrics(w io.Writer) {
+	metricDiskCache.write(w)
+	metricSharedCache.write(w)
+	metricRedisErrors.write(w)
+	if s := diskCache.Stats(); s != nil {
+		writeGauge(w, "proxy_disk_cache_entries", "Entries held in the disk cache.", float64(s.Entries))
+		writeGauge(w, "proxy_disk_cache_bytes", "Size of the disk cache file.", float64(s.Bytes))
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package proxy
+
+func diskUsage(path string) (free, total uint64, ok bool) {
+	return 0, 0, false
+}
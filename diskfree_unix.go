@@ -0,0 +1,14 @@
+//go:build unix
+
+package proxy
+
+import "syscall"
+
+// diskUsage 返回 path 所在文件系统对非 root 用户可用的字节数和总字节数
+func diskUsage(path string) (free, total uint64, ok bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), true
+}
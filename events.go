@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+type eventType string
+
+const (
+	EventNewBlock          eventType = "new_block"
+	EventReorg             eventType = "reorg"
+	EventUpstreamUnhealthy eventType = "upstream_unhealthy"
+	EventUpstreamHealthy   eventType = "upstream_healthy"
+	EventConfigReloaded    eventType = "config_reloaded"
+	EventCacheFill         eventType = "cache_fill"
+	EventClockSkew         eventType = "clock_skew"
+)
+
+type event struct {
+	Type eventType
+	Time time.Time
+	Data interface{}
+}
+
+type newBlockEvent struct {
+	Header blockHeader
+}
+
+// reorgEvent Height 及以上的区块已被替换
+type reorgEvent struct {
+	Height  int64
+	OldHash string
+	NewHash string
+}
+
+type upstreamUnhealthyEvent struct {
+	URL   string
+	Kind  string
+	Error string
+}
+
+type upstreamHealthyEvent struct {
+	URL  string
+	Kind string
+}
+
+// clockSkewEvent 偏差超过阈值和恢复时各发布一次
+type clockSkewEvent struct {
+	SkewSeconds float64
+	Reason      string
+	Resolved    bool
+}
+
+type configReloadedEvent struct {
+	Source string
+}
+
+// cacheFillEvent 只在有备用实例跟随时发布，见 standby.go
+type cacheFillEvent struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+var (
+	events = newEventBus()
+
+	metricEventsDropped = newCounterVec("proxy_events_dropped_total",
+		"Events dropped because a subscriber queue was full, by subscriber.", "subscriber")
+)
+
+type subscription struct {
+	name  string
+	types map[eventType]bool
+	ch    chan event
+}
+
+// eventBus 进程内发布订阅，每个订阅者一个带缓冲的队列和独立 goroutine，
+// 慢订阅者只会丢自己的事件，不会阻塞发布方
+type eventBus struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// subscribe 不传 types 表示订阅全部事件
+func (b *eventBus) subscribe(name string, buffer int, fn func(event), types ...eventType) *subscription {
+	s := &subscription{name: name, ch: make(chan event, buffer)}
+	if len(types) > 0 {
+		s.types = make(map[eventType]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	go func() {
+		for e := range s.ch {
+			fn(e)
+		}
+	}()
+	return s
+}
+
+func (b *eventBus) unsubscribe(s *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+func (b *eventBus) publish(t eventType, data interface{}) {
+	e := event{Type: t, Time: time.Now(), Data: data}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.types != nil && !s.types[t] {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			metricEventsDropped.inc(s.name)
+			log.Printf("Event subscriber %s queue full, dropping %s event", s.name, t)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type methodExample struct {
+	Method    string `json:"method"`
+	Summary   string `json:"summary"`
+	Extension bool   `json:"extension,omitempty"`
+	Curl      string `json:"curl"`
+	Ethers    string `json:"ethers"`
+	Web3py    string `json:"web3py"`
+}
+
+// exampleEndpoint 按访问文档时用的地址生成，反向代理后面以 X-Forwarded-Proto/Host 为准
+func exampleEndpoint(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	host := r.Host
+	if h := r.Header.Get("X-Forwarded-Host"); h != "" {
+		host = h
+	}
+	path := "/jsonrpc"
+	if len(jsonrpcPaths) > 0 {
+		path = jsonrpcPaths[0].path
+	}
+	return scheme + "://" + host + path
+}
+
+func buildMethodExample(m methodSpec, endpoint string, withKey bool) methodExample {
+	params := m.Example
+	if params == "" {
+		params = "[]"
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, []byte(params))
+	params = compact.String()
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":%s}`, m.Name, params)
+
+	ex := methodExample{Method: m.Name, Summary: m.Summary, Extension: m.Extension}
+	var curl strings.Builder
+	fmt.Fprintf(&curl, "curl -s -X POST %s \\\n  -H 'Content-Type: application/json' \\\n", endpoint)
+	if withKey {
+		curl.WriteString("  -H \"X-API-Key: $API_KEY\" \\\n")
+	}
+	fmt.Fprintf(&curl, "  -d '%s'", body)
+	ex.Curl = curl.String()
+
+	var ethers strings.Builder
+	ethers.WriteString("import { ethers } from \"ethers\";\n\n")
+	fmt.Fprintf(&ethers, "const req = new ethers.FetchRequest(%q);\n", endpoint)
+	if withKey {
+		ethers.WriteString("req.setHeader(\"X-API-Key\", process.env.API_KEY);\n")
+	}
+	ethers.WriteString("const provider = new ethers.JsonRpcProvider(req, undefined, { staticNetwork: true });\n")
+	fmt.Fprintf(&ethers, "const result = await provider.send(%q, %s);\nconsole.log(result);", m.Name, params)
+	ex.Ethers = ethers.String()
+
+	var py strings.Builder
+	if withKey {
+		py.WriteString("import os\n")
+	}
+	py.WriteString("from web3 import Web3\n\n")
+	if withKey {
+		fmt.Fprintf(&py, "w3 = Web3(Web3.HTTPProvider(%q, request_kwargs={\"headers\": {\"X-API-Key\": os.environ[\"API_KEY\"]}}))\n", endpoint)
+	} else {
+		fmt.Fprintf(&py, "w3 = Web3(Web3.HTTPProvider(%q))\n", endpoint)
+	}
+	fmt.Fprintf(&py, "resp = w3.provider.make_request(%q, %s)\nprint(resp[\"result\"])", m.Name, pythonLiteral(params))
+	ex.Web3py = py.String()
+	return ex
+}
+
+// pythonLiteral 把 JSON 参数转成 Python 字面量（true/false/null 改写，字符串和数字不变）
+func pythonLiteral(raw string) string {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return raw
+	}
+	var b strings.Builder
+	writePythonValue(&b, v)
+	return b.String()
+}
+
+func writePythonValue(b *strings.Builder, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("None")
+	case bool:
+		if x {
+			b.WriteString("True")
+		} else {
+			b.WriteString("False")
+		}
+	case json.Number:
+		b.WriteString(x.String())
+	case string:
+		b.WriteString(strconv.Quote(x))
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range x {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writePythonValue(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Quote(k) + ": ")
+			writePythonValue(b, x[k])
+		}
+		b.WriteByte('}')
+	}
+}
+
+// handleExamples GET /examples?method=eth_getLogs&format=json
+// 按方法注册表生成可以直接粘贴的 curl、ethers.js v6 和 web3.py 示例，默认返回纯文本
+func handleExamples(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	specs := methodRegistry
+	if name := q.Get("method"); name != "" {
+		m := lookupMethodSpec(name)
+		if m == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown method " + name})
+			return
+		}
+		specs = []methodSpec{*m}
+	}
+	endpoint := exampleEndpoint(r)
+	withKey := authEnabled()
+	out := make([]methodExample, 0, len(specs))
+	for _, m := range specs {
+		out = append(out, buildMethodExample(m, endpoint, withKey))
+	}
+	if q.Get("format") == "json" {
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, ex := range out {
+		title := ex.Method
+		if ex.Extension {
+			title += " (proxy extension)"
+		}
+		fmt.Fprintf(w, "## %s\n%s\n\n### curl\n%s\n\n### ethers.js v6\n%s\n\n### web3.py\n%s\n\n", title, ex.Summary, ex.Curl, ex.Ethers, ex.Web3py)
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	// UPSTREAM_CERT_CHECK_INTERVAL 检查 https 上游证书有效期的间隔，0 关闭
+	upstreamCertCheckInterval = envDuration("UPSTREAM_CERT_CHECK_INTERVAL", time.Hour)
+	// 证书剩余天数低于这个值时每次检查都打印警告
+	upstreamCertWarnDays = envInt("UPSTREAM_CERT_WARN_DAYS", 14)
+
+	metricUpstreamAuthFailures = newCounterVec("proxy_upstream_auth_failures_total",
+		"Upstream responses rejecting our credentials (HTTP 401/403), by upstream.", "upstream")
+)
+
+// observeAuth 401/403 说明 API key 失效或被吊销。只在状态变化时打印日志，
+// 持续失败时由指标和 /admin/support-bundle 里的状态体现
+func (u *upstream) observeAuth(status int) {
+	rejected := status == http.StatusUnauthorized || status == http.StatusForbidden
+	if rejected {
+		metricUpstreamAuthFailures.inc(u.label)
+	} else if status/100 != 2 {
+		// 其他错误说明不了凭证是否有效
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	switch {
+	case rejected && !u.authFailing:
+		log.Printf("Upstream %s rejected credentials (HTTP %d), API key may be expired or revoked", redactURL(u.url), status)
+	case !rejected && u.authFailing:
+		log.Printf("Upstream %s accepts credentials again", redactURL(u.url))
+	}
+	u.authFailing = rejected
+}
+
+func startCertExpiryChecks() {
+	if upstreamCertCheckInterval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			for _, p := range allUpstreamPools() {
+				for _, u := range p.list() {
+					u.checkCertExpiry()
+				}
+			}
+			time.Sleep(upstreamCertCheckInterval)
+		}
+	}()
+}
+
+// checkCertExpiry 记录证书链里最早的过期时间，中间证书先过期同样会导致连接失败
+func (u *upstream) checkCertExpiry() {
+	target, err := url.Parse(u.url)
+	if err != nil || target.Scheme != "https" {
+		return
+	}
+	host := target.Host
+	if target.Port() == "" {
+		host = net.JoinHostPort(target.Hostname(), "443")
+	}
+	dialer := &net.Dialer{Timeout: healthCheckClient.Timeout}
+	// 证书已经过期时也要拿到过期时间，所以不在握手时校验
+	cfg := &tls.Config{ServerName: target.Hostname(), InsecureSkipVerify: true}
+	if upstreamTLS != nil {
+		// 只接受 mTLS 的入口不出示客户端证书会直接断开
+		cfg.GetClientCertificate = upstreamTLS.GetClientCertificate
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, cfg)
+	if err != nil {
+		log.Printf("Certificate check for %s failed: %v", redactURL(u.url), err)
+		return
+	}
+	defer conn.Close()
+	var notAfter time.Time
+	for _, c := range conn.ConnectionState().PeerCertificates {
+		if notAfter.IsZero() || c.NotAfter.Before(notAfter) {
+			notAfter = c.NotAfter
+		}
+	}
+	if notAfter.IsZero() {
+		return
+	}
+	u.mu.Lock()
+	u.certNotAfter = notAfter
+	u.mu.Unlock()
+	days := time.Until(notAfter).Hours() / 24
+	switch {
+	case days < 0:
+		log.Printf("Certificate for upstream %s expired on %s", redactURL(u.url), notAfter.Format(time.RFC3339))
+	case days < float64(upstreamCertWarnDays):
+		log.Printf("Certificate for upstream %s expires in %.1f days (%s)", redactURL(u.url), days, notAfter.Format(time.RFC3339))
+	}
+}
+
+func writeExpiryMetrics(w io.Writer) {
+	metricUpstreamAuthFailures.write(w)
+	pools := allUpstreamPools()
+	fmt.Fprintf(w, "# HELP proxy_upstream_cert_expiry_days Days until the earliest certificate in the upstream chain expires.\n# TYPE proxy_upstream_cert_expiry_days gauge\n")
+	for _, p := range pools {
+		for _, s := range p.status() {
+			if s.CertExpiresAt != nil {
+				fmt.Fprintf(w, "proxy_upstream_cert_expiry_days{kind=%q,upstream=%q} %.2f\n", s.Kind, upstreamLabel(s.URL), time.Until(*s.CertExpiresAt).Hours()/24)
+			}
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_auth_ok Whether the upstream accepted our credentials on the last call (0 after HTTP 401/403).\n# TYPE proxy_upstream_auth_ok gauge\n")
+	for _, p := range pools {
+		for _, s := range p.status() {
+			ok := 1
+			if s.AuthFailing {
+				ok = 0
+			}
+			fmt.Fprintf(w, "proxy_upstream_auth_ok{kind=%q,upstream=%q} %d\n", s.Kind, upstreamLabel(s.URL), ok)
+		}
+	}
+}
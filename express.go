@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	// 快速通道只处理这些轻量方法
+	expressMethods = toSet(splitList(envString("EXPRESS_METHODS", "eth_blockNumber,eth_chainId,net_version,web3_clientVersion")))
+	// 只有来自这些 IP/CIDR 的监控探测才能走快速通道，未配置时不启用快速通道
+	expressSources = parseCIDRs(splitList(configValue("EXPRESS_SOURCES")))
+
+	metricExpress = newCounterVec("proxy_express_requests_total",
+		"Requests served through the express lane, by method.", "method")
+)
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+func parseCIDRs(items []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			log.Printf("Ignoring invalid network %q: %v", item, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func ipInNets(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+type expressCtxKey struct{}
+
+func isExpress(ctx context.Context) bool {
+	v, _ := ctx.Value(expressCtxKey{}).(bool)
+	return v
+}
+
+// expressMethod 请求体是单个快速方法，或全部由快速方法组成的批量请求时返回方法名
+func expressMethod(body []byte) (string, bool) {
+	type methodOnly struct {
+		Method string `json:"method"`
+	}
+	var items []methodOnly
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if codec.Unmarshal(trimmed, &items) != nil || len(items) == 0 {
+			return "", false
+		}
+	} else {
+		var single methodOnly
+		if codec.Unmarshal(trimmed, &single) != nil {
+			return "", false
+		}
+		items = append(items, single)
+	}
+	for _, item := range items {
+		if !expressMethods[item.Method] {
+			return "", false
+		}
+	}
+	return items[0].Method, true
+}
+
+// expressMiddleware 识别快速通道请求：跳过限流和共享 worker，使用独立的上游连接池
+func expressMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(expressMethods) == 0 || !ipInNets(clientIP(r), expressSources) {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			next(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		method, ok := expressMethod(body)
+		if !ok {
+			next(w, r)
+			return
+		}
+		metricExpress.inc(method)
+		next(w, r.WithContext(context.WithValue(r.Context(), expressCtxKey{}, true)))
+	}
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 只有 EXPRESS_SOURCES 里的来源才能走快速通道跳过限流，未配置时谁都不行
+func TestExpressSources(t *testing.T) {
+	oldSources := expressSources
+	t.Cleanup(func() { expressSources = oldSources })
+
+	express := func(remote string) bool {
+		var got bool
+		h := expressMiddleware(func(w http.ResponseWriter, r *http.Request) { got = isExpress(r.Context()) })
+		req := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`))
+		req.RemoteAddr = remote + ":1234"
+		h(httptest.NewRecorder(), req)
+		return got
+	}
+
+	expressSources = nil
+	if express("203.0.113.7") {
+		t.Error("express lane open without EXPRESS_SOURCES")
+	}
+	expressSources = parseCIDRs([]string{"10.0.0.0/8"})
+	if !express("10.1.2.3") {
+		t.Error("configured probe source not on the express lane")
+	}
+	if express("203.0.113.7") {
+		t.Error("unlisted client on the express lane")
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+var (
+	// FALLBACK_RESPONSES 为 JSON 对象 {"eth_gasPrice": "0x...", ...}，也可以用 FALLBACK_RESPONSES_FILE 指定文件。
+	// 只在所有上游都失败时返回，不会写入缓存。由 New 加载
+	fallbackResponses map[string]json.RawMessage
+
+	metricFallbackResponses = newCounterVec("proxy_fallback_responses_total",
+		"Static fallback responses served because every upstream failed, by method.", "method")
+)
+
+// fallbackInfo 放在 _proxy.fallback 里，提示客户端这是配置的静态值而不是节点返回
+type fallbackInfo struct {
+	Static bool   `json:"static"`
+	Reason string `json:"reason"`
+}
+
+func loadFallbackResponses(inline, file string) (map[string]json.RawMessage, error) {
+	data := []byte(inline)
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading FALLBACK_RESPONSES_FILE: %w", err)
+		}
+		data = b
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid fallback responses: %w", err)
+	}
+	for method := range out {
+		log.Printf("Static fallback configured for %s", method)
+	}
+	return out, nil
+}
+
+func fallbackResponse(req JSONRPCRequest, cause error) (JSONRPCResponse, bool) {
+	value, ok := fallbackResponses[req.Method]
+	if !ok {
+		return JSONRPCResponse{}, false
+	}
+	metricFallbackResponses.inc(req.Method)
+	log.Printf("Serving static fallback for %s: %v", req.Method, cause)
+	resp := JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: value}
+	ensureProxyMeta(&resp).Fallback = &fallbackInfo{Static: true, Reason: cause.Error()}
+	return resp, true
+}
+
+func isFallback(resp JSONRPCResponse) bool {
+	return resp.Proxy != nil && resp.Proxy.Fallback != nil
+}
+
+// fallbackBatch 整个批量转发失败时，有静态值的条目单独兜底，其余返回原错误
+func fallbackBatch(reqs []JSONRPCRequest, cause error) []JSONRPCResponse {
+	out := make([]JSONRPCResponse, len(reqs))
+	for i, r := range reqs {
+		if resp, ok := fallbackResponse(r, cause); ok {
+			out[i] = resp
+			continue
+		}
+		out[i] = errorResponse(r.ID, cause)
+	}
+	return out
+}
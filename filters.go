@@ -0,0 +1,508 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// emulate：由代理保存过滤器状态，跟随新区块收集变化；forward：原样转发（只适合单个节点）
+	filterMode = envString("FILTER_MODE", "emulate")
+	// 超过这个时间没有轮询的过滤器自动删除，和 geth 的默认值相同
+	filterTTL        = envDuration("FILTER_TTL", 5*time.Minute)
+	filterMaxCount   = envInt("FILTER_MAX_COUNT", 10000)
+	filterMaxPending = envInt("FILTER_MAX_PENDING", 10000)
+	// 回滚时需要重新发出 removed 日志，已返回的日志保留这么多个区块
+	filterReorgDepth = int64(envInt("FILTER_REORG_DEPTH", 32))
+
+	filters = &filterRegistry{byID: make(map[string]*proxyFilter)}
+)
+
+const (
+	filterLogs    = "logs"
+	filterBlocks  = "blocks"
+	filterPending = "pendingTransactions"
+)
+
+// proxyFilter Next 为下一个要扫描的区块；pending 和 hashes 是还没返回给客户端的变化
+type proxyFilter struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`
+	Criteria json.RawMessage `json:"criteria,omitempty"`
+	Next     int64           `json:"next"`
+	To       int64           `json:"to,omitempty"`
+	LastPoll time.Time       `json:"lastPoll"`
+	// Seq 每次写入 Redis 时加一，和本实例上次写入的不同说明其他实例处理过这个过滤器
+	Seq int64 `json:"seq,omitempty"`
+
+	match     *logFilter
+	pending   []ethLog
+	hashes    []string
+	delivered []ethLog
+}
+
+type filterRegistry struct {
+	mu   sync.Mutex
+	byID map[string]*proxyFilter
+	// 轮询不并发执行，避免同一区块被扫描两次
+	pollMu sync.Mutex
+}
+
+func init() {
+	events.subscribe("filters", 64, func(e event) {
+		switch d := e.Data.(type) {
+		case newBlockEvent:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			filters.poll(ctx, d.Header.Number)
+			cancel()
+		case reorgEvent:
+			filters.rewind(d.Height)
+		}
+	}, EventNewBlock, EventReorg)
+	registerSnapshotSection("filters", filters.export, filters.restore)
+	go func() {
+		for range time.Tick(time.Minute) {
+			filters.expire(time.Now())
+		}
+	}()
+}
+
+func newFilterID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "0x" + hex.EncodeToString(b)
+}
+
+func (r *filterRegistry) add(f *proxyFilter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.byID) >= filterMaxCount {
+		return fmt.Errorf("too many filters installed (max %d)", filterMaxCount)
+	}
+	f.LastPoll = time.Now()
+	r.byID[f.ID] = f
+	return nil
+}
+
+func sharedFilterKey(id string) string {
+	return redisPrefix + "filter:" + strings.ToLower(id)
+}
+
+// share 把过滤条件和游标写入 Redis，其他实例收到同一个过滤器的轮询时从游标继续扫描
+func (r *filterRegistry) share(ctx context.Context, id string) {
+	if !redis.shares("filters") {
+		return
+	}
+	r.mu.Lock()
+	f := r.byID[strings.ToLower(id)]
+	if f == nil {
+		r.mu.Unlock()
+		return
+	}
+	f.Seq++
+	rec := proxyFilter{ID: f.ID, Kind: f.Kind, Criteria: f.Criteria, Next: f.nextUnread(), To: f.To, LastPoll: f.LastPoll, Seq: f.Seq}
+	r.mu.Unlock()
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	redis.do(ctx, "SET", sharedFilterKey(id), string(raw), "PX", fmt.Sprint(filterTTL.Milliseconds()))
+}
+
+// sync 以 Redis 里的记录为准：其他实例处理过时丢弃本地未读的变化，从记录的游标重新扫描，
+// 此时 reloaded 为 true。Redis 不可用时只用本地状态
+func (r *filterRegistry) sync(ctx context.Context, id string) (f *proxyFilter, reloaded bool) {
+	if !redis.shares("filters") {
+		return r.get(id), false
+	}
+	v, err := redis.getString(ctx, sharedFilterKey(id))
+	if err == errRedisNil {
+		// 已在其他实例上卸载或过期
+		r.remove(id)
+		return nil, false
+	}
+	var rec proxyFilter
+	if err != nil || json.Unmarshal([]byte(v), &rec) != nil {
+		return r.get(id), false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f := r.byID[rec.ID]; f != nil && f.Seq == rec.Seq {
+		return f, false
+	}
+	if rec.Kind == filterLogs {
+		match, err := translate.ParseLogFilter(rec.Criteria)
+		if err != nil {
+			return nil, false
+		}
+		rec.match = match
+	}
+	rec.LastPoll = time.Now()
+	r.byID[rec.ID] = &rec
+	return &rec, true
+}
+
+func (r *filterRegistry) get(id string) *proxyFilter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.byID[strings.ToLower(id)]
+}
+
+func (r *filterRegistry) remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id = strings.ToLower(id)
+	_, ok := r.byID[id]
+	delete(r.byID, id)
+	return ok
+}
+
+// uninstall 同时删除 Redis 里的记录，只在其他实例上创建过的过滤器也能卸载
+func (r *filterRegistry) uninstall(ctx context.Context, id string) bool {
+	ok := r.remove(id)
+	if redis.shares("filters") {
+		if n, err := redis.do(ctx, "DEL", sharedFilterKey(id)); err == nil && n == int64(1) {
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (r *filterRegistry) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, f := range r.byID {
+		if now.Sub(f.LastPoll) > filterTTL {
+			delete(r.byID, id)
+		}
+	}
+}
+
+// poll 把 Next 到 head 之间的区块分发给各个过滤器，每个区块的日志只读取一次
+func (r *filterRegistry) poll(ctx context.Context, head int64) {
+	r.pollMu.Lock()
+	defer r.pollMu.Unlock()
+	r.mu.Lock()
+	from, wantLogs, active := head+1, false, false
+	for _, f := range r.byID {
+		if f.Kind == filterPending || f.Next > head {
+			continue
+		}
+		active = true
+		if f.Next < from {
+			from = f.Next
+		}
+		wantLogs = wantLogs || f.Kind == filterLogs
+	}
+	r.mu.Unlock()
+	if !active {
+		return
+	}
+	// 落后太多时（例如节点长时间不可用）只补最近的区块
+	if head-from+1 > getLogsMaxBlockRange {
+		log.Printf("Filters are %d blocks behind, skipping to block %d", head-from+1, head-getLogsMaxBlockRange+1)
+		from = head - getLogsMaxBlockRange + 1
+	}
+	for n := from; n <= head; n++ {
+		h, err := getHeader(ctx, n)
+		if err != nil {
+			log.Printf("Filter poll stopped at block %d: %v", n, err)
+			return
+		}
+		var logs []ethLog
+		if wantLogs {
+			if logs, err = blockLogs(ctx, &logFilter{}, n); err != nil {
+				log.Printf("Filter poll stopped at block %d: %v", n, err)
+				return
+			}
+		}
+		r.deliver(n, h.Hash, logs)
+	}
+}
+
+func (r *filterRegistry) deliver(num int64, hash string, logs []ethLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.byID {
+		if f.Kind == filterPending || f.Next > num || (f.To > 0 && num > f.To) {
+			continue
+		}
+		f.Next = num + 1
+		switch f.Kind {
+		case filterBlocks:
+			f.hashes = appendCapped(f.hashes, hash)
+		case filterLogs:
+			for _, l := range logs {
+				if f.match.Matches(strings.TrimPrefix(l.Address, "0x"), l.Topics) {
+					f.pending = appendCappedLog(f.pending, l)
+				}
+			}
+		}
+	}
+}
+
+// rewind 回滚后从 height 重新扫描：还没返回的日志丢弃，已经返回的以 removed=true 再发一次
+func (r *filterRegistry) rewind(height int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.byID {
+		if f.Next > height {
+			f.Next = height
+		}
+		if f.Kind != filterLogs {
+			continue
+		}
+		kept := f.pending[:0]
+		for _, l := range f.pending {
+			if n, _ := translate.ParseQuantity(l.BlockNumber); n < height {
+				kept = append(kept, l)
+			}
+		}
+		f.pending = kept
+		delivered := f.delivered[:0]
+		for _, l := range f.delivered {
+			if n, _ := translate.ParseQuantity(l.BlockNumber); n >= height {
+				l.Removed = true
+				f.pending = appendCappedLog(f.pending, l)
+				continue
+			}
+			delivered = append(delivered, l)
+		}
+		f.delivered = delivered
+	}
+}
+
+func appendCapped(list []string, v string) []string {
+	if len(list) >= filterMaxPending {
+		list = list[1:]
+	}
+	return append(list, v)
+}
+
+func appendCappedLog(list []ethLog, l ethLog) []ethLog {
+	if len(list) >= filterMaxPending {
+		list = list[1:]
+	}
+	return append(list, l)
+}
+
+// changes 返回并清空未读的变化
+func (r *filterRegistry) changes(id string) (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.byID[strings.ToLower(id)]
+	if f == nil {
+		return nil, false
+	}
+	f.LastPoll = time.Now()
+	switch f.Kind {
+	case filterBlocks:
+		out := f.hashes
+		f.hashes = nil
+		if out == nil {
+			out = []string{}
+		}
+		return out, true
+	case filterLogs:
+		out := f.pending
+		f.pending = nil
+		if out == nil {
+			out = []ethLog{}
+		}
+		for _, l := range out {
+			if !l.Removed {
+				f.delivered = append(f.delivered, l)
+			}
+		}
+		// 只保留可能被回滚的区块
+		keep := f.delivered[:0]
+		for _, l := range f.delivered {
+			if n, _ := translate.ParseQuantity(l.BlockNumber); n >= f.Next-filterReorgDepth {
+				keep = append(keep, l)
+			}
+		}
+		f.delivered = keep
+		return out, true
+	}
+	return []string{}, true
+}
+
+// watcherRunning 没有区块轮询时，在 eth_getFilterChanges 里按需推进
+func watcherRunning() bool {
+	return blockWatchInterval > 0 && len(jsonrpcUpstreams.list()) > 0
+}
+
+func filterIDParam(req JSONRPCRequest) (string, bool) {
+	if len(req.Params) == 0 {
+		return "", false
+	}
+	var id string
+	if codec.Unmarshal(req.Params[0], &id) != nil || id == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func filterNotFound(id interface{}) JSONRPCResponse {
+	return jsonError(id, -32000, "filter not found")
+}
+
+func handleFilterMethod(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	// 区块监听只跟随默认网络，其他网络的过滤器由节点保存
+	if filterMode == "forward" || networkFrom(ctx) != nil {
+		return forwardAndReturn(ctx, req)
+	}
+	switch req.Method {
+	case "eth_newFilter":
+		return handleNewFilter(ctx, req)
+	case "eth_newBlockFilter", "eth_newPendingTransactionFilter":
+		kind := filterBlocks
+		if req.Method == "eth_newPendingTransactionFilter" {
+			// TRON 节点不公开交易池，这类过滤器始终没有变化
+			kind = filterPending
+		}
+		head, err := getLatestHeader(ctx)
+		if err != nil {
+			return errorResponse(req.ID, err)
+		}
+		f := &proxyFilter{ID: newFilterID(), Kind: kind, Next: head.Number + 1}
+		if err := filters.add(f); err != nil {
+			return jsonError(req.ID, -32005, err.Error())
+		}
+		filters.share(ctx, f.ID)
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: f.ID}
+	case "eth_getFilterChanges":
+		id, ok := filterIDParam(req)
+		if !ok {
+			return jsonError(req.ID, -32602, "Invalid params: expected [filterId]")
+		}
+		f, reloaded := filters.sync(ctx, id)
+		if f == nil {
+			return filterNotFound(req.ID)
+		}
+		if !watcherRunning() || reloaded {
+			head, err := getLatestHeader(ctx)
+			if err != nil {
+				return errorResponse(req.ID, err)
+			}
+			filters.poll(ctx, head.Number)
+		}
+		out, ok := filters.changes(id)
+		if !ok {
+			return filterNotFound(req.ID)
+		}
+		filters.share(ctx, id)
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: out}
+	case "eth_getFilterLogs":
+		id, ok := filterIDParam(req)
+		if !ok {
+			return jsonError(req.ID, -32602, "Invalid params: expected [filterId]")
+		}
+		f, _ := filters.sync(ctx, id)
+		if f == nil || f.Kind != filterLogs {
+			return filterNotFound(req.ID)
+		}
+		filters.mu.Lock()
+		f.LastPoll = time.Now()
+		filters.mu.Unlock()
+		filters.share(ctx, id)
+		return handleGetLogs(ctx, JSONRPCRequest{Jsonrpc: "2.0", ID: req.ID, Method: "eth_getLogs", Params: []json.RawMessage{f.Criteria}})
+	case "eth_uninstallFilter":
+		id, ok := filterIDParam(req)
+		if !ok {
+			return jsonError(req.ID, -32602, "Invalid params: expected [filterId]")
+		}
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: filters.uninstall(ctx, id)}
+	}
+	return jsonError(req.ID, -32601, "Method not found")
+}
+
+// handleNewFilter 和 geth 一样，eth_getFilterChanges 只返回创建之后的新区块里的日志，
+// fromBlock 在未来时从该区块开始；历史日志用 eth_getFilterLogs 查询
+func handleNewFilter(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [filter]")
+	}
+	match, err := translate.ParseLogFilter(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	if match.BlockHash != "" {
+		return jsonError(req.ID, -32602, "Invalid params: blockHash is not supported by filters")
+	}
+	head, err := getLatestHeader(ctx)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	f := &proxyFilter{ID: newFilterID(), Kind: filterLogs, Criteria: req.Params[0], Next: head.Number + 1, match: match}
+	if n, err := parseInt64Param(match.FromBlock); err == nil && n > f.Next {
+		f.Next = n
+	}
+	if n, err := parseInt64Param(match.ToBlock); err == nil {
+		f.To = n
+	}
+	if err := filters.add(f); err != nil {
+		return jsonError(req.ID, -32005, err.Error())
+	}
+	filters.share(ctx, f.ID)
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: f.ID}
+}
+
+// export 只导出过滤条件和游标，未读的变化在新实例上从 Next 重新扫描得到
+func (r *filterRegistry) export() (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]proxyFilter, 0, len(r.byID))
+	for _, f := range r.byID {
+		out = append(out, proxyFilter{ID: f.ID, Kind: f.Kind, Criteria: f.Criteria, Next: f.nextUnread(), To: f.To, LastPoll: f.LastPoll})
+	}
+	return out, nil
+}
+
+func (f *proxyFilter) nextUnread() int64 {
+	next := f.Next
+	for _, l := range f.pending {
+		if n, err := translate.ParseQuantity(l.BlockNumber); err == nil && n < next {
+			next = n
+		}
+	}
+	if len(f.hashes) > 0 && f.Next-int64(len(f.hashes)) < next {
+		next = f.Next - int64(len(f.hashes))
+	}
+	return next
+}
+
+func (r *filterRegistry) restore(raw json.RawMessage) (int, error) {
+	var list []proxyFilter
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for i := range list {
+		f := list[i]
+		if _, ok := r.byID[f.ID]; ok || f.ID == "" || time.Since(f.LastPoll) > filterTTL {
+			continue
+		}
+		if f.Kind == filterLogs {
+			match, err := translate.ParseLogFilter(f.Criteria)
+			if err != nil {
+				continue
+			}
+			f.match = match
+		}
+		r.byID[f.ID] = &f
+		n++
+	}
+	return n, nil
+}
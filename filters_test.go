@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourname/proxy/translate"
+)
+
+func withFilterRegistry(t *testing.T) {
+	old := filters
+	filters = &filterRegistry{byID: make(map[string]*proxyFilter)}
+	t.Cleanup(func() { filters = old })
+}
+
+func testLog(block int64, address string, topic string) ethLog {
+	return ethLog{Address: "0x" + address, Topics: []string{topic}, BlockNumber: translate.HexQuantity(block)}
+}
+
+// 日志过滤器只收到匹配的日志，取走之后清空；回滚后已经返回的日志以 removed=true 再发一次
+func TestLogFilterChangesAndReorg(t *testing.T) {
+	withFilterRegistry(t)
+	addrA, addrB := strings.Repeat("a", 40), strings.Repeat("b", 40)
+	topic := "0x" + strings.Repeat("1", 64)
+	criteria := json.RawMessage(`{"address":"0x` + addrA + `"}`)
+	match, err := translate.ParseLogFilter(criteria)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := &proxyFilter{ID: "0x01", Kind: filterLogs, Criteria: criteria, Next: 10, match: match}
+	blocks := &proxyFilter{ID: "0x02", Kind: filterBlocks, Next: 10}
+	for _, f := range []*proxyFilter{logs, blocks} {
+		if err := filters.add(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters.deliver(10, "0xh10", []ethLog{testLog(10, addrA, topic), testLog(10, addrB, topic)})
+	filters.deliver(11, "0xh11", []ethLog{testLog(11, addrA, topic)})
+
+	out, ok := filters.changes("0x01")
+	if got := out.([]ethLog); !ok || len(got) != 2 || got[0].Address != "0x"+addrA || got[0].Removed {
+		t.Fatalf("log changes = %+v", out)
+	}
+	if out, _ := filters.changes("0x01"); len(out.([]ethLog)) != 0 {
+		t.Errorf("changes not cleared: %+v", out)
+	}
+	if out, _ := filters.changes("0x02"); strings.Join(out.([]string), ",") != "0xh10,0xh11" {
+		t.Errorf("block changes = %v", out)
+	}
+
+	// 区块 11 被回滚
+	filters.rewind(11)
+	out, _ = filters.changes("0x01")
+	if got := out.([]ethLog); len(got) != 1 || !got[0].Removed || got[0].BlockNumber != "0xb" {
+		t.Errorf("changes after reorg = %+v, want block 11 removed", got)
+	}
+	if logs.Next != 11 || blocks.Next != 11 {
+		t.Errorf("filters not rewound: next = %d, %d", logs.Next, blocks.Next)
+	}
+}
+
+func TestFilterMethodsUnknownID(t *testing.T) {
+	withTestNode(t)
+	withFilterRegistry(t)
+	for _, method := range []string{"eth_getFilterChanges", "eth_getFilterLogs"} {
+		var resp struct{ Error *struct{ Message string } }
+		json.Unmarshal(postJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":["0xdead"]}`), &resp)
+		if resp.Error == nil || resp.Error.Message != "filter not found" {
+			t.Errorf("%s: %+v, want filter not found", method, resp.Error)
+		}
+	}
+
+	var created struct{ Result string }
+	json.Unmarshal(postJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"eth_newBlockFilter","params":[]}`), &created)
+	if f := filters.get(created.Result); f == nil || f.Next != 101 {
+		t.Fatalf("block filter %q not installed after head 100: %+v", created.Result, f)
+	}
+	uninstall := func() bool {
+		var resp struct{ Result bool }
+		json.Unmarshal(postJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"eth_uninstallFilter","params":["`+created.Result+`"]}`), &resp)
+		return resp.Result
+	}
+	if !uninstall() || filters.get(created.Result) != nil {
+		t.Error("filter not uninstalled")
+	}
+	if uninstall() {
+		t.Error("uninstalling twice returned true")
+	}
+}
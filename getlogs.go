@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// translate：由代理按区块的交易回执组装日志；forward：原样转发给节点
+	getLogsMode          = envString("GETLOGS_MODE", "translate")
+	getLogsMaxBlockRange = int64(envInt("GETLOGS_MAX_BLOCK_RANGE", 1000))
+	getLogsMaxResults    = envInt("GETLOGS_MAX_RESULTS", 10000)
+	// TronGrid 风格的事件 API，配置后带 address 的查询先用它定位有事件的区块
+	tronEventAPI    = strings.TrimRight(configValue("TRON_EVENT_API"), "/")
+	tronEventAPIKey = configValue("TRON_EVENT_API_KEY")
+)
+
+// logFilter、ethLog 旧名字，实现见 translate 包
+type (
+	logFilter = translate.LogFilter
+	ethLog    = translate.Log
+)
+
+// TronNode REST 交易回执里的日志，地址不带 41 前缀，topics/data 不带 0x
+type restTxLogs struct {
+	ID  string `json:"id"`
+	Log []struct {
+		Address string   `json:"address"`
+		Topics  []string `json:"topics"`
+		Data    string   `json:"data"`
+	} `json:"log"`
+}
+
+// resolveBlockTag fromBlock/toBlock 缺省为 latest
+func resolveBlockTag(ctx context.Context, raw json.RawMessage) (int64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		h, err := getLatestHeader(ctx)
+		return h.Number, err
+	}
+	return resolveBlockNumberParam(ctx, raw)
+}
+
+func handleGetLogs(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if getLogsMode == "forward" {
+		return forwardAndReturn(ctx, req)
+	}
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [filter]")
+	}
+	f, err := translate.ParseLogFilter(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+
+	var blocks []int64
+	if f.BlockHash != "" {
+		h, err := getHeaderByHash(ctx, f.BlockHash)
+		if err != nil {
+			return jsonError(req.ID, -32000, "block "+f.BlockHash+" not found")
+		}
+		blocks = []int64{h.Number}
+	} else {
+		from, err := resolveBlockTag(ctx, f.FromBlock)
+		if err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: fromBlock: "+err.Error())
+		}
+		to, err := resolveBlockTag(ctx, f.ToBlock)
+		if err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: toBlock: "+err.Error())
+		}
+		if from > to {
+			return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: []ethLog{}}
+		}
+		if tronEventAPI != "" && f.Addresses() != nil {
+			blocks, err = eventBlocks(ctx, f, from, to)
+			if err != nil {
+				log.Printf("Event API lookup failed, scanning blocks %d-%d instead: %v", from, to, err)
+			}
+		}
+		if blocks == nil {
+			if to-from+1 > getLogsMaxBlockRange {
+				return jsonError(req.ID, -32005, fmt.Sprintf("query exceeds max block range %d", getLogsMaxBlockRange))
+			}
+			for n := from; n <= to; n++ {
+				blocks = append(blocks, n)
+			}
+		}
+	}
+
+	logs, err := collectLogs(ctx, f, blocks)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	if len(logs) > getLogsMaxResults {
+		return jsonError(req.ID, -32005, fmt.Sprintf("query returned more than %d results", getLogsMaxResults))
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: logs}
+}
+
+// collectLogs 逐块读取交易回执并按过滤条件筛选，logIndex 为区块内序号
+func collectLogs(ctx context.Context, f *logFilter, blocks []int64) ([]ethLog, error) {
+	perBlock := make([][]ethLog, len(blocks))
+	var mu sync.Mutex
+	var firstErr error
+	workers.run(ctx, len(blocks), batchConcurrency, func(i int) {
+		logs, err := blockLogs(ctx, f, blocks[i])
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+		perBlock[i] = logs
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	logs := []ethLog{}
+	for _, l := range perBlock {
+		logs = append(logs, l...)
+	}
+	return logs, nil
+}
+
+func blockLogs(ctx context.Context, f *logFilter, num int64) ([]ethLog, error) {
+	var infos []restTxLogs
+	if err := callRest(ctx, "/wallet/gettransactioninfobyblocknum", map[string]interface{}{"num": num}, &infos); err != nil {
+		return nil, err
+	}
+	var logs []ethLog
+	var hash string
+	logIndex := 0
+	for txIndex, info := range infos {
+		for _, l := range info.Log {
+			idx := logIndex
+			logIndex++
+			if !f.Matches(l.Address, l.Topics) {
+				continue
+			}
+			if hash == "" {
+				h, err := getHeader(ctx, num)
+				if err != nil {
+					return nil, err
+				}
+				hash = h.Hash
+			}
+			topics := make([]string, len(l.Topics))
+			for i, t := range l.Topics {
+				topics[i] = "0x" + translate.NormalizeTopic(t)
+			}
+			logs = append(logs, ethLog{
+				Address:          "0x" + strings.ToLower(l.Address),
+				Topics:           topics,
+				Data:             "0x" + l.Data,
+				BlockNumber:      translate.HexQuantity(num),
+				BlockHash:        hash,
+				TransactionHash:  "0x" + info.ID,
+				TransactionIndex: translate.HexQuantity(int64(txIndex)),
+				LogIndex:         translate.HexQuantity(int64(idx)),
+			})
+		}
+	}
+	return logs, nil
+}
+
+type tronEventPage struct {
+	Data []struct {
+		BlockNumber int64 `json:"block_number"`
+	} `json:"data"`
+	Meta struct {
+		Fingerprint string `json:"fingerprint"`
+	} `json:"meta"`
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// eventBlocks 通过事件 API 找出各合约在 [from, to] 内有事件的区块，按 fingerprint 翻页。
+// 事件 API 只按时间戳过滤，区块号在这里再精确过滤一次
+func eventBlocks(ctx context.Context, f *logFilter, from, to int64) ([]int64, error) {
+	fromHeader, err := getHeader(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toHeader, err := getHeader(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	for _, addr := range f.Addresses() {
+		base58, err := translate.ToBase58Address("0x" + addr)
+		if err != nil {
+			return nil, err
+		}
+		q := url.Values{}
+		q.Set("min_block_timestamp", strconv.FormatInt(fromHeader.Timestamp*1000, 10))
+		q.Set("max_block_timestamp", strconv.FormatInt(toHeader.Timestamp*1000+999, 10))
+		q.Set("order_by", "block_timestamp,asc")
+		q.Set("limit", "200")
+		for {
+			var page tronEventPage
+			if err := getEventPage(ctx, tronEventAPI+"/v1/contracts/"+base58+"/events?"+q.Encode(), &page); err != nil {
+				return nil, err
+			}
+			for _, e := range page.Data {
+				if e.BlockNumber >= from && e.BlockNumber <= to {
+					seen[e.BlockNumber] = true
+				}
+			}
+			if page.Meta.Fingerprint == "" || len(page.Data) == 0 {
+				break
+			}
+			if int64(len(seen)) > getLogsMaxBlockRange*10 {
+				return nil, fmt.Errorf("too many matching blocks")
+			}
+			q.Set("fingerprint", page.Meta.Fingerprint)
+		}
+	}
+	blocks := make([]int64, 0, len(seen))
+	for n := range seen {
+		blocks = append(blocks, n)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	return blocks, nil
+}
+
+func getEventPage(ctx context.Context, u string, out *tronEventPage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	setUpstreamHeaders(ctx, req, &upstream{url: tronEventAPI, label: upstreamLabel(tronEventAPI)})
+	if tronEventAPIKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", tronEventAPIKey)
+	}
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, upstreamMaxResponseBytes)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event API returned HTTP %d", resp.StatusCode)
+	}
+	if err := codec.Unmarshal(data, out); err != nil {
+		return err
+	}
+	if !out.Success && out.Error != "" {
+		return fmt.Errorf("event API error: %s", out.Error)
+	}
+	return nil
+}
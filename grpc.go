@@ -0,0 +1,747 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// TRON fullnode 原生 gRPC 端口（wallet.proto），host:port，逗号分隔
+	grpcUpstreams = newUpstreamPool("grpc", grpcTargets(splitList(configValue("TRON_GRPC_ENDPOINT"))))
+	// 走 gRPC 的 REST 接口名，其余仍走 HTTP 网关
+	grpcMethods = toSet(splitList(envString("GRPC_METHODS", "gettransactioninfobyblocknum,getblockbynum,broadcasttransaction")))
+
+	grpcConnsMu sync.Mutex
+	grpcConns   = make(map[string]*grpc.ClientConn)
+)
+
+// grpcCall 把 REST 请求体编码成 protobuf，再把 protobuf 响应转换成 REST 网关返回的 JSON 结构。
+// decode 拿到的是原始 JSON 请求体，visible 等参数从这里取
+type grpcCall struct {
+	method string
+	encode func(body []byte) ([]byte, error)
+	decode func(body, resp []byte) (interface{}, error)
+}
+
+var grpcCalls = map[string]grpcCall{
+	"getblockbynum":                {"/protocol.Wallet/GetBlockByNum2", encodeNumberMessage, decodeBlockExtention},
+	"gettransactioninfobyblocknum": {"/protocol.Wallet/GetTransactionInfoByBlockNum", encodeNumberMessage, decodeTransactionInfoList},
+	"broadcasttransaction":         {"/protocol.Wallet/BroadcastTransaction", encodeTransaction, decodeBroadcastReturn},
+}
+
+func grpcTargets(items []string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		if !strings.Contains(item, "://") {
+			item = "grpc://" + item
+		}
+		out[i] = item
+	}
+	return out
+}
+
+// grpcRoute 返回 REST 路径对应的 gRPC 调用，未配置 gRPC 节点或未开启该方法时返回 false
+func grpcRoute(path string) (grpcCall, bool) {
+	name := strings.TrimPrefix(path, "/wallet/")
+	if len(grpcUpstreams.list()) == 0 || !grpcMethods[name] {
+		return grpcCall{}, false
+	}
+	call, ok := grpcCalls[name]
+	return call, ok
+}
+
+// rawCodec 直接收发 protobuf 字节，省去生成 wallet.proto 的代码
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error)      { return v.([]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error { *v.(*[]byte) = data; return nil }
+func (rawCodec) Name() string                               { return "proto" }
+
+func (u *upstream) grpcConn() (*grpc.ClientConn, error) {
+	grpcConnsMu.Lock()
+	defer grpcConnsMu.Unlock()
+	if conn, ok := grpcConns[u.url]; ok {
+		return conn, nil
+	}
+	// grpcs:// 走 TLS，客户端证书和 CA 与 HTTP 上游相同
+	creds := insecure.NewCredentials()
+	if strings.HasPrefix(u.url, "grpcs://") {
+		cfg := upstreamTLSClone()
+		if cfg == nil {
+			cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	target := strings.TrimPrefix(strings.TrimPrefix(u.url, "grpc://"), "grpcs://")
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(64<<20)))
+	if err != nil {
+		return nil, err
+	}
+	grpcConns[u.url] = conn
+	return conn, nil
+}
+
+func (u *upstream) invokeGRPC(ctx context.Context, method string, in []byte) ([]byte, error) {
+	conn, err := u.grpcConn()
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	if err := conn.Invoke(ctx, method, in, &out, grpc.ForceCodec(rawCodec{})); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// postGRPC 对应 upstream.post，body 为 REST 请求体，返回的 Body 与 REST 网关格式一致
+func (u *upstream) postGRPC(ctx context.Context, path string, body []byte) (*upstreamResponse, error) {
+	call, ok := grpcCalls[strings.TrimPrefix(path, "/wallet/")]
+	if !ok {
+		return nil, fmt.Errorf("no gRPC mapping for %s", path)
+	}
+	in, err := call.encode(body)
+	if err != nil {
+		return nil, err
+	}
+	out, err := u.invokeGRPC(ctx, call.method, in)
+	if err != nil {
+		return nil, err
+	}
+	v, err := call.decode(body, out)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", call.method, err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamResponse{Body: b, Status: 200, Upstream: u}, nil
+}
+
+func (u *upstream) grpcHealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckClient.Timeout)
+	defer cancel()
+	_, err := u.invokeGRPC(ctx, "/protocol.Wallet/GetNowBlock2", nil)
+	return err
+}
+
+// postViaGRPC 先走 gRPC 节点；只读接口失败时退回 REST 网关，广播交易不会重复发送
+func postViaGRPC(ctx context.Context, call grpcCall, path string, body []byte) (*upstreamResponse, bool, error) {
+	if _, err := call.encode(body); err != nil {
+		// 请求无法转换成 protobuf 时交给 REST 网关处理
+		return nil, false, nil
+	}
+	resp, err := grpcUpstreams.send(ctx, path, "", body)
+	if err == nil || !idempotent("", path) || len(restUpstreams.list()) == 0 {
+		return resp, true, err
+	}
+	if ctx.Err() != nil {
+		return nil, true, ctx.Err()
+	}
+	log.Printf("gRPC %s failed, falling back to REST: %v", path, err)
+	return nil, false, nil
+}
+
+// 以下是 wallet.proto / Tron.proto 中用到的消息，字段号见 java-tron 的 protocol 定义
+
+type pbField struct {
+	num  protowire.Number
+	v    uint64
+	data []byte
+}
+
+func pbParse(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := pbField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x uint32
+			x, n = protowire.ConsumeFixed32(b)
+			f.v = uint64(x)
+		case protowire.Fixed64Type:
+			f.v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func encodeNumberMessage(body []byte) ([]byte, error) {
+	var p struct {
+		Num int64 `json:"num"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	var b []byte
+	if p.Num != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(p.Num))
+	}
+	return b, nil
+}
+
+// encodeTransaction 只需要 raw_data_hex 和签名，raw_data 的 JSON 形式不用重新编码
+func encodeTransaction(body []byte) ([]byte, error) {
+	var tx struct {
+		RawDataHex string   `json:"raw_data_hex"`
+		Signature  []string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &tx); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(tx.RawDataHex)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("raw_data_hex is required")
+	}
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, raw)
+	for _, s := range tx.Signature {
+		sig, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sig)
+	}
+	return b, nil
+}
+
+var broadcastCodes = map[uint64]string{
+	0: "SUCCESS", 1: "SIGERROR", 2: "CONTRACT_VALIDATE_ERROR", 3: "CONTRACT_EXE_ERROR",
+	4: "BANDWITH_ERROR", 5: "DUP_TRANSACTION_ERROR", 6: "TAPOS_ERROR", 7: "TOO_BIG_TRANSACTION_ERROR",
+	8: "TRANSACTION_EXPIRATION_ERROR", 9: "SERVER_BUSY", 10: "NO_CONNECTION",
+	11: "NOT_ENOUGH_EFFECTIVE_CONNECTION", 12: "BLOCK_UNSOLIDIFIED", 20: "OTHER_ERROR",
+}
+
+func decodeBroadcastReturn(body, resp []byte) (interface{}, error) {
+	fields, err := pbParse(resp)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	var ok bool
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			ok = f.v != 0
+		case 2:
+			if f.v != 0 {
+				out["code"] = broadcastCodes[f.v]
+			}
+		case 3:
+			out["message"] = hex.EncodeToString(f.data)
+		}
+	}
+	if ok {
+		out["result"] = true
+	}
+	// txid 与 REST 网关一致，为 raw_data 的 sha256
+	var tx struct {
+		RawDataHex string `json:"raw_data_hex"`
+	}
+	if json.Unmarshal(body, &tx) == nil {
+		if raw, err := hex.DecodeString(tx.RawDataHex); err == nil {
+			sum := sha256.Sum256(raw)
+			out["txid"] = hex.EncodeToString(sum[:])
+		}
+	}
+	return out, nil
+}
+
+var contractResults = map[uint64]string{
+	0: "DEFAULT", 1: "SUCCESS", 2: "REVERT", 3: "BAD_JUMP_DESTINATION", 4: "OUT_OF_MEMORY",
+	5: "PRECOMPILED_CONTRACT", 6: "STACK_TOO_SMALL", 7: "STACK_TOO_LARGE", 8: "ILLEGAL_OPERATION",
+	9: "STACK_OVERFLOW", 10: "OUT_OF_ENERGY", 11: "OUT_OF_TIME", 12: "JVM_STACK_OVER_FLOW",
+	13: "UNKNOWN", 14: "TRANSFER_FAILED", 15: "INVALID_CODE",
+}
+
+func decodeTransactionInfoList(_, resp []byte) (interface{}, error) {
+	fields, err := pbParse(resp)
+	if err != nil {
+		return nil, err
+	}
+	infos := []interface{}{}
+	for _, f := range fields {
+		if f.num != 1 {
+			continue
+		}
+		info, err := decodeTransactionInfo(f.data)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func decodeTransactionInfo(b []byte) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{}
+	var contractResult []string
+	var logs, internals []interface{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			info["id"] = hex.EncodeToString(f.data)
+		case 2:
+			info["fee"] = int64(f.v)
+		case 3:
+			info["blockNumber"] = int64(f.v)
+		case 4:
+			info["blockTimeStamp"] = int64(f.v)
+		case 5:
+			contractResult = append(contractResult, hex.EncodeToString(f.data))
+		case 6:
+			info["contract_address"] = hex.EncodeToString(f.data)
+		case 7:
+			if info["receipt"], err = decodeResourceReceipt(f.data); err != nil {
+				return nil, err
+			}
+		case 8:
+			l, err := decodeLog(f.data)
+			if err != nil {
+				return nil, err
+			}
+			logs = append(logs, l)
+		case 9:
+			if f.v == 1 {
+				info["result"] = "FAILED"
+			}
+		case 10:
+			info["resMessage"] = hex.EncodeToString(f.data)
+		case 14:
+			info["assetIssueID"] = string(f.data)
+		case 15:
+			info["withdraw_amount"] = int64(f.v)
+		case 16:
+			info["unfreeze_amount"] = int64(f.v)
+		case 17:
+			it, err := decodeInternalTransaction(f.data)
+			if err != nil {
+				return nil, err
+			}
+			internals = append(internals, it)
+		case 27:
+			info["packingFee"] = int64(f.v)
+		}
+	}
+	if contractResult != nil {
+		info["contractResult"] = contractResult
+	}
+	if logs != nil {
+		info["log"] = logs
+	}
+	if internals != nil {
+		info["internal_transactions"] = internals
+	}
+	return info, nil
+}
+
+func decodeResourceReceipt(b []byte) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	names := map[protowire.Number]string{
+		1: "energy_usage", 2: "energy_fee", 3: "origin_energy_usage", 4: "energy_usage_total",
+		5: "net_usage", 6: "net_fee", 8: "energy_penalty_total",
+	}
+	out := map[string]interface{}{}
+	for _, f := range fields {
+		if f.num == 7 {
+			out["result"] = contractResults[f.v]
+		} else if name, ok := names[f.num]; ok {
+			out[name] = int64(f.v)
+		}
+	}
+	return out, nil
+}
+
+// decodeLog 日志地址与 REST 网关一致，为不带 0x41 前缀的 20 字节 hex
+func decodeLog(b []byte) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	topics := []string{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			addr := f.data
+			if len(addr) == 21 && addr[0] == translate.AddressPrefix {
+				addr = addr[1:]
+			}
+			out["address"] = hex.EncodeToString(addr)
+		case 2:
+			topics = append(topics, hex.EncodeToString(f.data))
+		case 3:
+			out["data"] = hex.EncodeToString(f.data)
+		}
+	}
+	out["topics"] = topics
+	return out, nil
+}
+
+func decodeInternalTransaction(b []byte) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{}
+	var values []interface{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			out["hash"] = hex.EncodeToString(f.data)
+		case 2:
+			out["caller_address"] = hex.EncodeToString(f.data)
+		case 3:
+			out["transferTo_address"] = hex.EncodeToString(f.data)
+		case 4:
+			cv, err := pbParse(f.data)
+			if err != nil {
+				return nil, err
+			}
+			value := map[string]interface{}{}
+			for _, c := range cv {
+				switch c.num {
+				case 1:
+					value["callValue"] = int64(c.v)
+				case 2:
+					value["tokenId"] = string(c.data)
+				}
+			}
+			values = append(values, value)
+		case 5:
+			out["note"] = hex.EncodeToString(f.data)
+		case 6:
+			out["rejected"] = f.v != 0
+		case 7:
+			out["extra"] = string(f.data)
+		}
+	}
+	if values != nil {
+		out["callValueInfo"] = values
+	}
+	return out, nil
+}
+
+var contractTypes = map[uint64]string{
+	0: "AccountCreateContract", 1: "TransferContract", 2: "TransferAssetContract", 3: "VoteAssetContract",
+	4: "VoteWitnessContract", 5: "WitnessCreateContract", 6: "AssetIssueContract", 8: "WitnessUpdateContract",
+	9: "ParticipateAssetIssueContract", 10: "AccountUpdateContract", 11: "FreezeBalanceContract",
+	12: "UnfreezeBalanceContract", 13: "WithdrawBalanceContract", 14: "UnfreezeAssetContract",
+	15: "UpdateAssetContract", 16: "ProposalCreateContract", 17: "ProposalApproveContract",
+	18: "ProposalDeleteContract", 19: "SetAccountIdContract", 20: "CustomContract", 30: "CreateSmartContract",
+	31: "TriggerSmartContract", 32: "GetContract", 33: "UpdateSettingContract", 41: "ExchangeCreateContract",
+	42: "ExchangeInjectContract", 43: "ExchangeWithdrawContract", 44: "ExchangeTransactionContract",
+	45: "UpdateEnergyLimitContract", 46: "AccountPermissionUpdateContract", 48: "ClearABIContract",
+	49: "UpdateBrokerageContract", 51: "ShieldedTransferContract", 52: "MarketSellAssetContract",
+	53: "MarketCancelOrderContract", 54: "FreezeBalanceV2Contract", 55: "UnfreezeBalanceV2Contract",
+	56: "WithdrawExpireUnfreezeContract", 57: "DelegateResourceContract", 58: "UnDelegateResourceContract",
+	59: "CancelAllUnfreezeV2Contract",
+}
+
+// decodeBlockExtention 只还原代理内部用到的区块字段（见 restBlock），不是完整的 REST 输出
+func decodeBlockExtention(body, resp []byte) (interface{}, error) {
+	var p struct {
+		Visible bool `json:"visible"`
+	}
+	json.Unmarshal(body, &p)
+	visible := p.Visible
+	fields, err := pbParse(resp)
+	if err != nil {
+		return nil, err
+	}
+	block := map[string]interface{}{}
+	var txs []interface{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			tx, err := decodeTransactionExtention(f.data, visible)
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, tx)
+		case 2:
+			header, err := decodeBlockHeader(f.data, visible)
+			if err != nil {
+				return nil, err
+			}
+			block["block_header"] = header
+		case 3:
+			block["blockID"] = hex.EncodeToString(f.data)
+		}
+	}
+	if block["blockID"] == nil || block["blockID"] == "" {
+		// 区块不存在时 REST 返回空对象
+		return map[string]interface{}{}, nil
+	}
+	if txs != nil {
+		block["transactions"] = txs
+	}
+	return block, nil
+}
+
+func formatAddress(b []byte, visible bool) string {
+	if visible && len(b) == 21 && b[0] == translate.AddressPrefix {
+		return translate.Base58CheckEncode(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+func decodeBlockHeader(b []byte, visible bool) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]interface{}{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			rawFields, err := pbParse(f.data)
+			if err != nil {
+				return nil, err
+			}
+			raw := map[string]interface{}{}
+			for _, r := range rawFields {
+				switch r.num {
+				case 1:
+					raw["timestamp"] = int64(r.v)
+				case 2:
+					raw["txTrieRoot"] = hex.EncodeToString(r.data)
+				case 3:
+					raw["parentHash"] = hex.EncodeToString(r.data)
+				case 7:
+					raw["number"] = int64(r.v)
+				case 9:
+					raw["witness_address"] = formatAddress(r.data, visible)
+				case 10:
+					raw["version"] = int64(r.v)
+				}
+			}
+			header["raw_data"] = raw
+		case 2:
+			header["witness_signature"] = hex.EncodeToString(f.data)
+		}
+	}
+	return header, nil
+}
+
+func decodeTransactionExtention(b []byte, visible bool) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	tx := map[string]interface{}{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			if err := decodeTransaction(f.data, visible, tx); err != nil {
+				return nil, err
+			}
+		case 2:
+			tx["txID"] = hex.EncodeToString(f.data)
+		}
+	}
+	return tx, nil
+}
+
+func decodeTransaction(b []byte, visible bool, tx map[string]interface{}) error {
+	fields, err := pbParse(b)
+	if err != nil {
+		return err
+	}
+	var sigs []string
+	var rets []interface{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			raw, err := decodeTransactionRaw(f.data, visible)
+			if err != nil {
+				return err
+			}
+			tx["raw_data"] = raw
+			tx["raw_data_hex"] = hex.EncodeToString(f.data)
+		case 2:
+			sigs = append(sigs, hex.EncodeToString(f.data))
+		case 5:
+			retFields, err := pbParse(f.data)
+			if err != nil {
+				return err
+			}
+			ret := map[string]interface{}{}
+			for _, r := range retFields {
+				switch r.num {
+				case 1:
+					ret["fee"] = int64(r.v)
+				case 3:
+					ret["contractRet"] = contractResults[r.v]
+				}
+			}
+			rets = append(rets, ret)
+		}
+	}
+	if sigs != nil {
+		tx["signature"] = sigs
+	}
+	if rets != nil {
+		tx["ret"] = rets
+	}
+	return nil
+}
+
+func decodeTransactionRaw(b []byte, visible bool) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	var contracts []interface{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			raw["ref_block_bytes"] = hex.EncodeToString(f.data)
+		case 4:
+			raw["ref_block_hash"] = hex.EncodeToString(f.data)
+		case 8:
+			raw["expiration"] = int64(f.v)
+		case 10:
+			raw["data"] = hex.EncodeToString(f.data)
+		case 11:
+			c, err := decodeContract(f.data, visible)
+			if err != nil {
+				return nil, err
+			}
+			contracts = append(contracts, c)
+		case 14:
+			raw["timestamp"] = int64(f.v)
+		case 18:
+			raw["fee_limit"] = int64(f.v)
+		}
+	}
+	if contracts != nil {
+		raw["contract"] = contracts
+	}
+	return raw, nil
+}
+
+// decodeContract 只解出转账和合约调用的参数，其他类型只保留 owner_address（各合约的 1 号字段）
+func decodeContract(b []byte, visible bool) (map[string]interface{}, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, err
+	}
+	var typ uint64
+	var typeURL string
+	var value []byte
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			typ = f.v
+		case 2:
+			anyFields, err := pbParse(f.data)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range anyFields {
+				switch a.num {
+				case 1:
+					typeURL = string(a.data)
+				case 2:
+					value = a.data
+				}
+			}
+		}
+	}
+	valueFields, err := pbParse(value)
+	if err != nil {
+		return nil, err
+	}
+	v := map[string]interface{}{}
+	for _, f := range valueFields {
+		switch typ {
+		case 1: // TransferContract
+			switch f.num {
+			case 1:
+				v["owner_address"] = formatAddress(f.data, visible)
+			case 2:
+				v["to_address"] = formatAddress(f.data, visible)
+			case 3:
+				v["amount"] = int64(f.v)
+			}
+		case 2: // TransferAssetContract
+			switch f.num {
+			case 1:
+				v["asset_name"] = string(f.data)
+			case 2:
+				v["owner_address"] = formatAddress(f.data, visible)
+			case 3:
+				v["to_address"] = formatAddress(f.data, visible)
+			case 4:
+				v["amount"] = int64(f.v)
+			}
+		case 31: // TriggerSmartContract
+			switch f.num {
+			case 1:
+				v["owner_address"] = formatAddress(f.data, visible)
+			case 2:
+				v["contract_address"] = formatAddress(f.data, visible)
+			case 3:
+				v["call_value"] = int64(f.v)
+			case 4:
+				v["data"] = hex.EncodeToString(f.data)
+			}
+		default:
+			if f.num == 1 && f.data != nil {
+				v["owner_address"] = formatAddress(f.data, visible)
+			}
+		}
+	}
+	name, ok := contractTypes[typ]
+	if !ok {
+		name = fmt.Sprintf("ContractType(%d)", typ)
+	}
+	return map[string]interface{}{
+		"type":      name,
+		"parameter": map[string]interface{}{"type_url": typeURL, "value": v},
+	}, nil
+}
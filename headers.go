@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/yourname/proxy/translate"
+)
+
+type blockHeader struct {
+	Number     int64  `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// headerCache 缓存已确认（不高于固化高度）的区块头，按插入顺序淘汰。
+// 写入都经过 putConfirmed，回滚时的 dropFrom 只是兜底
+type headerCache struct {
+	mu    sync.Mutex
+	max   int
+	byNum map[int64]blockHeader
+	// 小写 hash 到高度，节点只支持按高度查询时用来解析 hash
+	byHash map[string]int64
+	order  []int64
+	// 见过的最高固化高度，只增不减，不高于它的区块不用再查节点
+	solid int64
+}
+
+var headers = newHeaderCache(envInt("HEADER_CACHE_SIZE", 100000))
+
+func init() {
+	events.subscribe("headers", 64, func(e event) {
+		headers.dropFrom(e.Data.(reorgEvent).Height)
+	}, EventReorg)
+}
+
+func newHeaderCache(max int) *headerCache {
+	return &headerCache{max: max, byNum: make(map[int64]blockHeader), byHash: make(map[string]int64)}
+}
+
+func (c *headerCache) get(num int64) (blockHeader, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.byNum[num]
+	return h, ok
+}
+
+func (c *headerCache) numberOf(hash string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.byHash[strings.ToLower(hash)]
+	return n, ok
+}
+
+func (c *headerCache) put(h blockHeader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.byNum[h.Number]; ok {
+		delete(c.byHash, strings.ToLower(old.Hash))
+		c.byNum[h.Number] = h
+		c.byHash[strings.ToLower(h.Hash)] = h.Number
+		return
+	}
+	c.byNum[h.Number] = h
+	c.byHash[strings.ToLower(h.Hash)] = h.Number
+	c.order = append(c.order, h.Number)
+	if len(c.order) > c.max {
+		c.deleteLocked(c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *headerCache) deleteLocked(num int64) {
+	delete(c.byHash, strings.ToLower(c.byNum[num].Hash))
+	delete(c.byNum, num)
+}
+
+// putConfirmed 高于固化高度的区块还可能被回滚，不写入；取不到固化高度时也不写入
+func (c *headerCache) putConfirmed(ctx context.Context, h blockHeader) {
+	c.mu.Lock()
+	solid := c.solid
+	c.mu.Unlock()
+	if h.Number > solid {
+		height, err := getSolidifiedHeight(ctx)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		if height > c.solid {
+			c.solid = height
+		}
+		c.mu.Unlock()
+		if h.Number > height {
+			return
+		}
+	}
+	c.put(h)
+}
+
+// dropFrom 删除 height 及以上的缓存区块头，用于回滚后
+func (c *headerCache) dropFrom(height int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.order[:0]
+	for _, n := range c.order {
+		if n >= height {
+			c.deleteLocked(n)
+			continue
+		}
+		kept = append(kept, n)
+	}
+	c.order = kept
+}
+
+type rpcBlockHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
+
+func fetchHeader(ctx context.Context, tag string) (blockHeader, error) {
+	return fetchHeaderWith(ctx, "eth_getBlockByNumber", tag)
+}
+
+func fetchHeaderByHash(ctx context.Context, hash string) (blockHeader, error) {
+	return fetchHeaderWith(ctx, "eth_getBlockByHash", hash)
+}
+
+func fetchHeaderWith(ctx context.Context, method, tag string) (blockHeader, error) {
+	result, err := callUpstream(ctx, method, tag, false)
+	if err != nil {
+		return blockHeader{}, err
+	}
+	var rb *rpcBlockHeader
+	if err := json.Unmarshal(result, &rb); err != nil {
+		return blockHeader{}, err
+	}
+	if rb == nil {
+		return blockHeader{}, fmt.Errorf("block %s not found", tag)
+	}
+	num, err := translate.ParseQuantity(rb.Number)
+	if err != nil {
+		return blockHeader{}, fmt.Errorf("invalid block number %q", rb.Number)
+	}
+	ts, err := translate.ParseQuantity(rb.Timestamp)
+	if err != nil {
+		return blockHeader{}, fmt.Errorf("invalid block timestamp %q", rb.Timestamp)
+	}
+	return blockHeader{Number: num, Hash: rb.Hash, ParentHash: rb.ParentHash, Timestamp: ts}, nil
+}
+
+func getHeader(ctx context.Context, num int64) (blockHeader, error) {
+	cache := headerCacheFor(ctx)
+	if h, ok := cache.get(num); ok {
+		return h, nil
+	}
+	h, err := fetchHeader(ctx, translate.HexQuantity(num))
+	if err != nil {
+		return blockHeader{}, err
+	}
+	cache.putConfirmed(ctx, h)
+	return h, nil
+}
+
+func headerCacheFor(ctx context.Context) *headerCache {
+	if n := networkFrom(ctx); n != nil {
+		return n.headers
+	}
+	return headers
+}
+
+// getHeaderByHash 先查本地缓存，调用方需要确认时仍应按高度核对是否在主链上
+func getHeaderByHash(ctx context.Context, hash string) (blockHeader, error) {
+	cache := headerCacheFor(ctx)
+	if n, ok := cache.numberOf(hash); ok {
+		if h, ok := cache.get(n); ok {
+			return h, nil
+		}
+	}
+	h, err := fetchHeaderByHash(ctx, hash)
+	if err != nil {
+		return blockHeader{}, err
+	}
+	cache.putConfirmed(ctx, h)
+	return h, nil
+}
+
+// latest 区块仍可能变化，不写入缓存
+func getLatestHeader(ctx context.Context) (blockHeader, error) {
+	return fetchHeader(ctx, "latest")
+}
+
+// findBlockByTimestamp 二分查找时间戳对应的区块（时间戳单位为秒）。
+// after=false 返回 timestamp <= ts 的最后一个区块，after=true 返回 timestamp >= ts 的第一个区块。
+func findBlockByTimestamp(ctx context.Context, ts int64, after bool) (*blockHeader, error) {
+	latest, err := getLatestHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := int64(0), latest.Number
+	if after {
+		if latest.Timestamp < ts {
+			return nil, nil
+		}
+		for lo < hi {
+			mid := lo + (hi-lo)/2
+			h, err := getHeader(ctx, mid)
+			if err != nil {
+				return nil, err
+			}
+			if h.Timestamp >= ts {
+				hi = mid
+			} else {
+				lo = mid + 1
+			}
+		}
+	} else {
+		if latest.Timestamp <= ts {
+			return &latest, nil
+		}
+		for lo < hi {
+			mid := lo + (hi-lo+1)/2
+			h, err := getHeader(ctx, mid)
+			if err != nil {
+				return nil, err
+			}
+			if h.Timestamp <= ts {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+	}
+	h, err := getHeader(ctx, lo)
+	if err != nil {
+		return nil, err
+	}
+	if (after && h.Timestamp < ts) || (!after && h.Timestamp > ts) {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func handleGetBlockByTimestamp(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params")
+	}
+	ts, err := parseInt64Param(req.Params[0])
+	if err != nil || ts < 0 {
+		return jsonError(req.ID, -32602, "Invalid params: timestamp must be a unix timestamp")
+	}
+	// 兼容 TRON 习惯的毫秒时间戳
+	if ts > 1e12 {
+		ts /= 1000
+	}
+	direction := "before"
+	if len(req.Params) > 1 {
+		if err := json.Unmarshal(req.Params[1], &direction); err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: direction must be \"before\" or \"after\"")
+		}
+	}
+	if direction != "before" && direction != "after" {
+		return jsonError(req.ID, -32602, "Invalid params: direction must be \"before\" or \"after\"")
+	}
+
+	log.Printf("Resolving block at timestamp=%d direction=%s", ts, direction)
+	h, err := findBlockByTimestamp(ctx, ts, direction == "after")
+	if err != nil {
+		log.Printf("Block by timestamp error: %v", err)
+		return errorResponse(req.ID, err)
+	}
+	if h == nil {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: nil}
+	}
+	return JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result: map[string]interface{}{
+			"number":    h.Number,
+			"hash":      h.Hash,
+			"timestamp": h.Timestamp,
+		},
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/tracestore"
+)
+
+var (
+	processStart = time.Now()
+
+	// 负载均衡和 Kubernetes 探针可能很频繁，结果缓存一小段时间，避免每次都打到上游
+	readyzCacheTTL = envDuration("READYZ_CACHE_TTL", 2*time.Second)
+
+	readyzMu     sync.Mutex
+	readyzLast   readyzReport
+	readyzLastAt time.Time
+)
+
+type dependencyStatus struct {
+	Name      string  `json:"name"`
+	Target    string  `json:"target"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readyzReport struct {
+	Ready        bool               `json:"ready"`
+	Reason       string             `json:"reason,omitempty"`
+	Mode         string             `json:"mode"`
+	CheckedAt    time.Time          `json:"checkedAt"`
+	Dependencies []dependencyStatus `json:"dependencies"`
+	// 时钟偏差默认只标记 degraded，不影响 ready，见 timesync.go
+	Degraded bool         `json:"degraded,omitempty"`
+	Clock    *clockStatus `json:"clock,omitempty"`
+}
+
+// handleHealthz 只说明进程活着，不检查上游，避免上游故障时被 liveness 探针反复重启
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":        "ok",
+		"uptimeSeconds": int64(time.Since(processStart).Seconds()),
+	})
+}
+
+// handleReadyz 实时探测每个上游节点和 trace 存储。每类上游至少有一个节点响应才算就绪，
+// 备用模式下始终返回 503
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := readyz(r.Context(), r.URL.Query().Get("fresh") == "1")
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func readyz(ctx context.Context, fresh bool) readyzReport {
+	readyzMu.Lock()
+	defer readyzMu.Unlock()
+	if !fresh && time.Since(readyzLastAt) < readyzCacheTTL {
+		return readyzLast
+	}
+
+	report := readyzReport{Ready: true, Mode: standby.status().Mode, CheckedAt: time.Now()}
+	pools := allUpstreamPools()
+	results := make([][]dependencyStatus, len(pools)+1)
+	var wg sync.WaitGroup
+	for i, p := range pools {
+		nodes := p.list()
+		results[i] = make([]dependencyStatus, len(nodes))
+		for j, u := range nodes {
+			i, j, u := i, j, u
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				start := time.Now()
+				err := u.healthCheck()
+				results[i][j] = dependencyResult("upstream:"+u.kind, u.label, start, err)
+			}()
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		start := time.Now()
+		results[len(pools)] = []dependencyStatus{dependencyResult("trace_store", traces.String(), start, checkTraceStore(ctx))}
+	}()
+	wg.Wait()
+
+	var failed []string
+	for i, deps := range results {
+		anyOK := false
+		for _, d := range deps {
+			report.Dependencies = append(report.Dependencies, d)
+			anyOK = anyOK || d.OK
+		}
+		if len(deps) > 0 && !anyOK {
+			if i < len(pools) {
+				failed = append(failed, pools[i].kind)
+			} else {
+				failed = append(failed, "trace_store")
+			}
+		}
+	}
+	if len(jsonrpcUpstreams.list()) == 0 {
+		failed = append(failed, "jsonrpc (not configured)")
+	}
+	if c := clock.status(); c != nil {
+		report.Clock, report.Degraded = c, c.Degraded
+	}
+	switch {
+	case report.Mode != "active":
+		report.Ready, report.Reason = false, "standby mode"
+	case len(failed) > 0:
+		report.Ready, report.Reason = false, "unavailable: "+strings.Join(failed, ", ")
+	case report.Degraded && clockSkewUnready:
+		report.Ready, report.Reason = false, "clock skew: "+report.Clock.Reason
+	}
+	readyzLast, readyzLastAt = report, time.Now()
+	return report
+}
+
+func dependencyResult(name, target string, start time.Time, err error) dependencyStatus {
+	d := dependencyStatus{Name: name, Target: target, OK: err == nil, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		d.Error = redactLine(err.Error())
+	}
+	return d
+}
+
+// checkTraceStore 读一个不存在的交易 ID：返回 not-exist 说明存储可访问。
+// 本地目录不存在时 Get 同样返回 not-exist，所以单独检查目录
+func checkTraceStore(ctx context.Context) error {
+	if local, ok := baseTraceStore(traces).(tracestore.Local); ok {
+		_, err := os.Stat(local.Dir)
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckClient.Timeout)
+	defer cancel()
+	_, err := traces.Get(ctx, strings.Repeat("0", 64))
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// 节点下线后需要连续成功这么多次（健康检查或实际请求）才重新加入轮询
+	upstreamRecoverySuccesses = envInt("UPSTREAM_RECOVERY_SUCCESSES", 3)
+	// UPSTREAM_FLAP_WINDOW 内状态变化达到 UPSTREAM_FLAP_THRESHOLD 次视为抖动：不再发布上下线事件，
+	// 恢复需要连续成功 UPSTREAM_FLAP_RECOVERY_SUCCESSES 次
+	upstreamFlapWindow            = envDuration("UPSTREAM_FLAP_WINDOW", 10*time.Minute)
+	upstreamFlapThreshold         = envInt("UPSTREAM_FLAP_THRESHOLD", 4)
+	upstreamFlapRecoverySuccesses = envInt("UPSTREAM_FLAP_RECOVERY_SUCCESSES", 10)
+	// 持久化的状态变化保留时长，内存中最多保留 SUPPORT_HEALTH_HISTORY 条
+	healthHistoryRetention = envDuration("UPSTREAM_HEALTH_HISTORY_RETENTION", 7*24*time.Hour)
+
+	upstreamHistory = newHealthHistory(envInt("SUPPORT_HEALTH_HISTORY", 500))
+)
+
+type healthTransition struct {
+	Time     time.Time `json:"time"`
+	Upstream string    `json:"upstream"`
+	Kind     string    `json:"kind"`
+	Healthy  bool      `json:"healthy"`
+	Error    string    `json:"error,omitempty"`
+	// 恢复时连续成功的次数
+	Successes int `json:"successes,omitempty"`
+	// 抖动期间的变化不发布事件
+	Flapping bool `json:"flapping,omitempty"`
+}
+
+type healthHistory struct {
+	mu     sync.Mutex
+	max    int
+	events []healthTransition
+
+	// 写入在单独的 goroutine 里按顺序进行，不阻塞持有节点锁的调用方
+	pending chan healthTransition
+	// 本地文件追加的行数，超过 2*max 时按内存内容重写
+	appended int
+}
+
+func newHealthHistory(max int) *healthHistory {
+	h := &healthHistory{max: max, pending: make(chan healthTransition, 256)}
+	go h.writeLoop()
+	return h
+}
+
+func (h *healthHistory) add(t healthTransition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, t)
+	if len(h.events) > h.max {
+		h.events = h.events[len(h.events)-h.max:]
+	}
+}
+
+func (h *healthHistory) snapshot() []healthTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]healthTransition(nil), h.events...)
+}
+
+// record 加入内存并排队持久化；队列满时只丢持久化，不阻塞请求
+func (h *healthHistory) record(t healthTransition) {
+	h.add(t)
+	select {
+	case h.pending <- t:
+	default:
+		log.Printf("Upstream health history queue full, transition of %s not persisted", t.Upstream)
+	}
+}
+
+func healthHistoryPath() string {
+	return filepath.Join(dataDir, "upstream-health.jsonl")
+}
+
+// load 启动时读入上次运行的记录，配置了存储时从数据库读
+func (h *healthHistory) load() {
+	cutoff := time.Now().Add(-healthHistoryRetention)
+	var loaded []healthTransition
+	if store != nil {
+		loaded = h.loadFromStore(cutoff)
+	} else {
+		loaded = h.loadFromFile(cutoff)
+	}
+	if len(loaded) > h.max {
+		loaded = loaded[len(loaded)-h.max:]
+	}
+	h.mu.Lock()
+	h.events = append(loaded, h.events...)
+	h.mu.Unlock()
+	if store == nil {
+		h.rewriteFile()
+	}
+	if len(loaded) > 0 {
+		log.Printf("Loaded %d upstream health transition(s) from previous runs", len(loaded))
+	}
+	// 重启前的变化也计入抖动窗口
+	now := time.Now()
+	for _, p := range allUpstreamPools() {
+		for _, u := range p.list() {
+			name := redactURL(u.url)
+			u.mu.Lock()
+			for _, t := range loaded {
+				if t.Upstream == name && t.Kind == u.kind && now.Sub(t.Time) < upstreamFlapWindow {
+					u.transitions = append(u.transitions, t.Time)
+				}
+			}
+			u.mu.Unlock()
+		}
+	}
+}
+
+func (h *healthHistory) loadFromStore(cutoff time.Time) []healthTransition {
+	ctx := context.Background()
+	if _, err := store.exec(ctx, "DELETE FROM upstream_health_events WHERE time < ?", cutoff.UnixMilli()); err != nil {
+		log.Printf("Error pruning upstream health history: %v", err)
+	}
+	rows, err := store.query(ctx, `SELECT time, upstream, kind, healthy, error, successes, flapping
+		FROM upstream_health_events ORDER BY time DESC LIMIT ?`, h.max)
+	if err != nil {
+		log.Printf("Error loading upstream health history from storage: %v", err)
+		return nil
+	}
+	defer rows.Close()
+	var out []healthTransition
+	for rows.Next() {
+		var t healthTransition
+		var ms int64
+		var healthy, flapping int
+		if err := rows.Scan(&ms, &t.Upstream, &t.Kind, &healthy, &t.Error, &t.Successes, &flapping); err != nil {
+			log.Printf("Error loading upstream health history from storage: %v", err)
+			return nil
+		}
+		t.Time, t.Healthy, t.Flapping = time.UnixMilli(ms), healthy == 1, flapping == 1
+		out = append(out, t)
+	}
+	// 查询按时间倒序，内存里按时间正序
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func (h *healthHistory) loadFromFile(cutoff time.Time) []healthTransition {
+	f, err := os.Open(healthHistoryPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading upstream health history: %v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+	var out []healthTransition
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var t healthTransition
+		if json.Unmarshal(scanner.Bytes(), &t) != nil || t.Time.Before(cutoff) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (h *healthHistory) writeLoop() {
+	for t := range h.pending {
+		var err error
+		if store != nil {
+			err = h.insert(t)
+		} else {
+			err = h.appendFile(t)
+		}
+		if err != nil {
+			log.Printf("Error persisting upstream health transition: %v", err)
+		}
+	}
+}
+
+func (h *healthHistory) insert(t healthTransition) error {
+	ctx := context.Background()
+	_, err := store.exec(ctx, `INSERT INTO upstream_health_events (time, upstream, kind, healthy, error, successes, flapping)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, t.Time.UnixMilli(), t.Upstream, t.Kind, boolInt(t.Healthy), t.Error, t.Successes, boolInt(t.Flapping))
+	if err != nil {
+		return err
+	}
+	_, err = store.exec(ctx, "DELETE FROM upstream_health_events WHERE time < ?", time.Now().Add(-healthHistoryRetention).UnixMilli())
+	return err
+}
+
+func (h *healthHistory) appendFile(t healthTransition) error {
+	if h.appended >= 2*h.max {
+		h.rewriteFile()
+		return nil
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(healthHistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, _ := json.Marshal(t)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	h.appended++
+	return nil
+}
+
+// rewriteFile 用内存中的记录替换文件，控制文件大小
+func (h *healthHistory) rewriteFile() {
+	var buf []byte
+	for _, t := range h.snapshot() {
+		line, _ := json.Marshal(t)
+		buf = append(append(buf, line...), '\n')
+	}
+	err := os.MkdirAll(dataDir, 0o755)
+	if err == nil {
+		tmp := healthHistoryPath() + ".tmp"
+		if err = os.WriteFile(tmp, buf, 0o644); err == nil {
+			err = os.Rename(tmp, healthHistoryPath())
+		}
+	}
+	if err != nil {
+		log.Printf("Error saving upstream health history: %v", err)
+		return
+	}
+	h.appended = 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// flappingLocked 丢掉窗口外的变化记录，返回是否处于抖动状态；调用方持有 u.mu
+func (u *upstream) flappingLocked(now time.Time) bool {
+	kept := u.transitions[:0]
+	for _, t := range u.transitions {
+		if now.Sub(t) < upstreamFlapWindow {
+			kept = append(kept, t)
+		}
+	}
+	u.transitions = kept
+	return upstreamFlapThreshold > 0 && len(u.transitions) >= upstreamFlapThreshold
+}
+
+// requiredSuccessesLocked 抖动中的节点恢复门槛更高
+func (u *upstream) requiredSuccessesLocked(now time.Time) int {
+	if u.flappingLocked(now) && upstreamFlapRecoverySuccesses > upstreamRecoverySuccesses {
+		return upstreamFlapRecoverySuccesses
+	}
+	return upstreamRecoverySuccesses
+}
+
+// transitionLocked 记录一次上下线；抖动期间只记历史，不发布事件，避免告警反复触发
+func (u *upstream) transitionLocked(healthy bool, errMsg string) {
+	now := time.Now()
+	wasFlapping := u.flappingLocked(now)
+	u.transitions = append(u.transitions, now)
+	flapping := u.flappingLocked(now)
+	upstreamHistory.record(healthTransition{
+		Time: now, Upstream: redactURL(u.url), Kind: u.kind, Healthy: healthy,
+		Error: redactLine(errMsg), Successes: u.recoveries, Flapping: flapping,
+	})
+	if flapping {
+		if !wasFlapping {
+			log.Printf("Upstream %s is flapping (%d transitions in %s), suppressing health events", u.url, len(u.transitions), upstreamFlapWindow)
+		}
+		return
+	}
+	if healthy {
+		events.publish(EventUpstreamHealthy, upstreamHealthyEvent{URL: u.url, Kind: u.kind})
+	} else {
+		events.publish(EventUpstreamUnhealthy, upstreamUnhealthyEvent{URL: u.url, Kind: u.kind, Error: errMsg})
+	}
+}
+
+type upstreamFlapState struct {
+	URL         string `json:"url"`
+	Kind        string `json:"kind"`
+	Healthy     bool   `json:"healthy"`
+	Transitions int    `json:"transitions"`
+	Flapping    bool   `json:"flapping"`
+	// 未恢复的节点已经连续成功的次数和需要的次数
+	Successes         int `json:"successes,omitempty"`
+	RequiredSuccesses int `json:"requiredSuccesses,omitempty"`
+}
+
+// handleAdminUpstreamHistory GET /admin/upstreams/history?upstream=&since=&limit=
+// since 为 RFC3339 时间或时长（如 1h），upstream 为脱敏后的 URL
+func handleAdminUpstreamHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+			since = t
+		} else {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be RFC3339 or a duration"})
+			return
+		}
+	}
+	limit := 100
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	// 最新的在前
+	history := []healthTransition{}
+	all := upstreamHistory.snapshot()
+	for i := len(all) - 1; i >= 0 && len(history) < limit; i-- {
+		t := all[i]
+		if t.Time.Before(since) {
+			break
+		}
+		if name := q.Get("upstream"); name != "" && t.Upstream != name {
+			continue
+		}
+		history = append(history, t)
+	}
+
+	now := time.Now()
+	nodes := []upstreamFlapState{}
+	for _, p := range allUpstreamPools() {
+		for _, u := range p.list() {
+			u.mu.Lock()
+			s := upstreamFlapState{URL: redactURL(u.url), Kind: u.kind, Healthy: u.healthy, Flapping: u.flappingLocked(now)}
+			s.Transitions = len(u.transitions)
+			if !u.healthy {
+				s.Successes, s.RequiredSuccesses = u.recoveries, u.requiredSuccessesLocked(now)
+			}
+			u.mu.Unlock()
+			nodes = append(nodes, s)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"history": history, "upstreams": nodes, "flapWindow": upstreamFlapWindow.String()})
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// upstreamClient 所有下游请求共用，复用连接并设置超时，避免慢节点把请求永久挂住
+var upstreamClient = newUpstreamClient()
+
+func newUpstreamClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   envDuration("UPSTREAM_DIAL_TIMEOUT", 5*time.Second),
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          envInt("UPSTREAM_MAX_IDLE_CONNS", 256),
+		MaxIdleConnsPerHost:   envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 64),
+		MaxConnsPerHost:       envInt("UPSTREAM_MAX_CONNS_PER_HOST", 0),
+		IdleConnTimeout:       envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second),
+		TLSHandshakeTimeout:   envDuration("UPSTREAM_TLS_TIMEOUT", 5*time.Second),
+		TLSClientConfig:       upstreamTLSClone(),
+		ResponseHeaderTimeout: envDuration("UPSTREAM_RESPONSE_HEADER_TIMEOUT", 30*time.Second),
+		ExpectContinueTimeout: time.Second,
+	}
+	transport.DisableCompression = !upstreamCompression
+	return &http.Client{
+		Transport: withUpstreamDecompression(transport),
+		Timeout:   envDuration("UPSTREAM_TIMEOUT", 60*time.Second),
+	}
+}
+
+// expressClient 使用独立的连接池，快速通道的请求不会排在大流量后面等连接
+var expressClient = newExpressClient()
+
+func newExpressClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 2 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   envInt("EXPRESS_MAX_IDLE_CONNS_PER_HOST", 4),
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   2 * time.Second,
+		TLSClientConfig:       upstreamTLSClone(),
+		ResponseHeaderTimeout: envDuration("EXPRESS_TIMEOUT", 5*time.Second),
+	}
+	transport.DisableCompression = !upstreamCompression
+	return &http.Client{
+		Transport: withUpstreamDecompression(transport),
+		Timeout:   envDuration("EXPRESS_TIMEOUT", 5*time.Second),
+	}
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+type txInfoPrefetchKey struct{}
+
+// prefetchTxInfos TRACE_GENERATE=txinfo 时一次取回整个区块的交易信息，逐笔生成 trace 时不再单独请求
+func prefetchTxInfos(ctx context.Context, block *restBlock, num int64) context.Context {
+	if traceGenerate != "txinfo" || len(block.Transactions) == 0 {
+		return ctx
+	}
+	var infos []translate.TxInfo
+	if err := callRest(ctx, "/wallet/gettransactioninfobyblocknum", map[string]interface{}{"num": num}, &infos); err != nil {
+		log.Printf("Cannot prefetch transaction info for block %d: %v", num, err)
+		return ctx
+	}
+	return withTxInfoPrefetch(ctx, block, infos)
+}
+
+func withTxInfoPrefetch(ctx context.Context, block *restBlock, infos []translate.TxInfo) context.Context {
+	txs := make(map[string]restTransaction, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txs[strings.ToLower(tx.TxID)] = tx
+	}
+	byID := make(map[string]translate.TxInfo, len(infos))
+	for _, info := range infos {
+		byID[strings.ToLower(info.ID)] = info
+	}
+	return context.WithValue(ctx, txInfoPrefetchKey{}, &txInfoPrefetch{txs: txs, infos: byID})
+}
+
+type txInfoPrefetch struct {
+	txs   map[string]restTransaction
+	infos map[string]translate.TxInfo
+}
+
+// traceFromTxInfo TRACE_GENERATE=txinfo：没有 trace 服务也没有支持 debug_traceTransaction 的节点时，
+// 用交易本身和 internal_transactions 拼出 callTracer 格式的结果。TRON 只记录内部调用的先后顺序，
+// 层级按调用方地址推断；内部调用没有 gas 和 input 信息
+func traceFromTxInfo(ctx context.Context, txID string) ([]byte, error) {
+	var tx restTransaction
+	var info translate.TxInfo
+	p, _ := ctx.Value(txInfoPrefetchKey{}).(*txInfoPrefetch)
+	prefetched := false
+	if p != nil {
+		tx, prefetched = p.txs[txID]
+		if prefetched {
+			info, prefetched = p.infos[txID]
+		}
+	}
+	if !prefetched {
+		if err := callRest(ctx, "/wallet/gettransactionbyid", map[string]interface{}{"value": txID, "visible": true}, &tx); err != nil {
+			return nil, err
+		}
+		if tx.TxID == "" {
+			return nil, fmt.Errorf("transaction %s not found", txID)
+		}
+		if err := callRest(ctx, "/wallet/gettransactioninfobyid", map[string]string{"value": txID}, &info); err != nil {
+			return nil, err
+		}
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("transaction %s not yet confirmed", txID)
+	}
+	frame, err := buildCallFrame(tx, info)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(frame)
+}
+
+func buildCallFrame(tx restTransaction, info translate.TxInfo) (*translate.CallFrame, error) {
+	if len(tx.RawData.Contract) == 0 {
+		return nil, fmt.Errorf("transaction %s has no contract", tx.TxID)
+	}
+	c := tx.RawData.Contract[0]
+	v := c.Parameter.Value
+	root := &translate.CallFrame{Type: "CALL", Gas: "0x0", GasUsed: translate.HexQuantity(info.Receipt.EnergyUsageTotal), Input: "0x" + v.Data}
+	root.From = translate.EthAddressOrEmpty(v.OwnerAddress)
+	switch c.Type {
+	case "CreateSmartContract":
+		root.Type = "CREATE"
+		root.To = translate.EthAddressOrEmpty(info.ContractAddress)
+	case "TriggerSmartContract":
+		root.To = translate.EthAddressOrEmpty(v.ContractAddress)
+		root.Value = translate.HexQuantity(v.CallValue)
+	default:
+		root.To = translate.EthAddressOrEmpty(v.ToAddress)
+		root.Value = translate.HexQuantity(v.Amount)
+	}
+	if len(info.ContractResult) > 0 && info.ContractResult[0] != "" {
+		root.Output = "0x" + info.ContractResult[0]
+	}
+	if r := info.Receipt.Result; r != "" && r != "SUCCESS" && r != "DEFAULT" {
+		root.Error = translate.ReceiptResultError(r)
+		if r == "REVERT" && info.ResMessage != "" {
+			root.RevertReason = up.DecodeMessage(info.ResMessage)
+		}
+	} else if info.Result == "FAILED" {
+		root.Error = up.DecodeMessage(info.ResMessage)
+	}
+	translate.NestInternalTxs(root, info.InternalTransactions)
+	return root, nil
+}
@@ -0,0 +1,257 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// inflight 记录正在处理的客户端请求，/admin/requests 列出
+var inflight = &inflightRegistry{reqs: make(map[*inflightRequest]struct{})}
+
+type inflightRegistry struct {
+	mu   sync.Mutex
+	reqs map[*inflightRequest]struct{}
+}
+
+type inflightRequest struct {
+	id     string
+	path   string
+	client string
+	start  time.Time
+
+	mu     sync.Mutex
+	method string
+	items  int
+	key    string
+}
+
+type inflightInfo struct {
+	RequestID string    `json:"requestId"`
+	Path      string    `json:"path"`
+	Client    string    `json:"client"`
+	Key       string    `json:"key,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Items     int       `json:"items,omitempty"`
+	Started   time.Time `json:"started"`
+	ElapsedMs float64   `json:"elapsedMs"`
+}
+
+type inflightCtxKey struct{}
+
+func (reg *inflightRegistry) track(ctx context.Context, r *http.Request, id string) (context.Context, func()) {
+	req := &inflightRequest{id: id, path: r.URL.Path, client: clientIP(r), start: time.Now()}
+	reg.mu.Lock()
+	reg.reqs[req] = struct{}{}
+	reg.mu.Unlock()
+	return context.WithValue(ctx, inflightCtxKey{}, req), func() {
+		reg.mu.Lock()
+		delete(reg.reqs, req)
+		reg.mu.Unlock()
+	}
+}
+
+// noteInflight 解析出方法之后补充到列表里；鉴权在 withRequestID 之后，key 也在这时取
+func noteInflight(ctx context.Context, method string, items int) {
+	req, ok := ctx.Value(inflightCtxKey{}).(*inflightRequest)
+	if !ok {
+		return
+	}
+	key := usageKeyLabel(ctx)
+	req.mu.Lock()
+	req.method, req.items, req.key = method, items, key
+	req.mu.Unlock()
+}
+
+// snapshot 按开始时间排序，最久的在前
+func (reg *inflightRegistry) snapshot() []inflightInfo {
+	reg.mu.Lock()
+	out := make([]inflightInfo, 0, len(reg.reqs))
+	for req := range reg.reqs {
+		req.mu.Lock()
+		out = append(out, inflightInfo{
+			RequestID: req.id, Path: req.path, Client: req.client, Key: req.key,
+			Method: req.method, Items: req.items, Started: req.start, ElapsedMs: msSince(req.start),
+		})
+		req.mu.Unlock()
+	}
+	reg.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// handleAdminRequests GET /admin/requests
+func handleAdminRequests(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, inflight.snapshot())
+}
+
+// handleAdminConfig GET /admin/config 返回脱敏后的生效配置（环境变量叠加 CONFIG_FILE）
+func handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	paths := []map[string]interface{}{}
+	for _, p := range jsonrpcPaths {
+		paths = append(paths, map[string]interface{}{"path": p.path, "auth": p.auth, "strict": p.strict})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"configFile": configFile, "values": redactedConfig(), "jsonrpcPaths": paths})
+}
+
+func allUpstreamPools() []*upstreamPool {
+	return append([]*upstreamPool{jsonrpcUpstreams, restUpstreams, grpcUpstreams, solidityUpstreams, archiveUpstreams, archiveRestUpstreams}, networkPools()...)
+}
+
+// handleAdminUpstreams GET /admin/upstreams
+func handleAdminUpstreams(w http.ResponseWriter, r *http.Request) {
+	out := map[string]interface{}{}
+	for _, p := range allUpstreamPools() {
+		nodes := p.status()
+		for i := range nodes {
+			nodes[i].URL = redactURL(nodes[i].URL)
+			nodes[i].LastError = redactLine(nodes[i].LastError)
+		}
+		out[p.name()] = nodes
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (u *upstream) isDraining() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.draining
+}
+
+// handleAdminUpstreamDrain POST /admin/upstreams/drain {"url": "...", "drain": true}
+// 摘除节点只影响新请求，已经发出的请求正常完成；drain=false 恢复。url 可以是脱敏后的形式
+func handleAdminUpstreamDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	body := struct {
+		URL   string `json:"url"`
+		Drain *bool  `json:"drain"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected {\"url\": \"...\", \"drain\": true|false}"})
+		return
+	}
+	drain := body.Drain == nil || *body.Drain
+	for _, p := range allUpstreamPools() {
+		nodes := p.list()
+		for _, u := range nodes {
+			if u.url != body.URL && redactURL(u.url) != body.URL {
+				continue
+			}
+			if drain && !u.isDraining() && activeNodes(nodes) <= 1 {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "refusing to drain the last active " + p.name() + " upstream"})
+				return
+			}
+			u.mu.Lock()
+			u.draining = drain
+			u.mu.Unlock()
+			if drain {
+				log.Printf("Upstream %s drained by admin request", redactURL(u.url))
+			} else {
+				log.Printf("Upstream %s restored by admin request", redactURL(u.url))
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"url": redactURL(u.url), "kind": p.name(), "draining": drain})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "upstream not found"})
+}
+
+func activeNodes(nodes []*upstream) int {
+	n := 0
+	for _, u := range nodes {
+		if !u.isDraining() {
+			n++
+		}
+	}
+	return n
+}
+
+// handleAdminCache GET /admin/cache 各缓存的统计
+func handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jsonrpc": responseCache.Stats(),
+		"rest":    restCache.Stats(),
+		"trace":   traceCache.Stats(),
+		"disk":    diskCache.Stats(),
+	})
+}
+
+// handleAdminCacheFlush POST /admin/cache/flush 清空全部响应缓存；按条件清除用 /admin/cache/invalidate
+func handleAdminCacheFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	removed := map[string]int{
+		"jsonrpc": responseCache.invalidate(cacheInvalidation{}),
+		"rest":    restCache.invalidate(cacheInvalidation{}),
+		"trace":   traceCache.flush(),
+		"disk":    diskCache.invalidate(cacheInvalidation{}),
+		"shared":  sharedCache.invalidate(r.Context(), cacheInvalidation{}),
+	}
+	sharedCache.broadcast(r.Context(), "jsonrpc", cacheInvalidation{})
+	sharedCache.broadcast(r.Context(), "rest", cacheInvalidation{})
+	log.Printf("Admin cache flush removed %v", removed)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"removed": removed})
+}
+
+type rateLimiterState struct {
+	Scope   string              `json:"scope"`
+	Enabled bool                `json:"enabled"`
+	Rate    float64             `json:"rate"`
+	Burst   float64             `json:"burst"`
+	Buckets int                 `json:"buckets"`
+	Lowest  []rateLimiterBucket `json:"lowest,omitempty"`
+}
+
+type rateLimiterBucket struct {
+	Subject string  `json:"subject"`
+	Tokens  float64 `json:"tokens"`
+}
+
+// state 列出剩余令牌最少的 limit 个桶；key 维度只显示名字或哈希，不暴露 key 本身
+func (l *rateLimiter) state(limit int) rateLimiterState {
+	lim := l.params()
+	s := rateLimiterState{Scope: l.scope, Enabled: lim.rate > 0, Rate: lim.rate, Burst: lim.burst}
+	now := time.Now()
+	l.mu.Lock()
+	s.Buckets = len(l.buckets)
+	for key, b := range l.buckets {
+		b.mu.Lock()
+		tokens := math.Min(lim.burst, b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+		b.mu.Unlock()
+		subject := key
+		if l.scope == "key" {
+			name := ""
+			if k := lookupAPIKey(key); k != nil {
+				name = k.Name
+			}
+			subject = apiKeyLabel(name, key)
+		}
+		s.Lowest = append(s.Lowest, rateLimiterBucket{Subject: subject, Tokens: math.Round(tokens*100) / 100})
+	}
+	l.mu.Unlock()
+	sort.Slice(s.Lowest, func(i, j int) bool { return s.Lowest[i].Tokens < s.Lowest[j].Tokens })
+	if len(s.Lowest) > limit {
+		s.Lowest = s.Lowest[:limit]
+	}
+	return s
+}
+
+// handleAdminRateLimits GET /admin/ratelimits?limit=20
+func handleAdminRateLimits(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, []rateLimiterState{rateLimitIP.state(limit), rateLimitKey.state(limit)})
+}
@@ -0,0 +1,748 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourname/proxy/rpc"
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+// JSONRPCRequest 定义在 rpc 包
+type JSONRPCRequest = rpc.Request
+
+type JSONRPCResponse struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	ID      interface{} `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   interface{} `json:"error,omitempty"`
+	// 代理附加的扩展信息，只在客户端要求时返回
+	Proxy *proxyMeta `json:"_proxy,omitempty"`
+	// 结果里有占位的内容（例如没算出来的 logsBloom），可以返回但不能缓存
+	noCache bool
+}
+
+// MarshalJSON 成功的响应必须带 result 字段，结果为 null 时（例如还没上链的交易回执）omitempty 会把它去掉
+func (r JSONRPCResponse) MarshalJSON() ([]byte, error) {
+	type plain JSONRPCResponse
+	if r.Result != nil || r.Error != nil {
+		return codec.Marshal(plain(r))
+	}
+	return codec.Marshal(struct {
+		plain
+		Result json.RawMessage `json:"result"`
+	}{plain(r), json.RawMessage("null")})
+}
+
+type proxyMeta struct {
+	BlockContext *blockContext     `json:"blockContext,omitempty"`
+	Consistency  *traceConsistency `json:"consistency,omitempty"`
+	Debug        *debugInfo        `json:"debug,omitempty"`
+	Fallback     *fallbackInfo     `json:"fallback,omitempty"`
+	Stale        *staleInfo        `json:"stale,omitempty"`
+}
+
+var (
+	// 默认的本地 trace 目录，可以用 TRACE_STORE 覆盖
+	traceDir = "/project/trace"
+)
+
+func handleJSONRPC(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&metricInflight, 1)
+	defer atomic.AddInt64(&metricInflight, -1)
+	// 客户端断开时取消下游请求和 trace 读取
+	ctx := r.Context()
+	if r.Header.Get("X-Proxy-Block-Context") == "true" {
+		ctx = context.WithValue(ctx, blockContextCtxKey{}, true)
+	}
+	ctx = withAddressFormat(ctx, r)
+	ctx = withDebugFlag(ctx, r)
+	ctx = withPinMode(ctx, r)
+	ctx = withClientProfile(ctx, r)
+	ctx = withResultFields(ctx, r)
+	w = withResponseFormat(w, r)
+
+	limitBody(w, r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("Request body exceeds %d bytes, rejected", tooLarge.Limit)
+			sendBodyTooLarge(w)
+			return
+		}
+		log.Printf("Error reading request body: %v", err)
+		sendError(w, nil, -32603, "Internal error: unable to read request body")
+		return
+	}
+
+	// 打印原始请求体日志
+	log.Printf("Incoming request body: %s", string(body))
+
+	var raw interface{}
+	if err := codec.Unmarshal(body, &raw); err != nil {
+		log.Printf("JSON parse error: %v", err)
+		sendError(w, nil, -32700, "Parse error: invalid JSON")
+		return
+	}
+
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		// 单请求
+		log.Println("Detected single JSON-RPC request")
+		req, perr := parseSingleRequest(v)
+		if perr != nil {
+			log.Printf("Parse single request error: %v", perr)
+			sendError(w, v["id"], -32600, "Invalid Request: malformed request object")
+			return
+		}
+		if !strictRequests(ctx) {
+			req.Jsonrpc = "2.0"
+		}
+		noteInflight(ctx, req.Method, 0)
+		single := []JSONRPCRequest{req}
+		pinned, isPinned := pinLatest(ctx, single)
+		if isPinned {
+			req = single[0]
+			w.Header().Set("X-Proxy-Pinned-Block", translate.HexQuantity(pinned))
+		}
+		if streamSingle(ctx, w, r, req) {
+			log.Printf("Single request streamed: %s", r.URL.Path)
+			return
+		}
+		resp := handleSingleRequest(ctx, req)
+		if req.Notification {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if isPinned {
+			responses := []JSONRPCResponse{resp}
+			retryPinnedAsLatest(ctx, single, responses, pinned)
+			resp = responses[0]
+		}
+		setStaleHeader(w, resp)
+		sendJSONRPCResponse(w, resp)
+		// 打印响应日志
+		log.Printf("Single request response: %s", r.URL.Path)
+
+	case []interface{}:
+		// 批处理请求
+		if len(v) == 0 {
+			log.Println("Batch request but empty array, returning empty[]")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+			return
+		}
+		log.Printf("Detected batch JSON-RPC request with %d items", len(v))
+		if batchTooLarge(len(v)) {
+			log.Printf("Batch of %d items exceeds limit %d, rejected", len(v), jsonrpcMaxBatch)
+			sendError(w, nil, -32600, fmt.Sprintf("Batch too large: %d items (max %d)", len(v), jsonrpcMaxBatch))
+			return
+		}
+		if ok, wait, scope := chargeBatch(ctx, len(v)); !ok {
+			log.Printf("Batch of %d items rate limited (%s)", len(v), scope)
+			writeRateLimited(w, wait, scope)
+			return
+		}
+
+		stream := wantsNDJSON(r) && responseFormat(r) == ""
+		reqs, errs := parseBatchRequests(v)
+		if errs != nil {
+			log.Printf("Batch parse error, items with parse fail: %d", len(errs))
+			sendBatch(w, stream, nil, errs)
+			return
+		}
+		if !strictRequests(ctx) {
+			for i := range reqs {
+				reqs[i].Jsonrpc = "2.0"
+				v[i] = reqs[i]
+			}
+		}
+
+		// 检查method一致
+		allMethod := reqs[0].Method
+		for _, r := range reqs {
+			if r.Method != allMethod {
+				log.Println("Mixed methods in batch not supported, returning error")
+				sendBatch(w, stream, reqs, createErrorResponsesForBatch(reqs, -32601, "Mixed methods not supported"))
+				return
+			}
+		}
+		noteInflight(ctx, allMethod, len(reqs))
+		if reason := checkMethodPolicy(ctx, allMethod); reason != "" {
+			sendBatch(w, stream, reqs, createErrorResponsesForBatch(reqs, -32601, reason))
+			return
+		}
+		release, exceeded := reserveQuota(ctx, computeUnits(methodLabel(allMethod))*int64(len(reqs)))
+		if exceeded != nil {
+			logQuotaRejected(ctx, allMethod, exceeded)
+			responses := make([]JSONRPCResponse, len(reqs))
+			for i, r := range reqs {
+				responses[i] = exceeded.response(r.ID)
+			}
+			sendBatch(w, stream, reqs, responses)
+			return
+		}
+		// 记下用量之后才释放预留
+		defer release()
+
+		if hasBlockTagRule(allMethod) {
+			for i := range reqs {
+				reqs[i] = applyBlockTagRules(ctx, reqs[i])
+				v[i] = reqs[i]
+			}
+		}
+
+		ctx = withArchiveRoute(ctx, reqs...)
+		ctx = withLagTolerance(ctx, reqs...)
+
+		if stream {
+			log.Printf("Streaming batch of %d items as NDJSON", len(reqs))
+			n := streamBatch(ctx, w, reqs)
+			log.Printf("Batch request response items: %d", n)
+			return
+		}
+
+		// 每条请求自己的 fields 提示按 id 对应，转发的批量响应顺序不一定和请求一致
+		var fieldHints map[string]*fieldSelection
+		for i := range reqs {
+			if req, hint := takeFieldsHint(reqs[i]); hint != nil {
+				if fieldHints == nil {
+					fieldHints = make(map[string]*fieldSelection)
+				}
+				fieldHints[idKey(req.ID)] = hint
+				reqs[i] = req
+				v[i] = req
+			}
+		}
+
+		if addressFormatFor(ctx, allMethod) != "" {
+			for i := range reqs {
+				reqs[i] = normalizeRequestAddresses(ctx, reqs[i])
+				v[i] = reqs[i]
+			}
+		}
+
+		pinned, isPinned := pinLatest(ctx, reqs)
+		if isPinned {
+			for i := range reqs {
+				v[i] = reqs[i]
+			}
+			w.Header().Set("X-Proxy-Pinned-Block", translate.HexQuantity(pinned))
+		}
+
+		// 根据method分类处理
+		start := time.Now()
+		parent := ctx
+		ctx, cancel := withMethodTimeout(ctx, allMethod)
+		defer cancel()
+		ctx, dbg := startDebug(ctx, "batch")
+		var responses []JSONRPCResponse
+		if replayer != nil {
+			responses = replayBatch(ctx, reqs)
+		} else {
+			switch allMethod {
+			case "debug_traceBlockByHash":
+				log.Printf("Batch method getTransactionInfoByBlockNum, requests: %d", len(reqs))
+				responses = handleBatchGetTransactionInfo(ctx, reqs)
+			case "eth_debugTransactionTrace":
+				log.Printf("Batch method eth_debugTransactionTrace, requests: %d", len(reqs))
+				responses = handleBatchDebugTransactionTrace(ctx, reqs)
+			case "eth_call", "eth_estimateGas":
+				if !triggerCallLocal() && !hasBlockHashParam(reqs) {
+					responses = forwardBatchWithCache(ctx, reqs, v)
+					break
+				}
+				// 本地翻译和带区块上下文的调用需要逐条处理
+				responses = make([]JSONRPCResponse, len(reqs))
+				workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+					responses[i] = dispatchRequest(ctx, reqs[i])
+				})
+				fillCancelled(reqs, responses)
+			default:
+				if _, ok := localMethods[allMethod]; ok {
+					// 由代理自己组装结果的方法，逐条走单请求的处理和缓存
+					responses = make([]JSONRPCResponse, len(reqs))
+					wctx := inWorker(ctx)
+					workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+						responses[i] = withCache(wctx, reqs[i], dispatchRequest)
+					})
+					fillCancelled(reqs, responses)
+					break
+				}
+				log.Printf("Batch method %s not recognized (third category), directly forwarding batch", allMethod)
+				responses = forwardBatchWithCache(ctx, reqs, v)
+			}
+			recordBatch(reqs, responses, time.Since(start))
+		}
+		dbg.stage("dispatch", start)
+		stageStart := time.Now()
+
+		if timedOut(ctx, parent) {
+			log.Printf("Batch %s timed out after %s", allMethod, methodTimeout(allMethod))
+			for i := range responses {
+				if responses[i].Error != nil || responses[i].Jsonrpc == "" {
+					responses[i] = timeoutError(reqs[i].ID, allMethod)
+				}
+			}
+		}
+		if isPinned {
+			retryPinnedAsLatest(ctx, reqs, responses, pinned)
+		}
+		attachBlockContexts(ctx, reqs, responses)
+		checkTraceConsistencies(ctx, reqs, responses)
+		for i := range responses {
+			normalizeResponseAddresses(ctx, allMethod, &responses[i])
+			applyClientProfile(ctx, allMethod, &responses[i])
+			applyResultFields(ctx, fieldHints[idKey(responses[i].ID)], &responses[i])
+		}
+		dbg.stage("postprocess", stageStart)
+		if len(responses) > 0 {
+			// 批量请求的调试信息只附加在第一条上
+			dbg.attach(&responses[0])
+		}
+		slowLog.observe(ctx, dbg, allMethod, reqs, responses)
+		observeBatch(allMethod, start, responses)
+		recordBatchUsage(ctx, allMethod, responses)
+		setStaleHeader(w, responses...)
+		sendBatch(w, stream, reqs, responses)
+		// 打印批处理响应日志
+		log.Printf("Batch request response items: %d", len(responses))
+
+	default:
+		log.Println("Invalid JSON structure, not single or batch array")
+		sendError(w, nil, -32700, "Parse error: invalid structure")
+	}
+}
+
+func parseSingleRequest(v map[string]interface{}) (JSONRPCRequest, error) {
+	reqBytes, _ := codec.Marshal(v)
+	var req JSONRPCRequest
+	if err := codec.Unmarshal(reqBytes, &req); err != nil {
+		return JSONRPCRequest{}, err
+	}
+	_, hasID := v["id"]
+	req.Notification = !hasID
+	return req, nil
+}
+
+func parseBatchRequests(arr []interface{}) ([]JSONRPCRequest, []JSONRPCResponse) {
+	reqs := make([]JSONRPCRequest, 0, len(arr))
+	var errors []JSONRPCResponse
+	for _, elem := range arr {
+		// 元素本身已经是合法 JSON，解析失败属于 Invalid Request；能取到 id 时带上原来的 id
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			errors = append(errors, jsonError(nil, -32600, "Invalid Request: batch item must be an object"))
+			continue
+		}
+		req, err := parseSingleRequest(obj)
+		if err != nil {
+			errors = append(errors, jsonError(obj["id"], -32600, "Invalid Request: malformed request object"))
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return reqs, nil
+}
+
+func handleSingleRequest(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	log.Printf("handleSingleRequest - method=%s, id=%v", req.Method, req.ID)
+	start := time.Now()
+	ctx, dbg := startDebug(ctx, "single")
+	req, fields := takeFieldsHint(req)
+	var resp JSONRPCResponse
+	if req.Jsonrpc != "2.0" {
+		resp = jsonError(req.ID, -32600, "Invalid Request")
+	} else if reason := checkMethodPolicy(ctx, req.Method); reason != "" {
+		resp = jsonError(req.ID, -32601, reason)
+	} else if release, exceeded := reserveQuota(ctx, computeUnits(methodLabel(req.Method))); exceeded != nil {
+		logQuotaRejected(ctx, req.Method, exceeded)
+		resp = exceeded.response(req.ID)
+	} else {
+		// 记下用量之后才释放预留
+		defer release()
+		req = applyBlockTagRules(ctx, req)
+		req = normalizeRequestAddresses(ctx, req)
+		ctx = withArchiveRoute(ctx, req)
+		ctx = withLagTolerance(ctx, req)
+		stageStart := time.Now()
+		dctx, cancel := withMethodTimeout(ctx, req.Method)
+		resp = dispatchRecorded(req, func() JSONRPCResponse { return withCache(dctx, req, dispatchRequest) })
+		if timedOut(dctx, ctx) && !isStale(resp) {
+			resp = timeoutError(req.ID, req.Method)
+		}
+		cancel()
+		dbg.stage("dispatch", stageStart)
+		stageStart = time.Now()
+		attachBlockContext(ctx, req, &resp)
+		checkTraceConsistency(ctx, req, &resp)
+		normalizeResponseAddresses(ctx, req.Method, &resp)
+		dbg.stage("postprocess", stageStart)
+	}
+	applyClientProfile(ctx, req.Method, &resp)
+	applyResultFields(ctx, fields, &resp)
+	dbg.attach(&resp)
+	slowLog.observe(ctx, dbg, req.Method, []JSONRPCRequest{req}, []JSONRPCResponse{resp})
+	observeRequest(req.Method, "single", start, resp)
+	recordUsage(ctx, req.Method, resp)
+	return resp
+}
+
+// localMethods 由代理自己处理的方法，单请求和批量请求共用这张表，其余方法透传到下游。
+// 部分处理函数会回头调用 dispatchRequest，所以放在 init 里填
+var localMethods map[string]func(context.Context, JSONRPCRequest) JSONRPCResponse
+
+func init() {
+	localMethods = map[string]func(context.Context, JSONRPCRequest) JSONRPCResponse{
+		"debug_traceBlockByHash":          handleDebugTraceBlockByHash,
+		"debug_traceBlockByNumber":        handleDebugTraceBlockByNumber,
+		"debug_traceTransaction":          handleDebugTraceTransaction,
+		"eth_debugTransactionTrace":       handleDebugTransactionTrace,
+		"eth_call":                        handleEthCall,
+		"eth_estimateGas":                 handleEstimateGas,
+		"eth_sendRawTransaction":          handleSendRawTransaction,
+		"eth_getLogs":                     handleGetLogs,
+		"proxy_getLogsPage":               handleGetLogsPage,
+		"eth_getBlockByNumber":            handleGetBlock,
+		"eth_getBlockByHash":              handleGetBlock,
+		"eth_newFilter":                   handleFilterMethod,
+		"eth_newBlockFilter":              handleFilterMethod,
+		"eth_newPendingTransactionFilter": handleFilterMethod,
+		"eth_getFilterChanges":            handleFilterMethod,
+		"eth_getFilterLogs":               handleFilterMethod,
+		"eth_uninstallFilter":             handleFilterMethod,
+		"proxy_getBlockByTimestamp":       handleGetBlockByTimestamp,
+		"proxy_getBalanceHistory":         handleGetBalanceHistory,
+		"proxy_getAddressSummary":         handleGetAddressSummary,
+		"proxy_getContractCreation":       handleGetContractCreation,
+		"proxy_waitForReceipt":            handleWaitForReceipt,
+		"proxy_getTransactionInfoRange":   handleGetTransactionInfoRange,
+		"proxy_simulateTransaction":       handleSimulateTransaction,
+		"proxy_subscribeWebhook":          handleSubscribeWebhook,
+		"proxy_unsubscribeWebhook":        handleUnsubscribeWebhook,
+		"rpc.discover":                    handleRPCDiscover,
+	}
+}
+
+func dispatchRequest(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if h, ok := localMethods[req.Method]; ok {
+		return h(ctx, req)
+	}
+	// 透传到下游
+	return forwardAndReturn(ctx, req)
+}
+
+type TronTransactionInfo struct {
+	InternalTransactions []json.RawMessage `json:"internal_transactions"`
+	Id                   string            `json:"id"`
+	BlockNumber          int64             `json:"blockNumber"`
+	TransactionHash      string            `json:"transactionHash"`
+}
+
+func handleGetTransactionInfoByBlockNum(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params")
+	}
+	var blockId int64
+	if err := codec.Unmarshal(req.Params[0], &blockId); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: must be integer block number")
+	}
+
+	postData := map[string]interface{}{
+		"num": blockId,
+	}
+	postBytes, _ := codec.Marshal(postData)
+	log.Printf("REST call for blockNum=%d", blockId)
+	resp, err := restPool(ctx, blockId).post(ctx, "/wallet/gettransactioninfobyblocknum", postBytes)
+	if err != nil {
+		log.Printf("REST request error for block %d: %v", blockId, err)
+		errResp := errorResponse(req.ID, err)
+		setErrorData(&errResp, "block", blockId)
+		return errResp
+	}
+	log.Printf("REST response from %s code=%d, body=%s", resp.Upstream.url, resp.Status, string(resp.Body))
+
+	var respJson []TronTransactionInfo
+	if err := codec.Unmarshal(resp.Body, &respJson); err != nil {
+		observeUpstreamInvalid(resp.Upstream.label)
+		log.Printf("Invalid REST response for block %d from %s (HTTP %d): %s", blockId, resp.Upstream.label, resp.Status, up.Snippet(resp.Body))
+		return invalidRestResponse(req.ID, blockId, resp.Upstream, resp.Status, resp.Body)
+	}
+
+	return JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  respJson,
+	}
+}
+
+func handleDebugTransactionTrace(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params")
+	}
+	txId, err := parseTxIDParam(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+
+	log.Printf("Reading trace file for txId=%s", txId)
+	fileData, err := loadTrace(ctx, txId)
+	if errors.Is(err, errInvalidTraceJSON) {
+		return jsonError(req.ID, -32603, "Invalid JSON in trace file")
+	}
+	if err != nil {
+		log.Printf("Error reading file: %v", err)
+		return jsonError(req.ID, -32603, "cannot read trace file")
+	}
+
+	// 原样透传，不解码成 interface{}
+	return JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  json.RawMessage(fileData),
+	}
+}
+
+func forwardAndReturn(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	log.Printf("Forwarding single request, method=%s, id=%v", req.Method, req.ID)
+	reqBytes, _ := codec.Marshal(req)
+	resp, err := jsonrpcPool(ctx).send(ctx, "", req.Method, reqBytes)
+	if err == errMethodUnsupported {
+		if emulate, ok := emulatedMethods[req.Method]; ok {
+			log.Printf("No upstream supports %s, emulating", req.Method)
+			return emulate(ctx, req)
+		}
+		return jsonError(req.ID, -32601, "Method not found: "+err.Error())
+	}
+	if err != nil {
+		log.Printf("Forward request error: %v", err)
+		if fb, ok := fallbackResponse(req, err); ok {
+			return fb
+		}
+		return errorResponse(req.ID, err)
+	}
+	// log.Printf("Forwarded response code=%d, body=%s", resp.Status, string(resp.Body))
+
+	var forwardResp JSONRPCResponse
+	err = codec.Unmarshal(resp.Body, &forwardResp)
+	// REST 风格的 {"Error": "..."} 也能解码成功（字段名不区分大小写），error 是字符串而不是对象
+	_, errorObject := forwardResp.Error.(map[string]interface{})
+	if err != nil || (forwardResp.Jsonrpc == "" && forwardResp.Result == nil && !errorObject) {
+		observeUpstreamInvalid(resp.Upstream.label)
+		return upstreamErrorResponse(up.Classify(resp.Status, resp.Body), req.ID)
+	}
+	if emulate, ok := emulatedMethods[req.Method]; ok && errorObject && isMethodNotFound(forwardResp.Error.(map[string]interface{})) {
+		// 能力探测之前或节点升级后不再支持
+		log.Printf("Upstream %s does not support %s, emulating", resp.Upstream.label, req.Method)
+		return emulate(ctx, req)
+	}
+	applyShims(resp.Upstream, req.Method, &forwardResp)
+	forwardResp.ID = req.ID
+	return forwardResp
+}
+
+// callUpstream 供内部方法复用的下游 JSON-RPC 调用，返回 result 原始字节
+func callUpstream(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	rawParams := make([]json.RawMessage, len(params))
+	for i, p := range params {
+		b, err := codec.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		rawParams[i] = b
+	}
+	req := JSONRPCRequest{Jsonrpc: "2.0", Method: method, Params: rawParams, ID: 1}
+	resp := forwardAndReturn(ctx, req)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("%s failed: %v", method, resp.Error)
+	}
+	return codec.Marshal(resp.Result)
+}
+
+func handleBatchGetTransactionInfo(ctx context.Context, reqs []JSONRPCRequest) []JSONRPCResponse {
+	responses := make([]JSONRPCResponse, len(reqs))
+	wctx := inWorker(ctx)
+	workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+		responses[i] = withCache(wctx, reqs[i], handleDebugTraceBlockByHash)
+	})
+	fillCancelled(reqs, responses)
+	return responses
+}
+
+// fillCancelled 为取消后未调度的条目补上错误响应
+func fillCancelled(reqs []JSONRPCRequest, responses []JSONRPCResponse) {
+	for i := range responses {
+		if responses[i].Jsonrpc == "" {
+			responses[i] = jsonError(reqs[i].ID, -32603, "Request cancelled")
+		}
+	}
+}
+
+func handleBatchDebugTransactionTrace(ctx context.Context, reqs []JSONRPCRequest) []JSONRPCResponse {
+	responses := make([]JSONRPCResponse, len(reqs))
+	workers.run(ctx, len(reqs), batchConcurrency, func(idx int) {
+		if ctx.Err() != nil {
+			responses[idx] = jsonError(reqs[idx].ID, -32603, "Request cancelled")
+			return
+		}
+		if len(reqs[idx].Params) == 0 {
+			responses[idx] = jsonError(reqs[idx].ID, -32602, "Invalid params")
+			return
+		}
+		txId, err := parseTxIDParam(reqs[idx].Params[0])
+		if err != nil {
+			responses[idx] = jsonError(reqs[idx].ID, -32602, "Invalid params: "+err.Error())
+			return
+		}
+		log.Printf("Reading trace file(batch) for txId=%s", txId)
+
+		fileData, err := loadTrace(ctx, txId)
+		if errors.Is(err, errInvalidTraceJSON) {
+			responses[idx] = jsonError(reqs[idx].ID, -32603, "Invalid JSON in trace file")
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading file in batch: %v", err)
+			responses[idx] = jsonError(reqs[idx].ID, -32603, "cannot read trace file")
+			return
+		}
+
+		responses[idx] = JSONRPCResponse{
+			Jsonrpc: "2.0",
+			ID:      reqs[idx].ID,
+			Result:  json.RawMessage(fileData),
+		}
+	})
+	fillCancelled(reqs, responses)
+	return responses
+}
+
+// forwardBatchToJSONRPC 超过 UPSTREAM_MAX_BATCH 时拆成多个子批量并发转发，响应按子批量的顺序拼接
+func forwardBatchToJSONRPC(ctx context.Context, reqs []JSONRPCRequest, originalArr []interface{}) []JSONRPCResponse {
+	if upstreamMaxBatch <= 0 || len(reqs) <= upstreamMaxBatch {
+		return forwardSubBatch(ctx, reqs, originalArr)
+	}
+	n := (len(reqs) + upstreamMaxBatch - 1) / upstreamMaxBatch
+	log.Printf("Splitting batch request (length=%d) into %d sub-batches", len(reqs), n)
+	bounds := func(i int) (int, int) {
+		to := (i + 1) * upstreamMaxBatch
+		if to > len(reqs) {
+			to = len(reqs)
+		}
+		return i * upstreamMaxBatch, to
+	}
+	parts := make([][]JSONRPCResponse, n)
+	workers.run(ctx, n, batchConcurrency, func(i int) {
+		from, to := bounds(i)
+		parts[i] = forwardSubBatch(ctx, reqs[from:to], originalArr[from:to])
+	})
+	out := make([]JSONRPCResponse, 0, len(reqs))
+	for i, part := range parts {
+		if part == nil {
+			// 取消后未发送的子批量
+			from, to := bounds(i)
+			part = createErrorResponsesForBatch(reqs[from:to], -32603, "Request cancelled")
+		}
+		out = append(out, part...)
+	}
+	return out
+}
+
+func forwardSubBatch(ctx context.Context, reqs []JSONRPCRequest, originalArr []interface{}) []JSONRPCResponse {
+	log.Printf("Forwarding batch request (length=%d)", len(reqs))
+	originalBody, _ := codec.Marshal(originalArr)
+	resp, err := jsonrpcPool(ctx).send(ctx, "", reqs[0].Method, originalBody)
+	if err == errMethodUnsupported {
+		return createErrorResponsesForBatch(reqs, -32601, "Method not found: "+err.Error())
+	}
+	if err != nil {
+		log.Printf("Forward batch request error: %v", err)
+		return fallbackBatch(reqs, err)
+	}
+	// log.Printf("Forwarded batch response code=%d, body=%s", resp.Status, string(resp.Body))
+
+	var batchResp []JSONRPCResponse
+	if err := codec.Unmarshal(resp.Body, &batchResp); err == nil {
+		methods := make(map[string]string, len(reqs))
+		for _, r := range reqs {
+			methods[idKey(r.ID)] = r.Method
+		}
+		for i := range batchResp {
+			applyShims(resp.Upstream, methods[idKey(batchResp[i].ID)], &batchResp[i])
+		}
+		return batchResp
+	}
+	// 若无法解析为数组，尝试解析为单一Response
+	var singleResp JSONRPCResponse
+	if err := codec.Unmarshal(resp.Body, &singleResp); err == nil && singleResp.ID != nil {
+		return []JSONRPCResponse{singleResp}
+	}
+	// 否则返回错误
+	observeUpstreamInvalid(resp.Upstream.label)
+	ue := up.Classify(resp.Status, resp.Body)
+	out := make([]JSONRPCResponse, len(reqs))
+	for i, r := range reqs {
+		out[i] = upstreamErrorResponse(ue, r.ID)
+	}
+	return out
+}
+
+func sendJSONRPCResponse(w http.ResponseWriter, resp JSONRPCResponse) {
+	if ew, ok := w.(*encodedWriter); ok {
+		ew.send(resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	codec.NewEncoder(w).Encode(resp)
+}
+
+func sendBatchResponse(w http.ResponseWriter, responses []JSONRPCResponse) {
+	if ew, ok := w.(*encodedWriter); ok {
+		ew.send(responses)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	codec.NewEncoder(w).Encode(responses)
+}
+
+func sendError(w http.ResponseWriter, id interface{}, code int, message string) {
+	sendJSONRPCResponse(w, JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      id,
+		Error: map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func jsonError(id interface{}, code int, msg string) JSONRPCResponse {
+	return JSONRPCResponse{
+		Jsonrpc: "2.0",
+		ID:      id,
+		Error: map[string]interface{}{
+			"code":    code,
+			"message": msg,
+		},
+	}
+}
+
+func createErrorResponsesForBatch(reqs []JSONRPCRequest, code int, msg string) []JSONRPCResponse {
+	responses := make([]JSONRPCResponse, len(reqs))
+	for i, r := range reqs {
+		responses[i] = jsonError(r.ID, code, msg)
+	}
+	return responses
+}
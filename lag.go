@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"sync/atomic"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// 节点的最新高度落后同组最高节点超过这么多个区块时，不再接收依赖最新状态的请求，0 表示不检测
+	upstreamMaxLag = int64(envInt("UPSTREAM_MAX_LAG", 10))
+	// 不依赖最新状态的方法，落后的节点照常接收；带具体区块高度的查询只要节点已同步到该高度也可以发往落后节点
+	lagTolerantMethods = splitList(envString("UPSTREAM_LAG_TOLERANT_METHODS",
+		"eth_getTransactionByHash,eth_getTransactionReceipt,eth_getBlockByHash,eth_getTransactionByBlockHashAndIndex,"+
+			"eth_getBlockTransactionCountByHash,eth_chainId,net_version,web3_clientVersion,debug_*,trace_*"))
+)
+
+type lagCtxKey struct{}
+
+// parseHeadBlock 从健康检查的响应中取出节点的最新高度：JSON-RPC 为 eth_blockNumber，REST 为 getnowblock
+func parseHeadBlock(kind string, body []byte) (int64, bool) {
+	if kind == "jsonrpc" {
+		var resp struct {
+			Result string `json:"result"`
+		}
+		if json.Unmarshal(body, &resp) != nil || resp.Result == "" {
+			return 0, false
+		}
+		n, err := translate.ParseQuantity(resp.Result)
+		return n, err == nil
+	}
+	var block restBlock
+	if json.Unmarshal(body, &block) != nil || block.BlockID == "" {
+		return 0, false
+	}
+	return block.BlockHeader.RawData.Number, true
+}
+
+func (u *upstream) headBlock() int64 {
+	return atomic.LoadInt64(&u.head)
+}
+
+func (u *upstream) isLagging() bool {
+	return atomic.LoadInt32(&u.lagging) == 1
+}
+
+// updateLag 健康检查一轮结束后比较同组节点的高度；不可用或取不到高度的节点不参与比较
+func (p *upstreamPool) updateLag() {
+	if upstreamMaxLag <= 0 {
+		return
+	}
+	var best int64
+	for _, u := range p.list() {
+		if u.available() && u.headBlock() > best {
+			best = u.headBlock()
+		}
+	}
+	for _, u := range p.list() {
+		head := u.headBlock()
+		lagging := best > 0 && head > 0 && best-head > upstreamMaxLag
+		var v int32
+		if lagging {
+			v = 1
+		}
+		if atomic.SwapInt32(&u.lagging, v) == v {
+			continue
+		}
+		if lagging {
+			log.Printf("Upstream %s is %d blocks behind (head %d, best %d), avoiding it for latest-state requests", u.label, best-head, head, best)
+		} else {
+			log.Printf("Upstream %s caught up (head %d)", u.label, head)
+		}
+	}
+}
+
+// withLagTolerance 记录请求需要节点同步到的高度，-1 表示与高度无关；没有记录的请求只发往未落后的节点
+func withLagTolerance(ctx context.Context, reqs ...JSONRPCRequest) context.Context {
+	if upstreamMaxLag <= 0 || len(reqs) == 0 {
+		return ctx
+	}
+	need := int64(-1)
+	for _, r := range reqs {
+		n, ok := lagRequirement(r)
+		if !ok {
+			return ctx
+		}
+		if n > need {
+			need = n
+		}
+	}
+	return context.WithValue(ctx, lagCtxKey{}, need)
+}
+
+func lagRequirement(req JSONRPCRequest) (int64, bool) {
+	for _, p := range lagTolerantMethods {
+		if ok, _ := path.Match(p, req.Method); ok {
+			return -1, true
+		}
+	}
+	rule, ok := blockTagRules[req.Method]
+	if !ok || len(req.Params) <= rule.index {
+		return 0, false
+	}
+	if req.Method == "eth_getLogs" {
+		// 范围查询看 toBlock，省略时为 latest
+		var f struct {
+			ToBlock   json.RawMessage `json:"toBlock"`
+			BlockHash string          `json:"blockHash"`
+		}
+		if codec.Unmarshal(req.Params[rule.index], &f) != nil || f.BlockHash == "" && len(f.ToBlock) == 0 {
+			return 0, false
+		}
+		if f.BlockHash == "" {
+			n, err := parseInt64Param(f.ToBlock)
+			return n, err == nil
+		}
+	}
+	return requestBlockNumber(req.Params[rule.index])
+}
+
+// servesLag 落后的节点只接收与高度无关或它已经同步到的请求
+func (u *upstream) servesLag(ctx context.Context) bool {
+	if !u.isLagging() {
+		return true
+	}
+	need, ok := ctx.Value(lagCtxKey{}).(int64)
+	return ok && need <= u.headBlock()
+}
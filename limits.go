@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/yourname/proxy/tracestore"
+)
+
+var (
+	// JSON-RPC 请求体和批量请求的上限，0 表示不限制
+	jsonrpcMaxBodyBytes = int64(envInt("JSONRPC_MAX_BODY_BYTES", 10<<20))
+	jsonrpcMaxBatch     = envInt("JSONRPC_MAX_BATCH", 1000)
+	// 转发给节点的批量请求按这个大小拆开并发发送，节点拒绝过大的批量；0 表示不拆
+	upstreamMaxBatch = envInt("UPSTREAM_MAX_BATCH", 100)
+	// 单个上游响应和 trace 的大小上限，超过时返回 -32012 而不是整个读进内存，0 表示不限制
+	upstreamMaxResponseBytes = int64(envInt("UPSTREAM_MAX_RESPONSE_BYTES", 256<<20))
+	traceMaxBytes            = int64(envInt("TRACE_MAX_BYTES", 256<<20))
+	// METHOD=DURATION，方法名支持 glob，按顺序匹配第一条；未匹配或为 0 的方法不设超时。
+	// proxy_waitForReceipt 自己带超时参数
+	methodTimeouts = parseMethodTimeouts(envString("METHOD_TIMEOUTS",
+		"proxy_waitForReceipt=0,debug_*=60s,eth_debugTransactionTrace=60s,eth_getLogs=30s,proxy_*=60s,eth_blockNumber=5s,eth_chainId=5s,*=30s"))
+)
+
+// 与 geth 的超时错误码一致
+const errCodeTimeout = -32002
+
+const errCodeResponseTooLarge = -32012
+
+// 与 tracestore 共用同一个错误，trace 过大时也按 -32012 返回
+var errResponseTooLarge = tracestore.ErrTooLarge
+
+// readLimited 读取超过 max 字节时停止并返回 errResponseTooLarge
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, errResponseTooLarge
+	}
+	return data, nil
+}
+
+type methodTimeoutRule struct {
+	pattern string
+	timeout time.Duration
+}
+
+func parseMethodTimeouts(s string) []methodTimeoutRule {
+	var rules []methodTimeoutRule
+	for _, item := range splitList(s) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("Invalid METHOD_TIMEOUTS entry %q, ignored", item)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Printf("Invalid METHOD_TIMEOUTS entry %q, ignored", item)
+			continue
+		}
+		rules = append(rules, methodTimeoutRule{pattern: strings.TrimSpace(parts[0]), timeout: d})
+	}
+	return rules
+}
+
+func methodTimeout(method string) time.Duration {
+	for _, r := range methodTimeouts {
+		if ok, _ := path.Match(r.pattern, method); ok {
+			return r.timeout
+		}
+	}
+	return 0
+}
+
+// withMethodTimeout 超时为 0 时只返回可取消的 ctx
+func withMethodTimeout(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	if d := methodTimeout(method); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timedOut 只有方法自己的超时到期才算，客户端断开不算
+func timedOut(ctx, parent context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+}
+
+func timeoutError(id interface{}, method string) JSONRPCResponse {
+	return jsonError(id, errCodeTimeout, fmt.Sprintf("Request timed out: %s exceeded %s", method, methodTimeout(method)))
+}
+
+// limitBody 超过上限时读取会返回 *http.MaxBytesError
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	if jsonrpcMaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, jsonrpcMaxBodyBytes)
+	}
+}
+
+// withBodyLimit 在任何中间件（快速通道识别、请求签名校验）读取请求体之前套上大小限制。
+// /v1/<key>/jsonrpc 以外的 /v1/* 和 /wallet*/ 是 REST 透传，按 REST_MAX_BODY_BYTES 限制
+func withBodyLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.HasPrefix(r.URL.Path, "/wallet") || strings.HasPrefix(r.URL.Path, "/v1/") && pathAPIKey(r.URL.Path) == ""
+		if rest {
+			r.Body = http.MaxBytesReader(w, r.Body, restMaxBody)
+		} else {
+			limitBody(w, r)
+		}
+		next(w, r)
+	}
+}
+
+func sendBodyTooLarge(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	codec.NewEncoder(w).Encode(jsonError(nil, -32600, fmt.Sprintf("Request body too large (max %d bytes)", jsonrpcMaxBodyBytes)))
+}
+
+func batchTooLarge(n int) bool {
+	return jsonrpcMaxBatch > 0 && n > jsonrpcMaxBatch
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingReader 记录被读走了多少字节
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// 经过完整的中间件链时，快速通道识别也不能读入超过上限的请求体
+func TestBodyLimitBeforeExpress(t *testing.T) {
+	withTestNode(t)
+	oldLimit, oldSources := jsonrpcMaxBodyBytes, expressSources
+	jsonrpcMaxBodyBytes = 1 << 10
+	// httptest 请求的来源地址是 192.0.2.1
+	expressSources = parseCIDRs([]string{"192.0.2.1"})
+	t.Cleanup(func() { jsonrpcMaxBodyBytes, expressSources = oldLimit, oldSources })
+
+	const size = 1 << 20
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`),
+		strings.NewReader(strings.Repeat(" ", size)),
+	)}
+	req := httptest.NewRequest(http.MethodPost, "/jsonrpc", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	newServeMux().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("HTTP %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+	if body.n >= size {
+		t.Errorf("read %d bytes of an oversized body", body.n)
+	}
+}
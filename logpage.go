@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/yourname/proxy/translate"
+)
+
+// proxy_getLogsPage 默认每页的日志条数，上限为 GETLOGS_MAX_RESULTS
+var getLogsPageSize = envInt("GETLOGS_PAGE_SIZE", 1000)
+
+// logCursor 下一页从 Block 区块内序号为 LogIndex 的日志开始；To 固定为第一页解析出的结束区块，
+// toBlock 为 latest 时翻页过程中范围也不会变
+type logCursor struct {
+	Block    int64  `json:"b"`
+	LogIndex int64  `json:"i"`
+	To       int64  `json:"t"`
+	Filter   string `json:"f"`
+}
+
+type logPage struct {
+	Logs []ethLog `json:"logs"`
+	// 没有更多结果时为 null
+	Next *string `json:"next"`
+}
+
+// filterDigest 游标只能用于生成它的过滤条件
+func filterDigest(raw json.RawMessage) string {
+	return paramsHash([]json.RawMessage{raw})[:12]
+}
+
+func encodeLogCursor(c logCursor) *string {
+	data, _ := json.Marshal(c)
+	s := base64.RawURLEncoding.EncodeToString(data)
+	return &s
+}
+
+func decodeLogCursor(s, digest string) (logCursor, error) {
+	var c logCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil {
+		return c, fmt.Errorf("malformed cursor")
+	}
+	if c.Filter != digest {
+		return c, fmt.Errorf("cursor does not belong to this filter")
+	}
+	return c, nil
+}
+
+// handleGetLogsPage proxy_getLogsPage(filter, pageSize?, cursor?)
+// 与 eth_getLogs 的过滤条件相同，结果过多时分页返回 {logs, next}，把 next 作为 cursor 取下一页。
+// 每页最多扫描 GETLOGS_MAX_BLOCK_RANGE 个区块，因此 logs 为空时 next 也可能不为 null
+func handleGetLogsPage(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [filter, pageSize?, cursor?]")
+	}
+	f, err := translate.ParseLogFilter(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	pageSize := getLogsPageSize
+	if len(req.Params) > 1 && string(req.Params[1]) != "null" {
+		n, err := parseInt64Param(req.Params[1])
+		if err != nil || n <= 0 {
+			return jsonError(req.ID, -32602, "Invalid params: pageSize must be a positive integer")
+		}
+		pageSize = int(n)
+	}
+	if pageSize > getLogsMaxResults {
+		pageSize = getLogsMaxResults
+	}
+	digest := filterDigest(req.Params[0])
+
+	var cur logCursor
+	if len(req.Params) > 2 && string(req.Params[2]) != "null" {
+		var token string
+		if codec.Unmarshal(req.Params[2], &token) != nil {
+			return jsonError(req.ID, -32602, "Invalid params: cursor must be a string")
+		}
+		if cur, err = decodeLogCursor(token, digest); err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+		}
+	} else if f.BlockHash != "" {
+		h, err := getHeaderByHash(ctx, f.BlockHash)
+		if err != nil {
+			return jsonError(req.ID, -32000, "block "+f.BlockHash+" not found")
+		}
+		cur = logCursor{Block: h.Number, To: h.Number, Filter: digest}
+	} else {
+		from, err := resolveBlockTag(ctx, f.FromBlock)
+		if err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: fromBlock: "+err.Error())
+		}
+		to, err := resolveBlockTag(ctx, f.ToBlock)
+		if err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: toBlock: "+err.Error())
+		}
+		cur = logCursor{Block: from, To: to, Filter: digest}
+	}
+
+	page, err := collectLogPage(ctx, f, cur, pageSize)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: page}
+}
+
+func collectLogPage(ctx context.Context, f *logFilter, cur logCursor, pageSize int) (*logPage, error) {
+	page := &logPage{Logs: []ethLog{}}
+	if cur.Block > cur.To {
+		return page, nil
+	}
+	last := cur.To
+	var blocks []int64
+	if tronEventAPI != "" && f.Addresses() != nil && f.BlockHash == "" {
+		var err error
+		if blocks, err = eventBlocks(ctx, f, cur.Block, cur.To); err != nil {
+			log.Printf("Event API lookup failed, scanning blocks %d-%d instead: %v", cur.Block, cur.To, err)
+			blocks = nil
+		}
+	}
+	if blocks == nil {
+		if last-cur.Block+1 > getLogsMaxBlockRange {
+			last = cur.Block + getLogsMaxBlockRange - 1
+		}
+		for n := cur.Block; n <= last; n++ {
+			blocks = append(blocks, n)
+		}
+	}
+
+	// 按并发数分段读取，凑够一页就停，避免把剩余区块也读完
+	for start := 0; start < len(blocks); start += batchConcurrency {
+		end := start + batchConcurrency
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		logs, err := collectLogs(ctx, f, blocks[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range logs {
+			num, _ := translate.ParseQuantity(l.BlockNumber)
+			idx, _ := translate.ParseQuantity(l.LogIndex)
+			if num == cur.Block && idx < cur.LogIndex {
+				continue
+			}
+			if len(page.Logs) == pageSize {
+				page.Next = encodeLogCursor(logCursor{Block: num, LogIndex: idx, To: cur.To, Filter: cur.Filter})
+				return page, nil
+			}
+			page.Logs = append(page.Logs, l)
+		}
+	}
+	if last < cur.To {
+		page.Next = encodeLogCursor(logCursor{Block: last + 1, To: cur.To, Filter: cur.Filter})
+	}
+	return page, nil
+}
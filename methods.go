@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// methodSpec 对外文档用的方法说明，Example 为一组可以直接发送的参数
+type methodSpec struct {
+	Name    string
+	Summary string
+	Params  []methodParam
+	Example string
+	// 代理自己实现的扩展方法
+	Extension bool
+}
+
+type methodParam struct {
+	Name        string
+	Description string
+	Required    bool
+	// JSON Schema 类型
+	Type string
+}
+
+const (
+	exampleAddress = `"0xa614f803b6fd780986a42c78ec9c7f77e6ded13c"`
+	exampleTxHash  = `"0x2f9a5c4e4ed74f8b5d3d0b6a91df1c1f8a53b04a7e0f5b2e0b2f8d3a0c4b1e7d"`
+	exampleBlock   = `"0x3b9aca0"`
+)
+
+var (
+	blockParam    = methodParam{Name: "block", Description: "block number (hex) or tag: latest, earliest, pending", Required: true, Type: "string"}
+	txHashParam   = methodParam{Name: "txHash", Description: "transaction hash, 0x-prefixed", Required: true, Type: "string"}
+	addressParam  = methodParam{Name: "address", Description: "hex (0x...) or base58 (T...) address", Required: true, Type: "string"}
+	filterParam   = methodParam{Name: "filter", Description: "log filter: fromBlock, toBlock, address, topics or blockHash", Required: true, Type: "object"}
+	tracerParam   = methodParam{Name: "options", Description: `tracer options, e.g. {"tracer": "callTracer"}`, Type: "object"}
+	fullTxParam   = methodParam{Name: "fullTransactions", Description: "return full transaction objects instead of hashes", Required: true, Type: "boolean"}
+	callParam     = methodParam{Name: "call", Description: "call object: from, to, data, value", Required: true, Type: "object"}
+	exampleFilter = `{"fromBlock":` + exampleBlock + `,"toBlock":` + exampleBlock + `,"address":` + exampleAddress + `}`
+	exampleCall   = `{"to":` + exampleAddress + `,"data":"0x06fdde03"}`
+)
+
+// methodRegistry 常用的透传方法和全部 proxy_* 扩展方法
+var methodRegistry = []methodSpec{
+	{Name: "eth_blockNumber", Summary: "Latest block number."},
+	{Name: "eth_chainId", Summary: "Chain ID of the network."},
+	{Name: "eth_gasPrice", Summary: "Current energy price in sun."},
+	{Name: "eth_getBalance", Summary: "TRX balance of an account in sun.", Params: []methodParam{addressParam, blockParam}, Example: `[` + exampleAddress + `,"latest"]`},
+	{Name: "eth_getCode", Summary: "Runtime bytecode of a contract.", Params: []methodParam{addressParam, blockParam}, Example: `[` + exampleAddress + `,"latest"]`},
+	{Name: "eth_getBlockByNumber", Summary: "Block by number or tag.", Params: []methodParam{blockParam, fullTxParam}, Example: `[` + exampleBlock + `,false]`},
+	{Name: "eth_getBlockByHash", Summary: "Block by hash.", Params: []methodParam{{Name: "blockHash", Description: "block hash, 0x-prefixed", Required: true, Type: "string"}, fullTxParam}, Example: `[` + exampleTxHash + `,false]`},
+	{Name: "eth_getBlockReceipts", Summary: "All receipts of a block; emulated when the node does not support it.", Params: []methodParam{blockParam}, Example: `[` + exampleBlock + `]`},
+	{Name: "eth_getTransactionByHash", Summary: "Transaction by hash.", Params: []methodParam{txHashParam}, Example: `[` + exampleTxHash + `]`},
+	{Name: "eth_getTransactionReceipt", Summary: "Receipt of a transaction.", Params: []methodParam{txHashParam}, Example: `[` + exampleTxHash + `]`},
+	{Name: "eth_getLogs", Summary: "Logs matching a filter; ranges are split and fetched in parallel.", Params: []methodParam{filterParam}, Example: `[` + exampleFilter + `]`},
+	{Name: "eth_call", Summary: "Executes a constant contract call.", Params: []methodParam{callParam, blockParam}, Example: `[` + exampleCall + `,"latest"]`},
+	{Name: "eth_estimateGas", Summary: "Estimates energy used by a call.", Params: []methodParam{callParam}, Example: `[` + exampleCall + `]`},
+	{Name: "eth_sendRawTransaction", Summary: "Broadcasts a signed TRON transaction (protobuf hex).", Params: []methodParam{{Name: "transaction", Description: "signed transaction hex", Required: true, Type: "string"}}, Example: `["0x0a02..."]`},
+	{Name: "debug_traceTransaction", Summary: "Call trace of a transaction.", Params: []methodParam{txHashParam, tracerParam}, Example: `[` + exampleTxHash + `,{"tracer":"callTracer"}]`},
+	{Name: "debug_traceBlockByNumber", Summary: "Call traces of every transaction in a block.", Params: []methodParam{blockParam, tracerParam}, Example: `[` + exampleBlock + `,{"tracer":"callTracer"}]`},
+	{Name: "debug_traceBlockByHash", Summary: "Call traces of every transaction in a block, by hash.", Params: []methodParam{{Name: "blockHash", Description: "block hash, 0x-prefixed", Required: true, Type: "string"}, tracerParam}, Example: `[` + exampleTxHash + `,{"tracer":"callTracer"}]`},
+
+	{Name: "proxy_getBlockByTimestamp", Extension: true, Summary: "Block closest to a unix timestamp (seconds or milliseconds).",
+		Params: []methodParam{
+			{Name: "timestamp", Description: "unix timestamp", Required: true, Type: "integer"},
+			{Name: "direction", Description: `"before" (default) or "after"`, Type: "string"},
+		}, Example: `[1700000000,"before"]`},
+	{Name: "proxy_getBalanceHistory", Extension: true, Summary: "TRX balance sampled every step blocks between fromBlock and toBlock.",
+		Params: []methodParam{
+			addressParam,
+			{Name: "fromBlock", Description: "first block", Required: true, Type: "string"},
+			{Name: "toBlock", Description: "last block", Required: true, Type: "string"},
+			{Name: "step", Description: "sampling interval in blocks", Required: true, Type: "integer"},
+		}, Example: `[` + exampleAddress + `,"0x3b9ac00","0x3b9aca0",20]`},
+	{Name: "proxy_getAddressSummary", Extension: true, Summary: "Transaction counts, token contracts and assets touched by an address in a block range.",
+		Params:  []methodParam{addressParam, {Name: "range", Description: "{fromBlock, toBlock}", Type: "object"}},
+		Example: `[` + exampleAddress + `,{"fromBlock":"0x3b9ac00","toBlock":"0x3b9aca0"}]`},
+	{Name: "proxy_getContractCreation", Extension: true, Summary: "Creator and creation transaction of a contract.",
+		Params: []methodParam{addressParam}, Example: `[` + exampleAddress + `]`},
+	{Name: "proxy_waitForReceipt", Extension: true, Summary: "Waits until a transaction is confirmed and returns its receipt.",
+		Params: []methodParam{
+			txHashParam,
+			{Name: "confirmations", Description: "blocks on top of the inclusion block, default 0", Type: "integer"},
+			{Name: "timeoutSeconds", Description: "how long to wait", Type: "number"},
+		}, Example: `[` + exampleTxHash + `,19,60]`},
+	{Name: "proxy_getTransactionInfoRange", Extension: true, Summary: "Transaction info of every block in a range, fetched in parallel by the proxy.",
+		Params: []methodParam{
+			{Name: "fromBlock", Description: "first block", Required: true, Type: "integer"},
+			{Name: "toBlock", Description: "last block", Required: true, Type: "integer"},
+			{Name: "concurrency", Description: "parallel upstream requests", Type: "integer"},
+		}, Example: `[62500000,62500010]`},
+	{Name: "proxy_getLogsPage", Extension: true, Summary: "eth_getLogs with pagination; pass next back as cursor for the following page.",
+		Params: []methodParam{
+			filterParam,
+			{Name: "pageSize", Description: "logs per page", Type: "integer"},
+			{Name: "cursor", Description: "next from the previous page", Type: "string"},
+		}, Example: `[` + exampleFilter + `,100,null]`},
+	{Name: "proxy_simulateTransaction", Extension: true, Summary: "Dry-runs a transaction without broadcasting: energy, bandwidth, fee in sun and TRX, and revert reason.",
+		Params: []methodParam{
+			{Name: "transaction", Description: "call object (from, to, data, value), TRON transaction JSON with raw_data_hex, or signed transaction hex", Required: true, Type: "object"},
+		}, Example: `[{"from":` + exampleAddress + `,"to":` + exampleAddress + `,"data":"0xa9059cbb"}]`},
+	{Name: "proxy_subscribeWebhook", Extension: true, Summary: "Registers a callback URL for new blocks, address activity or contract events (requires WEBHOOK_RPC).",
+		Params: []methodParam{
+			{Name: "webhook", Description: `url, type ("blocks", "address" or "logs"), addresses, filter, confirmations`, Required: true, Type: "object"},
+		}, Example: `[{"url":"https://example.com/hook","type":"address","addresses":[` + exampleAddress + `]}]`},
+	{Name: "proxy_unsubscribeWebhook", Extension: true, Summary: "Removes a webhook registered with proxy_subscribeWebhook.",
+		Params: []methodParam{{Name: "id", Description: "webhook id", Required: true, Type: "string"}}, Example: `["wh_0123456789abcdef"]`},
+}
+
+func lookupMethodSpec(name string) *methodSpec {
+	for i := range methodRegistry {
+		if methodRegistry[i].Name == name {
+			return &methodRegistry[i]
+		}
+	}
+	return nil
+}
+
+// openRPCDocument 按 OpenRPC 1.2 生成，rpc.discover 和 GET /openrpc.json 返回同一份文档
+func openRPCDocument() map[string]interface{} {
+	methods := make([]map[string]interface{}, 0, len(methodRegistry))
+	for _, m := range methodRegistry {
+		params := make([]map[string]interface{}, 0, len(m.Params))
+		for _, p := range m.Params {
+			params = append(params, map[string]interface{}{
+				"name": p.Name, "description": p.Description, "required": p.Required,
+				"schema": map[string]string{"type": p.Type},
+			})
+		}
+		entry := map[string]interface{}{
+			"name": m.Name, "summary": m.Summary, "params": params,
+			"result": map[string]interface{}{"name": "result", "schema": map[string]interface{}{}},
+		}
+		if m.Example != "" {
+			var values []interface{}
+			json.Unmarshal([]byte(m.Example), &values)
+			exampleParams := make([]map[string]interface{}, 0, len(values))
+			for i, v := range values {
+				name := "param"
+				if i < len(m.Params) {
+					name = m.Params[i].Name
+				}
+				exampleParams = append(exampleParams, map[string]interface{}{"name": name, "value": v})
+			}
+			entry["examples"] = []map[string]interface{}{{"name": m.Name + " example", "params": exampleParams}}
+		}
+		if m.Extension {
+			entry["tags"] = []map[string]string{{"name": "proxy extension"}}
+		}
+		methods = append(methods, entry)
+	}
+	sort.SliceStable(methods, func(i, j int) bool { return methods[i]["name"].(string) < methods[j]["name"].(string) })
+	return map[string]interface{}{
+		"openrpc": "1.2.6",
+		"info":    map[string]string{"title": "TRON JSON-RPC proxy", "version": "1"},
+		"methods": methods,
+	}
+}
+
+func handleRPCDiscover(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: openRPCDocument()}
+}
+
+// handleOpenRPC GET /openrpc.json
+func handleOpenRPC(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openRPCDocument())
+}
@@ -0,0 +1,408 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 不引入 prometheus client，直接输出 text exposition format
+
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	metricRequests = newCounterVec("proxy_requests_total",
+		"JSON-RPC requests handled, by method and outcome.", "method", "status")
+	metricRequestDuration = newHistogramVec("proxy_request_duration_seconds",
+		"JSON-RPC request latency in seconds.", latencyBuckets, "method", "kind")
+	metricUpstreamRequests = newCounterVec("proxy_upstream_requests_total",
+		"Requests sent to upstream TRON nodes.", "upstream")
+	metricUpstreamErrors = newCounterVec("proxy_upstream_errors_total",
+		"Upstream requests that failed (transport error, 5xx or undecodable body).", "upstream")
+	metricTraceFiles = newCounterVec("proxy_trace_file_requests_total",
+		"Trace file lookups by result (hit, miss, error).", "result")
+	metricEvents = newCounterVec("proxy_events_total",
+		"Internal events published on the event bus, by type.", "type")
+	metricInflight int64
+)
+
+func init() {
+	events.subscribe("metrics", 256, func(e event) {
+		metricEvents.inc(string(e.Type))
+	})
+}
+
+// 方法名来自客户端输入，限制标签数量防止基数爆炸
+const maxMethodLabels = 256
+
+var methodLabels = struct {
+	sync.Mutex
+	seen map[string]bool
+}{seen: make(map[string]bool)}
+
+func methodLabel(method string) string {
+	if method == "" || len(method) > 64 {
+		return "invalid"
+	}
+	for _, c := range method {
+		if !(c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return "invalid"
+		}
+	}
+	methodLabels.Lock()
+	defer methodLabels.Unlock()
+	if methodLabels.seen[method] {
+		return method
+	}
+	if len(methodLabels.seen) >= maxMethodLabels {
+		return "other"
+	}
+	methodLabels.seen[method] = true
+	return method
+}
+
+func observeRequest(method, kind string, start time.Time, resp JSONRPCResponse) {
+	status := "ok"
+	if resp.Error != nil {
+		status = "error"
+	}
+	label := methodLabel(method)
+	metricRequests.inc(label, status)
+	metricRequestDuration.observe(time.Since(start).Seconds(), label, kind)
+	recentRequests.observe(label, resp.Error != nil)
+}
+
+func observeBatch(method string, start time.Time, responses []JSONRPCResponse) {
+	label := methodLabel(method)
+	for _, resp := range responses {
+		status := "ok"
+		if resp.Error != nil {
+			status = "error"
+		}
+		metricRequests.inc(label, status)
+		recentRequests.observe(label, resp.Error != nil)
+	}
+	metricRequestDuration.observe(time.Since(start).Seconds(), label, "batch")
+}
+
+func observeTraceFile(err error) {
+	switch {
+	case err == nil:
+		metricTraceFiles.inc("hit")
+	case errors.Is(err, fs.ErrNotExist):
+		metricTraceFiles.inc("miss")
+	default:
+		metricTraceFiles.inc("error")
+	}
+}
+
+func upstreamLabel(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+func observeUpstream(upstream string, failed bool) {
+	metricUpstreamRequests.inc(upstream)
+	if failed {
+		metricUpstreamErrors.inc(upstream)
+	}
+}
+
+// observeUpstreamInvalid 记录请求成功但响应无法解析的情况
+func observeUpstreamInvalid(upstream string) {
+	metricUpstreamErrors.inc(upstream)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	c.add(1, labelValues...)
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+// snapshot 键为用 \xff 连接的标签值
+func (c *counterVec) snapshot() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]float64, len(c.values))
+	for k, v := range c.values {
+		out[k] = v
+	}
+	return out
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), s.count)
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(names []string, key, le string) string {
+	var parts []string
+	if len(names) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, n := range names {
+			v := ""
+			if i < len(values) {
+				v = values[i]
+			}
+			parts = append(parts, fmt.Sprintf("%s=%q", n, v))
+		}
+	}
+	if le != "" {
+		parts = append(parts, fmt.Sprintf("le=%q", le))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w)
+}
+
+func writeMetrics(w io.Writer) {
+	metricRequests.write(w)
+	metricRequestDuration.write(w)
+	metricUpstreamRequests.write(w)
+	metricUpstreamErrors.write(w)
+	metricTraceFiles.write(w)
+	metricTraceGenerated.write(w)
+	metricTracePregenerated.write(w)
+	metricTraceConsistency.write(w)
+	metricUpstreamRetries.write(w)
+	metricFallbackResponses.write(w)
+	metricStaleResponses.write(w)
+	metricStreamed.write(w)
+	metricCompressed.write(w)
+	metricChaosInjected.write(w)
+	metricRateLimited.write(w)
+	metricSignatureRejected.write(w)
+	metricExpress.write(w)
+	metricCoalesced.write(w)
+	metricEvents.write(w)
+	metricEventsDropped.write(w)
+	metricWebhookDeliveries.write(w)
+	metricSlowRequests.write(w)
+	writeGauge(w, "proxy_webhook_subscriptions", "Registered webhook subscriptions.", float64(webhooks.count()))
+	writeGauge(w, "proxy_inflight_requests", "HTTP requests currently being served.", float64(atomic.LoadInt64(&metricInflight)))
+	writeGauge(w, "proxy_worker_pool_busy", "Shared fan-out workers currently in use.", float64(workers.busy()))
+	writeGauge(w, "proxy_worker_pool_size", "Capacity of the shared fan-out worker pool.", float64(workers.size()))
+	writeCacheMetrics(w)
+	writeRESTCacheMetrics(w)
+	writeTraceCacheMetrics(w)
+	writeDiskCacheMetrics(w)
+	writeTraceStoreMetrics(w)
+	writeUpstreamMetrics(w)
+	writeExpiryMetrics(w)
+	writeClockMetrics(w)
+}
+
+// metricUpstreamPools gRPC 和 solidity 节点没有这些统计
+func metricUpstreamPools() []*upstreamPool {
+	return append([]*upstreamPool{jsonrpcUpstreams, restUpstreams, archiveUpstreams, archiveRestUpstreams}, networkPools()...)
+}
+
+func writeUpstreamMetrics(w io.Writer) {
+	fmt.Fprintf(w, "# HELP proxy_upstream_up Whether the upstream is currently considered healthy.\n# TYPE proxy_upstream_up gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			up := 0
+			if s.Healthy {
+				up = 1
+			}
+			fmt.Fprintf(w, "proxy_upstream_up{kind=%q,upstream=%q} %d\n", s.Kind, upstreamLabel(s.URL), up)
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_circuit_open Whether the upstream circuit breaker is open (1) or half-open (0.5).\n# TYPE proxy_upstream_circuit_open gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			v := 0.0
+			switch s.Circuit {
+			case circuitOpen:
+				v = 1
+			case circuitHalfOpen:
+				v = 0.5
+			}
+			fmt.Fprintf(w, "proxy_upstream_circuit_open{kind=%q,upstream=%q} %g\n", s.Kind, upstreamLabel(s.URL), v)
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_info Detected upstream node version.\n# TYPE proxy_upstream_info gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			if s.Version != "" {
+				fmt.Fprintf(w, "proxy_upstream_info{kind=%q,upstream=%q,version=%q} 1\n", s.Kind, upstreamLabel(s.URL), s.Version)
+			}
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_head_block Latest block reported by the upstream health check.\n# TYPE proxy_upstream_head_block gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			if s.Head > 0 {
+				fmt.Fprintf(w, "proxy_upstream_head_block{kind=%q,upstream=%q} %d\n", s.Kind, upstreamLabel(s.URL), s.Head)
+			}
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_lagging Whether the upstream is behind its pool and avoided for latest-state requests.\n# TYPE proxy_upstream_lagging gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			v := 0
+			if s.Lagging {
+				v = 1
+			}
+			fmt.Fprintf(w, "proxy_upstream_lagging{kind=%q,upstream=%q} %d\n", s.Kind, upstreamLabel(s.URL), v)
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_pending_requests Requests currently in flight to the upstream.\n# TYPE proxy_upstream_pending_requests gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			fmt.Fprintf(w, "proxy_upstream_pending_requests{kind=%q,upstream=%q} %d\n", s.Kind, upstreamLabel(s.URL), s.Pending)
+		}
+	}
+	fmt.Fprintf(w, "# HELP proxy_upstream_latency_ewma_seconds Smoothed upstream latency used for routing, by method class.\n# TYPE proxy_upstream_latency_ewma_seconds gauge\n")
+	for _, p := range metricUpstreamPools() {
+		for _, s := range p.status() {
+			classes := make([]string, 0, len(s.LatencyMs))
+			for c := range s.LatencyMs {
+				classes = append(classes, c)
+			}
+			sort.Strings(classes)
+			for _, c := range classes {
+				fmt.Fprintf(w, "proxy_upstream_latency_ewma_seconds{kind=%q,upstream=%q,class=%q} %g\n", s.Kind, upstreamLabel(s.URL), c, s.LatencyMs[c]/1000)
+			}
+		}
+	}
+}
+
+func writeCacheMetrics(w io.Writer) {
+	stats := responseCache.Stats()
+	hits := newCounterVec("proxy_cache_hits_total", "Response cache hits by method.", "method")
+	misses := newCounterVec("proxy_cache_misses_total", "Response cache misses by method.", "method")
+	for m, s := range stats.Methods {
+		hits.add(float64(s.Hits), methodLabel(m))
+		misses.add(float64(s.Misses), methodLabel(m))
+	}
+	hits.write(w)
+	misses.write(w)
+	evictions := newCounterVec("proxy_cache_evictions_total", "Response cache LRU evictions.")
+	evictions.add(float64(stats.Evictions))
+	evictions.write(w)
+	writeGauge(w, "proxy_cache_entries", "Entries currently held in the response cache.", float64(stats.Entries))
+}
+
+func writeTraceCacheMetrics(w io.Writer) {
+	stats := traceCache.Stats()
+	c := newCounterVec("proxy_trace_cache_requests_total", "Trace cache lookups by result.", "result")
+	c.add(float64(stats.Hits), "hit")
+	c.add(float64(stats.Misses), "miss")
+	c.write(w)
+	evictions := newCounterVec("proxy_trace_cache_evictions_total", "Trace cache LRU evictions.")
+	evictions.add(float64(stats.Evictions))
+	evictions.write(w)
+	writeGauge(w, "proxy_trace_cache_bytes", "Bytes of trace JSON held in the trace cache.", float64(stats.Bytes))
+}
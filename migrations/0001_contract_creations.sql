@@ -0,0 +1,12 @@
+CREATE TABLE contract_creations (
+    address_hex      TEXT PRIMARY KEY,
+    address          TEXT NOT NULL,
+    creator          TEXT NOT NULL DEFAULT '',
+    transaction_hash TEXT NOT NULL DEFAULT '',
+    type             TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE proxy_state (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
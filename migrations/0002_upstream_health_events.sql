@@ -0,0 +1,11 @@
+CREATE TABLE upstream_health_events (
+    time       BIGINT  NOT NULL,
+    upstream   TEXT    NOT NULL,
+    kind       TEXT    NOT NULL,
+    healthy    INTEGER NOT NULL,
+    error      TEXT    NOT NULL DEFAULT '',
+    successes  INTEGER NOT NULL DEFAULT 0,
+    flapping   INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX upstream_health_events_time ON upstream_health_events (time);
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// wantsNDJSON 批量请求通过 Accept: application/x-ndjson 或 ?stream=1 开启流式响应
+func wantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") || r.URL.Query().Get("stream") == "1"
+}
+
+// ndjsonWriter 每完成一条就写一行并立即 flush，多个 worker 并发写入
+type ndjsonWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func newNDJSONWriter(w http.ResponseWriter) *ndjsonWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	flusher, _ := w.(http.Flusher)
+	return &ndjsonWriter{w: w, enc: json.NewEncoder(w), flusher: flusher}
+}
+
+func (n *ndjsonWriter) write(resp JSONRPCResponse) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.enc.Encode(resp)
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+}
+
+func sendBatch(w http.ResponseWriter, stream bool, reqs []JSONRPCRequest, responses []JSONRPCResponse) {
+	responses = dropNotifications(reqs, responses)
+	if len(responses) == 0 {
+		// 全部是通知，按规范不返回任何内容
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !stream {
+		sendBatchResponse(w, responses)
+		return
+	}
+	nw := newNDJSONWriter(w)
+	for _, resp := range responses {
+		nw.write(resp)
+	}
+}
+
+// dropNotifications 去掉通知对应的响应。转发的批量响应顺序不一定和请求一致，所以按 id 而不是位置匹配：
+// id 为 null 的响应只保留和显式 "id": null 请求一样多的条数
+func dropNotifications(reqs []JSONRPCRequest, responses []JSONRPCResponse) []JSONRPCResponse {
+	notifications, keepNull := 0, 0
+	for _, r := range reqs {
+		if r.Notification {
+			notifications++
+		} else if r.ID == nil {
+			keepNull++
+		}
+	}
+	if notifications == 0 {
+		return responses
+	}
+	out := make([]JSONRPCResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp.ID == nil {
+			if keepNull == 0 {
+				continue
+			}
+			keepNull--
+		}
+		out = append(out, resp)
+	}
+	return out
+}
+
+func allNotifications(reqs []JSONRPCRequest) bool {
+	for _, r := range reqs {
+		if !r.Notification {
+			return false
+		}
+	}
+	return true
+}
+
+// streamBatch 逐条按单请求路径处理，完成顺序即输出顺序，客户端按 id 对应。
+// 不在内存里拼整个数组，适合回填用的大批量请求
+func streamBatch(ctx context.Context, w http.ResponseWriter, reqs []JSONRPCRequest) int {
+	nw := newNDJSONWriter(w)
+	var mu sync.Mutex
+	done := make([]bool, len(reqs))
+	wctx := inWorker(ctx)
+	workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+		resp := handleSingleRequest(wctx, reqs[i])
+		if !reqs[i].Notification {
+			nw.write(resp)
+		}
+		mu.Lock()
+		done[i] = true
+		mu.Unlock()
+	})
+	for i, ok := range done {
+		if !ok && !reqs[i].Notification {
+			nw.write(jsonError(reqs[i].ID, -32603, "Request cancelled"))
+		}
+	}
+	if allNotifications(reqs) {
+		w.WriteHeader(http.StatusNoContent)
+		return 0
+	}
+	return len(reqs)
+}
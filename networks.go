@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// NETWORKS 同一个进程里代理的其他网络，例如 nile,shasta。每个网络单独配置：
+	//   NETWORK_NILE_JSONRPC_ENDPOINT、NETWORK_NILE_REST_ENDPOINT    节点列表，格式同 TRON_*_ENDPOINT
+	//   NETWORK_NILE_TRACE_STORE                                     默认为 TRACE_STORE 下的 nile 子目录
+	//   NETWORK_NILE_TRACE_GENERATOR_URL                             TRACE_GENERATE=service 时使用
+	// 客户端用路径前缀（/nile/jsonrpc、/nile/wallet/...）或 X-Target-Network 请求头选择网络，
+	// 都没有时使用 TRON_*_ENDPOINT 配置的默认网络 DEFAULT_NETWORK。X-Target-Upstream 按标签指定所选网络里的某个节点
+	defaultNetwork = strings.ToLower(envString("DEFAULT_NETWORK", "mainnet"))
+	// 由 New 加载
+	networks []*network
+)
+
+var networkNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// network 默认网络以外的网络。区块监听、过滤器模拟、响应缓存和 trace 预生成只用于默认网络，
+// 其他网络的请求不经过这些缓存，过滤器直接转发给节点
+type network struct {
+	name              string
+	jsonrpc           *upstreamPool
+	rest              *upstreamPool
+	traces            TraceStore
+	traceGeneratorURL string
+	headers           *headerCache
+}
+
+type networkCtxKey struct{}
+
+// upstreamTargetCtxKey X-Target-Upstream 指定的节点标签
+type upstreamTargetCtxKey struct{}
+
+func loadNetworks() ([]*network, error) {
+	var out []*network
+	seen := map[string]bool{defaultNetwork: true}
+	for _, name := range splitList(envString("NETWORKS", "")) {
+		name = strings.ToLower(name)
+		if !networkNamePattern.MatchString(name) || seen[name] || reservedPaths["/"+name+"/"] || name == "admin" || name == "debug" {
+			log.Printf("Invalid or duplicate network %q in NETWORKS, ignored", name)
+			continue
+		}
+		seen[name] = true
+		prefix := networkEnvPrefix(name)
+		traces, err := openTraceStore(envString(prefix+"TRACE_STORE", strings.TrimRight(envString("TRACE_STORE", traceDir), "/")+"/"+name))
+		if err != nil {
+			return nil, fmt.Errorf("network %s: %w", name, err)
+		}
+		n := &network{
+			name:              name,
+			jsonrpc:           newNetworkPool(name, "jsonrpc", splitList(configValue(prefix+"JSONRPC_ENDPOINT"))),
+			rest:              newNetworkPool(name, "rest", splitList(configValue(prefix+"REST_ENDPOINT"))),
+			traces:            traces,
+			traceGeneratorURL: configValue(prefix + "TRACE_GENERATOR_URL"),
+			headers:           newHeaderCache(envInt("HEADER_CACHE_SIZE", 100000) / 10),
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func networkEnvPrefix(name string) string {
+	return "NETWORK_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+}
+
+func newNetworkPool(name, kind string, urls []string) *upstreamPool {
+	p := &upstreamPool{kind: kind, role: name}
+	p.setURLs(urls)
+	return p
+}
+
+func findNetwork(name string) (*network, bool) {
+	name = strings.ToLower(name)
+	if name == defaultNetwork {
+		return nil, true
+	}
+	for _, n := range networks {
+		if n.name == name {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+func networkPools() []*upstreamPool {
+	var out []*upstreamPool
+	for _, n := range networks {
+		out = append(out, n.jsonrpc, n.rest)
+	}
+	return out
+}
+
+// networkFrom 默认网络返回 nil
+func networkFrom(ctx context.Context) *network {
+	n, _ := ctx.Value(networkCtxKey{}).(*network)
+	return n
+}
+
+// registerNetworkPaths /<网络>/ 前缀去掉后交给同一个 mux，其余处理和默认网络相同
+func registerNetworkPaths(mux *http.ServeMux) {
+	for _, n := range networks {
+		n := n
+		prefix := "/" + n.name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), networkCtxKey{}, n)))
+		})))
+		log.Printf("Serving network %s under %s/ (%d JSON-RPC, %d REST upstream(s), traces in %s)",
+			n.name, prefix, len(n.jsonrpc.list()), len(n.rest.list()), n.traces)
+	}
+	if len(networks) > 0 {
+		mux.Handle("/"+defaultNetwork+"/", http.StripPrefix("/"+defaultNetwork, mux))
+	}
+}
+
+// withUpstreamTarget 处理 X-Target-Network 和 X-Target-Upstream；路径前缀已经选了网络时忽略请求头里的网络
+func withUpstreamTarget(ctx context.Context, r *http.Request) (context.Context, error) {
+	if name := r.Header.Get("X-Target-Network"); name != "" && ctx.Value(networkCtxKey{}) == nil {
+		n, ok := findNetwork(name)
+		if !ok {
+			return ctx, fmt.Errorf("unknown network %q", name)
+		}
+		if n != nil {
+			ctx = context.WithValue(ctx, networkCtxKey{}, n)
+		}
+	}
+	label := r.Header.Get("X-Target-Upstream")
+	if label == "" {
+		return ctx, nil
+	}
+	pools := []*upstreamPool{jsonrpcUpstreams, restUpstreams, solidityUpstreams, archiveUpstreams, archiveRestUpstreams}
+	if n := networkFrom(ctx); n != nil {
+		pools = []*upstreamPool{n.jsonrpc, n.rest}
+	}
+	ctx = context.WithValue(ctx, upstreamTargetCtxKey{}, label)
+	if targetPool(ctx, pools...) == nil {
+		return ctx, fmt.Errorf("unknown upstream %q", label)
+	}
+	return ctx, nil
+}
+
+func upstreamTarget(ctx context.Context) string {
+	label, _ := ctx.Value(upstreamTargetCtxKey{}).(string)
+	return label
+}
+
+// targetPool 指定了节点时选包含它的节点组，例如指定的是归档节点时即使方法不需要归档也发往那里
+func targetPool(ctx context.Context, pools ...*upstreamPool) *upstreamPool {
+	label := upstreamTarget(ctx)
+	if label == "" {
+		return nil
+	}
+	for _, p := range pools {
+		if p.find(label) != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func writeTargetError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	msg, _ := json.Marshal(err.Error())
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":null,"error":{"code":-32602,"message":%s}}`+"\n", msg)
+}
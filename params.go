@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourname/proxy/translate"
+)
+
+// parseInt64Param 接受 JSON 数字、十进制字符串或 0x 十六进制字符串
+func parseInt64Param(raw json.RawMessage) (int64, error) {
+	var n int64
+	if err := codec.Unmarshal(raw, &n); err == nil {
+		return n, nil
+	}
+	var s string
+	if err := codec.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf("expected integer or string, got %s", string(raw))
+	}
+	return translate.ParseQuantity(s)
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// JSON-RPC 路径上的鉴权方式
+const (
+	pathAuthRequired = "required" // 启用鉴权时必须带有效 key
+	pathAuthOptional = "optional" // 不带 key 按匿名处理，带了无效 key 仍然拒绝
+	pathAuthNone     = "none"     // 不检查 key，只按 IP 限流
+)
+
+var (
+	// PATH[;auth=required|optional|none][;strict=true|false]，逗号分隔。
+	// 不同 SDK 默认请求的路径不一样（/、/jsonrpc、/rpc 等），可以同时挂多个
+	jsonrpcPaths = parseJSONRPCPaths(envString("JSONRPC_PATHS", "/jsonrpc"))
+	// 已经被其他处理器占用的路径
+	reservedPaths = toSet([]string{"/v1/", "/wallet/", "/walletsolidity/", "/bulk", "/signing-key", "/healthz", "/readyz", "/test", "/metrics", "/status", "/openrpc.json", "/examples"})
+)
+
+type jsonrpcPath struct {
+	path string
+	auth string
+	// strict=false 时接受缺少或不是 "2.0" 的 jsonrpc 字段，部分旧 SDK 不带
+	strict bool
+}
+
+func parseJSONRPCPaths(s string) []jsonrpcPath {
+	var paths []jsonrpcPath
+	seen := map[string]bool{}
+	for _, item := range splitList(s) {
+		parts := strings.Split(item, ";")
+		p := jsonrpcPath{path: strings.TrimSpace(parts[0]), auth: pathAuthRequired, strict: true}
+		valid := strings.HasPrefix(p.path, "/") && !strings.HasPrefix(p.path, "/admin/") && !reservedPaths[p.path] && !seen[p.path]
+		for _, opt := range parts[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch {
+			case k == "auth" && (v == pathAuthRequired || v == pathAuthOptional || v == pathAuthNone):
+				p.auth = v
+			case k == "strict" && (v == "true" || v == "false"):
+				p.strict = v == "true"
+			default:
+				valid = false
+			}
+		}
+		if !valid {
+			log.Printf("Invalid JSONRPC_PATHS entry %q, ignored", item)
+			continue
+		}
+		seen[p.path] = true
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func registerJSONRPCPaths(mux *http.ServeMux) {
+	for _, p := range jsonrpcPaths {
+		mux.HandleFunc(p.path, p.handler())
+		log.Printf("Serving JSON-RPC on %s (auth=%s, strict=%t)", p.path, p.auth, p.strict)
+	}
+}
+
+type jsonrpcPathCtxKey struct{}
+
+func (p jsonrpcPath) handler() http.HandlerFunc {
+	var auth func(http.HandlerFunc) http.HandlerFunc
+	switch p.auth {
+	case pathAuthOptional:
+		auth = optionalAuthMiddleware
+	case pathAuthNone:
+		auth = func(next http.HandlerFunc) http.HandlerFunc { return next }
+	default:
+		auth = authMiddleware
+	}
+	h := withBodyLimit(withCORS(signResponses(withRequestID(standbyGate(auth(expressMiddleware(rateLimitMiddleware(handleJSONRPC))))))))
+	return func(w http.ResponseWriter, r *http.Request) {
+		// ServeMux 里以 / 结尾的模式会匹配整个子树，这里只接受完全相同的路径
+		if r.URL.Path != p.path {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), jsonrpcPathCtxKey{}, p)))
+	}
+}
+
+// optionalAuthMiddleware 没有带 key 的请求直接放行
+func optionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	withAuth := authMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requestAPIKey(r) == "" && r.Header.Get(requestSignatureHeader) == "" {
+			next(w, r)
+			return
+		}
+		withAuth(w, r)
+	}
+}
+
+// strictRequests /v1/<key>/jsonrpc 等不经过 JSONRPC_PATHS 的入口保持严格校验
+func strictRequests(ctx context.Context) bool {
+	p, ok := ctx.Value(jsonrpcPathCtxKey{}).(jsonrpcPath)
+	return !ok || p.strict
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// PGO_MAX_SECONDS 限制单次采集时长；采样期间 CPU 开销约几个百分点
+var pgoMaxSeconds = envInt("PGO_MAX_SECONDS", 300)
+
+// handleAdminProfile GET /admin/profile?seconds=30 采集 CPU profile，输出 pprof 格式，
+// 可直接保存为 cmd/proxy/default.pgo 供 go build -pgo 使用（多份用 make pgo-merge 合并）
+func handleAdminProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET required"})
+		return
+	}
+	seconds := 30
+	if s := r.URL.Query().Get("seconds"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > pgoMaxSeconds {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "seconds must be between 1 and " + strconv.Itoa(pgoMaxSeconds)})
+			return
+		}
+		seconds = n
+	}
+
+	// 先写入内存，采集失败时还能返回 JSON 错误
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Capturing %ds CPU profile for %s", seconds, clientIP(r))
+	timer := time.NewTimer(time.Duration(seconds) * time.Second)
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		timer.Stop()
+		pprof.StopCPUProfile()
+		log.Printf("CPU profile capture aborted: %v", r.Context().Err())
+		return
+	}
+	pprof.StopCPUProfile()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="default.pgo"`)
+	// 内容本身已经是 gzip，不再压缩
+	w.Header().Set("Cache-Control", "no-transform")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
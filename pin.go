@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// PIN_LATEST 把 "latest" 改写成具体高度，保证读到同一个区块：
+	// off 不改写；batch 每个批量请求解析一次 latest；sticky 在 PIN_STICKY_WINDOW 内所有请求共用同一个高度。
+	// 请求头 X-Proxy-Pin-Latest 可以按请求覆盖
+	pinLatestMode = envString("PIN_LATEST", "off")
+	pinStickyTTL  = envDuration("PIN_STICKY_WINDOW", 3*time.Second)
+
+	sticky struct {
+		sync.Mutex
+		height int64
+		at     time.Time
+	}
+)
+
+// pinBlockParamIndex 区块参数的位置；参数缺省时节点按 latest 处理，同样补上具体高度
+var pinBlockParamIndex = map[string]int{
+	"eth_getBalance":          1,
+	"eth_getCode":             1,
+	"eth_getTransactionCount": 1,
+	"eth_getStorageAt":        2,
+	"eth_call":                1,
+	"eth_getBlockByNumber":    0,
+}
+
+type pinModeCtxKey struct{}
+
+func withPinMode(ctx context.Context, r *http.Request) context.Context {
+	mode := pinLatestMode
+	switch h := r.Header.Get("X-Proxy-Pin-Latest"); h {
+	case "off", "batch", "sticky":
+		mode = h
+	}
+	return context.WithValue(ctx, pinModeCtxKey{}, mode)
+}
+
+func pinModeFrom(ctx context.Context) string {
+	if m, ok := ctx.Value(pinModeCtxKey{}).(string); ok {
+		return m
+	}
+	return pinLatestMode
+}
+
+// needsPin 参数缺省或为 "latest" 时需要改写；blockHash 形式和具体高度保持不变
+func needsPin(req JSONRPCRequest) bool {
+	idx, ok := pinBlockParamIndex[req.Method]
+	if !ok || len(req.Params) < idx {
+		return false
+	}
+	if len(req.Params) == idx {
+		return idx > 0
+	}
+	var tag string
+	return codec.Unmarshal(req.Params[idx], &tag) == nil && tag == "latest"
+}
+
+func pinRequest(req JSONRPCRequest, height int64) JSONRPCRequest {
+	idx := pinBlockParamIndex[req.Method]
+	param, _ := json.Marshal(translate.HexQuantity(height))
+	params := append([]json.RawMessage(nil), req.Params...)
+	if len(params) == idx {
+		params = append(params, param)
+	} else {
+		params[idx] = param
+	}
+	req.Params = params
+	return req
+}
+
+func resolvePinHeight(ctx context.Context, mode string) (int64, error) {
+	if mode == "sticky" {
+		sticky.Lock()
+		defer sticky.Unlock()
+		if time.Since(sticky.at) < pinStickyTTL {
+			return sticky.height, nil
+		}
+	}
+	h, err := getLatestHeader(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if mode == "sticky" {
+		// 多节点时可能读到落后节点，窗口刷新时高度不回退
+		if h.Number > sticky.height {
+			sticky.height = h.Number
+		}
+		sticky.at = time.Now()
+		return sticky.height, nil
+	}
+	return h.Number, nil
+}
+
+// pinLatest 就地改写 reqs 里的 latest，返回使用的高度；没有需要改写的条目或解析失败时返回 false，
+// 请求保持原样
+func pinLatest(ctx context.Context, reqs []JSONRPCRequest) (int64, bool) {
+	mode := pinModeFrom(ctx)
+	if mode == "off" || (mode == "batch" && len(reqs) < 2) {
+		return 0, false
+	}
+	var targets []int
+	for i, req := range reqs {
+		if needsPin(req) {
+			targets = append(targets, i)
+		}
+	}
+	if len(targets) == 0 {
+		return 0, false
+	}
+	height, err := resolvePinHeight(ctx, mode)
+	if err != nil {
+		log.Printf("Cannot pin latest block, forwarding unchanged: %v", err)
+		return 0, false
+	}
+	for _, i := range targets {
+		reqs[i] = pinRequest(reqs[i], height)
+	}
+	return height, true
+}
+
+// quantityUnsupported java-tron 的 eth_call、eth_getCode 等只接受 "latest"，传具体高度会返回这个错误
+func quantityUnsupported(resp JSONRPCResponse) bool {
+	m, ok := resp.Error.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	msg, _ := m["message"].(string)
+	return strings.Contains(msg, "QUANTITY not supported")
+}
+
+// retryPinnedAsLatest 节点不接受具体高度时，只要最新区块仍是固定的高度，就改回 latest 重试，
+// 结果与固定高度一致；最新区块已经变化时保留原错误
+func retryPinnedAsLatest(ctx context.Context, reqs []JSONRPCRequest, responses []JSONRPCResponse, height int64) {
+	byID := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		byID[idKey(req.ID)] = i
+	}
+	var retry []int
+	for i := range responses {
+		if quantityUnsupported(responses[i]) {
+			retry = append(retry, i)
+		}
+	}
+	if len(retry) == 0 {
+		return
+	}
+	head, err := getLatestHeader(ctx)
+	if err != nil || head.Number != height {
+		log.Printf("Pinned block %d rejected by upstream and head moved on, returning error", height)
+		return
+	}
+	for _, i := range retry {
+		j, ok := byID[idKey(responses[i].ID)]
+		if !ok {
+			continue
+		}
+		req := reqs[j]
+		tag, _ := json.Marshal("latest")
+		params := append([]json.RawMessage(nil), req.Params...)
+		params[pinBlockParamIndex[req.Method]] = tag
+		req.Params = params
+		responses[i] = withCache(ctx, req, dispatchRequest)
+	}
+}
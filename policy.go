@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"path"
+	"sync/atomic"
+)
+
+// METHOD_ALLOW 非空时只放行匹配的方法；METHOD_DENY 优先于 METHOD_ALLOW。都支持 glob
+var methodPolicy atomic.Value // methodLists
+
+type methodLists struct {
+	allow []string
+	deny  []string
+}
+
+func init() {
+	loadMethodPolicy()
+}
+
+// loadMethodPolicy 启动和重新加载配置时调用
+func loadMethodPolicy() {
+	lists := methodLists{
+		allow: splitList(envString("METHOD_ALLOW", "")),
+		deny:  splitList(envString("METHOD_DENY", "admin_*,personal_*,miner_*")),
+	}
+	if len(lists.allow) > 0 {
+		log.Printf("Method allowlist: %v", lists.allow)
+	}
+	if len(lists.deny) > 0 {
+		log.Printf("Method denylist: %v", lists.deny)
+	}
+	methodPolicy.Store(lists)
+}
+
+func matchAny(patterns []string, method string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMethodPolicy 返回拒绝原因，空字符串表示放行。
+// API key 的 deny 总是生效；key 配置了 methods 时以它为准，覆盖全局的 allow/deny
+func checkMethodPolicy(ctx context.Context, method string) string {
+	if k := apiKeyFromContext(ctx); k != nil {
+		if matchAny(k.Deny, method) {
+			return "Method not allowed for this API key"
+		}
+		if len(k.Methods) > 0 {
+			if matchAny(k.Methods, method) {
+				return ""
+			}
+			return "Method not allowed for this API key"
+		}
+	}
+	lists := methodPolicy.Load().(methodLists)
+	if matchAny(lists.deny, method) {
+		return "Method not allowed"
+	}
+	if len(lists.allow) > 0 && !matchAny(lists.allow, method) {
+		return "Method not allowed"
+	}
+	return ""
+}
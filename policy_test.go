@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestMethodPolicy(t *testing.T) {
+	old := methodPolicy.Load()
+	methodPolicy.Store(methodLists{allow: []string{"eth_*", "net_version"}, deny: []string{"eth_sign*", "admin_*"}})
+	t.Cleanup(func() { methodPolicy.Store(old) })
+
+	keyCtx := func(k *apiKey) context.Context {
+		return context.WithValue(context.Background(), apiKeyCtxKey{}, k)
+	}
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		allowed bool
+	}{
+		{"allowed", context.Background(), "eth_blockNumber", true},
+		{"deny wins over allow", context.Background(), "eth_signTransaction", false},
+		{"not in allowlist", context.Background(), "debug_traceTransaction", false},
+		{"denied", context.Background(), "admin_peers", false},
+		// key 的 methods 覆盖全局列表，deny 总是生效
+		{"key methods", keyCtx(&apiKey{Methods: []string{"debug_*"}}), "debug_traceTransaction", true},
+		{"outside key methods", keyCtx(&apiKey{Methods: []string{"debug_*"}}), "eth_blockNumber", false},
+		{"key deny", keyCtx(&apiKey{Deny: []string{"eth_getLogs"}}), "eth_getLogs", false},
+		{"key deny over key methods", keyCtx(&apiKey{Methods: []string{"eth_*"}, Deny: []string{"eth_call"}}), "eth_call", false},
+	}
+	for _, c := range cases {
+		if got := checkMethodPolicy(c.ctx, c.method) == ""; got != c.allowed {
+			t.Errorf("%s: %s allowed = %v, want %v", c.name, c.method, got, c.allowed)
+		}
+	}
+}
+
+// 被拒绝的方法不能到达节点，单请求和批量请求都返回 -32601
+func TestMethodPolicyDeniesRequests(t *testing.T) {
+	withTestNode(t)
+	old := methodPolicy.Load()
+	methodPolicy.Store(methodLists{deny: []string{"eth_getBalance"}})
+	t.Cleanup(func() { methodPolicy.Store(old) })
+
+	var single struct{ Error *struct{ Code int } }
+	json.Unmarshal(postJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","0x2"]}`), &single)
+	if single.Error == nil || single.Error.Code != -32601 {
+		t.Errorf("single request: %+v, want -32601", single.Error)
+	}
+	var batch []struct{ Error *struct{ Code int } }
+	json.Unmarshal(postJSONRPC(t, `[{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","0x2"]},`+
+		`{"jsonrpc":"2.0","id":2,"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000001","0x3"]}]`), &batch)
+	if len(batch) != 2 {
+		t.Fatalf("batch returned %d responses", len(batch))
+	}
+	for i, resp := range batch {
+		if resp.Error == nil || resp.Error.Code != -32601 {
+			t.Errorf("batch item %d: %+v, want -32601", i, resp.Error)
+		}
+	}
+}
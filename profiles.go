@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// clientProfile 针对特定客户端库的兼容开关，只改写返回给该客户端的响应，不影响缓存和其他客户端
+type clientProfile struct {
+	Name string `json:"name"`
+	// 数量类字段去掉前导零并转小写（"0x01" -> "0x1"），ethers v6 和 web3.py 会拒绝带前导零的值
+	NormalizeQuantities bool `json:"normalizeQuantities,omitempty"`
+	// receipt 补齐 type、effectiveGasPrice、logsBloom，不依赖上游版本
+	ReceiptFields bool `json:"receiptFields,omitempty"`
+	// 错误只保留 code 和 message，旧 Java 客户端遇到 data 字段会解析失败
+	StripErrorData bool `json:"stripErrorData,omitempty"`
+}
+
+var (
+	builtinProfiles = map[string]*clientProfile{
+		"web3py":      {Name: "web3py", NormalizeQuantities: true, ReceiptFields: true},
+		"ethers6":     {Name: "ethers6", NormalizeQuantities: true, ReceiptFields: true},
+		"legacy-java": {Name: "legacy-java", StripErrorData: true},
+	}
+	// NAME=FLAG|FLAG，FLAG 为 quantities、receipt、errordata；同名时覆盖内置配置
+	clientProfiles = parseClientProfiles(envString("CLIENT_PROFILES", ""))
+	// SUBSTRING=PROFILE，按顺序匹配 User-Agent（不区分大小写），也可以在 API key 配置里指定 profile
+	clientProfileAgents = parseProfileAgents(envString("CLIENT_PROFILE_USER_AGENTS", "web3.py=web3py,ethers/6=ethers6,okhttp=legacy-java"))
+)
+
+type profileAgentRule struct {
+	match   string
+	profile string
+}
+
+func parseClientProfiles(s string) map[string]*clientProfile {
+	profiles := make(map[string]*clientProfile, len(builtinProfiles))
+	for name, p := range builtinProfiles {
+		profiles[name] = p
+	}
+	for _, item := range splitList(s) {
+		name, flags, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			log.Printf("Invalid CLIENT_PROFILES entry %q, ignored", item)
+			continue
+		}
+		p := &clientProfile{Name: name}
+		valid := true
+		for _, f := range strings.Split(flags, "|") {
+			switch strings.TrimSpace(f) {
+			case "quantities":
+				p.NormalizeQuantities = true
+			case "receipt":
+				p.ReceiptFields = true
+			case "errordata":
+				p.StripErrorData = true
+			case "":
+			default:
+				valid = false
+			}
+		}
+		if !valid {
+			log.Printf("Invalid CLIENT_PROFILES entry %q, ignored", item)
+			continue
+		}
+		profiles[name] = p
+	}
+	return profiles
+}
+
+func parseProfileAgents(s string) []profileAgentRule {
+	var rules []profileAgentRule
+	for _, item := range splitList(s) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || clientProfiles[strings.TrimSpace(parts[1])] == nil {
+			log.Printf("Invalid CLIENT_PROFILE_USER_AGENTS entry %q, ignored", item)
+			continue
+		}
+		rules = append(rules, profileAgentRule{match: strings.ToLower(strings.TrimSpace(parts[0])), profile: strings.TrimSpace(parts[1])})
+	}
+	return rules
+}
+
+type clientProfileCtxKey struct{}
+
+// withClientProfile 优先级：X-Client-Profile 头 > API key 配置 > User-Agent
+func withClientProfile(ctx context.Context, r *http.Request) context.Context {
+	name := r.Header.Get("X-Client-Profile")
+	if name == "" {
+		if k := apiKeyFromContext(ctx); k != nil {
+			name = k.Profile
+		}
+	}
+	if name == "" {
+		ua := strings.ToLower(r.UserAgent())
+		for _, rule := range clientProfileAgents {
+			if ua != "" && strings.Contains(ua, rule.match) {
+				name = rule.profile
+				break
+			}
+		}
+	}
+	if p := clientProfiles[name]; p != nil {
+		return context.WithValue(ctx, clientProfileCtxKey{}, p)
+	}
+	return ctx
+}
+
+func clientProfileFrom(ctx context.Context) *clientProfile {
+	p, _ := ctx.Value(clientProfileCtxKey{}).(*clientProfile)
+	return p
+}
+
+// 结果本身是数量的方法
+var quantityResultMethods = toSet([]string{
+	"eth_blockNumber", "eth_chainId", "eth_gasPrice", "eth_getBalance", "eth_estimateGas",
+	"eth_getTransactionCount", "eth_getBlockTransactionCountByHash", "eth_getBlockTransactionCountByNumber",
+})
+
+// 对象结果里的数量字段；哈希、地址、input 等数据字段不能去前导零
+var quantityFields = toSet([]string{
+	"blockNumber", "number", "gas", "gasUsed", "gasLimit", "gasPrice", "cumulativeGasUsed", "effectiveGasPrice",
+	"nonce", "transactionIndex", "logIndex", "status", "type", "value", "timestamp", "size", "difficulty",
+	"totalDifficulty", "baseFeePerGas", "chainId", "v",
+})
+
+var emptyLogsBloom = "0x" + strings.Repeat("0", 512)
+
+// applyClientProfile 在副本上改写，缓存里的结果对象可能被其他请求共享
+func applyClientProfile(ctx context.Context, method string, resp *JSONRPCResponse) {
+	p := clientProfileFrom(ctx)
+	if p == nil {
+		return
+	}
+	if resp.Error != nil {
+		if e, ok := resp.Error.(map[string]interface{}); ok && p.StripErrorData {
+			if _, has := e["data"]; has {
+				resp.Error = map[string]interface{}{"code": e["code"], "message": e["message"]}
+			}
+		}
+		return
+	}
+	if resp.Result == nil || !(p.NormalizeQuantities || p.ReceiptFields) {
+		return
+	}
+	if s, ok := resp.Result.(string); ok {
+		if p.NormalizeQuantities && quantityResultMethods[method] {
+			resp.Result = normalizeQuantity(s)
+		}
+		return
+	}
+	raw, err := codec.Marshal(resp.Result)
+	if err != nil {
+		return
+	}
+	v, ok := decodeJSONNumber(raw)
+	if !ok {
+		return
+	}
+	if p.ReceiptFields && method == "eth_getTransactionReceipt" {
+		if receipt, ok := v.(map[string]interface{}); ok {
+			setDefault(receipt, "type", "0x0")
+			setDefault(receipt, "effectiveGasPrice", "0x0")
+			setDefault(receipt, "logsBloom", emptyLogsBloom)
+		}
+	}
+	if p.NormalizeQuantities {
+		normalizeQuantityFields(v)
+	}
+	resp.Result = v
+}
+
+func normalizeQuantityFields(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			if s, ok := child.(string); ok && quantityFields[k] {
+				node[k] = normalizeQuantity(s)
+				continue
+			}
+			normalizeQuantityFields(child)
+		}
+	case []interface{}:
+		for _, child := range node {
+			normalizeQuantityFields(child)
+		}
+	}
+}
+
+func normalizeQuantity(s string) string {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return s
+	}
+	digits := strings.TrimLeft(strings.ToLower(s[2:]), "0")
+	if digits == "" {
+		return "0x0"
+	}
+	for _, c := range digits {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return s
+		}
+	}
+	return "0x" + digits
+}
@@ -0,0 +1,212 @@
+// Package proxy 把 TRON 节点包装成以太坊风格的 JSON-RPC 服务。cmd/proxy 是独立运行的入口，
+// 其他 Go 服务可以用 New 嵌入，把 Handler 挂到自己的路由上
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/yourname/proxy/server"
+	"github.com/yourname/proxy/tracestore"
+)
+
+var shutdownGracePeriod = envDuration("SHUTDOWN_GRACE_PERIOD", 30*time.Second)
+
+// Config 嵌入时的配置，零值表示全部使用环境变量和 CONFIG_FILE。
+// 这里的字段优先于两者，重新加载配置时保留；其余设置在包初始化时从环境变量读取，
+// 其中有误的（CONFIG_FILE、上游 TLS 证书等）由 New 返回错误
+type Config struct {
+	// 为空时使用 LISTEN_ADDR，默认 :9090；只有 ListenAndServe 使用
+	ListenAddr string
+	// 对应 TRON_JSONRPC_ENDPOINT、TRON_REST_ENDPOINT 和 TRON_SOLIDITY_ENDPOINT
+	JSONRPCEndpoints  []string
+	RESTEndpoints     []string
+	SolidityEndpoints []string
+	// 不为 nil 时代替 TRACE_STORE
+	TraceStore tracestore.Store
+}
+
+// Proxy 连接池、缓存、配置和后台任务都是进程级的，一个进程只能创建一个，New 失败后也不能重试
+type Proxy struct {
+	addr    string
+	handler http.Handler
+	closers []func()
+}
+
+var created int32
+
+// New 应用 cfg，打开存储并启动后台任务（健康检查、区块监听、trace 预生成等）。
+// 配置有误或存储打不开时返回错误，已经打开的存储会关闭
+func New(cfg Config) (*Proxy, error) {
+	if !atomic.CompareAndSwapInt32(&created, 0, 1) {
+		return nil, errors.New("proxy: New called more than once in this process")
+	}
+	log.SetOutput(io.MultiWriter(log.Writer(), logTail))
+	log.Printf("JSON codec: %s", codecName)
+	if err := applyConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{addr: cfg.ListenAddr}
+	if p.addr == "" {
+		// 同一台机器上跑备用实例时用 LISTEN_ADDR 换端口
+		p.addr = envString("LISTEN_ADDR", ":9090")
+	}
+	if err := initStorage(); err != nil {
+		return nil, err
+	}
+	p.onClose(closeStorage)
+	if err := startDiskCache(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.onClose(closeDiskCache)
+	if err := startRedis(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if err := startAPIKeyReloader(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	loadSnapshotFile()
+	p.onClose(saveSnapshotFile)
+	startHealthChecks()
+	startCertExpiryChecks()
+	startConfigReloader()
+	startBlockWatcher()
+	startTracePregeneration()
+	startWebhooks()
+	p.onClose(stopWebhooks)
+	startTimeSyncChecks()
+	startTraceReprocessing()
+	startTraceStoreStats()
+	startContractIndexer()
+	p.onClose(contractCreations.flush)
+	startUsageReports()
+	p.onClose(usage.flush)
+	startStandby()
+
+	p.handler = withCompression(newServeMux())
+	startAdminServer()
+	return p, nil
+}
+
+// applyConfig 把 cfg 写进 embeddedConfig，上游节点立即替换；然后打开 trace 存储，
+// 读取包初始化时没有处理的配置（其他网络、签名密钥、静态兜底响应、录制和回放）
+func applyConfig(cfg Config) error {
+	if err := errors.Join(configErrors...); err != nil {
+		return err
+	}
+	pools := []struct {
+		key  string
+		pool *upstreamPool
+		urls []string
+	}{
+		{"TRON_JSONRPC_ENDPOINT", jsonrpcUpstreams, cfg.JSONRPCEndpoints},
+		{"TRON_REST_ENDPOINT", restUpstreams, cfg.RESTEndpoints},
+		{"TRON_SOLIDITY_ENDPOINT", solidityUpstreams, cfg.SolidityEndpoints},
+	}
+	for _, c := range pools {
+		if len(c.urls) == 0 {
+			continue
+		}
+		embeddedConfig[c.key] = strings.Join(c.urls, ",")
+		c.pool.setURLs(c.urls)
+	}
+	if cfg.TraceStore != nil {
+		traces = instrumentedTraceStore{cfg.TraceStore}
+	} else {
+		s, err := openTraceStore(envString("TRACE_STORE", traceDir))
+		if err != nil {
+			return err
+		}
+		traces = s
+	}
+
+	var err error
+	if networks, err = loadNetworks(); err != nil {
+		return err
+	}
+	if requestSigningKeys, err = parseRequestSigningKeys(configValue("REQUEST_SIGNING_KEYS")); err != nil {
+		return err
+	}
+	responseSigner, err = newResponseSigner(envString("RESPONSE_SIGNING", "off"), configValue("RESPONSE_SIGNING_KEY"), envString("RESPONSE_SIGNING_KEY_ID", "proxy"))
+	if err != nil {
+		return err
+	}
+	if fallbackResponses, err = loadFallbackResponses(configValue("FALLBACK_RESPONSES"), configValue("FALLBACK_RESPONSES_FILE")); err != nil {
+		return err
+	}
+	if replayer, err = loadReplay(replayDir); err != nil {
+		return err
+	}
+	recorder, err = newExchangeRecorder(recordDir)
+	return err
+}
+
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	registerJSONRPCPaths(mux)
+	registerNetworkPaths(mux)
+	// Infura 风格的 /v1/<key>/jsonrpc，其余 /v1/* 透传到 TRON 事件 API
+	mux.HandleFunc("/v1/", withBodyLimit(withCORS(signResponses(withRequestID(standbyGate(authMiddleware(expressMiddleware(rateLimitMiddleware(handleV1)))))))))
+	mux.HandleFunc("/wallet/", withBodyLimit(withCORS(signResponses(withRequestID(standbyGate(authMiddleware(expressMiddleware(rateLimitMiddleware(handleREST)))))))))
+	mux.HandleFunc("/walletsolidity/", withBodyLimit(withCORS(signResponses(withRequestID(standbyGate(authMiddleware(expressMiddleware(rateLimitMiddleware(handleREST)))))))))
+	mux.HandleFunc("/bulk", signResponses(withRequestID(standbyGate(authMiddleware(rateLimitMiddleware(handleBulk))))))
+	mux.HandleFunc("/signing-key", handleSigningKey)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	// 旧的探针地址，等同 /healthz
+	mux.HandleFunc("/test", handleHealthz)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/openrpc.json", withCORS(handleOpenRPC))
+	mux.HandleFunc("/examples", handleExamples)
+	registerAdminRoutes(mux)
+	return mux
+}
+
+// Handler 全部对外接口（JSON-RPC、REST 透传、/metrics、/admin 等），已经包好压缩
+func (p *Proxy) Handler() http.Handler {
+	return p.handler
+}
+
+// ListenAndServe 收到 SIGTERM/SIGINT 后停止接收新连接，等待进行中的请求完成；
+// 超过 SHUTDOWN_GRACE_PERIOD 后取消所有请求并强制关闭
+func (p *Proxy) ListenAndServe() error {
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return fmt.Errorf("TLS configuration error: %w", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return server.Run(ctx, server.Options{
+		Addr:        p.addr,
+		Handler:     p.handler,
+		TLSConfig:   tlsConfig,
+		GracePeriod: shutdownGracePeriod,
+		Inflight:    func() int64 { return atomic.LoadInt64(&metricInflight) },
+	})
+}
+
+func (p *Proxy) onClose(fn func()) {
+	p.closers = append(p.closers, fn)
+}
+
+// Close 按启动的相反顺序保存状态、关闭存储，在服务停止之后调用
+func (p *Proxy) Close() {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		p.closers[i]()
+	}
+	p.closers = nil
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourname/proxy/tracestore"
+)
+
+func TestApplyConfig(t *testing.T) {
+	oldRPC, oldRest, oldSolidity, oldTraces := jsonrpcUpstreams, restUpstreams, solidityUpstreams, traces
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", nil)
+	restUpstreams = newUpstreamPool("rest", []string{"http://rest-from-env:8090"})
+	solidityUpstreams = newUpstreamPool("solidity", nil)
+	t.Cleanup(func() {
+		jsonrpcUpstreams, restUpstreams, solidityUpstreams, traces = oldRPC, oldRest, oldSolidity, oldTraces
+		embeddedConfig = map[string]string{}
+	})
+	t.Setenv("TRON_JSONRPC_ENDPOINT", "http://jsonrpc-from-env:8545")
+
+	dir := t.TempDir()
+	err := applyConfig(Config{
+		JSONRPCEndpoints: []string{"http://a:8545", "http://b:8545"},
+		TraceStore:       tracestore.Local{Dir: dir},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(jsonrpcUpstreams.list()); n != 2 {
+		t.Errorf("jsonrpc pool has %d node(s), want 2", n)
+	}
+	// 没有设置的字段保持环境变量里的配置
+	if nodes := restUpstreams.list(); len(nodes) != 1 || nodes[0].url != "http://rest-from-env:8090" {
+		t.Errorf("rest pool changed: %d node(s)", len(nodes))
+	}
+	// 重新加载时按 configValue 重建节点列表，Config 的值要优先于环境变量
+	if v := configValue("TRON_JSONRPC_ENDPOINT"); v != "http://a:8545,http://b:8545" {
+		t.Errorf("configValue = %q", v)
+	}
+	if _, ok := embeddedConfig["TRON_REST_ENDPOINT"]; ok {
+		t.Error("unset field written to embeddedConfig")
+	}
+	if s, ok := baseTraceStore(traces).(tracestore.Local); !ok || s.Dir != dir {
+		t.Errorf("trace store = %v", traces)
+	}
+	// 替换后的存储同样记录指标
+	if _, ok := traces.(instrumentedTraceStore); !ok {
+		t.Errorf("trace store not instrumented: %T", traces)
+	}
+}
+
+// 配置有误时 applyConfig 返回错误而不是退出进程；Config.TraceStore 设置后不再打开 TRACE_STORE
+func TestApplyConfigErrors(t *testing.T) {
+	oldTraces := traces
+	t.Cleanup(func() {
+		traces = oldTraces
+		requestSigningKeys, responseSigner = nil, nil
+		embeddedConfig = map[string]string{}
+	})
+	t.Setenv("TRACE_STORE", "ftp://traces.example/x")
+
+	if err := applyConfig(Config{TraceStore: tracestore.Local{Dir: t.TempDir()}}); err != nil {
+		t.Errorf("TRACE_STORE opened although Config.TraceStore is set: %v", err)
+	}
+	if err := applyConfig(Config{}); err == nil || !strings.Contains(err.Error(), "TRACE_STORE") {
+		t.Errorf("invalid TRACE_STORE: err = %v", err)
+	}
+
+	t.Setenv("TRACE_STORE", t.TempDir())
+	for key, value := range map[string]string{
+		"REQUEST_SIGNING_KEYS": "no-secret",
+		"RESPONSE_SIGNING":     "rsa",
+		"FALLBACK_RESPONSES":   "{not json",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if err := applyConfig(Config{}); err == nil {
+				t.Errorf("%s=%q accepted", key, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 额度用完时的错误码，和限流的 -32005 区分开，客户端据此停止重试直到 resetsAt
+const errCodeQuotaExceeded = -32006
+
+var (
+	// 每个 API key 每天 / 每月（UTC）可用的 compute units，0 不限；key 配置里的 dailyQuota、monthlyQuota 优先，-1 表示不限
+	quotaDailyUnits   = int64(envInt("QUOTA_DAILY_UNITS", 0))
+	quotaMonthlyUnits = int64(envInt("QUOTA_MONTHLY_UNITS", 0))
+
+	quotas = &quotaTracker{daily: make(map[string]int64), monthly: make(map[string]int64), pending: make(map[string]int64)}
+)
+
+// quotaTracker 按 usageKeyLabel 累计当天和当月的 compute units，来源和 usage 报表相同。
+// 请求开始前先预留 units，结束时（用量已经记下或者请求没有执行）释放，并发请求不会一起越过额度
+type quotaTracker struct {
+	mu      sync.Mutex
+	day     string
+	month   string
+	daily   map[string]int64
+	monthly map[string]int64
+	pending map[string]int64
+}
+
+func usageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func (q *quotaTracker) rolloverLocked(now time.Time) {
+	if day := usageDay(now); day != q.day {
+		q.day, q.daily = day, make(map[string]int64)
+	}
+	if month := usageMonth(now); month != q.month {
+		q.month, q.monthly = month, make(map[string]int64)
+	}
+}
+
+func (q *quotaTracker) add(key string, units int64, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rolloverLocked(now)
+	q.daily[key] += units
+	q.monthly[key] += units
+}
+
+// reserve 在同一把锁里检查并预留，超出额度时不预留
+func (q *quotaTracker) reserve(key string, units, dailyLimit, monthlyLimit int64, now time.Time) *quotaExceeded {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rolloverLocked(now)
+	day, month := q.daily[key]+q.pending[key], q.monthly[key]+q.pending[key]
+	if monthlyLimit > 0 && month+units > monthlyLimit {
+		return &quotaExceeded{Period: "monthly", Limit: monthlyLimit, Used: month,
+			ResetsAt: time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)}
+	}
+	if dailyLimit > 0 && day+units > dailyLimit {
+		return &quotaExceeded{Period: "daily", Limit: dailyLimit, Used: day,
+			ResetsAt: time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)}
+	}
+	q.pending[key] += units
+	return nil
+}
+
+func (q *quotaTracker) release(key string, units int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[key] -= units; q.pending[key] <= 0 {
+		delete(q.pending, key)
+	}
+}
+
+func (q *quotaTracker) used(key string, now time.Time) (day, month int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rolloverLocked(now)
+	return q.daily[key], q.monthly[key]
+}
+
+// load 启动时从本月已写出的 usage 报表恢复计数，当天的报表也在其中
+func (q *quotaTracker) load(now time.Time) {
+	if usageReportDir == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.day, q.month = "", ""
+	q.rolloverLocked(now)
+	today := now.UTC()
+	for d := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC); !d.After(today); d = d.AddDate(0, 0, 1) {
+		rows, err := readUsageReport(usageDay(d))
+		if err != nil {
+			continue
+		}
+		for _, r := range rows {
+			q.monthly[r.Key] += r.ComputeUnits
+			if r.Date == q.day {
+				q.daily[r.Key] += r.ComputeUnits
+			}
+		}
+	}
+}
+
+func keyQuotas(k *apiKey) (daily, monthly int64) {
+	daily, monthly = quotaDailyUnits, quotaMonthlyUnits
+	if k.DailyQuota != 0 {
+		daily = k.DailyQuota
+	}
+	if k.MonthlyQuota != 0 {
+		monthly = k.MonthlyQuota
+	}
+	return daily, monthly
+}
+
+type quotaExceeded struct {
+	Period   string    `json:"period"`
+	Limit    int64     `json:"limit"`
+	Used     int64     `json:"used"`
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+func (e *quotaExceeded) message() string {
+	return fmt.Sprintf("Quota exceeded: %s limit of %d compute units reached, resets at %s", e.Period, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *quotaExceeded) response(id interface{}) JSONRPCResponse {
+	resp := jsonError(id, errCodeQuotaExceeded, e.message())
+	resp.Error.(map[string]interface{})["data"] = e
+	return resp
+}
+
+// quotaRejected 被额度拒绝的请求不计入用量，否则用完之后的重试会继续累加
+func quotaRejected(resp JSONRPCResponse) bool {
+	e, ok := resp.Error.(map[string]interface{})
+	return ok && e["code"] == errCodeQuotaExceeded
+}
+
+// reserveQuota 匿名请求和没有配置额度的 key 不检查；units 为这次请求要消耗的量。
+// 没有超额时预留 units，调用方在记下用量之后调用 release；请求没有执行完（出错返回、读请求体失败）时
+// 用量不会被记下，release 相当于退回预留
+func reserveQuota(ctx context.Context, units int64) (release func(), exceeded *quotaExceeded) {
+	release = func() {}
+	k := apiKeyFromContext(ctx)
+	if k == nil {
+		return release, nil
+	}
+	dailyLimit, monthlyLimit := keyQuotas(k)
+	if dailyLimit <= 0 && monthlyLimit <= 0 {
+		return release, nil
+	}
+	key := usageKeyLabel(ctx)
+	if exceeded := quotas.reserve(key, units, dailyLimit, monthlyLimit, time.Now().UTC()); exceeded != nil {
+		return release, exceeded
+	}
+	return func() { quotas.release(key, units) }, nil
+}
+
+// hasQuota 配置了额度的 key 不走流式路径，保证每个请求都经过检查
+func hasQuota(ctx context.Context) bool {
+	k := apiKeyFromContext(ctx)
+	if k == nil {
+		return false
+	}
+	daily, monthly := keyQuotas(k)
+	return daily > 0 || monthly > 0
+}
+
+type quotaStatus struct {
+	Key          string `json:"key"`
+	DailyUsed    int64  `json:"dailyUsed"`
+	DailyLimit   int64  `json:"dailyLimit,omitempty"`
+	MonthlyUsed  int64  `json:"monthlyUsed"`
+	MonthlyLimit int64  `json:"monthlyLimit,omitempty"`
+	Exhausted    string `json:"exhausted,omitempty"`
+}
+
+// handleAdminQuotas GET /admin/quotas 列出各 key 当天和当月的用量和额度；明细见 /admin/usage
+func handleAdminQuotas(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	seen := map[string]bool{}
+	var out []quotaStatus
+	keys, _ := apiKeys.Load().(map[string]*apiKey)
+	for _, k := range keys {
+		label := apiKeyLabel(k.Name, k.Key)
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		s := quotaStatus{Key: label}
+		s.DailyLimit, s.MonthlyLimit = keyQuotas(k)
+		s.DailyUsed, s.MonthlyUsed = quotas.used(label, now)
+		switch {
+		case s.MonthlyLimit > 0 && s.MonthlyUsed >= s.MonthlyLimit:
+			s.Exhausted = "monthly"
+		case s.DailyLimit > 0 && s.DailyUsed >= s.DailyLimit:
+			s.Exhausted = "daily"
+		}
+		if s.DailyLimit < 0 {
+			s.DailyLimit = 0
+		}
+		if s.MonthlyLimit < 0 {
+			s.MonthlyLimit = 0
+		}
+		out = append(out, s)
+	}
+	// 不在当前 key 列表里的用量（已删除的 key、匿名请求）
+	quotas.mu.Lock()
+	quotas.rolloverLocked(now)
+	for label, monthly := range quotas.monthly {
+		if !seen[label] {
+			out = append(out, quotaStatus{Key: label, DailyUsed: quotas.daily[label], MonthlyUsed: monthly})
+		}
+	}
+	quotas.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return strings.Compare(out[i].Key, out[j].Key) < 0 })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"day": usageDay(now), "month": usageMonth(now), "keys": out})
+}
+
+func logQuotaRejected(ctx context.Context, method string, e *quotaExceeded) {
+	log.Printf("Request %s from %s rejected: %s quota exhausted (%d/%d)", method, usageKeyLabel(ctx), e.Period, e.Used, e.Limit)
+}
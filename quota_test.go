@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 并发请求同时检查额度时只能放行额度以内的数量；释放的预留要退回
+func TestQuotaReservation(t *testing.T) {
+	old := quotas
+	quotas = &quotaTracker{daily: make(map[string]int64), monthly: make(map[string]int64), pending: make(map[string]int64)}
+	t.Cleanup(func() { quotas = old })
+	ctx := context.WithValue(context.Background(), apiKeyCtxKey{}, &apiKey{Key: "k", Name: "quota-test", DailyQuota: 10})
+
+	var admitted int32
+	var releases []func()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, exceeded := reserveQuota(ctx, 1); exceeded == nil {
+				atomic.AddInt32(&admitted, 1)
+				mu.Lock()
+				releases = append(releases, release)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted != 10 {
+		t.Fatalf("admitted %d concurrent requests with a quota of 10", admitted)
+	}
+
+	// 没有记下用量就释放，额度退回
+	for _, release := range releases[:5] {
+		release()
+	}
+	if _, exceeded := reserveQuota(ctx, 5); exceeded != nil {
+		t.Errorf("released reservations not refunded: %s", exceeded.message())
+	}
+	// 记下用量之后释放，额度不退回
+	quotas.add("quota-test", 5, time.Now())
+	for _, release := range releases[5:] {
+		release()
+	}
+	if _, exceeded := reserveQuota(ctx, 1); exceeded == nil {
+		t.Error("recorded usage was refunded")
+	}
+}
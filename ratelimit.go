@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	rateLimitIP         = newRateLimiter("ip", envFloat("RATE_LIMIT_IP_RPS", 0), envInt("RATE_LIMIT_IP_BURST", 0))
+	rateLimitKey        = newRateLimiter("key", envFloat("RATE_LIMIT_KEY_RPS", 0), envInt("RATE_LIMIT_KEY_BURST", 0))
+	rateLimitRetryAfter = envString("RATE_LIMIT_RETRY_AFTER", "true") == "true"
+	// 只有部署在可信反向代理之后才使用 X-Forwarded-For
+	rateLimitTrustProxy = configValue("RATE_LIMIT_TRUST_PROXY") == "true"
+
+	metricRateLimited = newCounterVec("proxy_rate_limited_total",
+		"Requests rejected by the rate limiter, by scope.", "scope")
+)
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter 按 key 维护令牌桶，rate 为每秒补充的令牌数
+type rateLimiter struct {
+	scope   string
+	limits  atomic.Value // rateLimits，重新加载配置时替换
+	cleanup sync.Once
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type rateLimits struct {
+	rate  float64
+	burst float64
+}
+
+func newRateLimiter(scope string, rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{scope: scope, buckets: make(map[string]*tokenBucket)}
+	l.configure(rate, burst)
+	return l
+}
+
+// configure 已有的桶保留当前令牌数，超过新 burst 的部分在下次取令牌时截掉
+func (l *rateLimiter) configure(rate float64, burst int) {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	l.limits.Store(rateLimits{rate: rate, burst: float64(burst)})
+	if rate > 0 {
+		log.Printf("Rate limit per %s: %.2f req/s, burst %d", l.scope, rate, burst)
+		l.cleanup.Do(func() { go l.cleanupLoop() })
+	}
+}
+
+func (l *rateLimiter) params() rateLimits {
+	return l.limits.Load().(rateLimits)
+}
+
+func (l *rateLimiter) enabled() bool {
+	return l.params().rate > 0
+}
+
+func (l *rateLimiter) bucket(key string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.params().burst, last: time.Now()}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// take 尝试取 n 个令牌，失败时返回需要等待的时间
+func (l *rateLimiter) take(key string, n float64) (bool, time.Duration) {
+	lim := l.params()
+	if redis.shares("ratelimit") {
+		if ok, wait, err := l.takeShared(key, n, lim); err == nil {
+			return ok, wait
+		}
+	}
+	b := l.bucket(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(lim.burst, b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+	b.last = now
+	if b.tokens >= n {
+		b.tokens -= n
+		return true, 0
+	}
+	wait := time.Duration((n - b.tokens) / lim.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *rateLimiter) refund(key string, n float64) {
+	if redis.shares("ratelimit") {
+		if _, _, err := l.takeShared(key, -n, l.params()); err == nil {
+			return
+		}
+	}
+	b := l.bucket(key)
+	b.mu.Lock()
+	b.tokens = math.Min(l.params().burst, b.tokens+n)
+	b.mu.Unlock()
+}
+
+// redisTokenBucket 和本地令牌桶相同的算法，在 Redis 里原子执行，所有实例共用一个桶；
+// 时间取 Redis 服务器的时钟，各实例的时钟偏差不影响结果。n 为负数时退还令牌
+const redisTokenBucket = `
+local rate, burst, n = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens, last = tonumber(b[1]), tonumber(b[2])
+if tokens == nil then
+  tokens, last = burst, now
+end
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+local ok, wait = 1, 0
+if tokens >= n then
+  tokens = math.min(burst, tokens - n)
+else
+  ok, wait = 0, math.ceil((n - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {ok, wait}
+`
+
+// takeShared Redis 不可用时返回错误，调用方退回本地令牌桶
+func (l *rateLimiter) takeShared(key string, n float64, lim rateLimits) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	v, err := redis.eval(ctx, redisTokenBucket, []string{redisPrefix + "ratelimit:" + l.scope + ":" + key},
+		formatFloat(lim.rate), formatFloat(lim.burst), formatFloat(n))
+	if err != nil {
+		return false, 0, err
+	}
+	reply, _ := v.([]interface{})
+	if len(reply) != 2 {
+		return false, 0, fmt.Errorf("unexpected reply %v", v)
+	}
+	ok, _ := reply[0].(int64)
+	wait, _ := reply[1].(int64)
+	return ok == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// cleanupLoop 清理已经回满的闲置桶，避免 map 无限增长
+func (l *rateLimiter) cleanupLoop() {
+	for range time.Tick(time.Minute) {
+		lim := l.params()
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			b.mu.Lock()
+			idle := time.Since(b.last).Seconds()*lim.rate+b.tokens >= lim.burst
+			b.mu.Unlock()
+			if idle {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if rateLimitTrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type rateLimitCtxKey struct{}
+
+type rateLimitSubject struct {
+	ip  string
+	key string
+}
+
+// allow 对 IP 和 API key 两个维度同时扣减，任一不足则整体拒绝
+func (s rateLimitSubject) allow(n float64) (bool, time.Duration, string) {
+	if rateLimitIP.enabled() {
+		if ok, wait := rateLimitIP.take(s.ip, n); !ok {
+			return false, wait, "ip"
+		}
+	}
+	if rateLimitKey.enabled() && s.key != "" {
+		if ok, wait := rateLimitKey.take(s.key, n); !ok {
+			if rateLimitIP.enabled() {
+				rateLimitIP.refund(s.ip, n)
+			}
+			return false, wait, "key"
+		}
+	}
+	return true, 0, ""
+}
+
+func rateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if (!rateLimitIP.enabled() && !rateLimitKey.enabled()) || isExpress(r.Context()) {
+			next(w, r)
+			return
+		}
+		subject := rateLimitSubject{ip: clientIP(r), key: r.Header.Get("X-API-Key")}
+		if k := apiKeyFromContext(r.Context()); k != nil {
+			subject.key = k.Key
+		}
+		if ok, wait, scope := subject.allow(1); !ok {
+			writeRateLimited(w, wait, scope)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), rateLimitCtxKey{}, subject)))
+	}
+}
+
+// chargeBatch 批量请求按条目计费，入口中间件已扣过 1 个令牌
+func chargeBatch(ctx context.Context, items int) (bool, time.Duration, string) {
+	return chargeItems(ctx, items-1)
+}
+
+func chargeItems(ctx context.Context, n int) (bool, time.Duration, string) {
+	subject, ok := ctx.Value(rateLimitCtxKey{}).(rateLimitSubject)
+	if !ok || n <= 0 {
+		return true, 0, ""
+	}
+	return subject.allow(float64(n))
+}
+
+func writeRateLimited(w http.ResponseWriter, wait time.Duration, scope string) {
+	metricRateLimited.inc(scope)
+	if rateLimitRetryAfter {
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32005,"message":"limit exceeded"}}` + "\n"))
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 令牌用完后拒绝，并带上 Retry-After；其他 IP 不受影响
+func TestRateLimitRefusesAtLimit(t *testing.T) {
+	oldIP, oldKey := rateLimitIP, rateLimitKey
+	rateLimitIP = newRateLimiter("ip", 0.001, 2)
+	rateLimitKey = newRateLimiter("key", 0, 0)
+	t.Cleanup(func() { rateLimitIP, rateLimitKey = oldIP, oldKey })
+
+	h := rateLimitMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+	send := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", nil)
+		r.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := send("198.51.100.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: HTTP %d", i+1, rec.Code)
+		}
+	}
+	rec := send("198.51.100.1:1234")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the burst: HTTP %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := send("198.51.100.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("another IP: HTTP %d", rec.Code)
+	}
+}
+
+// key 维度拒绝时退还 IP 维度已经扣的令牌
+func TestRateLimitKeyRefundsIP(t *testing.T) {
+	oldIP, oldKey := rateLimitIP, rateLimitKey
+	rateLimitIP = newRateLimiter("ip", 0.001, 2)
+	rateLimitKey = newRateLimiter("key", 0.001, 1)
+	t.Cleanup(func() { rateLimitIP, rateLimitKey = oldIP, oldKey })
+
+	s := rateLimitSubject{ip: "198.51.100.1", key: "k"}
+	if ok, _, _ := s.allow(1); !ok {
+		t.Fatal("first request refused")
+	}
+	if ok, _, scope := s.allow(1); ok || scope != "key" {
+		t.Fatalf("second request: ok=%v scope=%q, want refused by key", ok, scope)
+	}
+	// IP 桶里还剩 1 个令牌
+	if ok, _ := rateLimitIP.take("198.51.100.1", 1); !ok {
+		t.Error("IP token not refunded after the key limit refused the request")
+	}
+}
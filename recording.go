@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// RECORD_DIR 不为空时把每个请求和上游结果按天写入 requests-YYYY-MM-DD.ndjson，
+	// RECORD_SAMPLE_RATE 为记录比例，RECORD_REDACT 中的字段（params 和结果里任意层级）替换为 "REDACTED"
+	recordDir        = envString("RECORD_DIR", "")
+	recordSampleRate = envFloat("RECORD_SAMPLE_RATE", 1)
+	recordRedact     = toSet(splitList(envString("RECORD_REDACT", "privateKey,private_key,password")))
+
+	// REPLAY_DIR 不为空时从录制文件返回结果，不访问上游；REPLAY_MISS=upstream 时未录制的请求照常转发
+	replayDir  = envString("REPLAY_DIR", "")
+	replayMiss = envString("REPLAY_MISS", "error")
+
+	// 由 New 创建
+	recorder *exchangeRecorder
+	replayer *replayStore
+)
+
+// recordedExchange 录制文件的一行。Key 按原始参数计算，参数脱敏后仍能匹配
+type recordedExchange struct {
+	Time       time.Time       `json:"time"`
+	Key        string          `json:"key"`
+	Method     string          `json:"method"`
+	Params     json.RawMessage `json:"params"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      interface{}     `json:"error,omitempty"`
+	DurationMs float64         `json:"durationMs"`
+}
+
+func exchangeKey(req JSONRPCRequest) string {
+	return cacheKey(req.Method, paramsHash(req.Params))
+}
+
+type exchangeRecorder struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+	w    *bufio.Writer
+}
+
+func newExchangeRecorder(dir string) (*exchangeRecorder, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating RECORD_DIR %s: %w", dir, err)
+	}
+	r := &exchangeRecorder{dir: dir}
+	go func() {
+		for range time.Tick(time.Second) {
+			r.flush()
+		}
+	}()
+	log.Printf("Recording requests to %s (sample rate %.2f)", dir, recordSampleRate)
+	return r, nil
+}
+
+// record 在 dispatch 之后调用，记录的是上游（或代理自己组装）的结果，不含返回前的改写
+func (r *exchangeRecorder) record(req JSONRPCRequest, resp JSONRPCResponse, d time.Duration) {
+	if r == nil || (recordSampleRate < 1 && rand.Float64() >= recordSampleRate) {
+		return
+	}
+	params, _ := json.Marshal(req.Params)
+	e := recordedExchange{Time: time.Now().UTC(), Key: exchangeKey(req), Method: req.Method,
+		Params: redactJSON(params), DurationMs: float64(d.Microseconds()) / 1000}
+	if resp.Error != nil {
+		e.Error = resp.Error
+	} else {
+		result, err := json.Marshal(resp.Result)
+		if err != nil {
+			return
+		}
+		e.Result = redactJSON(result)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.openLocked(usageDay(e.Time)); err != nil {
+		log.Printf("Error opening recording file: %v", err)
+		return
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+func (r *exchangeRecorder) openLocked(day string) error {
+	if r.file != nil && r.day == day {
+		return nil
+	}
+	if r.file != nil {
+		r.w.Flush()
+		r.file.Close()
+		r.file = nil
+	}
+	f, err := os.OpenFile(filepath.Join(r.dir, "requests-"+day+".ndjson"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.day, r.file, r.w = day, f, bufio.NewWriterSize(f, 64<<10)
+	return nil
+}
+
+func (r *exchangeRecorder) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w != nil {
+		if err := r.w.Flush(); err != nil {
+			log.Printf("Error writing recording file: %v", err)
+		}
+	}
+}
+
+// redactJSON 不是 JSON 或没有需要脱敏的字段时原样返回
+func redactJSON(raw []byte) json.RawMessage {
+	if len(recordRedact) == 0 {
+		return raw
+	}
+	var v interface{}
+	if json.Unmarshal(raw, &v) != nil {
+		return raw
+	}
+	if !redactValue(v) {
+		return raw
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
+func redactValue(v interface{}) bool {
+	changed := false
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, child := range x {
+			if recordRedact[k] {
+				x[k] = "REDACTED"
+				changed = true
+				continue
+			}
+			changed = redactValue(child) || changed
+		}
+	case []interface{}:
+		for _, child := range x {
+			changed = redactValue(child) || changed
+		}
+	}
+	return changed
+}
+
+// replayStore 同一个请求录制了多次时按录制顺序依次返回，用完后一直返回最后一条，重放结果是确定的
+type replayStore struct {
+	mu      sync.Mutex
+	entries map[string][]recordedExchange
+	next    map[string]int
+}
+
+func loadReplay(dir string) (*replayStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil || len(files) == 0 {
+		return nil, fmt.Errorf("no recordings found in REPLAY_DIR %s", dir)
+	}
+	sort.Strings(files)
+	s := &replayStore{entries: make(map[string][]recordedExchange), next: make(map[string]int)}
+	total := 0
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading recording %s: %w", name, err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 256<<20)
+		for line := 1; scanner.Scan(); line++ {
+			var e recordedExchange
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Key == "" {
+				log.Printf("Skipping invalid recording %s:%d", name, line)
+				continue
+			}
+			s.entries[e.Key] = append(s.entries[e.Key], e)
+			total++
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("Error reading recording %s: %v", name, err)
+		}
+		f.Close()
+	}
+	log.Printf("Replay mode: %d recorded response(s) for %d distinct request(s) from %d file(s), misses %s", total, len(s.entries), len(files), replayMiss)
+	return s, nil
+}
+
+func (s *replayStore) lookup(req JSONRPCRequest) (recordedExchange, bool) {
+	key := exchangeKey(req)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.entries[key]
+	if len(list) == 0 {
+		return recordedExchange{}, false
+	}
+	i := s.next[key]
+	if i < len(list)-1 {
+		s.next[key] = i + 1
+	}
+	return list[i], true
+}
+
+// replayResponse ok 为 false 表示没有录制且 REPLAY_MISS=upstream，由调用方照常处理
+func (s *replayStore) replayResponse(req JSONRPCRequest) (JSONRPCResponse, bool) {
+	e, found := s.lookup(req)
+	if !found {
+		if strings.EqualFold(replayMiss, "upstream") {
+			return JSONRPCResponse{}, false
+		}
+		return jsonError(req.ID, errCodeUnavailable, "No recorded response for "+req.Method+" with these params"), true
+	}
+	if e.Error != nil {
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Error: e.Error}, true
+	}
+	result := e.Result
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}, true
+}
+
+// dispatchRecorded 替代 withCache(ctx, req, dispatchRequest)：重放模式下优先返回录制结果，录制模式下记下结果
+func dispatchRecorded(req JSONRPCRequest, dispatch func() JSONRPCResponse) JSONRPCResponse {
+	if replayer != nil {
+		if resp, ok := replayer.replayResponse(req); ok {
+			return resp
+		}
+	}
+	start := time.Now()
+	resp := dispatch()
+	recorder.record(req, resp, time.Since(start))
+	return resp
+}
+
+// replayBatch 批量请求逐条重放，REPLAY_MISS=upstream 时未录制的条目逐条转发
+func replayBatch(ctx context.Context, reqs []JSONRPCRequest) []JSONRPCResponse {
+	responses := make([]JSONRPCResponse, len(reqs))
+	for i := range reqs {
+		req := reqs[i]
+		responses[i] = dispatchRecorded(req, func() JSONRPCResponse { return withCache(ctx, req, dispatchRequest) })
+	}
+	return responses
+}
+
+// recordBatch 转发的批量响应顺序不一定和请求一致，按 id 对应
+func recordBatch(reqs []JSONRPCRequest, responses []JSONRPCResponse, d time.Duration) {
+	if recorder == nil {
+		return
+	}
+	byID := make(map[string]JSONRPCRequest, len(reqs))
+	for _, r := range reqs {
+		byID[idKey(r.ID)] = r
+	}
+	for _, resp := range responses {
+		if req, ok := byID[idKey(resp.ID)]; ok {
+			recorder.record(req, resp, d)
+		}
+	}
+}
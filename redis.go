@@ -0,0 +1,361 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// REDIS_URL redis://[:password@]host:port/db 或 rediss://（TLS），未配置时各实例独立保存状态。
+	// 多个实例在负载均衡后面运行时，用它共享响应缓存、限流令牌桶和过滤器
+	redisURL      = configValue("REDIS_URL")
+	redisPrefix   = envString("REDIS_PREFIX", "tron-proxy:")
+	redisTimeout  = envDuration("REDIS_TIMEOUT", 500*time.Millisecond)
+	redisPoolSize = envInt("REDIS_POOL_SIZE", 16)
+	// 共享哪些状态：cache、ratelimit、filters
+	redisShared = toSet(splitList(envString("REDIS_SHARED", "cache,ratelimit,filters")))
+
+	redis *redisClient
+
+	metricRedisErrors = newCounterVec("proxy_redis_errors_total",
+		"Failed Redis commands by operation; the proxy falls back to local state.", "op")
+)
+
+// errRedisNil 键不存在
+var errRedisNil = errors.New("redis: nil")
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisClient 只实现代理用到的 RESP2 子集，连接池大小固定
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	tlsName  string
+	password string
+	username string
+	db       int
+	idle     chan *redisConn
+	slots    chan struct{}
+	// 出错后只记一条日志，恢复时再记一条
+	down int32
+}
+
+type redisConn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func startRedis() error {
+	if redisURL == "" {
+		return nil
+	}
+	c, err := newRedisClient(redisURL)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.do(ctx, "PING"); err != nil {
+		// 启动时不可用也继续运行，命令失败时退回本地状态
+		log.Printf("Redis %s is not reachable: %v", c.addr, err)
+	}
+	redis = c
+	var shared []string
+	for _, s := range []string{"cache", "ratelimit", "filters"} {
+		if redisShared[s] {
+			shared = append(shared, s)
+		}
+	}
+	log.Printf("Sharing %s through Redis at %s", strings.Join(shared, ", "), c.addr)
+	startSharedCache()
+	return nil
+}
+
+func newRedisClient(raw string) (*redisClient, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisClient{addr: u.Host, tlsName: u.Hostname()}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.useTLS = true
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+		c.username = u.User.Username()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid database %q", db)
+		}
+	}
+	size := redisPoolSize
+	if size <= 0 {
+		size = 1
+	}
+	c.idle = make(chan *redisConn, size)
+	c.slots = make(chan struct{}, size)
+	return c, nil
+}
+
+// shares nil 时表示未开启
+func (c *redisClient) shares(what string) bool {
+	return c != nil && redisShared[what]
+}
+
+func (c *redisClient) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: redisTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.useTLS {
+		tc := tls.Client(nc, &tls.Config{ServerName: c.tlsName, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+	conn := &redisConn{c: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	nc.SetDeadline(time.Now().Add(redisTimeout))
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := conn.roundTrip(args...); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.roundTrip("SELECT", strconv.Itoa(c.db)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// get 返回的 reused 表示是空闲连接，服务端可能已经关闭了它
+func (c *redisClient) get(ctx context.Context, fresh bool) (conn *redisConn, reused bool, err error) {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	if !fresh {
+		select {
+		case conn := <-c.idle:
+			return conn, true, nil
+		default:
+		}
+	}
+	conn, err = c.dial(ctx)
+	if err != nil {
+		<-c.slots
+	}
+	return conn, false, err
+}
+
+func (c *redisClient) release(conn *redisConn, broken bool) {
+	if broken {
+		conn.c.Close()
+	} else {
+		c.idle <- conn
+	}
+	<-c.slots
+}
+
+// do 执行一条命令，返回值为 string、int64、nil、[]interface{}；服务端错误为 redisError
+func (c *redisClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	v, reused, err := c.try(ctx, false, args)
+	var rerr redisError
+	if err != nil && reused && !errors.As(err, &rerr) && ctx.Err() == nil {
+		// 空闲连接可能在 Redis 重启后失效，换新连接重试一次
+		v, _, err = c.try(ctx, true, args)
+	}
+	if err != nil {
+		if errors.As(err, &rerr) {
+			return nil, err
+		}
+		return nil, c.failed(args[0], err)
+	}
+	if atomic.SwapInt32(&c.down, 0) == 1 {
+		log.Printf("Redis %s is reachable again", c.addr)
+	}
+	return v, nil
+}
+
+func (c *redisClient) try(ctx context.Context, fresh bool, args []string) (interface{}, bool, error) {
+	conn, reused, err := c.get(ctx, fresh)
+	if err != nil {
+		return nil, false, err
+	}
+	deadline := time.Now().Add(redisTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.c.SetDeadline(deadline)
+	v, err := conn.roundTrip(args...)
+	var rerr redisError
+	c.release(conn, err != nil && !errors.As(err, &rerr))
+	return v, reused, err
+}
+
+func (c *redisClient) failed(op string, err error) error {
+	metricRedisErrors.inc(strings.ToLower(op))
+	if atomic.SwapInt32(&c.down, 1) == 0 {
+		log.Printf("Redis %s failed, using local state: %v", c.addr, err)
+	}
+	return err
+}
+
+func (c *redisClient) getString(ctx context.Context, key string) (string, error) {
+	v, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errRedisNil
+	}
+	return s, nil
+}
+
+func (conn *redisConn) roundTrip(args ...string) (interface{}, error) {
+	if err := conn.write(args...); err != nil {
+		return nil, err
+	}
+	return conn.read()
+}
+
+func (conn *redisConn) write(args ...string) error {
+	fmt.Fprintf(conn.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(conn.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return conn.w.Flush()
+}
+
+func (conn *redisConn) read() (interface{}, error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(conn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			// 数组里的错误（例如 EXEC）按值返回
+			v, err := conn.read()
+			var rerr redisError
+			if err != nil && !errors.As(err, &rerr) {
+				return nil, err
+			}
+			if err != nil {
+				v = err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+}
+
+// subscribe 用独立连接订阅频道，断开后重连，直到进程退出
+func (c *redisClient) subscribe(channel string, fn func(payload string)) {
+	for {
+		conn, err := c.dial(context.Background())
+		if err == nil {
+			err = conn.write("SUBSCRIBE", channel)
+		}
+		if err == nil {
+			conn.c.SetDeadline(time.Time{})
+			for {
+				var v interface{}
+				if v, err = conn.read(); err != nil {
+					break
+				}
+				msg, ok := v.([]interface{})
+				if ok && len(msg) == 3 && msg[0] == "message" {
+					if payload, ok := msg[2].(string); ok {
+						fn(payload)
+					}
+				}
+			}
+			conn.c.Close()
+		}
+		c.failed("subscribe", err)
+		time.Sleep(5 * time.Second)
+	}
+}
+
+var redisScriptSHA sync.Map // script -> sha1
+
+// eval 优先用 EVALSHA，服务端还没有该脚本时回退到 EVAL
+func (c *redisClient) eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	cmd := append([]string{"", "", strconv.Itoa(len(keys))}, keys...)
+	cmd = append(cmd, args...)
+	if sha, ok := redisScriptSHA.Load(script); ok {
+		cmd[0], cmd[1] = "EVALSHA", sha.(string)
+		v, err := c.do(ctx, cmd...)
+		if err == nil || !strings.HasPrefix(err.Error(), "redis: NOSCRIPT") {
+			return v, err
+		}
+	}
+	if v, err := c.do(ctx, "SCRIPT", "LOAD", script); err == nil {
+		if sha, ok := v.(string); ok {
+			redisScriptSHA.Store(script, sha)
+		}
+	}
+	cmd[0], cmd[1] = "EVAL", script
+	return c.do(ctx, cmd...)
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadMu 同时收到 SIGHUP 和 /admin/reload 时按顺序执行
+var reloadMu sync.Mutex
+
+type poolChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+type reloadResult struct {
+	Source     string                `json:"source"`
+	ReloadedAt time.Time             `json:"reloadedAt"`
+	ConfigFile string                `json:"configFile,omitempty"`
+	Upstreams  map[string]poolChange `json:"upstreams"`
+	APIKeys    int                   `json:"apiKeys"`
+}
+
+// reloadConfig 重新读取 CONFIG_FILE 并应用可以热更新的配置：上游节点及其凭证、限流、方法策略和 API key。
+// 其余配置仍需重启。文件或 API key 有错误时什么都不改
+func reloadConfig(source string) (*reloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	previous := configOverlay.Load()
+	configOverlay.Store(values)
+	var keys map[string]*apiKey
+	if authEnabled() {
+		if keys, err = loadAPIKeys(); err != nil {
+			configOverlay.Store(previous)
+			return nil, fmt.Errorf("loading API keys: %w", err)
+		}
+	}
+
+	result := &reloadResult{Source: source, ReloadedAt: time.Now(), ConfigFile: configFile, Upstreams: map[string]poolChange{}, APIKeys: len(keys)}
+	type poolURLs struct {
+		pool *upstreamPool
+		urls []string
+	}
+	pools := []poolURLs{
+		{jsonrpcUpstreams, splitList(configValue("TRON_JSONRPC_ENDPOINT"))},
+		{restUpstreams, splitList(configValue("TRON_REST_ENDPOINT"))},
+		{solidityUpstreams, splitList(configValue("TRON_SOLIDITY_ENDPOINT"))},
+		{grpcUpstreams, grpcTargets(splitList(configValue("TRON_GRPC_ENDPOINT")))},
+		{archiveUpstreams, splitList(configValue("TRON_ARCHIVE_JSONRPC_ENDPOINT"))},
+		{archiveRestUpstreams, splitList(configValue("TRON_ARCHIVE_REST_ENDPOINT"))},
+	}
+	// 只重新读取已有网络的节点，增减网络需要重启
+	for _, n := range networks {
+		prefix := networkEnvPrefix(n.name)
+		pools = append(pools, poolURLs{n.jsonrpc, splitList(configValue(prefix + "JSONRPC_ENDPOINT"))},
+			poolURLs{n.rest, splitList(configValue(prefix + "REST_ENDPOINT"))})
+	}
+	for _, c := range pools {
+		p := c.pool
+		added, removed := p.setURLs(c.urls)
+		for _, u := range added {
+			log.Printf("Reload: added %s upstream %s", p.name(), redactURL(u))
+		}
+		for _, u := range removed {
+			log.Printf("Reload: removed %s upstream %s", p.name(), redactURL(u))
+		}
+		result.Upstreams[p.name()] = poolChange{Added: redactURLs(added), Removed: redactURLs(removed)}
+	}
+	rateLimitIP.configure(envFloat("RATE_LIMIT_IP_RPS", 0), envInt("RATE_LIMIT_IP_BURST", 0))
+	rateLimitKey.configure(envFloat("RATE_LIMIT_KEY_RPS", 0), envInt("RATE_LIMIT_KEY_BURST", 0))
+	loadMethodPolicy()
+	loadUpstreamCredentials()
+	if keys != nil {
+		apiKeys.Store(keys)
+	}
+
+	log.Printf("Configuration reloaded (%s), %d API key(s)", source, len(keys))
+	events.publish(EventConfigReloaded, configReloadedEvent{Source: source})
+	return result, nil
+}
+
+func redactURLs(urls []string) []string {
+	out := make([]string, len(urls))
+	for i, u := range urls {
+		out[i] = redactURL(u)
+	}
+	return out
+}
+
+// startConfigReloader SIGHUP 触发重新加载，正在处理的请求不受影响
+func startConfigReloader() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reloadConfig("sighup"); err != nil {
+				log.Printf("Configuration reload failed, keeping previous settings: %v", err)
+			}
+		}
+	}()
+}
+
+// handleAdminReload POST /admin/reload
+func handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	result, err := reloadConfig("admin")
+	if err != nil {
+		log.Printf("Configuration reload failed, keeping previous settings: %v", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
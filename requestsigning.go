@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const requestSignatureHeader = "X-Request-Signature"
+
+// 内部调用方用共享密钥对请求签名，不需要在请求里带 API key：
+// X-Request-Signature: t=<unix 秒>,kid=<key id>,sig=<base64>
+// sig = HMAC-SHA256(secret, "<t>\n<HTTP 方法>\n<请求 URI>\n<X-Target-Network>\n<X-Result-Fields>\n<请求体 SHA-256 的 hex>")
+// 请求 URI 是客户端实际发送的路径加查询串，包括 /<network>/ 前缀；没有带的请求头按空字符串计算。
+// 这两个请求头会改变请求发往的网络和返回的内容，所以也要签名；各项用换行分隔，请求头的值里不会有换行
+// kid 对应 REQUEST_SIGNING_KEYS=<kid>:<secret>,... 或 API_KEYS_FILE 里带 signingSecret 的 key 的 name；
+// 配了 signingSecret 的 key 只能签名调用，单独的 X-API-Key 不再被接受。
+// 不记录 nonce，时间窗口内的重放无法识别，窗口由 REQUEST_SIGNING_MAX_SKEW 控制
+var (
+	// 由 New 解析 REQUEST_SIGNING_KEYS
+	requestSigningKeys    map[string]*apiKey
+	requestSigningMaxSkew = envDuration("REQUEST_SIGNING_MAX_SKEW", 5*time.Minute)
+
+	metricSignatureRejected = newCounterVec("proxy_request_signature_rejected_total",
+		"Signed requests rejected, by reason.", "reason")
+)
+
+func parseRequestSigningKeys(s string) (map[string]*apiKey, error) {
+	keys := make(map[string]*apiKey)
+	for _, item := range splitList(s) {
+		kid, secret, ok := strings.Cut(item, ":")
+		if !ok || kid == "" || secret == "" {
+			return nil, fmt.Errorf("invalid REQUEST_SIGNING_KEYS entry %q, expected <kid>:<secret>", item)
+		}
+		// Key 只用作限流和用量统计的标识，不能当 X-API-Key 使用
+		keys[kid] = &apiKey{Key: "sig:" + kid, Name: kid, SigningSecret: secret}
+	}
+	return keys, nil
+}
+
+func lookupSigningKey(kid string) *apiKey {
+	if k := requestSigningKeys[kid]; k != nil {
+		return k
+	}
+	keys, _ := apiKeys.Load().(map[string]*apiKey)
+	for _, k := range keys {
+		if k.SigningSecret != "" && k.Name == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+var (
+	errSignatureMalformed = errors.New("malformed signature header")
+	errSignatureExpired   = errors.New("signature timestamp outside the allowed window")
+	errSignatureUnknown   = errors.New("unknown signing key")
+	errSignatureMismatch  = errors.New("signature mismatch")
+)
+
+// verifyRequestSignature 读出请求体校验签名，之后把请求体放回去给后面的处理函数
+func verifyRequestSignature(w http.ResponseWriter, r *http.Request, header string) (*apiKey, error) {
+	var ts int64
+	var kid, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "kid":
+			kid = v
+		case "sig":
+			sig = v
+		}
+	}
+	mac, err := base64.StdEncoding.DecodeString(sig)
+	if ts == 0 || kid == "" || err != nil || len(mac) == 0 {
+		return nil, errSignatureMalformed
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > requestSigningMaxSkew || skew < -requestSigningMaxSkew {
+		return nil, errSignatureExpired
+	}
+	key := lookupSigningKey(kid)
+	if key == nil {
+		return nil, errSignatureUnknown
+	}
+
+	limitBody(w, r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	digest := sha256.Sum256(body)
+	expected := hmac.New(sha256.New, []byte(key.SigningSecret))
+	expected.Write([]byte(canonicalRequest(r, ts, digest[:])))
+	if !hmac.Equal(mac, expected.Sum(nil)) {
+		return nil, errSignatureMismatch
+	}
+	return key, nil
+}
+
+// signedRequestHeaders 参与签名的请求头，顺序固定
+var signedRequestHeaders = []string{"X-Target-Network", "X-Result-Fields"}
+
+func canonicalRequest(r *http.Request, ts int64, bodyDigest []byte) string {
+	parts := []string{strconv.FormatInt(ts, 10), r.Method, signedRequestURI(r)}
+	for _, h := range signedRequestHeaders {
+		parts = append(parts, r.Header.Get(h))
+	}
+	return strings.Join(append(parts, hex.EncodeToString(bodyDigest)), "\n")
+}
+
+// signedRequestURI 用原始的 RequestURI：http.StripPrefix 只改 URL.Path，查询串也要参与签名
+func signedRequestURI(r *http.Request) string {
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
+
+func rejectSignature(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendBodyTooLarge(w)
+		return
+	}
+	reason := "error"
+	switch err {
+	case errSignatureMalformed:
+		reason = "malformed"
+	case errSignatureExpired:
+		reason = "expired"
+	case errSignatureUnknown:
+		reason = "unknown_key"
+	case errSignatureMismatch:
+		reason = "mismatch"
+	}
+	metricSignatureRejected.inc(reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	codec.NewEncoder(w).Encode(jsonError(nil, -32000, "unauthorized: "+err.Error()))
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withSigningKey(t *testing.T, kid, secret string) {
+	old := requestSigningKeys
+	requestSigningKeys = map[string]*apiKey{kid: {Key: "sig:" + kid, Name: kid, SigningSecret: secret}}
+	t.Cleanup(func() { requestSigningKeys = old })
+}
+
+// signRequest 签名时带上 r 已经设置的 X-Target-Network 和 X-Result-Fields
+func signRequest(r *http.Request, kid, secret, uri, body string) {
+	ts := fmt.Sprint(time.Now().Unix())
+	digest := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{ts, r.Method, uri, r.Header.Get("X-Target-Network"), r.Header.Get("X-Result-Fields"), hex.EncodeToString(digest[:])}, "\n")))
+	r.Header.Set(requestSignatureHeader, "t="+ts+",kid="+kid+",sig="+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func TestSignatureCoversQuery(t *testing.T) {
+	withSigningKey(t, "svc", "s3cret")
+	h := authMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := httptest.NewRequest(http.MethodGet, "/wallet/getnowblock?visible=true", nil)
+	signRequest(r, "svc", "s3cret", "/wallet/getnowblock?visible=true", "")
+	rec := httptest.NewRecorder()
+	h(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("signed request: HTTP %d %s", rec.Code, rec.Body)
+	}
+
+	// 改了查询串，签名不能再通过
+	r = httptest.NewRequest(http.MethodGet, "/wallet/getnowblock?visible=false", nil)
+	signRequest(r, "svc", "s3cret", "/wallet/getnowblock?visible=true", "")
+	rec = httptest.NewRecorder()
+	h(rec, r)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "signature mismatch") {
+		t.Fatalf("tampered query: HTTP %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSignatureUnderNetworkPrefix(t *testing.T) {
+	withSigningKey(t, "svc", "s3cret")
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	h := http.StripPrefix("/nile", authMiddleware(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodPost, "/nile/jsonrpc", strings.NewReader(body))
+	signRequest(r, "svc", "s3cret", "/nile/jsonrpc", body)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("prefixed request: HTTP %d %s", rec.Code, rec.Body)
+	}
+}
+
+// 改变目标网络和返回字段的请求头也在签名范围内，签名之后不能再加或改
+func TestSignatureCoversRoutingHeaders(t *testing.T) {
+	withSigningKey(t, "svc", "s3cret")
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	h := authMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+	send := func(tamper func(r *http.Request)) int {
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		r.Header.Set("X-Result-Fields", "number,hash")
+		signRequest(r, "svc", "s3cret", "/jsonrpc", body)
+		tamper(r)
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec.Code
+	}
+	if code := send(func(r *http.Request) {}); code != http.StatusOK {
+		t.Fatalf("signed request: HTTP %d", code)
+	}
+	if code := send(func(r *http.Request) { r.Header.Set("X-Target-Network", "nile") }); code != http.StatusUnauthorized {
+		t.Errorf("added X-Target-Network: HTTP %d, want 401", code)
+	}
+	if code := send(func(r *http.Request) { r.Header.Set("X-Result-Fields", "number") }); code != http.StatusUnauthorized {
+		t.Errorf("changed X-Result-Fields: HTTP %d, want 401", code)
+	}
+}
+
+func TestAdminSigningKeyNotBearer(t *testing.T) {
+	old := apiKeys.Load()
+	apiKeys.Store(map[string]*apiKey{
+		"signed-admin": {Key: "signed-admin", Name: "ops", Admin: true, SigningSecret: "s3cret"},
+		"plain-admin":  {Key: "plain-admin", Name: "root", Admin: true},
+	})
+	t.Cleanup(func() { apiKeys.Store(old) })
+
+	for token, want := range map[string]bool{"signed-admin": false, "plain-admin": true} {
+		r := httptest.NewRequest(http.MethodGet, "/admin/slow", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		if got := isAdminRequest(r); got != want {
+			t.Errorf("isAdminRequest(%s) = %v, want %v", token, got, want)
+		}
+	}
+}
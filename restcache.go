@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 区块浏览器反复读取最近的区块，REST 透传对这些路径做读穿缓存；REST_CACHE_MAX_ENTRIES=0 关闭
+	restCache = newResponseCache(
+		envInt("REST_CACHE_MAX_ENTRIES", 5000),
+		envDuration("REST_CACHE_TTL", time.Hour),
+	)
+	// 还没固化的区块只缓存很短时间，重组后也不会长时间返回旧数据
+	restCacheRecentTTL = envDuration("REST_CACHE_RECENT_TTL", 3*time.Second)
+	// TRON 区块得到 2/3 以上 SR（19 个）确认后固化
+	restCacheConfirmations = int64(envInt("REST_CACHE_CONFIRMATIONS", 19))
+
+	// 区块监听看到的最新高度，未开启 BLOCK_WATCH_INTERVAL 时为 0，所有 /wallet 结果都按未固化处理
+	restHead int64
+)
+
+// restCacheRoute 描述如何得到结果所在的区块高度：请求里的字段，或者从响应里解析。
+// /walletsolidity 只返回已固化的数据，总是按长 TTL 缓存
+type restCacheRoute struct {
+	numField string
+	// getblockbylimitnext 的 endNum 不包含在结果里
+	exclusive bool
+	solid     bool
+}
+
+var restCacheRoutes = map[string]restCacheRoute{
+	"/wallet/getblockbynum":                        {numField: "num"},
+	"/wallet/getblockbyid":                         {},
+	"/wallet/getblockbylimitnext":                  {numField: "endNum", exclusive: true},
+	"/wallet/gettransactioninfobyblocknum":         {numField: "num"},
+	"/wallet/gettransactioninfobyid":               {},
+	"/walletsolidity/getblockbynum":                {solid: true},
+	"/walletsolidity/getblockbyid":                 {solid: true},
+	"/walletsolidity/gettransactioninfobyblocknum": {solid: true},
+	"/walletsolidity/gettransactioninfobyid":       {solid: true},
+}
+
+func init() {
+	events.subscribe("rest-cache", 64, func(e event) {
+		switch e.Type {
+		case EventNewBlock:
+			n := e.Data.(newBlockEvent).Header.Number
+			for {
+				cur := atomic.LoadInt64(&restHead)
+				if n <= cur || atomic.CompareAndSwapInt64(&restHead, cur, n) {
+					break
+				}
+			}
+		case EventReorg:
+			from := e.Data.(reorgEvent).Height
+			restCache.invalidate(cacheInvalidation{FromBlock: &from})
+			// 每个实例都会看到回滚，各自清除一遍共享缓存也没有问题
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			sharedCache.invalidate(ctx, cacheInvalidation{FromBlock: &from})
+			cancel()
+		}
+	}, EventNewBlock, EventReorg)
+}
+
+// restCacheParams 把请求体规范化（键排序、去空白）后和查询串一起作为缓存键，
+// 同时取出 route.numField 指定的区块高度；请求体不是 JSON 对象时不缓存
+func restCacheParams(route restCacheRoute, body []byte, rawQuery string) ([]json.RawMessage, int64, bool) {
+	fields := map[string]interface{}{}
+	if len(body) > 0 {
+		if err := codec.Unmarshal(body, &fields); err != nil {
+			return nil, -1, false
+		}
+	}
+	canonical, err := codec.Marshal(fields)
+	if err != nil {
+		return nil, -1, false
+	}
+	num := int64(-1)
+	if route.numField != "" {
+		raw, ok := fields[route.numField]
+		if !ok {
+			if q, err := url.ParseQuery(rawQuery); err == nil && q.Get(route.numField) != "" {
+				raw = q.Get(route.numField)
+			}
+		}
+		switch v := raw.(type) {
+		case float64:
+			num = int64(v)
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				num = n
+			}
+		}
+		if num >= 0 && route.exclusive {
+			num--
+		}
+	}
+	return []json.RawMessage{canonical, json.RawMessage(strconv.Quote(rawQuery))}, num, true
+}
+
+// restResultHeight 从区块或交易信息里取高度，用于按 ID 查询的路径
+func restResultHeight(body []byte) int64 {
+	var v struct {
+		BlockHeader struct {
+			RawData struct {
+				Number int64 `json:"number"`
+			} `json:"raw_data"`
+		} `json:"block_header"`
+		BlockNumber int64 `json:"blockNumber"`
+	}
+	if codec.Unmarshal(body, &v) != nil {
+		return -1
+	}
+	if v.BlockHeader.RawData.Number > 0 {
+		return v.BlockHeader.RawData.Number
+	}
+	if v.BlockNumber > 0 {
+		return v.BlockNumber
+	}
+	return -1
+}
+
+// restCacheable 空结果（区块还没产生、交易还没上链）和错误都不缓存
+func restCacheable(body []byte) bool {
+	var v map[string]json.RawMessage
+	if err := codec.Unmarshal(body, &v); err != nil {
+		// getblockbylimitnext 等路径可能返回数组
+		var arr []json.RawMessage
+		return codec.Unmarshal(body, &arr) == nil && len(arr) > 0
+	}
+	if len(v) == 0 {
+		return false
+	}
+	_, hasError := v["Error"]
+	return !hasError
+}
+
+func restCacheTTL(route restCacheRoute, height int64) time.Duration {
+	if route.solid {
+		return restCache.ttl
+	}
+	head := atomic.LoadInt64(&restHead)
+	if height < 0 || head == 0 || head-height < restCacheConfirmations {
+		return restCacheRecentTTL
+	}
+	return restCache.ttl
+}
+
+// cachedREST 命中时直接返回缓存的响应体，未命中时调用 fetch 并按区块是否固化决定 TTL
+func cachedREST(ctx context.Context, path, rawQuery string, body []byte, fetch func() (*upstreamResponse, error)) (*upstreamResponse, bool, error) {
+	route, ok := restCacheRoutes[path]
+	if !ok || (!restCache.enabled() && !diskCache.usable(path) && !sharedCache.usable()) || networkFrom(ctx) != nil {
+		resp, err := fetch()
+		return resp, false, err
+	}
+	params, num, ok := restCacheParams(route, body, rawQuery)
+	if !ok {
+		resp, err := fetch()
+		return resp, false, err
+	}
+	if cached, hit := restCache.get(path, params); hit {
+		debugFrom(ctx).cache(path, "hit")
+		return &upstreamResponse{Body: cached.([]byte), Status: 200, Upstream: &upstream{label: "cache"}}, true, nil
+	}
+	if raw, hit := sharedCache.get(ctx, path, params); hit {
+		debugFrom(ctx).cache(path, "shared")
+		restCache.putTTL(path, params, []byte(raw), restCacheTTL(route, num), num)
+		return &upstreamResponse{Body: raw, Status: 200, Upstream: &upstream{label: "shared-cache"}}, true, nil
+	}
+	if raw, hit := diskCache.get(path, params); hit {
+		debugFrom(ctx).cache(path, "disk")
+		restCache.putTTL(path, params, []byte(raw), restCache.ttl, num)
+		return &upstreamResponse{Body: raw, Status: 200, Upstream: &upstream{label: "disk-cache"}}, true, nil
+	}
+	debugFrom(ctx).cache(path, "miss")
+	resp, err := fetch()
+	if err != nil || resp.Status != 200 || !restCacheable(resp.Body) {
+		return resp, false, err
+	}
+	if num < 0 {
+		num = restResultHeight(resp.Body)
+	}
+	restCache.putTTL(path, params, resp.Body, restCacheTTL(route, num), num)
+	diskCache.put(ctx, path, params, resp.Body, num, "")
+	sharedCache.put(path, params, resp.Body, restCacheTTL(route, num), num, "")
+	return resp, false, nil
+}
+
+func writeRESTCacheMetrics(w io.Writer) {
+	stats := restCache.Stats()
+	hits := newCounterVec("proxy_rest_cache_hits_total", "REST read-through cache hits by path.", "path")
+	misses := newCounterVec("proxy_rest_cache_misses_total", "REST read-through cache misses by path.", "path")
+	for p, s := range stats.Methods {
+		hits.add(float64(s.Hits), restLabel(p))
+		misses.add(float64(s.Misses), restLabel(p))
+	}
+	hits.write(w)
+	misses.write(w)
+	writeGauge(w, "proxy_rest_cache_entries", "Entries currently held in the REST read-through cache.", float64(stats.Entries))
+}
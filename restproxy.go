@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// /walletsolidity/* 默认发往 REST 节点（TronGrid 同一个域名两种接口都有），
+	// 自建 FullNode 的 solidity 接口在单独端口时用 TRON_SOLIDITY_ENDPOINT 指定
+	solidityUpstreams = newUpstreamPool("solidity", splitList(configValue("TRON_SOLIDITY_ENDPOINT")))
+
+	restMaxBody = int64(envInt("REST_MAX_BODY_BYTES", 4<<20))
+)
+
+type upstreamMethodCtxKey struct{}
+
+// withUpstreamMethod 透传 GET 请求时让 upstream.post 保留原始 HTTP 方法
+func withUpstreamMethod(ctx context.Context, method string) context.Context {
+	return context.WithValue(ctx, upstreamMethodCtxKey{}, method)
+}
+
+func upstreamMethod(ctx context.Context) string {
+	if m, ok := ctx.Value(upstreamMethodCtxKey{}).(string); ok {
+		return m
+	}
+	return http.MethodPost
+}
+
+// handleV1 /v1/<key>/jsonrpc 仍是 JSON-RPC，其余 /v1/* 透传到 TRON 事件 API（TronGrid v1）
+func handleV1(w http.ResponseWriter, r *http.Request) {
+	if pathAPIKey(r.URL.Path) != "" {
+		handleJSONRPC(w, r)
+		return
+	}
+	handleREST(w, r)
+}
+
+// restLabel 用于指标和用量统计；/v1 路径里带地址，只保留资源类型避免标签基数过高
+func restLabel(p string) string {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "_")
+}
+
+// handleREST 透传 /wallet/*、/walletsolidity/*、/v1/*，鉴权、限流、方法策略与 JSON-RPC 路径一致，
+// 方法策略按请求路径匹配（例如 METHOD_DENY=/wallet/broadcast*）
+func handleREST(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&metricInflight, 1)
+	defer atomic.AddInt64(&metricInflight, -1)
+	start := time.Now()
+	ctx := r.Context()
+	label := restLabel(r.URL.Path)
+	noteInflight(ctx, r.URL.Path, 0)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"Error": "only GET and POST are supported"})
+		return
+	}
+	if reason := checkMethodPolicy(ctx, r.URL.Path); reason != "" {
+		log.Printf("REST %s rejected: %s", r.URL.Path, reason)
+		writeJSON(w, http.StatusForbidden, map[string]string{"Error": reason})
+		observeREST(ctx, label, start, false)
+		return
+	}
+	release, exceeded := reserveQuota(ctx, computeUnits(methodLabel(label)))
+	if exceeded != nil {
+		logQuotaRejected(ctx, r.URL.Path, exceeded)
+		writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{"Error": exceeded.message(), "quota": exceeded})
+		metricRequests.inc(methodLabel(label), "error")
+		return
+	}
+	defer release()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, restMaxBody))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"Error": "request body too large"})
+		return
+	}
+
+	target := r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	ctx = withUpstreamMethod(ctx, r.Method)
+	resp, hit, err := cachedREST(ctx, r.URL.Path, r.URL.RawQuery, body, func() (*upstreamResponse, error) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/"):
+			return eventAPIRequest(ctx, r.Method, target, body)
+		case networkFrom(ctx) != nil:
+			return networkFrom(ctx).rest.send(ctx, target, r.URL.Path, body)
+		case strings.HasPrefix(r.URL.Path, "/walletsolidity/") && len(solidityUpstreams.list()) > 0:
+			return solidityUpstreams.send(ctx, target, r.URL.Path, body)
+		}
+		// 不走 gRPC 映射，gRPC 转换只还原代理内部用到的字段
+		return restUpstreams.send(ctx, target, r.URL.Path, body)
+	})
+	if err != nil {
+		log.Printf("REST passthrough %s %s failed: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"Error": err.Error()})
+		observeREST(ctx, label, start, false)
+		return
+	}
+	if hit {
+		log.Printf("REST passthrough %s %s served from cache", r.Method, r.URL.Path)
+	} else {
+		log.Printf("REST passthrough %s %s -> HTTP %d from %s", r.Method, r.URL.Path, resp.Status, resp.Upstream.label)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+	observeREST(ctx, label, start, resp.Status < 400)
+}
+
+func observeREST(ctx context.Context, path string, start time.Time, ok bool) {
+	label := methodLabel(path)
+	status := "ok"
+	if !ok {
+		status = "error"
+	}
+	metricRequests.inc(label, status)
+	metricRequestDuration.observe(time.Since(start).Seconds(), label, "rest")
+	usage.record(usageKeyLabel(ctx), label, ok, time.Now())
+}
+
+// eventAPIRequest /v1/* 不属于 FullNode，只能发往 TRON_EVENT_API，没有重试和熔断
+func eventAPIRequest(ctx context.Context, method, target string, body []byte) (*upstreamResponse, error) {
+	if tronEventAPI == "" {
+		return &upstreamResponse{Body: []byte(`{"Error":"TRON_EVENT_API is not configured"}`), Status: http.StatusNotFound, Upstream: &upstream{label: "none"}}, nil
+	}
+	var reader io.Reader
+	if method == http.MethodPost {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, tronEventAPI+target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	eventAPI := &upstream{url: tronEventAPI, label: upstreamLabel(tronEventAPI)}
+	setUpstreamHeaders(ctx, req, eventAPI)
+	if tronEventAPIKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", tronEventAPIKey)
+	}
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := readLimited(resp.Body, upstreamMaxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamResponse{Body: respBody, Status: resp.StatusCode, Upstream: eventAPI}, nil
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// 结果字段过滤：X-Result-Fields 头对请求里的所有调用生效，params 末尾的 {"fields": [...]} 只对这一条生效并优先于头，
+// 这个对象在查缓存和转发前就去掉。字段名可以用点号指定嵌套字段（transactions.hash），
+// 以 - 开头表示去掉该字段（-logsBloom）。数组结果对每个元素分别过滤，字符串结果和错误不处理
+type fieldTree map[string]fieldTree // 值为 nil 表示整个字段
+
+type fieldSelection struct {
+	include fieldTree
+	exclude fieldTree
+}
+
+type resultFieldsCtxKey struct{}
+
+func withResultFields(ctx context.Context, r *http.Request) context.Context {
+	if sel := parseFieldSelection(splitList(r.Header.Get("X-Result-Fields"))); sel != nil {
+		return context.WithValue(ctx, resultFieldsCtxKey{}, sel)
+	}
+	return ctx
+}
+
+func resultFieldsFrom(ctx context.Context) *fieldSelection {
+	sel, _ := ctx.Value(resultFieldsCtxKey{}).(*fieldSelection)
+	return sel
+}
+
+func parseFieldSelection(paths []string) *fieldSelection {
+	sel := &fieldSelection{}
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		tree := &sel.include
+		if strings.HasPrefix(p, "-") {
+			p = p[1:]
+			tree = &sel.exclude
+		}
+		if p == "" {
+			continue
+		}
+		if *tree == nil {
+			*tree = fieldTree{}
+		}
+		(*tree).insert(strings.Split(p, "."))
+	}
+	if sel.include == nil && sel.exclude == nil {
+		return nil
+	}
+	return sel
+}
+
+func (t fieldTree) insert(parts []string) {
+	node := t
+	for i, part := range parts {
+		child, ok := node[part]
+		if i == len(parts)-1 {
+			node[part] = nil
+			return
+		}
+		if ok && child == nil {
+			// 已经保留整个字段
+			return
+		}
+		if !ok {
+			child = fieldTree{}
+			node[part] = child
+		}
+		node = child
+	}
+}
+
+// fieldsHint 识别 params 末尾只有 fields 一个键的对象，其他对象参数（过滤条件、调用对象）不会只有这个键
+func fieldsHint(req JSONRPCRequest) *fieldSelection {
+	if len(req.Params) == 0 {
+		return nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(req.Params[len(req.Params)-1], &obj); err != nil || len(obj) != 1 {
+		return nil
+	}
+	raw, ok := obj["fields"]
+	if !ok {
+		return nil
+	}
+	var paths []string
+	if err := json.Unmarshal(raw, &paths); err != nil {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil
+		}
+		paths = splitList(s)
+	}
+	if sel := parseFieldSelection(paths); sel != nil {
+		return sel
+	}
+	// 空列表也当作提示去掉，不转发给上游
+	return &fieldSelection{}
+}
+
+// takeFieldsHint 返回去掉提示之后的请求，原 params 切片可能和其他地方共享，不在原地修改
+func takeFieldsHint(req JSONRPCRequest) (JSONRPCRequest, *fieldSelection) {
+	sel := fieldsHint(req)
+	if sel == nil {
+		return req, nil
+	}
+	req.Params = append([]json.RawMessage(nil), req.Params[:len(req.Params)-1]...)
+	return req, sel
+}
+
+func wantsResultFields(ctx context.Context, req JSONRPCRequest) bool {
+	return resultFieldsFrom(ctx) != nil || fieldsHint(req) != nil
+}
+
+// applyResultFields 在解码出的副本上过滤，缓存里的结果对象可能被其他请求共享；hint 为空时用请求头的设置
+func applyResultFields(ctx context.Context, hint *fieldSelection, resp *JSONRPCResponse) {
+	sel := hint
+	if sel == nil {
+		sel = resultFieldsFrom(ctx)
+	}
+	if sel == nil || (sel.include == nil && sel.exclude == nil) || resp.Error != nil || resp.Result == nil {
+		return
+	}
+	if _, ok := resp.Result.(string); ok {
+		return
+	}
+	raw, err := codec.Marshal(resp.Result)
+	if err != nil {
+		return
+	}
+	v, ok := decodeJSONNumber(raw)
+	if !ok {
+		return
+	}
+	if sel.include != nil {
+		projectFields(v, sel.include)
+	}
+	if sel.exclude != nil {
+		excludeFields(v, sel.exclude)
+	}
+	resp.Result = v
+}
+
+func projectFields(v interface{}, tree fieldTree) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			sub, ok := tree[k]
+			if !ok {
+				delete(node, k)
+			} else if sub != nil {
+				projectFields(child, sub)
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			projectFields(child, tree)
+		}
+	}
+}
+
+func excludeFields(v interface{}, tree fieldTree) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range tree {
+			if sub == nil {
+				delete(node, k)
+			} else if child, ok := node[k]; ok {
+				excludeFields(child, sub)
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			excludeFields(child, tree)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var (
+	// 单次调用最多尝试的次数（含第一次），节点数更多时至少每个节点试一次
+	upstreamRetryAttempts   = envInt("UPSTREAM_RETRY_ATTEMPTS", 3)
+	upstreamRetryBackoff    = envDuration("UPSTREAM_RETRY_BACKOFF", 100*time.Millisecond)
+	upstreamRetryMaxBackoff = envDuration("UPSTREAM_RETRY_MAX_BACKOFF", 2*time.Second)
+	// 会改变链上状态的方法和 REST 接口，请求可能已经到达节点时不重试
+	nonIdempotent = toSet(splitList(envString("UPSTREAM_NO_RETRY",
+		"eth_sendRawTransaction,eth_sendTransaction,/wallet/broadcasttransaction,/wallet/broadcasthex")))
+
+	metricUpstreamRetries = newCounterVec("proxy_upstream_retries_total",
+		"Upstream calls retried after a transient failure, by upstream kind.", "kind")
+)
+
+func idempotent(method, path string) bool {
+	return !nonIdempotent[method] && !nonIdempotent[path]
+}
+
+// requestNotSent 连接阶段的失败，请求一定没有到达节点，任何方法都可以安全重试
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// transientFailure 超时、连接被重置和网关类错误视为临时故障
+func transientFailure(err error, status int) bool {
+	if err == nil {
+		return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return requestNotSent(err) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// shouldRetry 非幂等方法只在请求确定没发出时重试
+func shouldRetry(method, path string, err error, status int) bool {
+	if !idempotent(method, path) {
+		return err != nil && requestNotSent(err)
+	}
+	return transientFailure(err, status)
+}
+
+// retryBackoff 第 n 次重试前的等待时间，指数增长并加全抖动
+func retryBackoff(n int) time.Duration {
+	d := upstreamRetryBackoff
+	for i := 1; i < n && d < upstreamRetryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > upstreamRetryMaxBackoff {
+		d = upstreamRetryMaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingNodes 两个节点都返回 status（为 0 时读完请求后直接断开连接），hits 为两个节点收到的请求总数
+func failingNodes(t *testing.T, status int) (urls []string, hits *int32) {
+	hits = new(int32)
+	for i := 0; i < 2; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(hits, 1)
+			if status == 0 {
+				panic(http.ErrAbortHandler)
+			}
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+	}
+	oldBackoff := upstreamRetryBackoff
+	upstreamRetryBackoff = time.Millisecond
+	t.Cleanup(func() { upstreamRetryBackoff = oldBackoff })
+	return urls, hits
+}
+
+// 广播交易的请求已经到达节点（收到响应或连接中途断开）后不能重试，否则可能重复广播
+func TestNoRetryAfterBroadcastSent(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, 0} {
+		urls, hits := failingNodes(t, status)
+		pool := newUpstreamPool("jsonrpc", urls)
+		pool.send(context.Background(), "", "eth_sendRawTransaction", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`))
+		if n := atomic.LoadInt32(hits); n != 1 {
+			t.Errorf("eth_sendRawTransaction (status %d) sent %d times, want 1", status, n)
+		}
+
+		urls, hits = failingNodes(t, status)
+		pool = newUpstreamPool("rest", urls)
+		pool.send(context.Background(), "/wallet/broadcasthex", "", []byte(`{"transaction":"00"}`))
+		if n := atomic.LoadInt32(hits); n != 1 {
+			t.Errorf("/wallet/broadcasthex (status %d) sent %d times, want 1", status, n)
+		}
+
+		// 幂等的方法换节点重试
+		urls, hits = failingNodes(t, status)
+		pool = newUpstreamPool("jsonrpc", urls)
+		pool.send(context.Background(), "", "eth_blockNumber", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`))
+		if n := atomic.LoadInt32(hits); n < 2 {
+			t.Errorf("eth_blockNumber (status %d) sent %d times, want a retry", status, n)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+	cases := []struct {
+		method, path string
+		err          error
+		status       int
+		want         bool
+	}{
+		{"eth_sendRawTransaction", "", dialErr, 0, true},
+		{"eth_sendRawTransaction", "", readErr, 0, false},
+		{"eth_sendRawTransaction", "", nil, http.StatusBadGateway, false},
+		{"", "/wallet/broadcasthex", nil, http.StatusServiceUnavailable, false},
+		{"", "/wallet/broadcasthex", dialErr, 0, true},
+		{"eth_blockNumber", "", nil, http.StatusBadGateway, true},
+		{"eth_blockNumber", "", nil, http.StatusBadRequest, false},
+	}
+	for _, c := range cases {
+		if got := shouldRetry(c.method, c.path, c.err, c.status); got != c.want {
+			t.Errorf("shouldRetry(%s%s, %v, %d) = %v, want %v", c.method, c.path, c.err, c.status, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"log"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// latency（或 ewma）：按延迟 EWMA 乘以正在进行的请求数选择节点；least-pending：正在进行的请求最少的节点；
+	// round-robin：简单轮询
+	routingStrategy = routingStrategyFromEnv()
+	routingAlpha    = envFloat("ROUTING_EWMA_ALPHA", 0.3)
+	// 保留一部分流量随机分配，保证慢节点恢复后能被重新测量到
+	routingExplore = envFloat("ROUTING_EXPLORE_RATIO", 0.05)
+)
+
+func routingStrategyFromEnv() string {
+	s := envString("ROUTING_STRATEGY", "latency")
+	switch s {
+	case "latency", "round-robin", "least-pending":
+		return s
+	case "ewma":
+		return "latency"
+	}
+	log.Printf("Unknown ROUTING_STRATEGY %q, using latency", s)
+	return "latency"
+}
+
+// methodClass 把方法归类，不同类别的延迟差别很大，分开统计
+func methodClass(method string) string {
+	switch {
+	case method == "":
+		return "default"
+	case strings.HasPrefix(method, "debug_") || strings.HasPrefix(method, "trace_"):
+		return "trace"
+	case method == "eth_getLogs" || method == "eth_getFilterLogs" || method == "eth_getBlockReceipts":
+		return "logs"
+	case method == "eth_call" || method == "eth_estimateGas":
+		return "call"
+	}
+	return "default"
+}
+
+func (u *upstream) observeLatency(class string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.latency == nil {
+		u.latency = make(map[string]float64)
+	}
+	prev, ok := u.latency[class]
+	if !ok {
+		u.latency[class] = ms
+		return
+	}
+	u.latency[class] = routingAlpha*ms + (1-routingAlpha)*prev
+}
+
+// latencyEWMA 没有样本时返回 0，新节点会被优先选中以便尽快测量
+func (u *upstream) latencyEWMA(class string) float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.latency[class]
+}
+
+// begin/end 记录节点上正在进行的请求数，供 least-pending 和 latency 策略使用
+func (u *upstream) begin() { atomic.AddInt64(&u.pending, 1) }
+func (u *upstream) end()   { atomic.AddInt64(&u.pending, -1) }
+
+func (u *upstream) inflight() int64 {
+	return atomic.LoadInt64(&u.pending)
+}
+
+// loadScore 延迟乘以排队的请求数：快节点堆积请求后会让给慢节点，而不是一直压在最快的节点上
+func (u *upstream) loadScore(class string) float64 {
+	return u.latencyEWMA(class) * float64(u.inflight()+1)
+}
+
+// chooseUpstream candidates 已按轮询顺序排列
+func chooseUpstream(candidates []*upstream, class string) *upstream {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	switch routingStrategy {
+	case "least-pending":
+		best := candidates[0]
+		for _, u := range candidates[1:] {
+			if u.inflight() < best.inflight() {
+				best = u
+			}
+		}
+		return best
+	case "latency":
+	default:
+		return candidates[0]
+	}
+	// 保留一部分流量随机分配，随机取两个选得分较低的一个，避免所有请求同时涌向同一个节点
+	if rand.Float64() < routingExplore {
+		return candidates[rand.Intn(len(candidates))]
+	}
+	i := rand.Intn(len(candidates))
+	j := rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := candidates[i], candidates[j]
+	if b.loadScore(class) < a.loadScore(class) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,30 @@
+// Package rpc JSON-RPC 2.0 请求类型和错误码，供代理和各个拆出来的包共用
+package rpc
+
+import "encoding/json"
+
+// JSON-RPC 2.0 规范定义的错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternal       = -32603
+)
+
+// 按 EIP-1474 的约定选用的错误码
+const (
+	CodeInvalidInput  = -32000
+	CodeUnavailable   = -32002
+	CodeTxRejected    = -32003
+	CodeLimitExceeded = -32005
+)
+
+type Request struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      interface{}       `json:"id"`
+	// 请求里没有 id 字段（"id": null 不算），按规范执行但不返回响应
+	Notification bool `json:"-"`
+}
@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestJSON(t *testing.T) {
+	var req Request
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","method":"eth_call","params":[{"to":"0x1"},"latest"],"id":7}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "eth_call" || len(req.Params) != 2 || string(req.Params[1]) != `"latest"` || req.ID != float64(7) {
+		t.Errorf("decoded %+v", req)
+	}
+
+	// Notification 只在解析时根据有没有 id 设置，不出现在线上格式里
+	req.Notification = true
+	out, _ := json.Marshal(req)
+	var m map[string]interface{}
+	json.Unmarshal(out, &m)
+	if _, ok := m["Notification"]; ok || len(m) != 4 {
+		t.Errorf("encoded %s", out)
+	}
+}
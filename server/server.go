@@ -0,0 +1,82 @@
+// Package server 负责 HTTP 服务的启动和优雅退出，不涉及具体路由
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Options Handler 以外的字段都可以为零值
+type Options struct {
+	Addr    string
+	Handler http.Handler
+	// 不为 nil 时使用 HTTPS，证书由 TLSConfig 提供
+	TLSConfig *tls.Config
+	// 退出时等待进行中请求的时间，超过后取消所有请求的 context 并强制关闭；0 表示不等待
+	GracePeriod time.Duration
+	// 返回进行中的请求数，只用于日志
+	Inflight func() int64
+}
+
+// Run 监听 opts.Addr，ctx 取消后停止接收新连接，等待进行中的请求完成
+func Run(ctx context.Context, opts Options) error {
+	addr := opts.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, ln, opts)
+}
+
+// Serve 与 Run 相同，使用调用方提供的 listener；返回时 ln 已关闭
+func Serve(ctx context.Context, ln net.Listener, opts Options) error {
+	// 请求的 context 都从 baseCtx 派生（包括批量请求的 goroutine），宽限期过后一起取消
+	baseCtx, cancelAll := context.WithCancel(context.Background())
+	defer cancelAll()
+	srv := &http.Server{
+		Handler:     opts.Handler,
+		TLSConfig:   opts.TLSConfig,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		if opts.Inflight != nil {
+			log.Printf("Shutting down, draining %d in-flight request(s) (grace period %s)", opts.Inflight(), opts.GracePeriod)
+		} else {
+			log.Printf("Shutting down (grace period %s)", opts.GracePeriod)
+		}
+		sctx, cancel := context.WithTimeout(context.Background(), opts.GracePeriod)
+		defer cancel()
+		if err := srv.Shutdown(sctx); err != nil {
+			log.Printf("Grace period expired, aborting remaining requests: %v", err)
+			cancelAll()
+			srv.Close()
+		}
+	}()
+
+	var err error
+	if opts.TLSConfig != nil {
+		log.Printf("Proxy server started on %s (HTTPS)", ln.Addr())
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		log.Printf("Proxy server started on %s", ln.Addr())
+		err = srv.Serve(ln)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-done
+	log.Println("Proxy server stopped")
+	return nil
+}
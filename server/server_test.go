@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startServe 在随机端口上运行 Serve，返回地址和 Serve 的返回值
+func startServe(t *testing.T, ctx context.Context, opts Options) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- Serve(ctx, ln, opts) }()
+	return "http://" + ln.Addr().String(), errc
+}
+
+func TestServeDrainsInflight(t *testing.T) {
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	url, errc := startServe(t, ctx, Options{Handler: h, GracePeriod: 5 * time.Second})
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+	if got := <-body; got != "done" {
+		t.Errorf("in-flight request got %q, want it to finish", got)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestServeGracePeriodCancelsRequests(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	url, errc := startServe(t, ctx, Options{Handler: h, GracePeriod: 50 * time.Millisecond})
+	go http.Get(url)
+	<-started
+	begin := time.Now()
+	cancel()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request context not cancelled after the grace period")
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+	if d := time.Since(begin); d > time.Second {
+		t.Errorf("shutdown took %s", d)
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := Run(context.Background(), Options{Addr: ln.Addr().String(), Handler: http.NotFoundHandler()}); err == nil {
+		t.Error("Run on a busy address returned nil")
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// 共享缓存按区块高度建了索引用于回滚和按区块清除，只保留最高的这么多个条目；
+	// 更早的条目到期自然删除，也可以按方法或 paramsHash 清除
+	redisCacheIndexMax = envInt("REDIS_CACHE_INDEX_MAX", 100000)
+
+	sharedCache *redisCache
+
+	metricSharedCache = newCounterVec("proxy_shared_cache_requests_total",
+		"Redis shared cache lookups by method and result (hit, miss).", "method", "result")
+)
+
+// redisCache 是内存 LRU 之后的共享层：本实例未命中时先查 Redis，写入内存时同时写 Redis。
+// 值的格式和磁盘缓存相同
+type redisCache struct {
+	c *redisClient
+}
+
+// cacheInvalidationMessage 在实例之间广播，各实例清除自己内存里的条目
+type cacheInvalidationMessage struct {
+	Cache string            `json:"cache"`
+	Query cacheInvalidation `json:"query"`
+}
+
+func startSharedCache() {
+	if redis.shares("cache") {
+		sharedCache = &redisCache{c: redis}
+		go redis.subscribe(redisPrefix+"cache-invalidate", applyCacheInvalidation)
+	}
+}
+
+// usable nil 时表示未开启
+func (rc *redisCache) usable() bool {
+	return rc != nil
+}
+
+func (rc *redisCache) key(method, hash string) string {
+	return redisPrefix + "cache:" + cacheKey(method, hash)
+}
+
+func (rc *redisCache) indexKey() string {
+	return redisPrefix + "cache-blocks"
+}
+
+func (rc *redisCache) get(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, bool) {
+	if !rc.usable() {
+		return nil, false
+	}
+	v, err := rc.c.getString(ctx, rc.key(method, paramsHash(params)))
+	var e diskEntry
+	if err != nil || json.Unmarshal([]byte(v), &e) != nil {
+		metricSharedCache.inc(methodLabel(method), "miss")
+		return nil, false
+	}
+	metricSharedCache.inc(methodLabel(method), "hit")
+	return e.Result, true
+}
+
+// put 在后台写入，不增加请求延迟
+func (rc *redisCache) put(method string, params []json.RawMessage, result []byte, ttl time.Duration, block int64, txID string) {
+	if !rc.usable() || ttl <= 0 {
+		return
+	}
+	key := rc.key(method, paramsHash(params))
+	value, err := json.Marshal(diskEntry{Block: block, Tx: txID, Result: result})
+	if err != nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*redisTimeout)
+		defer cancel()
+		if _, err := rc.c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil || block < 0 {
+			return
+		}
+		if _, err := rc.c.do(ctx, "ZADD", rc.indexKey(), strconv.FormatInt(block, 10), key); err != nil {
+			return
+		}
+		rc.c.do(ctx, "ZREMRANGEBYRANK", rc.indexKey(), "0", strconv.Itoa(-redisCacheIndexMax-1))
+	}()
+}
+
+// invalidate 删除 Redis 里符合条件的条目：有区块范围时走索引，否则用 SCAN；
+// 还有其他条件时逐个读取条目判断
+func (rc *redisCache) invalidate(ctx context.Context, q cacheInvalidation) int {
+	if !rc.usable() {
+		return 0
+	}
+	var candidates []string
+	switch {
+	case q.Method != "" && q.ParamsHash != "":
+		candidates = []string{rc.key(q.Method, q.ParamsHash)}
+	case q.FromBlock != nil || q.ToBlock != nil:
+		from, to := "-inf", "+inf"
+		if q.FromBlock != nil {
+			from = strconv.FormatInt(*q.FromBlock, 10)
+		}
+		if q.ToBlock != nil {
+			to = strconv.FormatInt(*q.ToBlock, 10)
+		}
+		v, err := rc.c.do(ctx, "ZRANGEBYSCORE", rc.indexKey(), from, to)
+		if err != nil {
+			return 0
+		}
+		candidates = redisStrings(v)
+	default:
+		pattern := redisPrefix + "cache:*"
+		if q.Method != "" {
+			pattern = rc.key(q.Method, "*")
+		}
+		candidates = rc.scan(ctx, pattern)
+	}
+	removed := 0
+	for _, key := range candidates {
+		method, hash, ok := rc.parseKey(key)
+		if !ok {
+			continue
+		}
+		e := &cacheEntry{method: method, paramsHash: hash, block: -1}
+		v, err := rc.c.getString(ctx, key)
+		if err == errRedisNil {
+			rc.c.do(ctx, "ZREM", rc.indexKey(), key)
+			continue
+		}
+		var stored diskEntry
+		if err != nil || json.Unmarshal([]byte(v), &stored) != nil {
+			continue
+		}
+		e.block, e.tx = stored.Block, stored.Tx
+		if !q.matches(e) {
+			continue
+		}
+		if n, err := rc.c.do(ctx, "DEL", key); err == nil && n == int64(1) {
+			removed++
+		}
+		rc.c.do(ctx, "ZREM", rc.indexKey(), key)
+	}
+	return removed
+}
+
+func (rc *redisCache) parseKey(key string) (string, string, bool) {
+	rest := strings.TrimPrefix(key, redisPrefix+"cache:")
+	i := strings.LastIndexByte(rest, ':')
+	if i <= 0 || len(rest) == len(key) {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+func (rc *redisCache) scan(ctx context.Context, pattern string) []string {
+	var keys []string
+	cursor := "0"
+	for {
+		v, err := rc.c.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", "1000")
+		reply, ok := v.([]interface{})
+		if err != nil || !ok || len(reply) != 2 {
+			return keys
+		}
+		keys = append(keys, redisStrings(reply[1])...)
+		if cursor, _ = reply[0].(string); cursor == "0" || cursor == "" {
+			return keys
+		}
+	}
+}
+
+// broadcast 通知其他实例清除内存缓存，自己也会收到一份，重复清除没有影响
+func (rc *redisCache) broadcast(ctx context.Context, cache string, q cacheInvalidation) {
+	if !rc.usable() {
+		return
+	}
+	raw, err := json.Marshal(cacheInvalidationMessage{Cache: cache, Query: q})
+	if err != nil {
+		return
+	}
+	rc.c.do(ctx, "PUBLISH", redisPrefix+"cache-invalidate", string(raw))
+}
+
+func applyCacheInvalidation(payload string) {
+	var m cacheInvalidationMessage
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		log.Printf("Ignoring invalid cache invalidation message: %v", err)
+		return
+	}
+	switch m.Cache {
+	case "rest":
+		restCache.invalidate(m.Query)
+	case "jsonrpc":
+		responseCache.invalidate(m.Query)
+		if m.Query.TxID != "" {
+			traceCache.remove(m.Query.TxID)
+		}
+	}
+}
+
+func redisStrings(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// responseShim 针对特定 java-tron 版本修正响应（已知 bug、缺失字段）。
+// 版本区间为 [minVersion, maxVersion)，空字符串表示不限。
+type responseShim struct {
+	name       string
+	methods    []string
+	minVersion string
+	maxVersion string
+	apply      func(result map[string]interface{})
+}
+
+var responseShims = []responseShim{
+	{
+		// 旧版 jsonrpc 模块的 receipt 缺少 EIP-1559 之后客户端默认读取的字段
+		name:       "receipt-missing-fields",
+		methods:    []string{"eth_getTransactionReceipt"},
+		maxVersion: "4.7.0",
+		apply: func(result map[string]interface{}) {
+			setDefault(result, "type", "0x0")
+			setDefault(result, "effectiveGasPrice", "0x0")
+		},
+	},
+	{
+		name:       "transaction-missing-type",
+		methods:    []string{"eth_getTransactionByHash", "eth_getTransactionByBlockHashAndIndex", "eth_getTransactionByBlockNumberAndIndex"},
+		maxVersion: "4.7.0",
+		apply: func(result map[string]interface{}) {
+			setDefault(result, "type", "0x0")
+		},
+	},
+}
+
+func setDefault(m map[string]interface{}, key string, v interface{}) {
+	if _, ok := m[key]; !ok {
+		m[key] = v
+	}
+}
+
+// parseVersion 从 "TRON/v4.7.3/Linux/Java1.8" 或 "4.7.3" 中提取版本号
+func parseVersion(s string) []int {
+	for _, part := range strings.Split(s, "/") {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "v")
+		fields := strings.Split(part, ".")
+		if len(fields) < 2 {
+			continue
+		}
+		var out []int
+		for _, f := range fields {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				break
+			}
+			out = append(out, n)
+		}
+		if len(out) == len(fields) {
+			return out
+		}
+	}
+	return nil
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s responseShim) matches(method string, version []int) bool {
+	found := false
+	for _, m := range s.methods {
+		if m == method {
+			found = true
+			break
+		}
+	}
+	if !found || version == nil {
+		return false
+	}
+	if s.minVersion != "" && compareVersions(version, parseVersion(s.minVersion)) < 0 {
+		return false
+	}
+	if s.maxVersion != "" && compareVersions(version, parseVersion(s.maxVersion)) >= 0 {
+		return false
+	}
+	return true
+}
+
+// applyShims 按响应来源节点的版本应用修正，版本未知时不做任何修改
+func applyShims(u *upstream, method string, resp *JSONRPCResponse) {
+	if u == nil || resp.Error != nil {
+		return
+	}
+	result, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		return
+	}
+	version := parseVersion(u.getVersion())
+	for _, s := range responseShims {
+		if s.matches(method, version) {
+			s.apply(result)
+		}
+	}
+}
+
+func (u *upstream) getVersion() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.version
+}
+
+// detectVersion 节点首次健康时记录 java-tron 版本
+func (u *upstream) detectVersion(ctx context.Context) {
+	var version string
+	var err error
+	switch u.kind {
+	case "grpc":
+		// wallet.proto 的 NodeInfo 没有映射，gRPC 节点不检测版本
+		return
+	case "jsonrpc":
+		version, err = u.clientVersion(ctx)
+	default:
+		version, err = u.nodeInfoVersion(ctx)
+	}
+	if err != nil || version == "" {
+		log.Printf("Unable to detect version of upstream %s: %v", u.url, err)
+		return
+	}
+	u.mu.Lock()
+	u.version = version
+	u.mu.Unlock()
+	log.Printf("Upstream %s runs %s", u.url, version)
+}
+
+func (u *upstream) clientVersion(ctx context.Context) (string, error) {
+	resp, err := u.post(ctx, "", []byte(`{"jsonrpc":"2.0","method":"web3_clientVersion","params":[],"id":1}`))
+	if err != nil {
+		return "", err
+	}
+	if resp.Status/100 != 2 {
+		return "", fmt.Errorf("HTTP %d", resp.Status)
+	}
+	var rpcResp struct {
+		Result string      `json:"result"`
+		Error  interface{} `json:"error"`
+	}
+	if err := codec.Unmarshal(resp.Body, &rpcResp); err != nil {
+		return "", err
+	}
+	if rpcResp.Error != nil {
+		return "", fmt.Errorf("%v", rpcResp.Error)
+	}
+	return rpcResp.Result, nil
+}
+
+func (u *upstream) nodeInfoVersion(ctx context.Context) (string, error) {
+	resp, err := u.post(ctx, "/wallet/getnodeinfo", []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+	if resp.Status/100 != 2 {
+		return "", fmt.Errorf("HTTP %d", resp.Status)
+	}
+	var info struct {
+		ConfigNodeInfo struct {
+			CodeVersion string `json:"codeVersion"`
+		} `json:"configNodeInfo"`
+	}
+	if err := codec.Unmarshal(resp.Body, &info); err != nil {
+		return "", err
+	}
+	return info.ConfigNodeInfo.CodeVersion, nil
+}
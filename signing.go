@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signatureHeader = "X-Proxy-Signature"
+
+// 签名内容为 "<unix 秒>.<X-Request-ID>.<响应体 SHA-256 的 hex>"，请求 ID 把响应绑定到请求上，
+// 客户端自己带 X-Request-ID 时可以确认签名的响应不是别的请求的。响应头格式：
+// X-Proxy-Signature: t=<unix 秒>,alg=<hmac-sha256|ed25519>,kid=<key id>,sig=<base64>
+// 流式响应在开始输出时还不知道完整内容，签名放在同名的 HTTP trailer 里
+// responseSigner 由 New 按 RESPONSE_SIGNING、RESPONSE_SIGNING_KEY 和 RESPONSE_SIGNING_KEY_ID 创建
+var responseSigner *signer
+
+type signer struct {
+	alg    string
+	kid    string
+	secret []byte
+	priv   ed25519.PrivateKey
+}
+
+func newResponseSigner(mode, key, kid string) (*signer, error) {
+	switch mode {
+	case "off", "":
+		return nil, nil
+	case "hmac":
+		if key == "" {
+			return nil, errors.New("RESPONSE_SIGNING=hmac requires RESPONSE_SIGNING_KEY")
+		}
+		log.Printf("Signing responses with HMAC-SHA256, key id %s", kid)
+		return &signer{alg: "hmac-sha256", kid: kid, secret: []byte(key)}, nil
+	case "ed25519":
+		var priv ed25519.PrivateKey
+		if key == "" {
+			_, priv, _ = ed25519.GenerateKey(rand.Reader)
+			log.Printf("RESPONSE_SIGNING_KEY not set, generated an ephemeral Ed25519 key")
+		} else {
+			seed, err := decodeKeyMaterial(key)
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return nil, fmt.Errorf("RESPONSE_SIGNING_KEY must be a %d-byte Ed25519 seed (hex or base64)", ed25519.SeedSize)
+			}
+			priv = ed25519.NewKeyFromSeed(seed)
+		}
+		log.Printf("Signing responses with Ed25519, key id %s, public key %s", kid,
+			base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)))
+		return &signer{alg: "ed25519", kid: kid, priv: priv}, nil
+	}
+	return nil, fmt.Errorf("unknown RESPONSE_SIGNING mode %q", mode)
+}
+
+func decodeKeyMaterial(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(strings.TrimPrefix(s, "0x")); err == nil {
+		return b, nil
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// sign id 为请求 ID（webhook 为投递 ID）
+func (s *signer) sign(ts int64, id string, digest []byte) string {
+	msg := []byte(strconv.FormatInt(ts, 10) + "." + id + "." + hex.EncodeToString(digest))
+	var sig []byte
+	if s.priv != nil {
+		sig = ed25519.Sign(s.priv, msg)
+	} else {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(msg)
+		sig = mac.Sum(nil)
+	}
+	return fmt.Sprintf("t=%d,alg=%s,kid=%s,sig=%s", ts, s.alg, s.kid, base64.StdEncoding.EncodeToString(sig))
+}
+
+// signingWriter 缓存响应体直到处理结束再写出签名头；处理函数调用 Flush 时改为边写边算摘要
+type signingWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	digest    hash.Hash
+	streaming bool
+}
+
+func (sw *signingWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+}
+
+func (sw *signingWriter) Write(p []byte) (int, error) {
+	sw.digest.Write(p)
+	if sw.streaming {
+		return sw.ResponseWriter.Write(p)
+	}
+	return sw.buf.Write(p)
+}
+
+func (sw *signingWriter) Flush() {
+	if !sw.streaming {
+		sw.streaming = true
+		sw.ResponseWriter.Header().Set("Trailer", signatureHeader)
+		sw.writeHeader()
+		sw.ResponseWriter.Write(sw.buf.Bytes())
+		sw.buf.Reset()
+	}
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *signingWriter) writeHeader() {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	sw.ResponseWriter.WriteHeader(sw.status)
+}
+
+func (sw *signingWriter) finish() {
+	// withRequestID 在签名中间件里面，处理开始时已经设置了响应头
+	sig := responseSigner.sign(time.Now().Unix(), sw.ResponseWriter.Header().Get("X-Request-ID"), sw.digest.Sum(nil))
+	if sw.streaming {
+		sw.ResponseWriter.Header().Set(signatureHeader, sig)
+		return
+	}
+	sw.ResponseWriter.Header().Set(signatureHeader, sig)
+	sw.writeHeader()
+	sw.ResponseWriter.Write(sw.buf.Bytes())
+}
+
+func signResponses(next http.HandlerFunc) http.HandlerFunc {
+	if responseSigner == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &signingWriter{ResponseWriter: w, digest: sha256.New()}
+		next(sw, r)
+		sw.finish()
+	}
+}
+
+// handleSigningKey 公开 Ed25519 公钥，HMAC 模式下密钥不对外
+func handleSigningKey(w http.ResponseWriter, r *http.Request) {
+	if responseSigner == nil || responseSigner.priv == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"alg":       responseSigner.alg,
+		"kid":       responseSigner.kid,
+		"publicKey": base64.StdEncoding.EncodeToString(responseSigner.priv.Public().(ed25519.PublicKey)),
+	})
+}
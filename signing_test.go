@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 签名里带请求 ID：同一个响应体换一个请求 ID 就验证不过
+func TestResponseSignatureBindsRequestID(t *testing.T) {
+	old := responseSigner
+	seed := strings.Repeat("07", ed25519.SeedSize)
+	s, err := newResponseSigner("ed25519", seed, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseSigner = s
+	t.Cleanup(func() { responseSigner = old })
+
+	h := signResponses(withRequestID(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/wallet/getnowblock", nil)
+	req.Header.Set("X-Request-ID", "client-42")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	var ts, sig string
+	for _, part := range strings.Split(rec.Header().Get(signatureHeader), ",") {
+		kv := strings.SplitN(part, "=", 2)
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "sig":
+			sig = kv[1]
+		}
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatalf("bad signature header %q", rec.Header().Get(signatureHeader))
+	}
+	digest := sha256.Sum256(rec.Body.Bytes())
+	pub := s.priv.Public().(ed25519.PublicKey)
+	verify := func(id string) bool {
+		return ed25519.Verify(pub, []byte(ts+"."+id+"."+hex.EncodeToString(digest[:])), raw)
+	}
+	if !verify("client-42") {
+		t.Error("signature does not verify with the request ID")
+	}
+	if verify("other-request") {
+		t.Error("signature verifies for a different request ID")
+	}
+}
@@ -0,0 +1,500 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// 链参数（energy 和带宽单价、建账户费用）缓存时间，提案通过后最多这么久生效
+	chainParamsTTL = envDuration("CHAIN_PARAMS_TTL", 10*time.Minute)
+)
+
+// TRON 合约类型，见 Tron.proto 的 Transaction.Contract.ContractType
+const (
+	contractTypeTransfer       = 1
+	contractTypeCreateContract = 30
+	contractTypeTrigger        = 31
+)
+
+// 每个合约在交易里预留的结果大小，java-tron 计算带宽时加上这部分
+const maxResultSizeInTx = 64
+
+// chainParams 模拟用到的几个链参数，单位 sun
+type chainParams struct {
+	EnergyFee                   int64
+	TransactionFee              int64
+	CreateAccountFee            int64
+	CreateNewAccountFeeInSystem int64
+}
+
+type chainParamsEntry struct {
+	params  chainParams
+	fetched time.Time
+}
+
+var chainParamsCache = struct {
+	sync.Mutex
+	m map[string]chainParamsEntry
+}{m: map[string]chainParamsEntry{}}
+
+// simulatedTx 从参数里解析出的交易；payload 非空时需要执行合约
+type simulatedTx struct {
+	owner     string
+	payload   map[string]interface{}
+	recipient string // 普通转账的收款地址，不存在时要额外收建账户费用
+	amount    int64
+	raw       []byte
+	sigs      int
+	feeLimit  int64
+}
+
+type simulationResult struct {
+	Success               bool   `json:"success"`
+	ContractResult        string `json:"contractResult,omitempty"`
+	Error                 string `json:"error,omitempty"`
+	RevertReason          string `json:"revertReason,omitempty"`
+	ReturnData            string `json:"returnData,omitempty"`
+	EnergyUsed            int64  `json:"energyUsed"`
+	EnergyFromStake       int64  `json:"energyFromStake"`
+	Bandwidth             int64  `json:"bandwidth"`
+	BandwidthFree         bool   `json:"bandwidthFree"`
+	EnergyPrice           int64  `json:"energyPrice"`
+	BandwidthPrice        int64  `json:"bandwidthPrice"`
+	EnergyFeeSun          int64  `json:"energyFeeSun"`
+	BandwidthFeeSun       int64  `json:"bandwidthFeeSun"`
+	AccountCreationFeeSun int64  `json:"accountCreationFeeSun,omitempty"`
+	FeeSun                int64  `json:"feeSun"`
+	FeeTrx                string `json:"feeTrx"`
+	FeeLimit              int64  `json:"feeLimit,omitempty"`
+	ExceedsFeeLimit       bool   `json:"exceedsFeeLimit,omitempty"`
+}
+
+type accountResource struct {
+	FreeNetLimit int64 `json:"freeNetLimit"`
+	FreeNetUsed  int64 `json:"freeNetUsed"`
+	NetLimit     int64 `json:"NetLimit"`
+	NetUsed      int64 `json:"NetUsed"`
+	EnergyLimit  int64 `json:"EnergyLimit"`
+	EnergyUsed   int64 `json:"EnergyUsed"`
+}
+
+// handleSimulateTransaction proxy_simulateTransaction(tx)：tx 可以是 eth_call 风格的调用对象、
+// 带 raw_data_hex 的 TRON 交易 JSON，或 eth_sendRawTransaction 格式的交易 hex。
+// 合约调用用 triggerconstantcontract 执行，按账户质押的资源和当前链参数估算 energy、带宽和手续费，不广播。
+// 合约部署者分摊的 energy 不在估算范围内
+func handleSimulateTransaction(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(restUpstreams.list()) == 0 && networkFrom(ctx) == nil {
+		return jsonError(req.ID, -32601, "proxy_simulateTransaction requires a REST upstream")
+	}
+	if len(req.Params) < 1 {
+		return jsonError(req.ID, -32602, "Invalid params: expected call object or transaction")
+	}
+	tx, err := parseSimulatedTx(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	res := simulationResult{Success: true, FeeTrx: "0", FeeLimit: tx.feeLimit}
+	if tx.payload != nil {
+		if err := simulateContract(ctx, tx, &res); err != nil {
+			return errorResponse(req.ID, err)
+		}
+	} else if tx.raw == nil {
+		if err := buildTransfer(ctx, tx, &res); err != nil {
+			return errorResponse(req.ID, err)
+		}
+	}
+	if res.Error != "" {
+		// 校验没有通过的交易不会上链，也不收费
+		return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: res}
+	}
+	params, err := getChainParams(ctx)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	var creates bool
+	if tx.recipient != "" {
+		if creates, err = accountMissing(ctx, tx.recipient); err != nil {
+			return errorResponse(req.ID, err)
+		}
+	}
+	var resource accountResource
+	if tx.owner != translate.ZeroAddress {
+		if err := callRest(ctx, "/wallet/getaccountresource", map[string]interface{}{"address": tx.owner, "visible": false}, &resource); err != nil {
+			return errorResponse(req.ID, err)
+		}
+	}
+	res.estimateFees(tx, params, resource, creates)
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: res}
+}
+
+func parseSimulatedTx(raw json.RawMessage) (*simulatedTx, error) {
+	var s string
+	if codec.Unmarshal(raw, &s) == nil {
+		b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("transaction is not valid hex")
+		}
+		return parseSignedTransaction(b)
+	}
+	var tronTx struct {
+		RawDataHex string   `json:"raw_data_hex"`
+		Signature  []string `json:"signature"`
+	}
+	if err := codec.Unmarshal(raw, &tronTx); err != nil {
+		return nil, fmt.Errorf("expected call object or transaction")
+	}
+	if tronTx.RawDataHex != "" {
+		b, err := hex.DecodeString(tronTx.RawDataHex)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("raw_data_hex is not valid hex")
+		}
+		return parseRawTransaction(b, len(tronTx.Signature))
+	}
+	return parseCallObject(raw)
+}
+
+// parseCallObject 有 to 和 data 是合约调用，只有 data 是部署合约，只有 to 是 TRX 转账
+func parseCallObject(raw json.RawMessage) (*simulatedTx, error) {
+	payload, ok, err := translate.TriggerPayload(raw)
+	if err != nil {
+		return nil, err
+	}
+	var call translate.CallObject
+	codec.Unmarshal(raw, &call)
+	if ok {
+		return &simulatedTx{owner: payload["owner_address"].(string), payload: payload, sigs: 1}, nil
+	}
+	if call.From == "" {
+		return nil, fmt.Errorf("from is required for transfers and deployments")
+	}
+	from, err := translate.DecodeAddress(call.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q", call.From)
+	}
+	tx := &simulatedTx{owner: hex.EncodeToString(from), sigs: 1}
+	if call.Value != "" {
+		if tx.amount, err = translate.ParseQuantity(call.Value); err != nil || tx.amount < 0 {
+			return nil, fmt.Errorf("invalid value %q", call.Value)
+		}
+	}
+	data := call.Input
+	if data == "" {
+		data = call.Data
+	}
+	data = strings.TrimPrefix(strings.TrimPrefix(data, "0x"), "0X")
+	switch {
+	case call.To == "" && data != "":
+		if _, err := hex.DecodeString(data); err != nil {
+			return nil, fmt.Errorf("invalid data: %v", err)
+		}
+		tx.payload = map[string]interface{}{"owner_address": tx.owner, "data": data, "visible": false}
+		if tx.amount > 0 {
+			tx.payload["call_value"] = tx.amount
+		}
+	case call.To != "":
+		to, err := translate.DecodeAddress(call.To)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to address %q", call.To)
+		}
+		tx.recipient = hex.EncodeToString(to)
+	default:
+		return nil, fmt.Errorf("to or data is required")
+	}
+	return tx, nil
+}
+
+// parseSignedTransaction Transaction{raw_data = 1, signature = 2}
+func parseSignedTransaction(b []byte) (*simulatedTx, error) {
+	fields, err := pbParse(b)
+	if err != nil {
+		return nil, fmt.Errorf("transaction is not a valid protobuf message")
+	}
+	var raw []byte
+	sigs := 0
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			raw = f.data
+		case 2:
+			sigs++
+		}
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("transaction has no raw_data")
+	}
+	return parseRawTransaction(raw, sigs)
+}
+
+// parseRawTransaction Transaction.raw：contract = 11，fee_limit = 18。只看第一个合约，
+// 转账、合约调用和部署以外的系统合约只消耗带宽
+func parseRawTransaction(raw []byte, sigs int) (*simulatedTx, error) {
+	fields, err := pbParse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("raw_data is not a valid protobuf message")
+	}
+	if sigs == 0 {
+		sigs = 1
+	}
+	tx := &simulatedTx{raw: raw, sigs: sigs, owner: translate.ZeroAddress}
+	var contract []byte
+	for _, f := range fields {
+		switch f.num {
+		case 11:
+			if contract == nil {
+				contract = f.data
+			}
+		case 18:
+			tx.feeLimit = int64(f.v)
+		}
+	}
+	if contract == nil {
+		return nil, fmt.Errorf("transaction has no contract")
+	}
+	// Contract{type = 1, parameter = 2 (Any{type_url = 1, value = 2})}
+	var typ uint64
+	var value []byte
+	cf, err := pbParse(contract)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract: %v", err)
+	}
+	for _, f := range cf {
+		switch f.num {
+		case 1:
+			typ = f.v
+		case 2:
+			anyFields, err := pbParse(f.data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid contract parameter: %v", err)
+			}
+			for _, a := range anyFields {
+				if a.num == 2 {
+					value = a.data
+				}
+			}
+		}
+	}
+	vf, err := pbParse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract parameter: %v", err)
+	}
+	// 各类合约的 owner_address 都是字段 1
+	for _, f := range vf {
+		if f.num == 1 && len(f.data) > 0 {
+			tx.owner = hex.EncodeToString(f.data)
+		}
+	}
+	switch typ {
+	case contractTypeTransfer:
+		// TransferContract{owner_address = 1, to_address = 2, amount = 3}
+		for _, f := range vf {
+			switch f.num {
+			case 2:
+				tx.recipient = hex.EncodeToString(f.data)
+			case 3:
+				tx.amount = int64(f.v)
+			}
+		}
+	case contractTypeTrigger:
+		// TriggerSmartContract{owner_address = 1, contract_address = 2, call_value = 3, data = 4, call_token_value = 5, token_id = 6}
+		tx.payload = map[string]interface{}{"owner_address": tx.owner, "visible": false}
+		for _, f := range vf {
+			switch f.num {
+			case 2:
+				tx.payload["contract_address"] = hex.EncodeToString(f.data)
+			case 3:
+				tx.payload["call_value"] = int64(f.v)
+			case 4:
+				tx.payload["data"] = hex.EncodeToString(f.data)
+			case 5:
+				tx.payload["call_token_value"] = int64(f.v)
+			case 6:
+				tx.payload["token_id"] = int64(f.v)
+			}
+		}
+	case contractTypeCreateContract:
+		// CreateSmartContract{owner_address = 1, new_contract = 2}，SmartContract{bytecode = 4, call_value = 5}
+		tx.payload = map[string]interface{}{"owner_address": tx.owner, "visible": false}
+		for _, f := range vf {
+			if f.num != 2 {
+				continue
+			}
+			sc, err := pbParse(f.data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid new_contract: %v", err)
+			}
+			for _, s := range sc {
+				switch s.num {
+				case 4:
+					tx.payload["data"] = hex.EncodeToString(s.data)
+				case 5:
+					tx.payload["call_value"] = int64(s.v)
+				}
+			}
+		}
+	}
+	return tx, nil
+}
+
+// simulateContract 执行合约调用或部署。节点拒绝执行（余额不足、合约不存在等）和 revert
+// 都作为模拟结果返回，只有请求节点失败时才返回错误
+func simulateContract(ctx context.Context, tx *simulatedTx, res *simulationResult) error {
+	var out triggerConstantResponse
+	if err := callRest(ctx, "/wallet/triggerconstantcontract", tx.payload, &out); err != nil {
+		return err
+	}
+	if !out.Result.Result {
+		res.Success = false
+		res.ContractResult = out.Result.Code
+		res.Error = up.DecodeMessage(out.Result.Message)
+		return nil
+	}
+	res.EnergyUsed = out.EnergyUsed
+	var data []byte
+	if len(out.ConstantResult) > 0 {
+		data, _ = hex.DecodeString(out.ConstantResult[0])
+		res.ReturnData = "0x" + out.ConstantResult[0]
+	}
+	res.ContractResult = "SUCCESS"
+	if len(out.Transaction.Ret) > 0 && out.Transaction.Ret[0].ContractRet != "" {
+		res.ContractResult = out.Transaction.Ret[0].ContractRet
+	}
+	if res.ContractResult != "SUCCESS" {
+		res.Success = false
+		res.RevertReason = translate.DecodeRevertReason(data)
+	}
+	if tx.raw == nil {
+		tx.raw, _ = hex.DecodeString(out.Transaction.RawDataHex)
+	}
+	return nil
+}
+
+// buildTransfer 让节点生成转账交易，同时检查余额等条件
+func buildTransfer(ctx context.Context, tx *simulatedTx, res *simulationResult) error {
+	var out struct {
+		RawDataHex string `json:"raw_data_hex"`
+	}
+	payload := map[string]interface{}{"owner_address": tx.owner, "to_address": tx.recipient, "amount": tx.amount, "visible": false}
+	err := callRest(ctx, "/wallet/createtransaction", payload, &out)
+	var ue *upstreamError
+	if errors.As(err, &ue) && ue.Status/100 == 2 {
+		// 节点返回 200 和 {"Error": "..."} 表示校验失败，例如余额不足
+		res.Success = false
+		res.ContractResult = ue.TronCode
+		if res.ContractResult == "" {
+			res.ContractResult = "CONTRACT_VALIDATE_ERROR"
+		}
+		res.Error = ue.Message
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tx.raw, _ = hex.DecodeString(out.RawDataHex)
+	return nil
+}
+
+func accountMissing(ctx context.Context, address string) (bool, error) {
+	var out struct {
+		Address string `json:"address"`
+	}
+	if err := callRest(ctx, "/wallet/getaccount", map[string]interface{}{"address": address, "visible": false}, &out); err != nil {
+		return false, err
+	}
+	return out.Address == "", nil
+}
+
+// estimateFees 按 java-tron BandwidthProcessor / EnergyProcessor 的规则估算：
+// 带宽优先用质押的，不够时用免费额度，都不够时整笔按单价燃烧 TRX；新建账户不能用免费额度，
+// 质押不够时收 getCreateAccountFee。energy 先用质押的，不足部分按单价燃烧
+func (res *simulationResult) estimateFees(tx *simulatedTx, p chainParams, r accountResource, createsAccount bool) {
+	res.EnergyPrice, res.BandwidthPrice = p.EnergyFee, p.TransactionFee
+	if tx.raw != nil {
+		size := protowire.SizeTag(1) + protowire.SizeBytes(len(tx.raw))
+		size += tx.sigs * (protowire.SizeTag(2) + protowire.SizeBytes(65))
+		res.Bandwidth = int64(size + maxResultSizeInTx)
+	}
+	staked, free := r.NetLimit-r.NetUsed, r.FreeNetLimit-r.FreeNetUsed
+	switch {
+	case createsAccount:
+		res.AccountCreationFeeSun = p.CreateNewAccountFeeInSystem
+		if staked >= res.Bandwidth {
+			res.BandwidthFree = true
+		} else {
+			res.BandwidthFeeSun = p.CreateAccountFee
+		}
+	case staked >= res.Bandwidth || free >= res.Bandwidth:
+		res.BandwidthFree = true
+	default:
+		res.BandwidthFeeSun = res.Bandwidth * p.TransactionFee
+	}
+	if available := r.EnergyLimit - r.EnergyUsed; available > 0 {
+		res.EnergyFromStake = available
+		if res.EnergyFromStake > res.EnergyUsed {
+			res.EnergyFromStake = res.EnergyUsed
+		}
+	}
+	res.EnergyFeeSun = (res.EnergyUsed - res.EnergyFromStake) * p.EnergyFee
+	res.FeeSun = res.EnergyFeeSun + res.BandwidthFeeSun + res.AccountCreationFeeSun
+	res.FeeTrx = formatSun(res.FeeSun)
+	res.ExceedsFeeLimit = res.FeeLimit > 0 && res.EnergyFeeSun > res.FeeLimit
+}
+
+// formatSun 1 TRX = 1,000,000 sun
+func formatSun(sun int64) string {
+	s := strconv.FormatInt(sun/1000000, 10) + "." + fmt.Sprintf("%06d", sun%1000000)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s
+}
+
+// getChainParams 按网络缓存 /wallet/getchainparameters 的结果
+func getChainParams(ctx context.Context) (chainParams, error) {
+	key := defaultNetwork
+	if n := networkFrom(ctx); n != nil {
+		key = n.name
+	}
+	chainParamsCache.Lock()
+	e, ok := chainParamsCache.m[key]
+	chainParamsCache.Unlock()
+	if ok && time.Since(e.fetched) < chainParamsTTL {
+		return e.params, nil
+	}
+	var out struct {
+		ChainParameter []struct {
+			Key   string `json:"key"`
+			Value int64  `json:"value"`
+		} `json:"chainParameter"`
+	}
+	if err := callRest(ctx, "/wallet/getchainparameters", map[string]interface{}{}, &out); err != nil {
+		return chainParams{}, err
+	}
+	var p chainParams
+	for _, kv := range out.ChainParameter {
+		switch kv.Key {
+		case "getEnergyFee":
+			p.EnergyFee = kv.Value
+		case "getTransactionFee":
+			p.TransactionFee = kv.Value
+		case "getCreateAccountFee":
+			p.CreateAccountFee = kv.Value
+		case "getCreateNewAccountFeeInSystemContract":
+			p.CreateNewAccountFeeInSystem = kv.Value
+		}
+	}
+	chainParamsCache.Lock()
+	chainParamsCache.m[key] = chainParamsEntry{params: p, fetched: time.Now()}
+	chainParamsCache.Unlock()
+	return p, nil
+}
@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/yourname/proxy/translate"
+)
+
+// rawTransfer 构造 Transaction.raw：一个 TransferContract 和 fee_limit
+func rawTransfer(owner, to []byte, amount, feeLimit uint64) []byte {
+	var value []byte
+	value = protowire.AppendTag(value, 1, protowire.BytesType)
+	value = protowire.AppendBytes(value, owner)
+	value = protowire.AppendTag(value, 2, protowire.BytesType)
+	value = protowire.AppendBytes(value, to)
+	value = protowire.AppendTag(value, 3, protowire.VarintType)
+	value = protowire.AppendVarint(value, amount)
+
+	var param []byte
+	param = protowire.AppendTag(param, 1, protowire.BytesType)
+	param = protowire.AppendString(param, "type.googleapis.com/protocol.TransferContract")
+	param = protowire.AppendTag(param, 2, protowire.BytesType)
+	param = protowire.AppendBytes(param, value)
+
+	var contract []byte
+	contract = protowire.AppendTag(contract, 1, protowire.VarintType)
+	contract = protowire.AppendVarint(contract, contractTypeTransfer)
+	contract = protowire.AppendTag(contract, 2, protowire.BytesType)
+	contract = protowire.AppendBytes(contract, param)
+
+	var raw []byte
+	raw = protowire.AppendTag(raw, 11, protowire.BytesType)
+	raw = protowire.AppendBytes(raw, contract)
+	if feeLimit > 0 {
+		raw = protowire.AppendTag(raw, 18, protowire.VarintType)
+		raw = protowire.AppendVarint(raw, feeLimit)
+	}
+	return raw
+}
+
+func TestParseSignedTransfer(t *testing.T) {
+	owner, _ := translate.DecodeAddress(testCreator)
+	to, _ := translate.DecodeAddress(testContract)
+	raw := rawTransfer(owner, to, 1500000, 0)
+
+	var signed []byte
+	signed = protowire.AppendTag(signed, 1, protowire.BytesType)
+	signed = protowire.AppendBytes(signed, raw)
+	for i := 0; i < 2; i++ {
+		signed = protowire.AppendTag(signed, 2, protowire.BytesType)
+		signed = protowire.AppendBytes(signed, make([]byte, 65))
+	}
+
+	param, _ := json.Marshal("0x" + hex.EncodeToString(signed))
+	tx, err := parseSimulatedTx(param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.owner != hex.EncodeToString(owner) || tx.recipient != hex.EncodeToString(to) || tx.amount != 1500000 {
+		t.Errorf("transfer = owner %s, to %s, amount %d", tx.owner, tx.recipient, tx.amount)
+	}
+	if tx.sigs != 2 || tx.payload != nil || string(tx.raw) != string(raw) {
+		t.Errorf("sigs = %d, payload = %v, raw kept = %v", tx.sigs, tx.payload, string(tx.raw) == string(raw))
+	}
+
+	// TRON JSON 格式：签名数量取 signature 数组，fee_limit 从 raw_data 读
+	raw = rawTransfer(owner, to, 1, 30000000)
+	param, _ = json.Marshal(map[string]interface{}{"raw_data_hex": hex.EncodeToString(raw), "signature": []string{"aa"}})
+	if tx, err = parseSimulatedTx(param); err != nil {
+		t.Fatal(err)
+	}
+	if tx.sigs != 1 || tx.feeLimit != 30000000 {
+		t.Errorf("sigs = %d, feeLimit = %d", tx.sigs, tx.feeLimit)
+	}
+}
+
+func TestParseCallObject(t *testing.T) {
+	from, _ := translate.DecodeAddress(testCreator)
+	cases := []struct {
+		name    string
+		call    string
+		payload bool
+		to      string
+		amount  int64
+		err     string
+	}{
+		{"contract call", `{"to":"` + testContract + `","data":"0xa9059cbb"}`, true, "", 0, ""},
+		{"deployment", `{"from":"` + testCreator + `","data":"0x6080","value":"0x10"}`, true, "", 16, ""},
+		{"transfer", `{"from":"` + testCreator + `","to":"` + testContract + `","value":"0x5"}`, false, "41a614f803b6fd780986a42c78ec9c7f77e6ded13c", 5, ""},
+		{"transfer without from", `{"to":"` + testContract + `"}`, false, "", 0, "from is required"},
+		{"nothing to do", `{"from":"` + testCreator + `"}`, false, "", 0, "to or data is required"},
+		{"negative value", `{"from":"` + testCreator + `","to":"` + testContract + `","value":"-1"}`, false, "", 0, "invalid value"},
+	}
+	for _, c := range cases {
+		tx, err := parseSimulatedTx(json.RawMessage(c.call))
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: err = %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if (tx.payload != nil) != c.payload || tx.recipient != c.to || tx.amount != c.amount {
+			t.Errorf("%s: payload %v, recipient %q, amount %d", c.name, tx.payload, tx.recipient, tx.amount)
+		}
+		if c.name != "contract call" && tx.owner != hex.EncodeToString(from) {
+			t.Errorf("%s: owner = %s", c.name, tx.owner)
+		}
+	}
+	// 调用合约不带 from 时用零地址执行
+	tx, _ := parseSimulatedTx(json.RawMessage(cases[0].call))
+	if tx.owner != translate.ZeroAddress {
+		t.Errorf("call without from: owner = %s", tx.owner)
+	}
+}
+
+func TestEstimateFees(t *testing.T) {
+	p := chainParams{EnergyFee: 420, TransactionFee: 1000, CreateAccountFee: 100000, CreateNewAccountFeeInSystem: 1000000}
+	raw := make([]byte, 100)
+	// 1 + 1 + 100 字节 raw_data，1 + 1 + 65 字节签名，再加 64 字节结果
+	const bandwidth = 233
+	cases := []struct {
+		name      string
+		energy    int64
+		r         accountResource
+		creates   bool
+		feeLimit  int64
+		free      bool
+		bwFee     int64
+		energyFee int64
+		total     int64
+		exceeds   bool
+	}{
+		{"free bandwidth", 0, accountResource{FreeNetLimit: 600}, false, 0, true, 0, 0, 0, false},
+		{"burned bandwidth", 0, accountResource{FreeNetLimit: 600, FreeNetUsed: 500}, false, 0, false, bandwidth * 1000, 0, bandwidth * 1000, false},
+		{"new account", 0, accountResource{FreeNetLimit: 600}, true, 0, false, 100000, 0, 1100000, false},
+		{"new account staked", 0, accountResource{NetLimit: 1000}, true, 0, true, 0, 0, 1000000, false},
+		{"partial stake", 30000, accountResource{NetLimit: 1000, EnergyLimit: 10000, EnergyUsed: 2000}, false, 0, true, 0, 22000 * 420, 22000 * 420, false},
+		{"over fee limit", 30000, accountResource{NetLimit: 1000}, false, 10000000, true, 0, 30000 * 420, 30000 * 420, true},
+	}
+	for _, c := range cases {
+		res := simulationResult{Success: true, EnergyUsed: c.energy, FeeLimit: c.feeLimit}
+		res.estimateFees(&simulatedTx{raw: raw, sigs: 1}, p, c.r, c.creates)
+		if res.Bandwidth != bandwidth {
+			t.Errorf("%s: bandwidth = %d, want %d", c.name, res.Bandwidth, bandwidth)
+		}
+		if res.BandwidthFree != c.free || res.BandwidthFeeSun != c.bwFee || res.EnergyFeeSun != c.energyFee ||
+			res.FeeSun != c.total || res.ExceedsFeeLimit != c.exceeds || res.FeeTrx != formatSun(c.total) {
+			t.Errorf("%s: got %+v", c.name, res)
+		}
+	}
+}
+
+func TestFormatSun(t *testing.T) {
+	cases := map[int64]string{0: "0", 1: "0.000001", 1000000: "1", 1234500: "1.2345", 27000000000: "27000"}
+	for sun, want := range cases {
+		if got := formatSun(sun); got != want {
+			t.Errorf("formatSun(%d) = %q, want %q", sun, got, want)
+		}
+	}
+}
+
+// 合约调用走 triggerconstantcontract，按链参数和账户资源估算手续费，不广播
+func TestSimulateContractCall(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/wallet/triggerconstantcontract":
+			w.Write([]byte(`{"result":{"result":true},"energy_used":13000,"constant_result":["` + strings.Repeat("0", 63) + `1"],` +
+				`"transaction":{"ret":[{"contractRet":"SUCCESS"}],"raw_data_hex":"` + strings.Repeat("ab", 150) + `"}}`))
+		case "/wallet/getchainparameters":
+			w.Write([]byte(`{"chainParameter":[{"key":"getEnergyFee","value":420},{"key":"getTransactionFee","value":1000}]}`))
+		case "/wallet/getaccountresource":
+			w.Write([]byte(`{"freeNetLimit":600,"EnergyLimit":3000}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	withTestNode(t)
+	restUpstreams = newUpstreamPool("rest", []string{srv.URL})
+	chainParamsCache.Lock()
+	oldParams := chainParamsCache.m
+	chainParamsCache.m = map[string]chainParamsEntry{}
+	chainParamsCache.Unlock()
+	t.Cleanup(func() {
+		chainParamsCache.Lock()
+		chainParamsCache.m = oldParams
+		chainParamsCache.Unlock()
+	})
+
+	params := []json.RawMessage{json.RawMessage(`{"from":"` + testCreator + `","to":"` + testContract + `","data":"0x70a08231"}`)}
+	resp := handleSimulateTransaction(context.Background(), JSONRPCRequest{Jsonrpc: "2.0", ID: json.RawMessage(`1`), Method: "proxy_simulateTransaction", Params: params})
+	if resp.Error != nil {
+		t.Fatalf("error: %+v", resp.Error)
+	}
+	res, ok := resp.Result.(simulationResult)
+	if !ok {
+		t.Fatalf("result type %T", resp.Result)
+	}
+	if !res.Success || res.ContractResult != "SUCCESS" || res.ReturnData != "0x"+strings.Repeat("0", 63)+"1" {
+		t.Errorf("result = %+v", res)
+	}
+	if res.EnergyUsed != 13000 || res.EnergyFromStake != 3000 || res.EnergyFeeSun != 10000*420 || !res.BandwidthFree {
+		t.Errorf("fees = %+v", res)
+	}
+	if res.FeeSun != 4200000 || res.FeeTrx != "4.2" {
+		t.Errorf("fee = %d sun, %s TRX", res.FeeSun, res.FeeTrx)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range paths {
+		if strings.Contains(p, "broadcast") {
+			t.Errorf("simulation called %s", p)
+		}
+	}
+}
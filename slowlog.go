@@ -0,0 +1,276 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 慢请求日志：超过 SLOW_LOG_THRESHOLD 的请求，以及按 SLOW_LOG_SAMPLE_RATE 随机抽样的请求，
+// 连同 _proxy.debug 里同样的耗时拆分记录到内存环形缓冲区，可选追加写入 SLOW_LOG_FILE（JSON lines）。
+// 两个都为 0 时关闭，请求路径上不做任何额外收集
+var (
+	slowLogThreshold  = envDuration("SLOW_LOG_THRESHOLD", 0)
+	slowLogSampleRate = envFloat("SLOW_LOG_SAMPLE_RATE", 0)
+	slowLogSize       = envInt("SLOW_LOG_SIZE", 1000)
+	slowLogFile       = configValue("SLOW_LOG_FILE")
+	// 批量请求只保留前几条的参数哈希
+	slowLogMaxHashes = 20
+
+	slowLog = newSlowRing(slowLogSize)
+
+	metricSlowRequests = newCounterVec("proxy_slow_requests_total",
+		"Requests recorded in the slow-request log, by method and reason.", "method", "reason")
+)
+
+type slowEntry struct {
+	RequestID  string     `json:"requestId,omitempty"`
+	Time       time.Time  `json:"time"`
+	Kind       string     `json:"kind"`
+	Method     string     `json:"method"`
+	ParamsHash []string   `json:"paramsHash,omitempty"`
+	BatchSize  int        `json:"batchSize,omitempty"`
+	Key        string     `json:"key"`
+	Upstreams  []string   `json:"upstreams,omitempty"`
+	DurationMs float64    `json:"durationMs"`
+	Errors     int        `json:"errors,omitempty"`
+	Sampled    bool       `json:"sampled,omitempty"`
+	Breakdown  *debugInfo `json:"breakdown"`
+}
+
+type slowRing struct {
+	mu      sync.Mutex
+	entries []slowEntry
+	next    int
+	full    bool
+	file    *os.File
+}
+
+func newSlowRing(size int) *slowRing {
+	if slowLogThreshold <= 0 && slowLogSampleRate <= 0 {
+		return nil
+	}
+	s := &slowRing{}
+	if size > 0 {
+		s.entries = make([]slowEntry, size)
+	}
+	if slowLogFile != "" {
+		f, err := os.OpenFile(slowLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Printf("Error opening slow log %s: %v", slowLogFile, err)
+		} else {
+			s.file = f
+		}
+	}
+	log.Printf("Slow-request log enabled (threshold %s, sample rate %g)", slowLogThreshold, slowLogSampleRate)
+	return s
+}
+
+func (s *slowRing) enabled() bool {
+	return s != nil
+}
+
+// observe 在请求处理完后调用，d 是 startDebug 创建的收集器
+func (s *slowRing) observe(ctx context.Context, d *debugInfo, method string, reqs []JSONRPCRequest, responses []JSONRPCResponse) {
+	if s == nil || d == nil {
+		return
+	}
+	elapsed := time.Since(d.start)
+	slow := slowLogThreshold > 0 && elapsed >= slowLogThreshold
+	sampled := !slow && slowLogSampleRate > 0 && rand.Float64() < slowLogSampleRate
+	if !slow && !sampled {
+		return
+	}
+	breakdown := d.snapshot()
+	e := slowEntry{
+		RequestID:  requestIDFromContext(ctx),
+		Time:       time.Now().UTC(),
+		Kind:       d.Scope,
+		Method:     method,
+		Key:        usageKeyLabel(ctx),
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+		Sampled:    sampled,
+		Breakdown:  breakdown,
+	}
+	if len(reqs) > 1 {
+		e.BatchSize = len(reqs)
+	}
+	for i, r := range reqs {
+		if i == slowLogMaxHashes {
+			break
+		}
+		e.ParamsHash = append(e.ParamsHash, paramsHash(r.Params))
+	}
+	for _, resp := range responses {
+		if resp.Error != nil {
+			e.Errors++
+		}
+	}
+	seen := make(map[string]bool)
+	for _, call := range breakdown.Upstreams {
+		if !seen[call.Upstream] {
+			seen[call.Upstream] = true
+			e.Upstreams = append(e.Upstreams, call.Upstream)
+		}
+	}
+	reason := "slow"
+	if sampled {
+		reason = "sampled"
+	}
+	metricSlowRequests.inc(methodLabel(method), reason)
+	if slow {
+		log.Printf("Slow %s request %s took %.0fms (key %s)", e.Kind, method, e.DurationMs, e.Key)
+	}
+
+	line, _ := json.Marshal(e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) > 0 {
+		s.entries[s.next] = e
+		s.next = (s.next + 1) % len(s.entries)
+		if s.next == 0 {
+			s.full = true
+		}
+	}
+	if s.file != nil {
+		s.file.Write(append(line, '\n'))
+	}
+}
+
+// snapshot 复制一份，请求结束后还在跑的对冲请求可能继续往 d 里写
+func (d *debugInfo) snapshot() *debugInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &debugInfo{
+		start:     d.start,
+		Scope:     d.Scope,
+		TotalMs:   msSince(d.start),
+		Stages:    append([]debugStage(nil), d.Stages...),
+		Cache:     append([]debugCacheLookup(nil), d.Cache...),
+		Trace:     d.Trace,
+		Upstreams: append([]debugUpstreamCall(nil), d.Upstreams...),
+		Retries:   d.Retries,
+	}
+}
+
+// list 按时间倒序返回
+func (s *slowRing) list(method string, minMs float64, sampled *bool, limit int) []slowEntry {
+	out := []slowEntry{}
+	if s == nil {
+		return out
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.next
+	if s.full {
+		n = len(s.entries)
+	}
+	for i := 1; i <= n && len(out) < limit; i++ {
+		e := s.entries[(s.next-i+len(s.entries))%len(s.entries)]
+		if (method != "" && e.Method != method) || e.DurationMs < minMs || (sampled != nil && e.Sampled != *sampled) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (s *slowRing) reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	for i := range s.entries {
+		s.entries[i] = slowEntry{}
+	}
+	s.next, s.full = 0, false
+	s.mu.Unlock()
+}
+
+// slowPattern 按方法 + 调用方 + 参数哈希聚合，找出反复出现的慢查询
+type slowPattern struct {
+	Method     string    `json:"method"`
+	Key        string    `json:"key"`
+	ParamsHash string    `json:"paramsHash,omitempty"`
+	Count      int       `json:"count"`
+	TotalMs    float64   `json:"totalMs"`
+	MaxMs      float64   `json:"maxMs"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+func slowPatterns(entries []slowEntry) []*slowPattern {
+	byKey := make(map[string]*slowPattern)
+	var out []*slowPattern
+	for _, e := range entries {
+		if e.Sampled {
+			continue
+		}
+		hash := ""
+		if e.BatchSize == 0 && len(e.ParamsHash) == 1 {
+			hash = e.ParamsHash[0]
+		}
+		k := e.Method + "\x00" + e.Key + "\x00" + hash
+		p := byKey[k]
+		if p == nil {
+			p = &slowPattern{Method: e.Method, Key: e.Key, ParamsHash: hash}
+			byKey[k] = p
+			out = append(out, p)
+		}
+		p.Count++
+		p.TotalMs += e.DurationMs
+		if e.DurationMs > p.MaxMs {
+			p.MaxMs = e.DurationMs
+		}
+		if e.Time.After(p.LastSeen) {
+			p.LastSeen = e.Time
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TotalMs > out[j].TotalMs })
+	return out
+}
+
+// handleAdminSlow GET /admin/slow?method=&minMs=&sampled=true|false&limit=100&group=pattern，DELETE 清空
+func handleAdminSlow(w http.ResponseWriter, r *http.Request) {
+	if !slowLog.enabled() {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "set SLOW_LOG_THRESHOLD or SLOW_LOG_SAMPLE_RATE to enable the slow-request log"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit := 100
+		if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+			limit = n
+		}
+		minMs, _ := strconv.ParseFloat(q.Get("minMs"), 64)
+		var sampled *bool
+		if v, err := strconv.ParseBool(q.Get("sampled")); err == nil {
+			sampled = &v
+		}
+		if q.Get("group") == "pattern" {
+			patterns := slowPatterns(slowLog.list(q.Get("method"), minMs, nil, len(slowLog.entries)))
+			if len(patterns) > limit {
+				patterns = patterns[:limit]
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"threshold": slowLogThreshold.String(), "patterns": patterns})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"threshold":  slowLogThreshold.String(),
+			"sampleRate": slowLogSampleRate,
+			"entries":    slowLog.list(q.Get("method"), minMs, sampled, limit),
+		})
+	case http.MethodDelete:
+		slowLog.reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET or DELETE required"})
+	}
+}
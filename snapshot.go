@@ -0,0 +1,362 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// SNAPSHOT_FILE 启动时导入（文件存在时），正常退出时写出，升级重启后缓存不用从零开始；以 .gz 结尾时压缩
+var snapshotFile = envString("SNAPSHOT_FILE", "")
+
+// snapshotSection 可以导出的一类状态，导入时与当前状态合并，已有的条目不覆盖
+type snapshotSection struct {
+	name    string
+	export  func() (interface{}, error)
+	restore func(raw json.RawMessage) (int, error)
+}
+
+var snapshotSections []snapshotSection
+
+func registerSnapshotSection(name string, export func() (interface{}, error), restore func(json.RawMessage) (int, error)) {
+	snapshotSections = append(snapshotSections, snapshotSection{name: name, export: export, restore: restore})
+}
+
+func init() {
+	registerSnapshotSection("response_cache", responseCache.exportJSONEntries, responseCache.restoreJSONEntries)
+	registerSnapshotSection("rest_cache", restCache.exportRawEntries, restCache.restoreRawEntries)
+	registerSnapshotSection("headers", headers.export, headers.restore)
+	registerSnapshotSection("contract_creations", contractCreations.export, contractCreations.restore)
+}
+
+type proxySnapshot struct {
+	Version   int                        `json:"version"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	Sections  map[string]json.RawMessage `json:"sections"`
+}
+
+func buildSnapshot(only map[string]bool) (*proxySnapshot, error) {
+	s := &proxySnapshot{Version: snapshotVersion, CreatedAt: time.Now().UTC(), Sections: map[string]json.RawMessage{}}
+	for _, sec := range snapshotSections {
+		if len(only) > 0 && !only[sec.name] {
+			continue
+		}
+		v, err := sec.export()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sec.name, err)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sec.name, err)
+		}
+		s.Sections[sec.name] = data
+	}
+	return s, nil
+}
+
+// restoreSnapshot 返回每一类导入的条目数；未知的类别忽略，版本更新后旧快照仍可导入
+func restoreSnapshot(s *proxySnapshot) (map[string]int, error) {
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	counts := map[string]int{}
+	for _, sec := range snapshotSections {
+		raw, ok := s.Sections[sec.name]
+		if !ok {
+			continue
+		}
+		n, err := sec.restore(raw)
+		if err != nil {
+			return counts, fmt.Errorf("%s: %w", sec.name, err)
+		}
+		counts[sec.name] = n
+	}
+	return counts, nil
+}
+
+// loadSnapshotFile 在 main 里启动存储之后调用
+func loadSnapshotFile() {
+	if snapshotFile == "" {
+		return
+	}
+	f, err := os.Open(snapshotFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error opening snapshot %s: %v", snapshotFile, err)
+		}
+		return
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(snapshotFile, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			log.Printf("Error reading snapshot %s: %v", snapshotFile, err)
+			return
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var s proxySnapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		log.Printf("Invalid snapshot %s: %v", snapshotFile, err)
+		return
+	}
+	counts, err := restoreSnapshot(&s)
+	if err != nil {
+		log.Printf("Error importing snapshot %s: %v", snapshotFile, err)
+	}
+	log.Printf("Imported snapshot %s created %s: %v", snapshotFile, s.CreatedAt.Format(time.RFC3339), counts)
+}
+
+func saveSnapshotFile() {
+	if snapshotFile == "" {
+		return
+	}
+	s, err := buildSnapshot(nil)
+	if err == nil {
+		err = writeSnapshot(snapshotFile, s)
+	}
+	if err != nil {
+		log.Printf("Error writing snapshot %s: %v", snapshotFile, err)
+		return
+	}
+	log.Printf("Wrote snapshot %s", snapshotFile)
+}
+
+func writeSnapshot(name string, s *proxySnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if strings.HasSuffix(name, ".gz") {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	err = json.NewEncoder(w).Encode(s)
+	if zw != nil && err == nil {
+		err = zw.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// handleAdminSnapshot GET 导出（?sections=headers,response_cache 只导出部分），POST 导入请求体里的快照
+func handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s, err := buildSnapshot(toSet(splitList(r.URL.Query().Get("sections"))))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="proxy-snapshot-%s.json"`, s.CreatedAt.Format("20060102-150405")))
+		writeJSON(w, http.StatusOK, s)
+	case http.MethodPost:
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			defer zr.Close()
+			body = zr
+		}
+		var s proxySnapshot
+		if err := json.NewDecoder(body).Decode(&s); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid snapshot: " + err.Error()})
+			return
+		}
+		counts, err := restoreSnapshot(&s)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error(), "imported": counts})
+			return
+		}
+		log.Printf("Imported snapshot created %s via admin API: %v", s.CreatedAt.Format(time.RFC3339), counts)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"imported": counts})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET or POST required"})
+	}
+}
+
+type snapshotCacheEntry struct {
+	Key        string          `json:"key"`
+	Method     string          `json:"method"`
+	ParamsHash string          `json:"paramsHash"`
+	Result     json.RawMessage `json:"result"`
+	Expires    time.Time       `json:"expires"`
+	Block      int64           `json:"block"`
+	Tx         string          `json:"tx,omitempty"`
+}
+
+// exportEntries 只导出未过期的条目，encode 返回 false 的条目跳过
+func (c *lruCache) exportEntries(encode func(result interface{}) (json.RawMessage, bool)) []snapshotCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	out := make([]snapshotCacheEntry, 0, c.ll.Len())
+	// 从最久未用的开始，导入时按顺序插入，最近使用的仍在前面
+	for el := c.ll.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*cacheEntry)
+		if !now.Before(e.expires) {
+			continue
+		}
+		raw, ok := encode(e.result)
+		if !ok {
+			continue
+		}
+		out = append(out, snapshotCacheEntry{Key: e.key, Method: e.method, ParamsHash: e.paramsHash, Result: raw, Expires: e.expires, Block: e.block, Tx: e.tx})
+	}
+	return out
+}
+
+func (c *lruCache) restoreEntries(raw json.RawMessage, decode func(json.RawMessage) (interface{}, bool)) (int, error) {
+	var entries []snapshotCacheEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return 0, err
+	}
+	if !c.enabled() {
+		return 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now, n := time.Now(), 0
+	for _, s := range entries {
+		if _, ok := c.items[s.Key]; ok || !now.Before(s.Expires) {
+			continue
+		}
+		result, ok := decode(s.Result)
+		if !ok {
+			continue
+		}
+		e := &cacheEntry{key: s.Key, method: s.Method, paramsHash: s.ParamsHash, result: result, expires: s.Expires, block: s.Block, tx: s.Tx}
+		c.items[s.Key] = c.ll.PushFront(e)
+		n++
+		for c.ll.Len() > c.max {
+			c.removeElement(c.ll.Back())
+			c.evictions++
+		}
+	}
+	return n, nil
+}
+
+// exportJSONEntries 响应缓存里代理内部用的 Go 结构（例如 proxy_blockActivity）不导出，只导出从 JSON 解码来的结果
+func (c *lruCache) exportJSONEntries() (interface{}, error) {
+	return c.exportEntries(func(result interface{}) (json.RawMessage, bool) {
+		switch result.(type) {
+		case nil, bool, float64, string, json.Number, []interface{}, map[string]interface{}, json.RawMessage:
+		default:
+			return nil, false
+		}
+		raw, err := codec.Marshal(result)
+		return raw, err == nil
+	}), nil
+}
+
+func (c *lruCache) restoreJSONEntries(raw json.RawMessage) (int, error) {
+	return c.restoreEntries(raw, func(r json.RawMessage) (interface{}, bool) {
+		var v interface{}
+		return v, codec.Unmarshal(r, &v) == nil
+	})
+}
+
+// exportRawEntries REST 缓存保存的是原始响应体
+func (c *lruCache) exportRawEntries() (interface{}, error) {
+	return c.exportEntries(func(result interface{}) (json.RawMessage, bool) {
+		b, ok := result.([]byte)
+		return json.RawMessage(b), ok && json.Valid(b)
+	}), nil
+}
+
+func (c *lruCache) restoreRawEntries(raw json.RawMessage) (int, error) {
+	return c.restoreEntries(raw, func(r json.RawMessage) (interface{}, bool) {
+		return []byte(r), true
+	})
+}
+
+func (c *headerCache) export() (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]blockHeader, 0, len(c.order))
+	for _, n := range c.order {
+		out = append(out, c.byNum[n])
+	}
+	return out, nil
+}
+
+func (c *headerCache) restore(raw json.RawMessage) (int, error) {
+	var list []blockHeader
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, h := range list {
+		if _, ok := c.get(h.Number); ok {
+			continue
+		}
+		c.put(h)
+		n++
+	}
+	return n, nil
+}
+
+func (ci *creationIndex) export() (interface{}, error) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.load()
+	out := make([]contractCreation, 0, len(ci.Contracts))
+	for _, c := range ci.Contracts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AddressHex < out[j].AddressHex })
+	return out, nil
+}
+
+func (ci *creationIndex) restore(raw json.RawMessage) (int, error) {
+	var list []contractCreation
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return 0, err
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.load()
+	if ci.Contracts == nil {
+		ci.Contracts = make(map[string]*contractCreation)
+	}
+	n := 0
+	for i := range list {
+		c := list[i]
+		if _, ok := ci.Contracts[c.AddressHex]; ok || c.AddressHex == "" {
+			continue
+		}
+		ci.Contracts[c.AddressHex] = &c
+		n++
+	}
+	if n > 0 {
+		ci.save()
+	}
+	return n, nil
+}
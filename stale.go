@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// STALE_WHILE_ERROR：circuit 只在所有上游都熔断时用过期的缓存应答；error 只要上游调用失败
+	// （不可用、超时、限流、内部错误）就用；off 关闭。只对可缓存的请求生效
+	staleWhileError = envString("STALE_WHILE_ERROR", "circuit")
+	// 超过这个时长的缓存不再兜底，0 不限制
+	staleMaxAge = envDuration("STALE_MAX_AGE", 0)
+
+	metricStaleResponses = newCounterVec("proxy_stale_responses_total",
+		"Expired cached responses served because upstreams failed, by method.", "method")
+)
+
+// staleInfo 放在 _proxy.stale 里，响应头 X-Proxy-Stale 为其中最大的 ageSeconds
+type staleInfo struct {
+	AgeSeconds int64  `json:"ageSeconds"`
+	Reason     string `json:"reason"`
+}
+
+// upstreamFailed 只认上游不可用一类的错误，参数错误、执行回滚等节点的正常应答不能用旧数据替换
+func upstreamFailed(resp JSONRPCResponse) bool {
+	if isFallback(resp) {
+		return true
+	}
+	e, ok := resp.Error.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch errorCode(e) {
+	case errCodeInternal, errCodeUnavailable, errCodeLimitExceeded:
+		return true
+	}
+	return false
+}
+
+func errorCode(e map[string]interface{}) int {
+	switch c := e["code"].(type) {
+	case int:
+		return c
+	case float64:
+		return int(c)
+	}
+	return 0
+}
+
+func shouldServeStale(resp JSONRPCResponse) bool {
+	switch staleWhileError {
+	case "error":
+		return upstreamFailed(resp)
+	case "circuit":
+		return (resp.Error != nil || isFallback(resp)) && jsonrpcUpstreams.circuitOpenAll()
+	}
+	return false
+}
+
+func staleResponse(ctx context.Context, req JSONRPCRequest, failed JSONRPCResponse) (JSONRPCResponse, bool) {
+	result, stored, ok := responseCache.getStale(req.Method, req.Params)
+	age := time.Since(stored)
+	if !ok || (staleMaxAge > 0 && age > staleMaxAge) {
+		return JSONRPCResponse{}, false
+	}
+	reason := "upstream unavailable"
+	if e, ok := failed.Error.(map[string]interface{}); ok {
+		if msg, ok := e["message"].(string); ok {
+			reason = msg
+		}
+	} else if isFallback(failed) {
+		reason = failed.Proxy.Fallback.Reason
+	}
+	debugFrom(ctx).cache(req.Method, "stale")
+	metricStaleResponses.inc(methodLabel(req.Method))
+	log.Printf("Serving stale cached %s (%s old): %s", req.Method, age.Round(time.Second), reason)
+	resp := JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+	ensureProxyMeta(&resp).Stale = &staleInfo{AgeSeconds: int64(math.Round(age.Seconds())), Reason: reason}
+	return resp, true
+}
+
+func isStale(resp JSONRPCResponse) bool {
+	return resp.Proxy != nil && resp.Proxy.Stale != nil
+}
+
+func setStaleHeader(w http.ResponseWriter, responses ...JSONRPCResponse) {
+	age := int64(-1)
+	for _, resp := range responses {
+		if isStale(resp) && resp.Proxy.Stale.AgeSeconds > age {
+			age = resp.Proxy.Stale.AgeSeconds
+		}
+	}
+	if age >= 0 {
+		w.Header().Set("X-Proxy-Stale", strconv.FormatInt(age, 10))
+	}
+}
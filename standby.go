@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// STANDBY_PRIMARY 配置后以备用模式启动：不接客户端流量，跟随主实例的事件流预热缓存，
+	// 直到 POST /admin/promote 或主实例失联超过 STANDBY_PROMOTE_AFTER
+	standbyPrimary      = strings.TrimRight(configValue("STANDBY_PRIMARY"), "/")
+	standbyToken        = configValue("STANDBY_PRIMARY_TOKEN")
+	standbyPromoteAfter = envDuration("STANDBY_PROMOTE_AFTER", 0)
+	standbyConcurrency  = envInt("STANDBY_WARM_CONCURRENCY", 4)
+	standbyHeartbeat    = envDuration("STANDBY_HEARTBEAT", 10*time.Second)
+
+	standby = &standbyState{active: standbyPrimary == ""}
+
+	// 主实例上正在跟随的备用实例数，没有时不发布 cache_fill 事件
+	standbyFollowers int64
+)
+
+type standbyState struct {
+	mu          sync.Mutex
+	active      bool
+	connected   bool
+	lastContact time.Time
+	promotedAt  time.Time
+	cancel      context.CancelFunc
+
+	warmed, skipped, failed uint64
+}
+
+type standbyStatus struct {
+	Mode        string    `json:"mode"`
+	Primary     string    `json:"primary,omitempty"`
+	Connected   bool      `json:"connected"`
+	LastContact time.Time `json:"lastContact,omitempty"`
+	PromotedAt  time.Time `json:"promotedAt,omitempty"`
+	Warmed      uint64    `json:"warmed"`
+	Skipped     uint64    `json:"skipped"`
+	Failed      uint64    `json:"failed"`
+	Followers   int64     `json:"followers"`
+}
+
+func (s *standbyState) isActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+func (s *standbyState) status() standbyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mode := "standby"
+	if s.active {
+		mode = "active"
+	}
+	return standbyStatus{
+		Mode: mode, Primary: standbyPrimary, Connected: s.connected, LastContact: s.lastContact,
+		PromotedAt: s.promotedAt, Warmed: atomic.LoadUint64(&s.warmed), Skipped: atomic.LoadUint64(&s.skipped),
+		Failed: atomic.LoadUint64(&s.failed), Followers: atomic.LoadInt64(&standbyFollowers),
+	}
+}
+
+func (s *standbyState) promote(reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		return false
+	}
+	s.active = true
+	s.connected = false
+	s.promotedAt = time.Now()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	log.Printf("Promoted from standby to active: %s", reason)
+	return true
+}
+
+func (s *standbyState) contact(connected bool) {
+	s.mu.Lock()
+	s.connected = connected
+	if connected {
+		s.lastContact = time.Now()
+	}
+	s.mu.Unlock()
+}
+
+// standbyGate 备用模式下拒绝客户端流量，负载均衡据此把请求发往主实例
+func standbyGate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !standby.isActive() {
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"proxy is in standby mode"}}` + "\n"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// publishCacheFill 在缓存写入新结果后调用，把请求转给跟随的备用实例重放
+func publishCacheFill(req JSONRPCRequest) {
+	if atomic.LoadInt64(&standbyFollowers) == 0 {
+		return
+	}
+	events.publish(EventCacheFill, cacheFillEvent{Method: req.Method, Params: req.Params})
+}
+
+type wireEvent struct {
+	Type eventType       `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// handleAdminEvents GET /admin/events?types=cache_fill,new_block 以 NDJSON 输出事件总线，
+// 空闲时定期发送 heartbeat，备用实例据此判断主实例是否存活
+func handleAdminEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+	var types []eventType
+	for _, t := range splitList(r.URL.Query().Get("types")) {
+		types = append(types, eventType(t))
+	}
+	ch := make(chan event, 1024)
+	sub := events.subscribe("follower:"+clientIP(r), 1024, func(e event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}, types...)
+	defer events.unsubscribe(sub)
+	atomic.AddInt64(&standbyFollowers, 1)
+	defer atomic.AddInt64(&standbyFollowers, -1)
+	log.Printf("Event follower %s connected", clientIP(r))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	heartbeat := time.NewTicker(standbyHeartbeat)
+	defer heartbeat.Stop()
+	enc.Encode(wireEvent{Type: "heartbeat", Time: time.Now()})
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("Event follower %s disconnected", clientIP(r))
+			return
+		case <-heartbeat.C:
+			enc.Encode(wireEvent{Type: "heartbeat", Time: time.Now()})
+		case e := <-ch:
+			data, _ := json.Marshal(e.Data)
+			enc.Encode(wireEvent{Type: e.Type, Time: e.Time, Data: data})
+		}
+		flusher.Flush()
+	}
+}
+
+func startStandby() {
+	if standbyPrimary == "" {
+		return
+	}
+	log.Printf("Starting in standby mode, following %s", standbyPrimary)
+	ctx, cancel := context.WithCancel(context.Background())
+	standby.mu.Lock()
+	standby.cancel = cancel
+	standby.lastContact = time.Now()
+	standby.mu.Unlock()
+
+	warm := make(chan JSONRPCRequest, 1024)
+	for i := 0; i < standbyConcurrency; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case req := <-warm:
+					standby.warm(ctx, req)
+				}
+			}
+		}()
+	}
+	go func() {
+		backoff := time.Second
+		for ctx.Err() == nil {
+			err := standby.follow(ctx, warm)
+			standby.contact(false)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Lost connection to primary %s: %v", standbyPrimary, err)
+			standby.mu.Lock()
+			silent := time.Since(standby.lastContact)
+			standby.mu.Unlock()
+			if standbyPromoteAfter > 0 && silent > standbyPromoteAfter {
+				standby.promote(fmt.Sprintf("primary unreachable for %s", silent.Round(time.Second)))
+				return
+			}
+			sleepContext(ctx, backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// follow 读取主实例的事件流直到断开；超过 3 个心跳周期没有任何数据也视为断开
+func (s *standbyState) follow(ctx context.Context, warm chan<- JSONRPCRequest) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 新区块还没固化，不写入区块头缓存，只跟随缓存填充
+	url := standbyPrimary + "/admin/events?types=" + string(EventCacheFill)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Admin-Token", standbyToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	idle := time.AfterFunc(3*standbyHeartbeat, cancel)
+	defer idle.Stop()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		idle.Reset(3 * standbyHeartbeat)
+		s.contact(true)
+		var e wireEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		switch e.Type {
+		case EventCacheFill:
+			var fill cacheFillEvent
+			if json.Unmarshal(e.Data, &fill) != nil {
+				continue
+			}
+			select {
+			case warm <- JSONRPCRequest{Jsonrpc: "2.0", Method: fill.Method, Params: fill.Params, ID: 1}:
+			default:
+				atomic.AddUint64(&s.skipped, 1)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("event stream closed")
+}
+
+// warm 在本地重放主实例填充过的请求，已经缓存的跳过
+func (s *standbyState) warm(ctx context.Context, req JSONRPCRequest) {
+	if _, _, ok := responseCache.getStale(req.Method, req.Params); ok {
+		atomic.AddUint64(&s.skipped, 1)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if resp := withCache(ctx, req, dispatchRequest); resp.Error != nil {
+		atomic.AddUint64(&s.failed, 1)
+		return
+	}
+	atomic.AddUint64(&s.warmed, 1)
+}
+
+// handleAdminStandby GET 查看备用状态，POST /admin/promote 手动切换为主
+func handleAdminStandby(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, standby.status())
+}
+
+func handleAdminPromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	standby.promote("admin request")
+	writeJSON(w, http.StatusOK, standby.status())
+}
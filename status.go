@@ -0,0 +1,257 @@
+package proxy
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 最近错误率按 5 秒一个桶统计最近 5 分钟
+	recentBucketWidth = 5 * time.Second
+	recentBuckets     = 60
+)
+
+// recentRequests 滚动窗口内的请求数和错误数，只用于状态页；累计值见 /metrics
+var recentRequests = &requestWindow{}
+
+type requestCounts struct {
+	Total  int
+	Errors int
+}
+
+type requestBucket struct {
+	start   int64
+	methods map[string]*requestCounts
+}
+
+type requestWindow struct {
+	mu      sync.Mutex
+	buckets [recentBuckets]requestBucket
+}
+
+func (rw *requestWindow) observe(method string, failed bool) {
+	slot := time.Now().UnixNano() / int64(recentBucketWidth)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	b := &rw.buckets[slot%recentBuckets]
+	if b.start != slot || b.methods == nil {
+		b.start, b.methods = slot, make(map[string]*requestCounts)
+	}
+	c := b.methods[method]
+	if c == nil {
+		c = &requestCounts{}
+		b.methods[method] = c
+	}
+	c.Total++
+	if failed {
+		c.Errors++
+	}
+}
+
+// totals 汇总窗口内各方法的计数
+func (rw *requestWindow) totals() map[string]requestCounts {
+	oldest := time.Now().UnixNano()/int64(recentBucketWidth) - recentBuckets + 1
+	out := make(map[string]requestCounts)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	for _, b := range rw.buckets {
+		if b.start < oldest {
+			continue
+		}
+		for m, c := range b.methods {
+			t := out[m]
+			t.Total += c.Total
+			t.Errors += c.Errors
+			out[m] = t
+		}
+	}
+	return out
+}
+
+type statusMethodRow struct {
+	Method string
+	requestCounts
+}
+
+func (r statusMethodRow) ErrorRate() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Total)
+}
+
+type statusUpstreamRow struct {
+	upstreamStatus
+	Requests  float64
+	Errors    float64
+	LatencyMs string
+}
+
+type statusCacheRow struct {
+	Name    string
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+func (r statusCacheRow) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+type statusPage struct {
+	Generated time.Time
+	Uptime    time.Duration
+	Mode      string
+	Build     [][2]string
+	Upstreams []statusUpstreamRow
+	Overall   statusMethodRow
+	Methods   []statusMethodRow
+	Caches    []statusCacheRow
+	Inflight  int
+}
+
+func lruCacheRow(name string, s cacheStats) statusCacheRow {
+	row := statusCacheRow{Name: name, Entries: s.Entries}
+	for _, m := range s.Methods {
+		row.Hits += m.Hits
+		row.Misses += m.Misses
+	}
+	return row
+}
+
+func buildStatusPage() statusPage {
+	now := time.Now()
+	p := statusPage{Generated: now.UTC(), Uptime: now.Sub(processStart).Truncate(time.Second), Mode: standby.status().Mode, Inflight: len(inflight.snapshot())}
+
+	p.Build = append(p.Build, [2]string{"go", runtime.Version()}, [2]string{"platform", runtime.GOOS + "/" + runtime.GOARCH}, [2]string{"json codec", codecName})
+	if host, err := os.Hostname(); err == nil {
+		p.Build = append(p.Build, [2]string{"hostname", host})
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		p.Build = append(p.Build, [2]string{"module", bi.Main.Path + "@" + bi.Main.Version})
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" || s.Key == "vcs.time" || s.Key == "vcs.modified" {
+				p.Build = append(p.Build, [2]string{s.Key, s.Value})
+			}
+		}
+	}
+
+	requests, failures := metricUpstreamRequests.snapshot(), metricUpstreamErrors.snapshot()
+	for _, pool := range allUpstreamPools() {
+		for _, s := range pool.status() {
+			label := upstreamLabel(s.URL)
+			row := statusUpstreamRow{upstreamStatus: s, Requests: requests[label], Errors: failures[label]}
+			row.URL, row.LastError = redactURL(s.URL), redactLine(s.LastError)
+			var parts []string
+			for _, class := range sortedLatencyClasses(s.LatencyMs) {
+				parts = append(parts, class+" "+formatFloat(float64(int(s.LatencyMs[class]*10))/10))
+			}
+			row.LatencyMs = strings.Join(parts, ", ")
+			p.Upstreams = append(p.Upstreams, row)
+		}
+	}
+	p.Overall.Method = "all"
+	for m, c := range recentRequests.totals() {
+		p.Methods = append(p.Methods, statusMethodRow{Method: m, requestCounts: c})
+		p.Overall.Total += c.Total
+		p.Overall.Errors += c.Errors
+	}
+	sort.Slice(p.Methods, func(i, j int) bool {
+		if p.Methods[i].Errors != p.Methods[j].Errors {
+			return p.Methods[i].Errors > p.Methods[j].Errors
+		}
+		return p.Methods[i].Total > p.Methods[j].Total
+	})
+	if len(p.Methods) > 20 {
+		p.Methods = p.Methods[:20]
+	}
+
+	p.Caches = append(p.Caches, lruCacheRow("jsonrpc", responseCache.Stats()), lruCacheRow("rest", restCache.Stats()))
+	t := traceCache.Stats()
+	p.Caches = append(p.Caches, statusCacheRow{Name: "trace", Entries: t.Entries, Hits: t.Hits, Misses: t.Misses})
+	if s := diskCache.Stats(); s != nil {
+		row := statusCacheRow{Name: "disk", Entries: int(s.Entries)}
+		for key, v := range metricDiskCache.snapshot() {
+			if strings.HasSuffix(key, "\xffhit") {
+				row.Hits += uint64(v)
+			} else {
+				row.Misses += uint64(v)
+			}
+		}
+		p.Caches = append(p.Caches, row)
+	}
+	return p
+}
+
+func sortedLatencyClasses(m map[string]float64) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// handleStatus GET /status 给没有 Grafana 权限时快速查看用，页面不引用任何外部资源
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := statusTemplate.Execute(w, buildStatusPage()); err != nil {
+		log.Printf("Error rendering status page: %v", err)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"pct": func(v float64) string { return formatFloat(float64(int(v*1000))/10) + "%" },
+	"ts": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format("15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="10"><title>tron-proxy status</title>
+<style>
+body{font:14px/1.4 system-ui,sans-serif;margin:1.5em;color:#222}
+table{border-collapse:collapse;margin-bottom:1.5em}
+th,td{border:1px solid #ccc;padding:3px 8px;text-align:left}
+th{background:#f3f3f3}
+.ok{color:#080}.bad{color:#c00;font-weight:bold}.warn{color:#b60}
+small{color:#777}
+</style></head><body>
+<h1>tron-proxy status</h1>
+<p>mode <b>{{.Mode}}</b> · uptime {{.Uptime}} · {{.Inflight}} request(s) in flight · <small>generated {{.Generated.Format "2006-01-02 15:04:05"}} UTC, refreshes every 10s</small></p>
+
+<h2>Upstreams</h2>
+<table><tr><th>kind</th><th>url</th><th>health</th><th>circuit</th><th>head</th><th>version</th><th>requests</th><th>errors</th><th>latency ms</th><th>pending</th><th>last check</th><th>last error</th></tr>
+{{range .Upstreams}}<tr><td>{{.Kind}}</td><td>{{.URL}}</td>
+<td>{{if .Draining}}<span class="warn">draining</span>{{else if .Healthy}}<span class="ok">healthy</span>{{else}}<span class="bad">down</span>{{end}}{{if .Flapping}} <span class="warn">flapping</span>{{end}}</td>
+<td>{{.Circuit}}</td><td>{{.Head}}{{if .Lagging}} (lagging){{end}}</td><td>{{.Version}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td><td>{{.LatencyMs}}</td><td>{{.Pending}}</td><td>{{ts .LastCheck}}</td><td>{{.LastError}}</td></tr>
+{{end}}</table>
+
+<h2>Requests, last 5 minutes</h2>
+<table><tr><th>method</th><th>requests</th><th>errors</th><th>error rate</th></tr>
+<tr><th>{{.Overall.Method}}</th><th>{{.Overall.Total}}</th><th>{{.Overall.Errors}}</th><th>{{pct .Overall.ErrorRate}}</th></tr>
+{{range .Methods}}<tr><td>{{.Method}}</td><td>{{.Total}}</td><td>{{.Errors}}</td><td{{if gt .ErrorRate 0.05}} class="bad"{{end}}>{{pct .ErrorRate}}</td></tr>
+{{end}}</table>
+
+<h2>Caches</h2>
+<table><tr><th>cache</th><th>entries</th><th>hits</th><th>misses</th><th>hit ratio</th></tr>
+{{range .Caches}}<tr><td>{{.Name}}</td><td>{{.Entries}}</td><td>{{.Hits}}</td><td>{{.Misses}}</td><td>{{pct .HitRatio}}</td></tr>
+{{end}}</table>
+
+<h2>Build</h2>
+<table>{{range .Build}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>{{end}}</table>
+</body></html>
+`))
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package proxy
+
+// sqlite 驱动依赖 cgo，默认的静态构建不包含，需要 go build -tags sqlite
+import _ "github.com/mattn/go-sqlite3"
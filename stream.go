@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// STREAM_RESPONSES=off 关闭流式路径；只用于单请求，批量请求仍然整体缓冲
+	streamResponses = envString("STREAM_RESPONSES", "on") == "on"
+	// 每写出多少个数组元素 flush 一次
+	streamFlushEvery = envInt("STREAM_FLUSH_EVERY", 64)
+
+	metricStreamed = newCounterVec("proxy_streamed_responses_total",
+		"Single responses written through the streaming path, by method and result.", "method", "result")
+)
+
+// streamable 判断单请求能否走流式路径：需要改写结果的功能（地址格式、区块上下文、客户端 profile、调试、
+// trace 一致性检查）开启时，或者结果已经在缓存里时，仍走普通路径
+func streamable(ctx context.Context, req JSONRPCRequest) bool {
+	if !streamResponses || req.Jsonrpc != "2.0" || req.Notification {
+		return false
+	}
+	if checkMethodPolicy(ctx, req.Method) != "" || addressFormatFor(ctx, req.Method) != "" || wantBlockContext(ctx) || clientProfileFrom(ctx) != nil || wantsResultFields(ctx, req) || hasQuota(ctx) || recorder != nil || replayer != nil {
+		return false
+	}
+	if v, _ := ctx.Value(debugCtxKey{}).(bool); v {
+		return false
+	}
+	if responseCache.enabled() && isCacheableRequest(req) && responseCache.has(req.Method, req.Params) {
+		return false
+	}
+	switch req.Method {
+	case "debug_traceBlockByHash":
+		// 只有旧的区块号形式直接来自 REST；gRPC 响应本来就在内存里
+		_, isHash := blockHashArg(req)
+		_, viaGRPC := grpcRoute("/wallet/gettransactioninfobyblocknum")
+		return !isHash && !viaGRPC && len(req.Params) > 0 && !legacyTracerRequested(req)
+	case "eth_debugTransactionTrace":
+		return !traceConsistencyCheck && len(req.Params) > 0
+	case "debug_traceTransaction":
+		if traceConsistencyCheck || len(req.Params) == 0 {
+			return false
+		}
+		opts, err := parseTraceOptions(req.Params, 1)
+		return err == nil && opts.fromStore()
+	}
+	return false
+}
+
+// streamSingle 把上游/trace 文件的字节直接写进响应，只拼接 id，不整体解码再编码。
+// 返回 false 表示不适用，调用方走 handleSingleRequest。方法超时、慢请求日志和缓存填充与普通路径一致
+func streamSingle(ctx context.Context, w http.ResponseWriter, r *http.Request, req JSONRPCRequest) bool {
+	if !streamable(ctx, req) || responseFormat(r) != "" {
+		return false
+	}
+	start := time.Now()
+	ctx = withArchiveRoute(ctx, req)
+	ctx = withLagTolerance(ctx, req)
+	ctx, dbg := startDebug(ctx, "single")
+	dctx, cancel := withMethodTimeout(ctx, req.Method)
+	defer cancel()
+	var status JSONRPCResponse
+	var written bool
+	switch req.Method {
+	case "debug_traceBlockByHash":
+		status, written = streamTransactionInfos(dctx, w, r, req)
+	default:
+		status, written = streamTrace(dctx, w, r, req)
+	}
+	// 已经开始写结果时只能截断，超时错误只用于统计
+	if timedOut(dctx, ctx) {
+		status = timeoutError(req.ID, req.Method)
+	}
+	if !written {
+		sendJSONRPCResponse(w, status)
+	}
+	dbg.stage("dispatch", start)
+	result := "ok"
+	if status.Error != nil {
+		result = "error"
+	}
+	metricStreamed.inc(methodLabel(req.Method), result)
+	slowLog.observe(ctx, dbg, req.Method, []JSONRPCRequest{req}, []JSONRPCResponse{status})
+	observeRequest(req.Method, "single", start, status)
+	recordUsage(ctx, req.Method, status)
+	return true
+}
+
+// cacheStreamed 判断流式结果是否需要在写出的同时留一份放进缓存
+func cacheStreamed(req JSONRPCRequest) bool {
+	return isCacheableRequest(req) && (responseCache.enabled() || diskCache.usable(req.Method) || sharedCache.usable())
+}
+
+// streamOut 流式响应的输出端，压缩由外层 withCompression 负责；头部在第一次写之前设置
+type streamOut struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func newStreamOut(w http.ResponseWriter) *streamOut {
+	w.Header().Set("Content-Type", "application/json")
+	out := &streamOut{w: w}
+	out.flusher, _ = w.(http.Flusher)
+	return out
+}
+
+func (s *streamOut) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *streamOut) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func envelopePrefix(id interface{}) []byte {
+	idBytes, _ := codec.Marshal(id)
+	return []byte(`{"jsonrpc":"2.0","id":` + string(idBytes) + `,"result":`)
+}
+
+// streamTrace 和 streamTransactionInfos 返回的 bool 表示响应是否已经写出，没写出的错误由 streamSingle 发送
+func streamTrace(ctx context.Context, w http.ResponseWriter, r *http.Request, req JSONRPCRequest) (JSONRPCResponse, bool) {
+	txID, err := parseTxIDParam(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error()), false
+	}
+	data, err := loadTrace(ctx, txID)
+	if err != nil {
+		// 错误处理与缓冲路径一致，交给原来的 handler
+		return dispatchRequest(ctx, req), false
+	}
+	out := newStreamOut(w)
+	out.Write(envelopePrefix(req.ID))
+	out.Write(data)
+	out.Write([]byte("}\n"))
+	if cacheStreamed(req) {
+		storeCached(ctx, req, json.RawMessage(data))
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID}, true
+}
+
+// streamTransactionInfos 逐个解码 gettransactioninfobyblocknum 返回的数组元素并立即写出，
+// 内存占用与单笔交易相当而不是整个区块
+func streamTransactionInfos(ctx context.Context, w http.ResponseWriter, r *http.Request, req JSONRPCRequest) (JSONRPCResponse, bool) {
+	var blockID int64
+	if err := codec.Unmarshal(req.Params[0], &blockID); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: must be integer block number"), false
+	}
+	body, _ := codec.Marshal(map[string]interface{}{"num": blockID})
+	log.Printf("Streaming REST call for blockNum=%d", blockID)
+	resp, u, err := restPool(ctx, blockID).open(ctx, "/wallet/gettransactioninfobyblocknum", body)
+	if err != nil {
+		log.Printf("REST request error for block %d: %v", blockID, err)
+		errResp := errorResponse(req.ID, err)
+		setErrorData(&errResp, "block", blockID)
+		return errResp, false
+	}
+	defer resp.Body.Close()
+
+	// 先看第一个非空白字符，不是数组时读出开头部分放进错误信息
+	br := bufio.NewReader(resp.Body)
+	if first, err := peekNonSpace(br); err != nil || first != '[' {
+		observeUpstreamInvalid(u.label)
+		head, _ := io.ReadAll(io.LimitReader(br, 4096))
+		log.Printf("Invalid REST response for block %d from %s (HTTP %d): %s", blockID, u.label, resp.StatusCode, up.Snippet(head))
+		return invalidRestResponse(req.ID, blockID, u, resp.StatusCode, head), false
+	}
+	dec := json.NewDecoder(br)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		observeUpstreamInvalid(u.label)
+		return invalidRestResponse(req.ID, blockID, u, resp.StatusCode, nil), false
+	}
+	out := newStreamOut(w)
+	out.Write(envelopePrefix(req.ID))
+	out.Write([]byte("["))
+	n := 0
+	var buf bytes.Buffer
+	var infos []TronTransactionInfo
+	keep := cacheStreamed(req)
+	for dec.More() {
+		var info TronTransactionInfo
+		if err := dec.Decode(&info); err != nil {
+			// 已经写出了部分结果，只能截断连接，客户端会看到不完整的 JSON
+			observeUpstreamInvalid(u.label)
+			log.Printf("Stream of block %d from %s aborted after %d item(s): %v", blockID, u.url, n, err)
+			return jsonError(req.ID, -32603, "stream aborted"), true
+		}
+		buf.Reset()
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		b, _ := codec.Marshal(info)
+		if keep {
+			infos = append(infos, info)
+		}
+		buf.Write(b)
+		if _, err := out.Write(buf.Bytes()); err != nil {
+			return jsonError(req.ID, -32603, "client went away"), true
+		}
+		n++
+		if n%streamFlushEvery == 0 {
+			out.flush()
+		}
+	}
+	out.Write([]byte("]}\n"))
+	log.Printf("Streamed %d transaction info(s) for block %d from %s", n, blockID, u.url)
+	if keep {
+		if infos == nil {
+			infos = []TronTransactionInfo{}
+		}
+		storeCached(ctx, req, infos)
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID}, true
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// openError 读出响应开头用于错误信息，然后关闭响应
+func openError(u *upstream, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	return upstreamFailure(u, resp.StatusCode, body)
+}
+
+// open 与 send 一样选点并遵守熔断，但只在拿到响应头之前换节点，响应体交给调用方流式读取
+func (p *upstreamPool) open(ctx context.Context, path string, body []byte) (*http.Response, *upstream, error) {
+	if len(p.list()) == 0 {
+		return nil, nil, fmt.Errorf("no %s upstream configured", p.kind)
+	}
+	tried := make(map[*upstream]bool, len(p.list()))
+	var lastErr error
+	for {
+		u := p.pick(ctx, "", tried)
+		if u == nil {
+			break
+		}
+		tried[u] = true
+		allowed, probe := u.breakerAllow()
+		if !allowed {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url+path, bytes.NewReader(body))
+		if err != nil {
+			u.breakerDone(probe)
+			return nil, nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setUpstreamHeaders(ctx, req, u)
+		u.begin()
+		resp, err := upstreamClient.Do(req)
+		u.end()
+		u.breakerDone(probe)
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, nil, ctx.Err()
+		}
+		if err == nil && resp.StatusCode < 500 {
+			observeUpstream(u.label, false)
+			u.markSuccess()
+			if resp.StatusCode/100 != 2 {
+				return nil, u, openError(u, resp)
+			}
+			return resp, u, nil
+		}
+		if err == nil {
+			err = openError(u, resp)
+		}
+		observeUpstream(u.label, true)
+		u.markFailure(err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no available " + p.kind + " upstream")
+	}
+	return nil, nil, lastErr
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withStreamNode REST 节点在 delay 之后返回一个区块的交易信息，客户端断开时提前返回
+func withStreamNode(t *testing.T, delay time.Duration) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, `[{"id":"aa","blockNumber":16},{"id":"bb","blockNumber":16}]`)
+	}))
+	t.Cleanup(srv.Close)
+	oldRest, oldCache, oldStream, oldTimeouts := restUpstreams, responseCache, streamResponses, methodTimeouts
+	oldSlow, oldThreshold := slowLog, slowLogThreshold
+	restUpstreams = newUpstreamPool("rest", []string{srv.URL})
+	responseCache = newResponseCache(100, time.Hour)
+	streamResponses = true
+	methodTimeouts = parseMethodTimeouts("debug_*=100ms")
+	slowLog, slowLogThreshold = &slowRing{entries: make([]slowEntry, 10)}, time.Nanosecond
+	t.Cleanup(func() {
+		restUpstreams, responseCache, streamResponses, methodTimeouts = oldRest, oldCache, oldStream, oldTimeouts
+		slowLog, slowLogThreshold = oldSlow, oldThreshold
+	})
+}
+
+const streamedBlockRequest = `{"jsonrpc":"2.0","id":7,"method":"debug_traceBlockByHash","params":[16]}`
+
+func TestStreamSingleMethodTimeout(t *testing.T) {
+	withStreamNode(t, 500*time.Millisecond)
+	start := time.Now()
+	var resp struct {
+		ID    int
+		Error struct{ Code int }
+	}
+	if err := json.Unmarshal(postJSONRPC(t, streamedBlockRequest), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 7 || resp.Error.Code != errCodeTimeout {
+		t.Errorf("response = %+v, want code %d", resp, errCodeTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("took %s, method timeout is 100ms", elapsed)
+	}
+	if got := slowLog.list("debug_traceBlockByHash", 0, nil, 10); len(got) != 1 || got[0].Errors != 1 {
+		t.Errorf("slow log entries = %+v", got)
+	}
+}
+
+func TestStreamSingleFillsCache(t *testing.T) {
+	withStreamNode(t, 0)
+	body := postJSONRPC(t, streamedBlockRequest)
+	if !strings.Contains(string(body), `"id":"bb"`) {
+		t.Fatalf("streamed response = %s", body)
+	}
+	if got := slowLog.list("debug_traceBlockByHash", 0, nil, 10); len(got) != 1 {
+		t.Errorf("slow log entries = %d, want 1", len(got))
+	}
+	var req JSONRPCRequest
+	json.Unmarshal([]byte(streamedBlockRequest), &req)
+	cached, ok := responseCache.get(req.Method, req.Params)
+	if infos, _ := cached.([]TronTransactionInfo); !ok || len(infos) != 2 {
+		t.Errorf("cache = %#v, %v", cached, ok)
+	}
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	addressSummaryMaxBlocks   = envInt("ADDRESS_SUMMARY_MAX_BLOCKS", 1000)
+	addressSummaryConcurrency = envInt("ADDRESS_SUMMARY_CONCURRENCY", 8)
+)
+
+// addressActivity 是从区块交易中抽取的最小索引记录
+type addressActivity struct {
+	TxID      string `json:"txId"`
+	Type      string `json:"type"`
+	From      string `json:"from"`
+	To        string `json:"to,omitempty"`
+	TokenTo   string `json:"tokenTo,omitempty"`
+	Amount    int64  `json:"amount"`
+	Contract  string `json:"contract,omitempty"`
+	Succeeded bool   `json:"succeeded"`
+}
+
+type blockActivity struct {
+	Number    int64             `json:"number"`
+	Timestamp int64             `json:"timestamp"`
+	Txs       []addressActivity `json:"txs"`
+}
+
+// TRC20 transfer(address,uint256) / transferFrom(address,address,uint256)
+const (
+	selectorTransfer     = "a9059cbb"
+	selectorTransferFrom = "23b872dd"
+)
+
+func extractActivity(block *restBlock) *blockActivity {
+	ba := &blockActivity{
+		Number:    block.BlockHeader.RawData.Number,
+		Timestamp: block.BlockHeader.RawData.Timestamp,
+	}
+	for _, tx := range block.Transactions {
+		for _, c := range tx.RawData.Contract {
+			v := c.Parameter.Value
+			a := addressActivity{TxID: tx.TxID, Type: c.Type, From: v.OwnerAddress, Succeeded: tx.succeeded()}
+			switch c.Type {
+			case "TransferContract":
+				a.To, a.Amount = v.ToAddress, v.Amount
+			case "TransferAssetContract":
+				a.To, a.Amount, a.Contract = v.ToAddress, v.Amount, v.AssetName
+			case "TriggerSmartContract":
+				a.To, a.Amount, a.Contract = v.ContractAddress, v.CallValue, v.ContractAddress
+				a.TokenTo = trc20Recipient(v.Data)
+			}
+			ba.Txs = append(ba.Txs, a)
+		}
+	}
+	return ba
+}
+
+// trc20Recipient 从 calldata 中解析 TRC20 转账接收方
+func trc20Recipient(data string) string {
+	data = strings.TrimPrefix(data, "0x")
+	var word string
+	switch {
+	case strings.HasPrefix(data, selectorTransfer) && len(data) >= 8+64:
+		word = data[8 : 8+64]
+	case strings.HasPrefix(data, selectorTransferFrom) && len(data) >= 8+128:
+		word = data[8+64 : 8+128]
+	default:
+		return ""
+	}
+	b, err := hex.DecodeString(word[24:])
+	if err != nil {
+		return ""
+	}
+	return translate.Base58CheckEncode(append([]byte{translate.AddressPrefix}, b...))
+}
+
+// getBlockActivity 抽取结果放在响应缓存里，重复查询同一区块不再访问节点
+func getBlockActivity(ctx context.Context, num int64) (*blockActivity, error) {
+	params := []json.RawMessage{json.RawMessage(fmt.Sprint(num))}
+	if responseCache.enabled() {
+		if v, ok := responseCache.get("proxy_blockActivity", params); ok {
+			return v.(*blockActivity), nil
+		}
+	}
+	block, err := getRestBlockByNum(ctx, num)
+	if err != nil {
+		return nil, err
+	}
+	ba := extractActivity(block)
+	if responseCache.enabled() {
+		responseCache.put("proxy_blockActivity", params, ba)
+	}
+	return ba, nil
+}
+
+type seenAt struct {
+	Block     int64  `json:"block"`
+	Timestamp int64  `json:"timestamp"`
+	TxID      string `json:"txId"`
+}
+
+type addressSummary struct {
+	Address        string   `json:"address"`
+	FromBlock      int64    `json:"fromBlock"`
+	ToBlock        int64    `json:"toBlock"`
+	TxCount        int      `json:"txCount"`
+	FirstSeen      *seenAt  `json:"firstSeen"`
+	LastSeen       *seenAt  `json:"lastSeen"`
+	TrxIn          int64    `json:"trxIn"`
+	TrxOut         int64    `json:"trxOut"`
+	TokenContracts []string `json:"tokenContracts"`
+	Assets         []string `json:"assets"`
+}
+
+func summarizeAddress(address string, blocks []*blockActivity) *addressSummary {
+	s := &addressSummary{Address: address, TokenContracts: []string{}, Assets: []string{}}
+	txs := make(map[string]bool)
+	tokens := make(map[string]bool)
+	assets := make(map[string]bool)
+	for _, b := range blocks {
+		for _, a := range b.Txs {
+			if a.From != address && a.To != address && a.TokenTo != address {
+				continue
+			}
+			if !txs[a.TxID] {
+				txs[a.TxID] = true
+				seen := &seenAt{Block: b.Number, Timestamp: b.Timestamp, TxID: a.TxID}
+				if s.FirstSeen == nil {
+					s.FirstSeen = seen
+				}
+				s.LastSeen = seen
+			}
+			if a.Succeeded && (a.Type == "TransferContract" || a.Type == "TriggerSmartContract") {
+				if a.From == address {
+					s.TrxOut += a.Amount
+				}
+				if a.To == address {
+					s.TrxIn += a.Amount
+				}
+			}
+			switch a.Type {
+			case "TriggerSmartContract":
+				if a.TokenTo != "" {
+					tokens[a.Contract] = true
+				}
+			case "TransferAssetContract":
+				assets[a.Contract] = true
+			}
+		}
+	}
+	s.TxCount = len(txs)
+	for c := range tokens {
+		s.TokenContracts = append(s.TokenContracts, c)
+	}
+	for c := range assets {
+		s.Assets = append(s.Assets, c)
+	}
+	sort.Strings(s.TokenContracts)
+	sort.Strings(s.Assets)
+	return s
+}
+
+type blockRange struct {
+	FromBlock json.RawMessage `json:"fromBlock"`
+	ToBlock   json.RawMessage `json:"toBlock"`
+}
+
+func handleGetAddressSummary(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) == 0 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [address, {fromBlock, toBlock}]")
+	}
+	var rawAddress string
+	if err := codec.Unmarshal(req.Params[0], &rawAddress); err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: address must be a string")
+	}
+	address, err := translate.ToBase58Address(rawAddress)
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+
+	var from, to int64
+	var rng blockRange
+	if len(req.Params) > 1 {
+		if err := codec.Unmarshal(req.Params[1], &rng); err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: range must be {fromBlock, toBlock}")
+		}
+	}
+	if rng.ToBlock != nil {
+		if to, err = parseInt64Param(rng.ToBlock); err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: bad toBlock")
+		}
+	} else {
+		latest, err := getLatestHeader(ctx)
+		if err != nil {
+			return errorResponse(req.ID, err)
+		}
+		to = latest.Number
+	}
+	if rng.FromBlock != nil {
+		if from, err = parseInt64Param(rng.FromBlock); err != nil {
+			return jsonError(req.ID, -32602, "Invalid params: bad fromBlock")
+		}
+	} else {
+		from = to - int64(addressSummaryMaxBlocks) + 1
+		if from < 0 {
+			from = 0
+		}
+	}
+	if from < 0 || to < from {
+		return jsonError(req.ID, -32602, "Invalid params: require 0 <= fromBlock <= toBlock")
+	}
+	if to-from+1 > int64(addressSummaryMaxBlocks) {
+		return jsonError(req.ID, -32602, fmt.Sprintf("Invalid params: range too large (max %d blocks)", addressSummaryMaxBlocks))
+	}
+
+	log.Printf("Address summary for %s, blocks %d-%d", address, from, to)
+	blocks := make([]*blockActivity, to-from+1)
+	errs := make([]error, len(blocks))
+	workers.run(ctx, len(blocks), addressSummaryConcurrency, func(i int) {
+		blocks[i], errs[i] = getBlockActivity(ctx, from+int64(i))
+	})
+	if err := ctx.Err(); err != nil {
+		return jsonError(req.ID, -32603, "Request cancelled")
+	}
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("Address summary error at block %d: %v", from+int64(i), err)
+			return jsonError(req.ID, -32603, fmt.Sprintf("Internal error at block %d: %v", from+int64(i), err))
+		}
+	}
+
+	summary := summarizeAddress(address, blocks)
+	summary.FromBlock, summary.ToBlock = from, to
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: summary}
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// 保留最近的日志行，生成支持包时附上；上游健康状态变化见 healthhistory.go
+	logTail          = newLineRing(envInt("SUPPORT_LOG_LINES", 2000))
+	errorLinePattern = regexp.MustCompile(`(?i)error|fail|panic|timeout|refused|invalid`)
+	// 名字里带这些词的环境变量只保留是否设置
+	secretEnvPattern = regexp.MustCompile(`(?i)token|secret|password|passwd|key|dsn|credential|private|upstream_auth`)
+)
+
+// redactLine 日志和错误信息里的 URL 也可能带凭证
+func redactLine(s string) string {
+	return up.Redact(s)
+}
+
+// lineRing 作为 log 的第二个输出，按行保留最近 max 行
+type lineRing struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLineRing(max int) *lineRing {
+	if max < 1 {
+		max = 1
+	}
+	return &lineRing{max: max, lines: make([]string, max)}
+}
+
+func (r *lineRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % r.max
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+	return len(p), nil
+}
+
+func (r *lineRing) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// redactURL 去掉用户名密码和查询串，节点 URL 里经常带 API key
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "REDACTED"
+	}
+	return u.String()
+}
+
+// redactedConfig 所有环境变量，敏感值替换掉，URL 列表逐个去掉凭证
+func redactedConfig() map[string]string {
+	values := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		values[k] = v
+	}
+	// CONFIG_FILE 中的值覆盖环境变量
+	overlay, _ := configOverlay.Load().(map[string]string)
+	for k, v := range overlay {
+		values[k] = v
+	}
+	out := map[string]string{}
+	for k, v := range values {
+		switch {
+		case secretEnvPattern.MatchString(k) && v != "":
+			out[k] = "REDACTED"
+		case strings.Contains(v, "://"):
+			parts := strings.Split(v, ",")
+			for i, p := range parts {
+				parts[i] = redactURL(strings.TrimSpace(p))
+			}
+			out[k] = strings.Join(parts, ",")
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func versionInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"go":            runtime.Version(),
+		"os":            runtime.GOOS,
+		"arch":          runtime.GOARCH,
+		"pid":           os.Getpid(),
+		"goroutines":    runtime.NumGoroutine(),
+		"uptimeSeconds": int64(time.Since(processStart).Seconds()),
+		"jsonCodec":     codecName,
+	}
+	if host, err := os.Hostname(); err == nil {
+		info["hostname"] = host
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = bi.Main.Path + "@" + bi.Main.Version
+		settings := map[string]string{}
+		for _, s := range bi.Settings {
+			settings[s.Key] = s.Value
+		}
+		info["build"] = settings
+	}
+	return info
+}
+
+func recentErrors() []string {
+	var out []string
+	for _, line := range logTail.snapshot() {
+		if errorLinePattern.MatchString(line) {
+			out = append(out, redactLine(line))
+		}
+	}
+	return out
+}
+
+// handleAdminSupportBundle GET /admin/support-bundle 下载 tar.gz：脱敏后的配置、版本、最近的错误日志、
+// 上游健康状态和变化历史、goroutine dump、指标快照
+func handleAdminSupportBundle(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	var names []string
+	add := func(name string, data []byte) {
+		names = append(names, name)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: now})
+		tw.Write(data)
+	}
+	addJSON := func(name string, v interface{}) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			data = []byte(fmt.Sprintf(`{"error":%q}`, err.Error()))
+		}
+		add(name, append(data, '\n'))
+	}
+
+	addJSON("config.json", redactedConfig())
+	addJSON("version.json", versionInfo())
+	addJSON("errors.json", recentErrors())
+	add("log.txt", []byte(redactLine(strings.Join(logTail.snapshot(), "\n"))+"\n"))
+
+	status := map[string]interface{}{}
+	for _, p := range allUpstreamPools() {
+		nodes := p.status()
+		for i := range nodes {
+			nodes[i].URL = redactURL(nodes[i].URL)
+			nodes[i].LastError = redactLine(nodes[i].LastError)
+		}
+		status[p.name()] = nodes
+	}
+	addJSON("upstreams.json", status)
+	addJSON("upstream_history.json", upstreamHistory.snapshot())
+	addJSON("readyz.json", readyz(r.Context(), true))
+	addJSON("standby.json", standby.status())
+	addJSON("cache.json", map[string]interface{}{"jsonrpc": responseCache.Stats(), "rest": restCache.Stats()})
+
+	var goroutines bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&goroutines, 2)
+	add("goroutines.txt", goroutines.Bytes())
+	var metrics bytes.Buffer
+	writeMetrics(&metrics)
+	add("metrics.txt", metrics.Bytes())
+
+	sort.Strings(names)
+	addJSON("MANIFEST.json", map[string]interface{}{"generatedAt": now, "files": names})
+
+	tw.Close()
+	gz.Close()
+	host, _ := os.Hostname()
+	log.Printf("Support bundle generated for %s (%d bytes)", clientIP(r), buf.Len())
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="proxy-support-%s-%s.tar.gz"`, host, now.Format("20060102-150405")))
+	w.Header().Set("Cache-Control", "no-transform")
+	w.Write(buf.Bytes())
+}
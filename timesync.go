@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// TIME_SYNC_INTERVAL 比较最新区块时间和本地时钟的间隔，0 关闭
+	timeSyncInterval = envDuration("TIME_SYNC_INTERVAL", 30*time.Second)
+	// 超过这个偏差认为链停滞或本地时钟不准；TRON 出块间隔 3 秒，正常偏差在几秒内
+	clockSkewMax = envDuration("CLOCK_SKEW_MAX", time.Minute)
+	// 默认只在 /readyz 里标记 degraded，设为 1 时直接返回未就绪
+	clockSkewUnready = envString("CLOCK_SKEW_UNREADY", "") == "1"
+
+	clock = &clockState{}
+)
+
+type clockStatus struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	BlockTime time.Time `json:"blockTime"`
+	Block     int64     `json:"block"`
+	// 本地时间减区块时间，正数表示区块落后于本地时钟
+	SkewSeconds float64 `json:"skewSeconds"`
+	// 上游 HTTP Date 头减本地时间，用来区分是链停滞还是本地时钟不准
+	UpstreamOffsetSeconds *float64 `json:"upstreamOffsetSeconds,omitempty"`
+	Degraded              bool     `json:"degraded"`
+	Reason                string   `json:"reason,omitempty"`
+}
+
+type clockState struct {
+	mu   sync.Mutex
+	last *clockStatus
+}
+
+func (c *clockState) status() *clockStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// set 状态变化时打日志并发布事件，持续异常时不重复
+func (c *clockState) set(s *clockStatus) {
+	c.mu.Lock()
+	prev := c.last
+	c.last = s
+	c.mu.Unlock()
+	wasDegraded := prev != nil && prev.Degraded
+	switch {
+	case s.Degraded && !wasDegraded:
+		log.Printf("Clock skew detected: %s", s.Reason)
+		events.publish(EventClockSkew, clockSkewEvent{SkewSeconds: s.SkewSeconds, Reason: s.Reason})
+	case !s.Degraded && wasDegraded:
+		log.Printf("Clock skew resolved, latest block is %.1fs behind local time", s.SkewSeconds)
+		events.publish(EventClockSkew, clockSkewEvent{SkewSeconds: s.SkewSeconds, Resolved: true})
+	}
+}
+
+func startTimeSyncChecks() {
+	if timeSyncInterval <= 0 || len(jsonrpcUpstreams.list()) == 0 {
+		return
+	}
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckClient.Timeout)
+			s, err := checkClock(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("Time sync check failed: %v", err)
+			} else {
+				clock.set(s)
+			}
+			time.Sleep(timeSyncInterval)
+		}
+	}()
+}
+
+func checkClock(ctx context.Context) (*clockStatus, error) {
+	head, err := getLatestHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	blockTime := time.Unix(head.Timestamp, 0)
+	s := &clockStatus{
+		CheckedAt:   now,
+		BlockTime:   blockTime,
+		Block:       head.Number,
+		SkewSeconds: math.Round(now.Sub(blockTime).Seconds()*10) / 10,
+	}
+	if offset, ok := upstreamClockOffset(ctx); ok {
+		s.UpstreamOffsetSeconds = &offset
+	}
+	maxSkew := clockSkewMax.Seconds()
+	if math.Abs(s.SkewSeconds) <= maxSkew {
+		return s, nil
+	}
+	s.Degraded = true
+	// Date 头只有秒级精度，偏差明显超过阈值才归因于本地时钟
+	localClockOff := s.UpstreamOffsetSeconds != nil && math.Abs(*s.UpstreamOffsetSeconds) > maxSkew
+	switch {
+	case localClockOff:
+		s.Reason = fmt.Sprintf("local clock differs from upstream by %.0fs", *s.UpstreamOffsetSeconds)
+	case s.SkewSeconds > 0:
+		s.Reason = fmt.Sprintf("latest block %d is %.0fs old, chain or upstream may be stalled", head.Number, s.SkewSeconds)
+	default:
+		s.Reason = fmt.Sprintf("latest block %d is %.0fs in the future, local clock may be behind", head.Number, -s.SkewSeconds)
+	}
+	return s, nil
+}
+
+// upstreamClockOffset 读第一个 jsonrpc 节点响应的 Date 头
+func upstreamClockOffset(ctx context.Context) (float64, bool) {
+	for _, u := range jsonrpcUpstreams.list() {
+		payload := `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, strings.NewReader(payload))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setUpstreamHeaders(ctx, req, u)
+		sent := time.Now()
+		resp, err := healthCheckClient.Do(req)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			continue
+		}
+		// 取请求往返的中点作为本地时间
+		mid := sent.Add(time.Since(sent) / 2)
+		return math.Round(date.Sub(mid).Seconds()), true
+	}
+	return 0, false
+}
+
+func writeClockMetrics(w io.Writer) {
+	s := clock.status()
+	if s == nil {
+		return
+	}
+	writeGauge(w, "proxy_clock_skew_seconds", "Local time minus the latest block timestamp.", s.SkewSeconds)
+	if s.UpstreamOffsetSeconds != nil {
+		writeGauge(w, "proxy_clock_upstream_offset_seconds", "Upstream HTTP Date header minus local time.", *s.UpstreamOffsetSeconds)
+	}
+	degraded := 0.0
+	if s.Degraded {
+		degraded = 1
+	}
+	writeGauge(w, "proxy_clock_skew_degraded", "Whether clock skew exceeds CLOCK_SKEW_MAX.", degraded)
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	// 直接提供 HTTPS：TLS_CERT_FILE/TLS_KEY_FILE 指定证书（文件更新后自动重新加载），
+	// 或者 TLS_AUTOCERT_DOMAINS 通过 ACME（Let's Encrypt）自动申请
+	tlsCertFile        = envString("TLS_CERT_FILE", "")
+	tlsKeyFile         = envString("TLS_KEY_FILE", "")
+	tlsAutocertDomains = splitList(envString("TLS_AUTOCERT_DOMAINS", ""))
+	tlsAutocertEmail   = envString("TLS_AUTOCERT_EMAIL", "")
+	tlsAutocertCache   = envString("TLS_AUTOCERT_CACHE", filepath.Join(dataDir, "autocert"))
+	// HTTP-01 验证和 HTTP 到 HTTPS 的跳转，空字符串时只用 TLS-ALPN-01
+	tlsAutocertHTTPAddr = envString("TLS_AUTOCERT_HTTP_ADDR", ":80")
+
+	// 访问上游时出示的客户端证书和额外信任的 CA（追加到系统 CA 之后），用于只接受 mTLS 的入口
+	upstreamTLSCertFile = envString("UPSTREAM_TLS_CERT_FILE", "")
+	upstreamTLSKeyFile  = envString("UPSTREAM_TLS_KEY_FILE", "")
+	upstreamTLSCAFile   = envString("UPSTREAM_TLS_CA_FILE", "")
+
+	upstreamTLS = newUpstreamTLSConfig()
+)
+
+// certReloader 每次握手时检查证书文件，修改过就重新加载，证书轮换不需要重启
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.get(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) get() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && time.Since(r.checked) < 10*time.Second {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && info.ModTime().Equal(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// 证书和私钥可能正在分两步替换，继续用旧的
+			log.Printf("Error reloading certificate %s, keeping previous one: %v", r.certFile, err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil {
+		log.Printf("Reloaded certificate %s", r.certFile)
+	}
+	r.cert, r.modTime = &cert, info.ModTime()
+	return r.cert, nil
+}
+
+// newUpstreamTLSConfig 未配置时返回 nil，使用 Go 的默认设置；证书有误时记进 configErrors，New 会失败
+func newUpstreamTLSConfig() *tls.Config {
+	if upstreamTLSCertFile == "" && upstreamTLSCAFile == "" {
+		return nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if upstreamTLSCAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(upstreamTLSCAFile)
+		if err != nil {
+			configErrors = append(configErrors, fmt.Errorf("reading UPSTREAM_TLS_CA_FILE: %w", err))
+			return nil
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			configErrors = append(configErrors, fmt.Errorf("no certificates found in UPSTREAM_TLS_CA_FILE %s", upstreamTLSCAFile))
+			return nil
+		}
+		cfg.RootCAs = pool
+	}
+	if upstreamTLSCertFile != "" {
+		certs, err := newCertReloader(upstreamTLSCertFile, upstreamTLSKeyFile)
+		if err != nil {
+			configErrors = append(configErrors, fmt.Errorf("loading upstream client certificate: %w", err))
+			return nil
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.get()
+		}
+	}
+	return cfg
+}
+
+// upstreamTLSClone 每个 Transport 用自己的副本，http2 会修改 NextProtos
+func upstreamTLSClone() *tls.Config {
+	if upstreamTLS == nil {
+		return nil
+	}
+	return upstreamTLS.Clone()
+}
+
+// serverTLSConfig 没有配置 HTTPS 时返回 nil
+func serverTLSConfig() (*tls.Config, error) {
+	switch {
+	case len(tlsAutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(tlsAutocertDomains...),
+			Cache:      autocert.DirCache(tlsAutocertCache),
+			Email:      tlsAutocertEmail,
+		}
+		if tlsAutocertHTTPAddr != "" {
+			go func() {
+				log.Printf("Serving ACME HTTP-01 challenges on %s", tlsAutocertHTTPAddr)
+				if err := http.ListenAndServe(tlsAutocertHTTPAddr, m.HTTPHandler(nil)); err != nil {
+					log.Printf("ACME HTTP listener stopped: %v", err)
+				}
+			}()
+		}
+		log.Printf("TLS certificates for %v obtained automatically, cached in %s", tlsAutocertDomains, tlsAutocertCache)
+		cfg := m.TLSConfig()
+		cfg.MinVersion = tls.VersionTLS12
+		return cfg, nil
+	case tlsCertFile != "" || tlsKeyFile != "":
+		if tlsCertFile == "" || tlsKeyFile == "" {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		certs, err := newCertReloader(tlsCertFile, tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return certs.get()
+			},
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"container/list"
+	"sync"
+)
+
+// traceCache 按交易 ID 缓存原始 trace JSON，按总字节数做 LRU 淘汰。
+// 超过单条上限的大文件不缓存，避免一个大 trace 挤掉大量小 trace
+var traceCache = newTraceCache(
+	int64(envInt("TRACE_CACHE_BYTES", 256<<20)),
+	int64(envInt("TRACE_CACHE_MAX_ITEM_BYTES", 16<<20)),
+)
+
+type traceCacheEntry struct {
+	txID string
+	data []byte
+}
+
+type traceCacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	maxItem  int64
+	bytes    int64
+	ll       *list.List
+	items    map[string]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+func newTraceCache(maxBytes, maxItem int64) *traceCacheStore {
+	return &traceCacheStore{maxBytes: maxBytes, maxItem: maxItem, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *traceCacheStore) get(txID string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[txID]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return el.Value.(*traceCacheEntry).data, true
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *traceCacheStore) put(txID string, data []byte) {
+	size := int64(len(data))
+	if c.maxBytes <= 0 || size > c.maxItem || size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[txID]; ok {
+		c.bytes -= int64(len(el.Value.(*traceCacheEntry).data))
+		el.Value.(*traceCacheEntry).data = data
+		c.bytes += size
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[txID] = c.ll.PushFront(&traceCacheEntry{txID: txID, data: data})
+		c.bytes += size
+	}
+	for c.bytes > c.maxBytes {
+		el := c.ll.Back()
+		e := el.Value.(*traceCacheEntry)
+		c.ll.Remove(el)
+		delete(c.items, e.txID)
+		c.bytes -= int64(len(e.data))
+		c.evictions++
+	}
+}
+
+func (c *traceCacheStore) remove(txID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[txID]
+	if !ok {
+		return false
+	}
+	c.ll.Remove(el)
+	delete(c.items, txID)
+	c.bytes -= int64(len(el.Value.(*traceCacheEntry).data))
+	return true
+}
+
+// flush 清空缓存，返回清除的条目数
+func (c *traceCacheStore) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.ll.Len()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+	return n
+}
+
+type traceCacheStats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"maxBytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+func (c *traceCacheStore) Stats() traceCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return traceCacheStats{
+		Entries: c.ll.Len(), Bytes: c.bytes, MaxBytes: c.maxBytes,
+		Hits: c.hits, Misses: c.misses, Evictions: c.evictions,
+	}
+}
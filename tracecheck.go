@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	traceConsistencyCheck = envString("TRACE_CONSISTENCY_CHECK", "off") == "on"
+
+	metricTraceConsistency = newCounterVec("proxy_trace_consistency_total",
+		"Trace vs receipt consistency checks at serve time, by result.", "result")
+)
+
+// traceConsistency 放在 _proxy 扩展里，Mismatches 为空表示一致
+type traceConsistency struct {
+	Checked    bool     `json:"checked"`
+	Mismatches []string `json:"mismatches,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type traceOutcome struct {
+	Type    string `json:"type"`
+	Error   string `json:"error"`
+	GasUsed string `json:"gasUsed"`
+}
+
+type receiptOutcome struct {
+	Status  string `json:"status"`
+	GasUsed string `json:"gasUsed"`
+}
+
+func ensureProxyMeta(resp *JSONRPCResponse) *proxyMeta {
+	if resp.Proxy == nil {
+		resp.Proxy = &proxyMeta{}
+	}
+	return resp.Proxy
+}
+
+// checkTraceConsistency 用交易回执核对 trace 顶层调用的成功/失败和 energy 消耗
+func checkTraceConsistency(ctx context.Context, req JSONRPCRequest, resp *JSONRPCResponse) {
+	if !traceConsistencyCheck || resp.Error != nil || len(req.Params) == 0 {
+		return
+	}
+	if req.Method != "eth_debugTransactionTrace" && req.Method != "debug_traceTransaction" {
+		return
+	}
+	raw, err := codec.Marshal(resp.Result)
+	if err != nil {
+		return
+	}
+	var trace traceOutcome
+	if codec.Unmarshal(raw, &trace) != nil || trace.Type == "" {
+		// 不是 callTracer 格式，没有可比较的字段
+		return
+	}
+	txID, err := parseTxIDParam(req.Params[0])
+	if err != nil {
+		return
+	}
+	result := &traceConsistency{Checked: true}
+	ensureProxyMeta(resp).Consistency = result
+	receipt, err := cachedReceipt(ctx, txID)
+	if err != nil {
+		metricTraceConsistency.inc("unavailable")
+		result.Checked = false
+		result.Error = err.Error()
+		return
+	}
+	result.Mismatches = compareTraceReceipt(trace, receipt)
+	if len(result.Mismatches) == 0 {
+		metricTraceConsistency.inc("ok")
+		return
+	}
+	metricTraceConsistency.inc("mismatch")
+	log.Printf("Trace/receipt mismatch for %s: %s", txID, strings.Join(result.Mismatches, "; "))
+}
+
+func checkTraceConsistencies(ctx context.Context, reqs []JSONRPCRequest, responses []JSONRPCResponse) {
+	if !traceConsistencyCheck || len(reqs) != len(responses) {
+		return
+	}
+	workers.run(ctx, len(reqs), batchConcurrency, func(i int) {
+		checkTraceConsistency(ctx, reqs[i], &responses[i])
+	})
+}
+
+// cachedReceipt 回执不可变，经过响应缓存获取
+func cachedReceipt(ctx context.Context, txID string) (*receiptOutcome, error) {
+	p, _ := codec.Marshal("0x" + txID)
+	req := JSONRPCRequest{Jsonrpc: "2.0", Method: "eth_getTransactionReceipt", Params: []json.RawMessage{p}, ID: 1}
+	resp := withCache(ctx, req, forwardAndReturn)
+	if resp.Error != nil {
+		return nil, fmt.Errorf("receipt lookup failed: %v", resp.Error)
+	}
+	raw, err := codec.Marshal(resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	var receipt *receiptOutcome
+	if err := codec.Unmarshal(raw, &receipt); err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("receipt not found")
+	}
+	return receipt, nil
+}
+
+func compareTraceReceipt(trace traceOutcome, receipt *receiptOutcome) []string {
+	var mismatches []string
+	traceOK := trace.Error == ""
+	if receipt.Status != "" {
+		receiptOK := receipt.Status == "0x1"
+		if traceOK != receiptOK {
+			mismatches = append(mismatches, fmt.Sprintf("status: trace success=%t, receipt status=%s", traceOK, receipt.Status))
+		}
+	}
+	if trace.GasUsed != "" && receipt.GasUsed != "" {
+		tg, err1 := translate.ParseQuantity(trace.GasUsed)
+		rg, err2 := translate.ParseQuantity(receipt.GasUsed)
+		if err1 == nil && err2 == nil && tg != rg {
+			mismatches = append(mismatches, fmt.Sprintf("energy: trace gasUsed=%d, receipt gasUsed=%d", tg, rg))
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+)
+
+// traceFormatVersion 修改 trace 的转换逻辑（例如 callTracer 字段映射）时递增，
+// 启动后会在后台用原始 trace 重新生成所有派生数据
+const traceFormatVersion = 1
+
+// auto 启动时检查并在后台重新处理；off 关闭
+var traceReprocess = envString("TRACE_REPROCESS", "auto")
+
+// traceArtifact 是从原始 trace 文件派生、需要随格式版本一起更新的数据
+type traceArtifact struct {
+	name    string
+	rebuild func(ctx context.Context) error
+}
+
+var traceArtifacts []traceArtifact
+
+func registerTraceArtifact(name string, rebuild func(ctx context.Context) error) {
+	traceArtifacts = append(traceArtifacts, traceArtifact{name: name, rebuild: rebuild})
+}
+
+func traceVersionKey(name string) string {
+	return "trace_format_version." + name
+}
+
+// staleTraceArtifacts 返回记录版本与当前版本不一致的派生数据
+func staleTraceArtifacts(ctx context.Context) []traceArtifact {
+	var stale []traceArtifact
+	for _, a := range traceArtifacts {
+		v, _ := loadState(ctx, traceVersionKey(a.name))
+		if n, err := strconv.Atoi(v); err != nil || n != traceFormatVersion {
+			stale = append(stale, a)
+		}
+	}
+	return stale
+}
+
+func startTraceReprocessing() {
+	if traceReprocess == "off" {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		for _, a := range staleTraceArtifacts(ctx) {
+			log.Printf("Trace format is v%d, reprocessing %s in background", traceFormatVersion, a.name)
+			start := time.Now()
+			if err := a.rebuild(ctx); err != nil {
+				// 版本号不更新，下次启动重试
+				log.Printf("Error reprocessing %s: %v", a.name, err)
+				continue
+			}
+			if err := saveState(ctx, traceVersionKey(a.name), strconv.Itoa(traceFormatVersion)); err != nil {
+				log.Printf("Error recording trace format version for %s: %v", a.name, err)
+			}
+			log.Printf("Reprocessed %s in %s", a.name, time.Since(start))
+		}
+	}()
+}
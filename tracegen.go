@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	// off：trace 缺失时直接报错；node：调用节点的 debug_traceTransaction；service：调用 TRACE_GENERATOR_URL；
+	// txinfo：由交易和 internal_transactions 转换（见 internaltx.go）
+	traceGenerate        = envString("TRACE_GENERATE", "off")
+	traceGeneratorURL    = configValue("TRACE_GENERATOR_URL")
+	traceGenerateTimeout = envDuration("TRACE_GENERATE_TIMEOUT", 2*time.Minute)
+
+	metricTraceGenerated = newCounterVec("proxy_trace_generated_total",
+		"On-demand trace generations for missing trace files, by result.", "result")
+)
+
+var errInvalidTraceJSON = errors.New("invalid JSON in trace file")
+
+// loadTrace 先查内存缓存，再读 trace 存储，缺失时按配置现场生成并写回存储。
+// 返回的数据已校验为合法 JSON，可以直接作为 json.RawMessage 输出
+func loadTrace(ctx context.Context, txID string) ([]byte, error) {
+	if data, ok := traceCache.get(traceCacheKey(ctx, txID)); ok {
+		debugFrom(ctx).traceSource("cache")
+		return data, nil
+	}
+	data, err := readOrGenerateTrace(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, errInvalidTraceJSON
+	}
+	traceCache.put(traceCacheKey(ctx, txID), data)
+	return data, nil
+}
+
+// traceCacheKey 其他网络的 trace 加上网络名，和默认网络分开
+func traceCacheKey(ctx context.Context, txID string) string {
+	if n := networkFrom(ctx); n != nil {
+		return n.name + ":" + txID
+	}
+	return txID
+}
+
+func traceStoreFor(ctx context.Context) TraceStore {
+	if n := networkFrom(ctx); n != nil {
+		return n.traces
+	}
+	return traces
+}
+
+func readOrGenerateTrace(ctx context.Context, txID string) ([]byte, error) {
+	store := traceStoreFor(ctx)
+	data, err := store.Get(ctx, txID)
+	observeTraceFile(err)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || traceGenerate == "off" {
+		if err == nil {
+			debugFrom(ctx).traceSource("store")
+		}
+		return data, err
+	}
+	genCtx, cancel := context.WithTimeout(ctx, traceGenerateTimeout)
+	defer cancel()
+	start := time.Now()
+	data, genErr := generateTrace(genCtx, txID)
+	if genErr == nil && !json.Valid(data) {
+		genErr = errInvalidTraceJSON
+	}
+	if genErr != nil {
+		metricTraceGenerated.inc("error")
+		log.Printf("Trace generation for %s failed: %v", txID, genErr)
+		// 返回原来的 not found，调用方的错误处理保持不变
+		return nil, err
+	}
+	metricTraceGenerated.inc("ok")
+	debugFrom(ctx).traceSource("generated")
+	log.Printf("Generated trace for %s via %s in %s", txID, traceGenerate, time.Since(start))
+	if err := store.Put(ctx, txID, data); err != nil {
+		log.Printf("Error storing generated trace %s: %v", txID, err)
+	}
+	return data, nil
+}
+
+func generateTrace(ctx context.Context, txID string) ([]byte, error) {
+	switch traceGenerate {
+	case "node":
+		result, err := callUpstream(ctx, "debug_traceTransaction", "0x"+txID, map[string]interface{}{"tracer": "callTracer"})
+		if err != nil {
+			return nil, err
+		}
+		if len(result) == 0 || string(result) == "null" {
+			return nil, fmt.Errorf("node returned no trace")
+		}
+		return result, nil
+	case "service":
+		return callTraceGenerator(ctx, txID)
+	case "txinfo":
+		return traceFromTxInfo(ctx, txID)
+	}
+	return nil, fmt.Errorf("unknown TRACE_GENERATE mode %q", traceGenerate)
+}
+
+// callTraceGenerator 请求体为 {"txId": "..."}，响应体即 trace JSON
+func callTraceGenerator(ctx context.Context, txID string) ([]byte, error) {
+	target := traceGeneratorURL
+	if n := networkFrom(ctx); n != nil {
+		target = n.traceGeneratorURL
+	}
+	if target == "" {
+		return nil, fmt.Errorf("TRACE_GENERATOR_URL not configured")
+	}
+	body, _ := json.Marshal(map[string]string{"txId": txID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := traceStoreClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, traceMaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("trace generator returned HTTP %d", resp.StatusCode)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("trace generator returned invalid JSON")
+	}
+	return data, nil
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 跟随最新区块，提前为新交易生成 trace 写入 trace 存储，客户端第一次请求时不用现场生成。
+	// 需要 TRACE_GENERATE 和 BLOCK_WATCH_INTERVAL
+	tracePregenerate = envString("TRACE_PREGENERATE", "false") == "true"
+	// 最多回补最新高度之前多少个区块，启动时和落后太多时从这里开始
+	tracePregenerateDepth       = int64(envInt("TRACE_PREGENERATE_DEPTH", 20))
+	tracePregenerateConcurrency = envInt("TRACE_PREGENERATE_CONCURRENCY", 4)
+	// 只处理这些合约类型的交易，为空时处理全部
+	tracePregenerateTypes = toSet(splitList(envString("TRACE_PREGENERATE_TYPES", "TriggerSmartContract,CreateSmartContract")))
+
+	metricTracePregenerated = newCounterVec("proxy_trace_pregenerated_total",
+		"Transactions processed by the trace pre-generation worker, by result.", "result")
+)
+
+func startTracePregeneration() {
+	if !tracePregenerate {
+		return
+	}
+	if traceGenerate == "off" {
+		log.Printf("TRACE_PREGENERATE needs TRACE_GENERATE, pre-generation disabled")
+		return
+	}
+	if !watcherRunning() {
+		log.Printf("TRACE_PREGENERATE needs the block watcher (BLOCK_WATCH_INTERVAL), pre-generation disabled")
+		return
+	}
+	var head int64
+	wake := make(chan struct{}, 1)
+	events.subscribe("tracepregen", 16, func(e event) {
+		atomic.StoreInt64(&head, e.Data.(newBlockEvent).Header.Number)
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}, EventNewBlock)
+	log.Printf("Trace pre-generation enabled (depth %d, concurrency %d)", tracePregenerateDepth, tracePregenerateConcurrency)
+	go func() {
+		done := int64(-1)
+		for range wake {
+			h := atomic.LoadInt64(&head)
+			from := done + 1
+			if done < 0 || h-from >= tracePregenerateDepth {
+				from = h - tracePregenerateDepth + 1
+			}
+			// 失败的区块不重试，客户端请求时仍会现场生成
+			for n := from; n <= h; n++ {
+				pregenerateBlock(n)
+				done = n
+			}
+		}
+	}()
+}
+
+func pregenerateBlock(num int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceGenerateTimeout)
+	defer cancel()
+	start := time.Now()
+	block, err := getRestBlockByNum(ctx, num)
+	if err != nil {
+		log.Printf("Trace pre-generation: block %d: %v", num, err)
+		return
+	}
+	var txIDs []string
+	for _, tx := range block.Transactions {
+		if len(tracePregenerateTypes) > 0 && (len(tx.RawData.Contract) == 0 || !tracePregenerateTypes[tx.RawData.Contract[0].Type]) {
+			continue
+		}
+		txIDs = append(txIDs, strings.ToLower(tx.TxID))
+	}
+	if len(txIDs) == 0 {
+		return
+	}
+	ctx = prefetchTxInfos(ctx, block, num)
+	var failed int64
+	workers.run(ctx, len(txIDs), tracePregenerateConcurrency, func(i int) {
+		// 已在存储中的直接返回，缺失的按 TRACE_GENERATE 生成并写入存储
+		if _, err := readOrGenerateTrace(ctx, txIDs[i]); err != nil {
+			atomic.AddInt64(&failed, 1)
+			metricTracePregenerated.inc("error")
+			return
+		}
+		metricTracePregenerated.inc("ok")
+	})
+	log.Printf("Trace pre-generation: block %d, %d transaction(s), %d failed, %s", num, len(txIDs), failed, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourname/proxy/tracestore"
+)
+
+var (
+	// TRACE_STATS_INTERVAL 扫描 trace 目录统计容量的间隔，0 关闭；只支持本地目录
+	traceStatsInterval = envDuration("TRACE_STATS_INTERVAL", 5*time.Minute)
+	// 按最近 N 个完整自然日（UTC）的平均写入量估算增长速度
+	traceForecastDays = envInt("TRACE_FORECAST_DAYS", 7)
+	// per-day 文件数保留的天数
+	traceStatsDays = envInt("TRACE_STATS_DAYS", 14)
+
+	metricTraceStoreDuration = newHistogramVec("proxy_trace_store_duration_seconds",
+		"Trace store operation latency in seconds, by operation.", latencyBuckets, "op", "result")
+
+	traceStats = &traceStoreStats{}
+)
+
+// instrumentedTraceStore 记录每次 Get/Put/List 的耗时
+type instrumentedTraceStore struct {
+	TraceStore
+}
+
+func (s instrumentedTraceStore) Get(ctx context.Context, txID string) ([]byte, error) {
+	start := time.Now()
+	data, err := s.TraceStore.Get(ctx, txID)
+	observeTraceStore("get", start, err)
+	return data, err
+}
+
+func (s instrumentedTraceStore) Put(ctx context.Context, txID string, data []byte) error {
+	start := time.Now()
+	err := s.TraceStore.Put(ctx, txID, data)
+	observeTraceStore("put", start, err)
+	return err
+}
+
+func (s instrumentedTraceStore) List(ctx context.Context, since time.Time) ([]string, error) {
+	start := time.Now()
+	ids, err := s.TraceStore.List(ctx, since)
+	observeTraceStore("list", start, err)
+	return ids, err
+}
+
+func observeTraceStore(op string, start time.Time, err error) {
+	result := "ok"
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist):
+		result = "miss"
+	default:
+		result = "error"
+	}
+	metricTraceStoreDuration.observe(time.Since(start).Seconds(), op, result)
+}
+
+// baseTraceStore 去掉指标包装，需要判断具体存储类型时使用
+func baseTraceStore(s TraceStore) TraceStore {
+	if in, ok := s.(instrumentedTraceStore); ok {
+		return in.TraceStore
+	}
+	return s
+}
+
+type traceDayStat struct {
+	Day   string `json:"day"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+type traceStoreSnapshot struct {
+	Store          string         `json:"store"`
+	ScannedAt      time.Time      `json:"scannedAt"`
+	ScanMs         int64          `json:"scanMs"`
+	Files          int            `json:"files"`
+	Bytes          int64          `json:"bytes"`
+	Days           []traceDayStat `json:"days"`
+	GrowthPerDay   float64        `json:"growthBytesPerDay"`
+	DiskFree       uint64         `json:"diskFreeBytes,omitempty"`
+	DiskTotal      uint64         `json:"diskTotalBytes,omitempty"`
+	DaysUntilFull  *float64       `json:"daysUntilFull,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	ForecastWindow int            `json:"forecastWindowDays"`
+}
+
+type traceStoreStats struct {
+	mu   sync.Mutex
+	last *traceStoreSnapshot
+}
+
+func (t *traceStoreStats) set(snap *traceStoreSnapshot) {
+	t.mu.Lock()
+	t.last = snap
+	t.mu.Unlock()
+}
+
+func (t *traceStoreStats) snapshot() *traceStoreSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+func startTraceStoreStats() {
+	local, ok := baseTraceStore(traces).(tracestore.Local)
+	if traceStatsInterval <= 0 || !ok {
+		return
+	}
+	go func() {
+		for {
+			snap := scanTraceDir(local.Dir, time.Now())
+			if snap.Error != "" {
+				log.Printf("Trace store scan failed: %s", snap.Error)
+			}
+			traceStats.set(snap)
+			time.Sleep(traceStatsInterval)
+		}
+	}()
+}
+
+// scanTraceDir 按修改时间把文件归到 UTC 自然日，用最近几个完整日的平均写入量作为增长速度。
+// 当天还没过完，不参与平均
+func scanTraceDir(dir string, now time.Time) *traceStoreSnapshot {
+	start := time.Now()
+	snap := &traceStoreSnapshot{Store: dir, ScannedAt: now, ForecastWindow: traceForecastDays}
+	byDay := map[string]*traceDayStat{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		snap.Error = err.Error()
+		return snap
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snap.Files++
+		snap.Bytes += info.Size()
+		day := info.ModTime().UTC().Format("2006-01-02")
+		d := byDay[day]
+		if d == nil {
+			d = &traceDayStat{Day: day}
+			byDay[day] = d
+		}
+		d.Files++
+		d.Bytes += info.Size()
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	var window int64
+	for i := 1; i <= traceForecastDays; i++ {
+		if d := byDay[today.AddDate(0, 0, -i).Format("2006-01-02")]; d != nil {
+			window += d.Bytes
+		}
+	}
+	if traceForecastDays > 0 {
+		snap.GrowthPerDay = float64(window) / float64(traceForecastDays)
+	}
+	for i := 0; i < traceStatsDays; i++ {
+		day := today.AddDate(0, 0, -i).Format("2006-01-02")
+		d := traceDayStat{Day: day}
+		if s := byDay[day]; s != nil {
+			d = *s
+		}
+		snap.Days = append(snap.Days, d)
+	}
+	if free, total, ok := diskUsage(dir); ok {
+		snap.DiskFree, snap.DiskTotal = free, total
+		if snap.GrowthPerDay > 0 {
+			days := math.Round(float64(free)/snap.GrowthPerDay*10) / 10
+			snap.DaysUntilFull = &days
+		}
+	}
+	snap.ScanMs = time.Since(start).Milliseconds()
+	return snap
+}
+
+func writeTraceStoreMetrics(w io.Writer) {
+	metricTraceStoreDuration.write(w)
+	snap := traceStats.snapshot()
+	if snap == nil {
+		return
+	}
+	writeGauge(w, "proxy_trace_store_bytes", "Total size of trace files in the trace store.", float64(snap.Bytes))
+	writeGauge(w, "proxy_trace_store_files", "Number of trace files in the trace store.", float64(snap.Files))
+	writeGauge(w, "proxy_trace_store_growth_bytes_per_day", "Average bytes written per day over the forecast window.", snap.GrowthPerDay)
+	if snap.DiskTotal > 0 {
+		writeGauge(w, "proxy_trace_store_disk_free_bytes", "Free space on the filesystem holding the trace store.", float64(snap.DiskFree))
+	}
+	if snap.DaysUntilFull != nil {
+		writeGauge(w, "proxy_trace_store_days_until_full", "Days until the trace store filesystem is full at the current growth rate.", *snap.DaysUntilFull)
+	}
+	fmt.Fprintf(w, "# HELP proxy_trace_store_day_files Trace files by UTC modification day.\n# TYPE proxy_trace_store_day_files gauge\n")
+	days := append([]traceDayStat(nil), snap.Days...)
+	sort.Slice(days, func(i, j int) bool { return days[i].Day < days[j].Day })
+	for _, d := range days {
+		fmt.Fprintf(w, "proxy_trace_store_day_files{day=%q} %d\n", d.Day, d.Files)
+	}
+}
+
+// handleAdminTraceStore GET /admin/tracestore 返回最近一次扫描结果，?rescan=1 立即重新扫描
+func handleAdminTraceStore(w http.ResponseWriter, r *http.Request) {
+	local, ok := baseTraceStore(traces).(tracestore.Local)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]string{"store": traces.String(), "error": "capacity stats are only collected for local trace directories"})
+		return
+	}
+	if r.URL.Query().Get("rescan") == "1" || traceStats.snapshot() == nil {
+		traceStats.set(scanTraceDir(local.Dir, time.Now()))
+	}
+	writeJSON(w, http.StatusOK, traceStats.snapshot())
+}
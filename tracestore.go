@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourname/proxy/tracestore"
+)
+
+// TRACE_STORE 可以是本地目录、s3://bucket/prefix 或 gs://bucket/prefix，由 New 打开；
+// Config.TraceStore 不为 nil 时不读取 TRACE_STORE
+var (
+	traces TraceStore = instrumentedTraceStore{tracestore.Local{Dir: traceDir, MaxBytes: traceMaxBytes}}
+
+	traceStoreClient = &http.Client{
+		Transport: upstreamClient.Transport,
+		Timeout:   envDuration("TRACE_STORE_TIMEOUT", 30*time.Second),
+	}
+)
+
+// TraceStore 旧名字，实现见 tracestore 包
+type TraceStore = tracestore.Store
+
+func openTraceStore(target string) (TraceStore, error) {
+	region := envString("TRACE_S3_REGION", envString("AWS_REGION", "us-east-1"))
+	s, err := tracestore.New(target, tracestore.Options{
+		Client:          traceStoreClient,
+		MaxBytes:        traceMaxBytes,
+		S3Region:        region,
+		S3Endpoint:      envString("TRACE_S3_ENDPOINT", ""),
+		AWSAccessKey:    configValue("AWS_ACCESS_KEY_ID"),
+		AWSSecretKey:    configValue("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken: configValue("AWS_SESSION_TOKEN"),
+		GCSEndpoint:     envString("TRACE_GCS_ENDPOINT", ""),
+		GCSToken:        func() string { return configValue("GCS_ACCESS_TOKEN") },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRACE_STORE %q: %w", target, err)
+	}
+	return instrumentedTraceStore{s}, nil
+}
+
+// parseTxIDParam 交易 ID 会拼进文件路径和对象名，只接受 64 位十六进制（可带 0x），统一转成小写
+func parseTxIDParam(raw json.RawMessage) (string, error) {
+	var s string
+	if err := codec.Unmarshal(raw, &s); err != nil {
+		return "", tracestore.ErrInvalidID
+	}
+	s = strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if !tracestore.ValidID(s) {
+		return "", tracestore.ErrInvalidID
+	}
+	return s, nil
+}
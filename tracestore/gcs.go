@@ -0,0 +1,183 @@
+package tracestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsStore 使用 GCS JSON API。凭证优先用 Options.GCSToken，
+// 否则在 GCE/GKE 上从 metadata server 获取，都没有时匿名访问
+type gcsStore struct {
+	bucket     string
+	prefix     string
+	endpoint   string
+	client     *http.Client
+	maxBytes   int64
+	fixedToken func() string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newGCSStore(bucket, prefix string, opts Options) (*gcsStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket")
+	}
+	endpoint := opts.GCSEndpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	return &gcsStore{
+		bucket:     bucket,
+		prefix:     prefix,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		client:     opts.client(),
+		maxBytes:   opts.MaxBytes,
+		fixedToken: opts.GCSToken,
+	}, nil
+}
+
+func (s *gcsStore) String() string {
+	return "gs://" + s.bucket + "/" + s.prefix
+}
+
+func (s *gcsStore) accessToken(ctx context.Context) string {
+	if s.fixedToken != nil {
+		if t := s.fixedToken(); t != "" {
+			return t
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return s.token
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&tok) != nil {
+		log.Printf("GCS metadata token request failed (HTTP %d), using anonymous access", resp.StatusCode)
+		return ""
+	}
+	// 提前一分钟刷新
+	s.token = tok.AccessToken
+	s.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return s.token
+}
+
+func (s *gcsStore) do(ctx context.Context, method, rawURL string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if t := s.accessToken(ctx); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+	return s.client.Do(req)
+}
+
+func (s *gcsStore) Get(ctx context.Context, txID string) ([]byte, error) {
+	if !ValidID(txID) {
+		return nil, ErrInvalidID
+	}
+	object := url.PathEscape(s.prefix + txID + ".json")
+	resp, err := s.do(ctx, http.MethodGet, s.endpoint+"/storage/v1/b/"+url.PathEscape(s.bucket)+"/o/"+object+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, notFound(txID)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("gcs GET %s returned HTTP %d", txID, resp.StatusCode)
+	}
+	return readLimited(resp.Body, s.maxBytes)
+}
+
+func (s *gcsStore) Put(ctx context.Context, txID string, data []byte) error {
+	if !ValidID(txID) {
+		return ErrInvalidID
+	}
+	q := url.Values{"uploadType": {"media"}, "name": {s.prefix + txID + ".json"}}
+	resp, err := s.do(ctx, http.MethodPost, s.endpoint+"/upload/storage/v1/b/"+url.PathEscape(s.bucket)+"/o?"+q.Encode(), data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gcs upload %s returned HTTP %d", txID, resp.StatusCode)
+	}
+	return nil
+}
+
+type gcsListResult struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (s *gcsStore) List(ctx context.Context, since time.Time) ([]string, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		q := url.Values{"prefix": {s.prefix}, "fields": {"items(name,updated),nextPageToken"}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		resp, err := s.do(ctx, http.MethodGet, s.endpoint+"/storage/v1/b/"+url.PathEscape(s.bucket)+"/o?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page gcsListResult
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("gcs list returned HTTP %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Items {
+			name := strings.TrimPrefix(obj.Name, s.prefix)
+			if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") || obj.Updated.Before(since) {
+				continue
+			}
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+		if page.NextPageToken == "" {
+			return ids, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
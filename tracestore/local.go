@@ -0,0 +1,98 @@
+package tracestore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Local 每个 trace 是目录里的 <txID>.json
+type Local struct {
+	Dir      string
+	MaxBytes int64
+}
+
+func (s Local) String() string {
+	return s.Dir
+}
+
+func (s Local) Get(ctx context.Context, txID string) ([]byte, error) {
+	if !ValidID(txID) {
+		return nil, ErrInvalidID
+	}
+	// 校验之外再确认路径没有跳出 trace 目录
+	path := filepath.Join(s.Dir, txID+".json")
+	if rel, err := filepath.Rel(s.Dir, path); err != nil || strings.HasPrefix(rel, "..") || strings.ContainsRune(rel, filepath.Separator) {
+		return nil, ErrInvalidID
+	}
+	if info, err := os.Stat(path); err == nil && s.MaxBytes > 0 && info.Size() > s.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	return readFileContext(ctx, path)
+}
+
+// Put 先写临时文件再改名，读者不会看到写了一半的 trace
+func (s Local) Put(ctx context.Context, txID string, data []byte) error {
+	if !ValidID(txID) {
+		return ErrInvalidID
+	}
+	path := filepath.Join(s.Dir, txID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s Local) List(ctx context.Context, since time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return ids, nil
+}
+
+// readFileContext 分块读取文件，ctx 取消后立即停止
+func readFileContext(ctx context.Context, path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if info, err := f.Stat(); err == nil {
+		buf.Grow(int(info.Size()))
+	}
+	chunk := make([]byte, 256*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := f.Read(chunk)
+		buf.Write(chunk[:n])
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package tracestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Store 直接用 SigV4 签名访问 S3 REST API，不引入 SDK；未配置 AWS 凭证时匿名访问
+type s3Store struct {
+	bucket   string
+	prefix   string
+	region   string
+	endpoint string
+	client   *http.Client
+	maxBytes int64
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func newS3Store(bucket, prefix string, opts Options) (*s3Store, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket")
+	}
+	region := opts.S3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := opts.S3Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &s3Store{
+		bucket:       bucket,
+		prefix:       prefix,
+		region:       region,
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		client:       opts.client(),
+		maxBytes:     opts.MaxBytes,
+		accessKey:    opts.AWSAccessKey,
+		secretKey:    opts.AWSSecretKey,
+		sessionToken: opts.AWSSessionToken,
+	}, nil
+}
+
+func (s *s3Store) String() string {
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+func (s *s3Store) Get(ctx context.Context, txID string) ([]byte, error) {
+	if !ValidID(txID) {
+		return nil, ErrInvalidID
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectPath(txID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, notFound(txID)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("s3 GET %s returned HTTP %d", txID, resp.StatusCode)
+	}
+	return readLimited(resp.Body, s.maxBytes)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Store) List(ctx context.Context, since time.Time) ([]string, error) {
+	var ids []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "/"+s.bucket, q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page s3ListResult
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("s3 list returned HTTP %d", resp.StatusCode)
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(obj.Key, s.prefix)
+			if strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") || obj.LastModified.Before(since) {
+				continue
+			}
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return ids, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *s3Store) objectPath(txID string) string {
+	return "/" + s.bucket + "/" + s.prefix + txID + ".json"
+}
+
+func (s *s3Store) Put(ctx context.Context, txID string, data []byte) error {
+	if !ValidID(txID) {
+		return ErrInvalidID
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectPath(txID), nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 PUT %s returned HTTP %d", txID, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *s3Store) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path
+	u.RawPath = awsURIEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.accessKey != "" {
+		s.sign(req, time.Now().UTC())
+	}
+	return s.client.Do(req)
+}
+
+// sign 按 AWS Signature Version 4 签名，payload 不参与签名
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": "UNSIGNED-PAYLOAD",
+		"x-amz-date":           amzDate,
+	}
+	if s.sessionToken != "" {
+		headers["x-amz-security-token"] = s.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsURIEncode 按 SigV4 规则编码，只保留 RFC 3986 的非保留字符
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
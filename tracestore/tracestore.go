@@ -0,0 +1,103 @@
+// Package tracestore 按交易 ID 存取 trace JSON，后端可以是本地目录、S3（或兼容服务）和 GCS
+package tracestore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Store 按交易 ID 读取 trace JSON。不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+type Store interface {
+	Get(ctx context.Context, txID string) ([]byte, error)
+	Put(ctx context.Context, txID string, data []byte) error
+	// List 返回 since 之后修改过的 trace 的交易 ID
+	List(ctx context.Context, since time.Time) ([]string, error)
+	String() string
+}
+
+var (
+	ErrInvalidID = errors.New("txId must be a 32-byte hex transaction hash")
+	// ErrTooLarge trace 超过 Options.MaxBytes
+	ErrTooLarge = errors.New("response too large, narrow your query")
+)
+
+// Options 零值可用：默认 HTTP 客户端、不限大小、S3 用 us-east-1 匿名访问
+type Options struct {
+	Client   *http.Client
+	MaxBytes int64
+
+	// S3Endpoint 可指向 MinIO 等兼容服务，为空时按 region 使用 AWS
+	S3Region        string
+	S3Endpoint      string
+	AWSAccessKey    string
+	AWSSecretKey    string
+	AWSSessionToken string
+
+	GCSEndpoint string
+	// GCSToken 每次请求前调用，返回空字符串时从 metadata server 获取
+	GCSToken func() string
+}
+
+func (o Options) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return http.DefaultClient
+}
+
+// New 按 target 选择后端：本地目录（可带 file://）、s3://bucket/prefix 或 gs://bucket/prefix
+func New(target string, opts Options) (Store, error) {
+	switch {
+	case strings.HasPrefix(target, "s3://"):
+		bucket, prefix := splitBucketPath(strings.TrimPrefix(target, "s3://"))
+		return newS3Store(bucket, prefix, opts)
+	case strings.HasPrefix(target, "gs://"):
+		bucket, prefix := splitBucketPath(strings.TrimPrefix(target, "gs://"))
+		return newGCSStore(bucket, prefix, opts)
+	case strings.Contains(target, "://") && !strings.HasPrefix(target, "file://"):
+		return nil, fmt.Errorf("unsupported scheme")
+	}
+	return Local{Dir: strings.TrimPrefix(target, "file://"), MaxBytes: opts.MaxBytes}, nil
+}
+
+func splitBucketPath(s string) (bucket, prefix string) {
+	bucket, prefix, _ = strings.Cut(s, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix
+}
+
+// ValidID 交易 ID 会拼进文件路径和对象名，只接受 64 位小写或大写十六进制，不带 0x
+func ValidID(txID string) bool {
+	if len(txID) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(txID)
+	return err == nil
+}
+
+func notFound(txID string) error {
+	return fmt.Errorf("trace %s: %w", txID, fs.ErrNotExist)
+}
+
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
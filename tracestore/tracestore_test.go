@@ -0,0 +1,202 @@
+package tracestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	txA = "abababababababababababababababababababababababababababababababab"
+	txB = "cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		target string
+		want   string
+	}{
+		{"/var/traces", "/var/traces"},
+		{"file:///var/traces", "/var/traces"},
+		{"s3://bucket/a/b", "s3://bucket/a/b/"},
+		{"s3://bucket", "s3://bucket/"},
+		{"gs://bucket/traces/", "gs://bucket/traces/"},
+	}
+	for _, c := range cases {
+		s, err := New(c.target, Options{})
+		if err != nil || s.String() != c.want {
+			t.Errorf("New(%q) = %v, %v; want %s", c.target, s, err, c.want)
+		}
+	}
+	for _, target := range []string{"http://example.com", "s3://", "gs:///prefix"} {
+		if _, err := New(target, Options{}); err == nil {
+			t.Errorf("New(%q) should fail", target)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := Local{Dir: dir, MaxBytes: 16}
+
+	if _, err := s.Get(ctx, txA); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Get missing = %v, want ErrNotExist", err)
+	}
+	if err := s.Put(ctx, txA, []byte(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(ctx, txA)
+	if err != nil || string(data) != `{"ok":true}` {
+		t.Fatalf("Get = %s, %v", data, err)
+	}
+	if err := s.Put(ctx, txB, []byte(`{"padding":"more than sixteen bytes"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, txB); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Get large = %v, want ErrTooLarge", err)
+	}
+	for _, id := range []string{"../etc/passwd", "0x" + txA[2:], txA[:63]} {
+		if _, err := s.Get(ctx, id); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Get(%q) = %v, want ErrInvalidID", id, err)
+		}
+		if err := s.Put(ctx, id, nil); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidID", id, err)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, txB+".json"), old, old)
+	ids, err := s.List(ctx, time.Time{})
+	sort.Strings(ids)
+	if err != nil || strings.Join(ids, ",") != txA+","+txB {
+		t.Errorf("List all = %v, %v", ids, err)
+	}
+	ids, _ = s.List(ctx, time.Now().Add(-time.Minute))
+	if len(ids) != 1 || ids[0] != txA {
+		t.Errorf("List since = %v", ids)
+	}
+}
+
+func TestS3(t *testing.T) {
+	var lastAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastAuth = r.Header.Get("Authorization")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/bucket/traces/"+txA+".json":
+			io.WriteString(w, `{"tx":"a"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/bucket" && r.URL.Query().Get("list-type") == "2":
+			if r.URL.Query().Get("continuation-token") == "" {
+				io.WriteString(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken>
+<Contents><Key>traces/`+txA+`.json</Key><LastModified>2024-01-02T00:00:00Z</LastModified></Contents>
+<Contents><Key>traces/sub/`+txB+`.json</Key><LastModified>2024-01-02T00:00:00Z</LastModified></Contents>
+</ListBucketResult>`)
+				return
+			}
+			io.WriteString(w, `<ListBucketResult><IsTruncated>false</IsTruncated>
+<Contents><Key>traces/`+txB+`.json</Key><LastModified>2023-01-01T00:00:00Z</LastModified></Contents>
+</ListBucketResult>`)
+		case r.Method == http.MethodPut:
+			io.Copy(io.Discard, r.Body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := New("s3://bucket/traces", Options{S3Endpoint: srv.URL, AWSAccessKey: "AKID", AWSSecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(ctx, txA)
+	if err != nil || string(data) != `{"tx":"a"}` {
+		t.Fatalf("Get = %s, %v", data, err)
+	}
+	if !strings.HasPrefix(lastAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(lastAuth, "/us-east-1/s3/aws4_request") {
+		t.Errorf("Authorization = %q", lastAuth)
+	}
+	if _, err := s.Get(ctx, txB); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get missing = %v, want ErrNotExist", err)
+	}
+	if err := s.Put(ctx, txB, []byte(`{}`)); err != nil {
+		t.Errorf("Put = %v", err)
+	}
+	ids, err := s.List(ctx, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(ids) != 1 || ids[0] != txA {
+		t.Errorf("List = %v, %v", ids, err)
+	}
+
+	// 没有凭证时匿名访问
+	anon, _ := New("s3://bucket/traces", Options{S3Endpoint: srv.URL})
+	anon.Get(ctx, txA)
+	if lastAuth != "" {
+		t.Errorf("anonymous Authorization = %q", lastAuth)
+	}
+}
+
+func TestGCS(t *testing.T) {
+	var lastAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastAuth = r.Header.Get("Authorization")
+		switch {
+		case r.URL.Path == "/storage/v1/b/bucket/o/traces/"+txA+".json" && r.URL.Query().Get("alt") == "media":
+			io.WriteString(w, `{"tx":"a"}`)
+		case r.URL.Path == "/storage/v1/b/bucket/o":
+			io.WriteString(w, `{"items":[
+{"name":"traces/`+txA+`.json","updated":"2024-01-02T00:00:00Z"},
+{"name":"traces/`+txB+`.json","updated":"2023-01-01T00:00:00Z"},
+{"name":"traces/readme.txt","updated":"2024-01-02T00:00:00Z"}]}`)
+		case r.URL.Path == "/upload/storage/v1/b/bucket/o" && r.URL.Query().Get("name") == "traces/"+txB+".json":
+			io.Copy(io.Discard, r.Body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := New("gs://bucket/traces", Options{GCSEndpoint: srv.URL, GCSToken: func() string { return "tok" }, MaxBytes: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(ctx, txA)
+	if err != nil || string(data) != `{"tx":"a"}` {
+		t.Fatalf("Get = %s, %v", data, err)
+	}
+	if lastAuth != "Bearer tok" {
+		t.Errorf("Authorization = %q", lastAuth)
+	}
+	if _, err := s.Get(ctx, txB); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get missing = %v, want ErrNotExist", err)
+	}
+	if err := s.Put(ctx, txB, []byte(`{}`)); err != nil {
+		t.Errorf("Put = %v", err)
+	}
+	ids, err := s.List(ctx, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(ids) != 1 || ids[0] != txA {
+		t.Errorf("List = %v, %v", ids, err)
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	if _, err := readLimited(strings.NewReader("12345"), 4); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over limit = %v", err)
+	}
+	if data, err := readLimited(strings.NewReader("1234"), 4); err != nil || string(data) != "1234" {
+		t.Errorf("at limit = %s, %v", data, err)
+	}
+	if data, err := readLimited(strings.NewReader("12345"), 0); err != nil || len(data) != 5 {
+		t.Errorf("no limit = %s, %v", data, err)
+	}
+}
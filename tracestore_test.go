@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 交易 ID 会拼进 trace 文件路径，构造的 ID 不能读到 trace 目录以外的文件，返回 -32602
+func TestTraceIDTraversalRejected(t *testing.T) {
+	withTestNode(t)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{"secret":true}`), 0o644)
+	traceDir := filepath.Join(dir, "traces")
+	os.Mkdir(traceDir, 0o755)
+	txID := strings.Repeat("ab", 32)
+	os.WriteFile(filepath.Join(traceDir, txID+".json"), []byte(`{"trace":true}`), 0o644)
+	traces, _ = openTraceStore(traceDir)
+
+	call := func(method, id string) (json.RawMessage, int) {
+		var resp struct {
+			Result json.RawMessage
+			Error  *struct{ Code int }
+		}
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":[%q]}`, method, id)
+		if err := json.Unmarshal(postJSONRPC(t, body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != nil {
+			return nil, resp.Error.Code
+		}
+		return resp.Result, 0
+	}
+	for _, method := range []string{"eth_debugTransactionTrace", "debug_traceTransaction"} {
+		for _, id := range []string{"../secret", "../../etc/passwd", "..%2fsecret", "/etc/passwd", txID[:62] + "/.", txID + "/../../secret"} {
+			result, code := call(method, id)
+			if code != -32602 {
+				t.Errorf("%s(%q): code %d, result %s, want -32602", method, id, code, result)
+			}
+			if strings.Contains(string(result), "secret") {
+				t.Errorf("%s(%q) read a file outside the trace directory", method, id)
+			}
+		}
+	}
+	// 带 0x 前缀、大写的合法 ID 仍然能读到
+	if result, code := call("eth_debugTransactionTrace", "0x"+strings.ToUpper(txID)); code != 0 || string(result) != `{"trace":true}` {
+		t.Errorf("valid ID: code %d, result %s", code, result)
+	}
+}
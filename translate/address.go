@@ -0,0 +1,117 @@
+// Package translate 是 TRON 与以太坊 JSON-RPC 之间不依赖网络和配置的转换：地址格式、数量编码、
+// 日志过滤和 logsBloom、eth_call 调用对象和 revert 原因、callTracer 格式的调用帧
+package translate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// AddressPrefix TRON 地址为 0x41 前缀 + 20 字节
+const AddressPrefix = 0x41
+
+var ErrInvalidAddress = errors.New("invalid TRON address")
+
+func Base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func Base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, ErrInvalidAddress
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+	out := n.Bytes()
+	for _, c := range s {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		out = append([]byte{0}, out...)
+	}
+	return out, nil
+}
+
+func checksum(b []byte) []byte {
+	h1 := sha256.Sum256(b)
+	h2 := sha256.Sum256(h1[:])
+	return h2[:4]
+}
+
+func Base58CheckEncode(b []byte) string {
+	return Base58Encode(append(append([]byte{}, b...), checksum(b)...))
+}
+
+// DecodeAddress 接受 base58 (T...)、41 开头的 hex 或 0x 开头的 20 字节 hex，返回 21 字节地址
+func DecodeAddress(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case len(s) == 34 && s[0] == 'T':
+		raw, err := Base58Decode(s)
+		if err != nil || len(raw) != 25 {
+			return nil, ErrInvalidAddress
+		}
+		if !bytes.Equal(checksum(raw[:21]), raw[21:]) || raw[0] != AddressPrefix {
+			return nil, ErrInvalidAddress
+		}
+		return raw[:21], nil
+	case len(s) == 42 && (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")):
+		b, err := hex.DecodeString(s[2:])
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+		return append([]byte{AddressPrefix}, b...), nil
+	case len(s) == 42 && strings.HasPrefix(s, "41"):
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+		return b, nil
+	}
+	return nil, ErrInvalidAddress
+}
+
+func ToBase58Address(s string) (string, error) {
+	b, err := DecodeAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return Base58CheckEncode(b), nil
+}
+
+// ToEthAddress 返回去掉 0x41 前缀的 0x 形式地址
+func ToEthAddress(s string) (string, error) {
+	b, err := DecodeAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(b[1:]), nil
+}
@@ -0,0 +1,25 @@
+package translate
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// IsHexString 0x 加 digits 位 hex
+func IsHexString(s string, digits int) bool {
+	if len(s) != digits+2 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// BlockHashNumber TRON 的区块 ID 前 8 字节是区块高度
+func BlockHashNumber(hash string) (int64, bool) {
+	if !IsHexString(hash, 64) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(hash[2:18], 16, 63)
+	return int64(n), err == nil
+}
@@ -0,0 +1,113 @@
+package translate
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ZeroAddress 没有 from 时用零地址作为调用方，与 java-tron 的 eth_call 一致
+const ZeroAddress = "410000000000000000000000000000000000000000"
+
+var (
+	revertErrorSelector = []byte{0x08, 0xc3, 0x79, 0xa0} // Error(string)
+	revertPanicSelector = []byte{0x4e, 0x48, 0x7b, 0x71} // Panic(uint256)
+)
+
+// CallObject eth_call / eth_estimateGas 的调用对象
+type CallObject struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Data  string `json:"data"`
+	Input string `json:"input"`
+	Value string `json:"value"`
+}
+
+// TriggerPayload 把 eth_call 的调用对象转成 triggerconstantcontract 的请求体。
+// 只处理合约调用（有 to 和 data），部署合约和普通转账返回 false，交给节点处理
+func TriggerPayload(raw json.RawMessage) (map[string]interface{}, bool, error) {
+	var call CallObject
+	if err := json.Unmarshal(raw, &call); err != nil {
+		return nil, false, fmt.Errorf("invalid call object: %v", err)
+	}
+	data := call.Input
+	if data == "" {
+		data = call.Data
+	}
+	data = strings.TrimPrefix(strings.TrimPrefix(data, "0x"), "0X")
+	if call.To == "" || data == "" {
+		return nil, false, nil
+	}
+	if _, err := hex.DecodeString(data); err != nil {
+		return nil, false, fmt.Errorf("invalid data: %v", err)
+	}
+	to, err := DecodeAddress(call.To)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid to address %q", call.To)
+	}
+	owner := ZeroAddress
+	if call.From != "" {
+		from, err := DecodeAddress(call.From)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid from address %q", call.From)
+		}
+		owner = hex.EncodeToString(from)
+	}
+	payload := map[string]interface{}{
+		"owner_address":    owner,
+		"contract_address": hex.EncodeToString(to),
+		"data":             data,
+		"visible":          false,
+	}
+	// value 按 sun 处理，与 java-tron 的 jsonrpc 一致
+	if call.Value != "" {
+		v, err := ParseQuantity(call.Value)
+		if err != nil || v < 0 {
+			return nil, false, fmt.Errorf("invalid value %q", call.Value)
+		}
+		if v > 0 {
+			payload["call_value"] = v
+		}
+	}
+	return payload, true, nil
+}
+
+// DecodeRevertReason 解码 Error(string) 和 Panic(uint256)，自定义错误无法解码，返回空串
+func DecodeRevertReason(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	sel, body := data[:4], data[4:]
+	switch {
+	case string(sel) == string(revertErrorSelector) && len(body) >= 64:
+		offset, ok := abiWord(body, 0)
+		if !ok || offset > uint64(len(body))-32 {
+			return ""
+		}
+		n, ok := abiWord(body, offset)
+		if !ok || n > uint64(len(body))-offset-32 {
+			return ""
+		}
+		return string(body[offset+32 : offset+32+n])
+	case string(sel) == string(revertPanicSelector) && len(body) == 32:
+		code, _ := abiWord(body, 0)
+		return fmt.Sprintf("panic code 0x%x", code)
+	}
+	return ""
+}
+
+// abiWord 读取 off 处的 32 字节整数，超出 uint64 的视为无效
+func abiWord(b []byte, off uint64) (uint64, bool) {
+	if off > uint64(len(b)) || uint64(len(b))-off < 32 {
+		return 0, false
+	}
+	w := b[off : off+32]
+	for _, c := range w[:24] {
+		if c != 0 {
+			return 0, false
+		}
+	}
+	return binary.BigEndian.Uint64(w[24:]), true
+}
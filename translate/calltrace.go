@@ -0,0 +1,122 @@
+package translate
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// TxInfo gettransactioninfobyid / gettransactioninfobyblocknum 里转换 trace 需要的字段
+type TxInfo struct {
+	ID              string   `json:"id"`
+	ContractAddress string   `json:"contract_address"`
+	ContractResult  []string `json:"contractResult"`
+	Result          string   `json:"result"`
+	ResMessage      string   `json:"resMessage"`
+	Receipt         struct {
+		EnergyUsageTotal int64  `json:"energy_usage_total"`
+		Result           string `json:"result"`
+	} `json:"receipt"`
+	InternalTransactions []InternalTx `json:"internal_transactions"`
+}
+
+// InternalTx note 为 hex 编码的 call/create/suicide；callValueInfo 中带 tokenId 的是 TRC10 转账
+type InternalTx struct {
+	Hash              string `json:"hash"`
+	CallerAddress     string `json:"caller_address"`
+	TransferToAddress string `json:"transferTo_address"`
+	CallValueInfo     []struct {
+		CallValue int64  `json:"callValue"`
+		TokenID   string `json:"tokenId"`
+	} `json:"callValueInfo"`
+	Note     string `json:"note"`
+	Rejected bool   `json:"rejected"`
+}
+
+// CallFrame geth callTracer 的输出格式
+type CallFrame struct {
+	Type         string       `json:"type"`
+	From         string       `json:"from"`
+	To           string       `json:"to,omitempty"`
+	Value        string       `json:"value,omitempty"`
+	Gas          string       `json:"gas"`
+	GasUsed      string       `json:"gasUsed"`
+	Input        string       `json:"input"`
+	Output       string       `json:"output,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	RevertReason string       `json:"revertReason,omitempty"`
+	Calls        []*CallFrame `json:"calls,omitempty"`
+}
+
+// NestInternalTxs 调用方是栈中某一帧的 to 时作为它的子调用，否则回退到外层，找不到时挂在根节点下
+func NestInternalTxs(root *CallFrame, internals []InternalTx) {
+	stack := []*CallFrame{root}
+	for _, it := range internals {
+		f := &CallFrame{Type: internalTxType(it.Note), Gas: "0x0", GasUsed: "0x0", Input: "0x",
+			From: EthAddressOrEmpty(it.CallerAddress), To: EthAddressOrEmpty(it.TransferToAddress)}
+		var value int64
+		for _, cv := range it.CallValueInfo {
+			if cv.TokenID == "" {
+				value += cv.CallValue
+			}
+		}
+		f.Value = HexQuantity(value)
+		if it.Rejected {
+			f.Error = "rejected"
+		}
+		for len(stack) > 1 && !strings.EqualFold(stack[len(stack)-1].To, f.From) {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.Calls = append(parent.Calls, f)
+		if f.Type != "SELFDESTRUCT" {
+			stack = append(stack, f)
+		}
+	}
+}
+
+func internalTxType(note string) string {
+	b, err := hex.DecodeString(note)
+	if err != nil {
+		b = []byte(note)
+	}
+	switch strings.ToLower(string(b)) {
+	case "call", "":
+		return "CALL"
+	case "create":
+		return "CREATE"
+	case "suicide":
+		return "SELFDESTRUCT"
+	}
+	return strings.ToUpper(string(b))
+}
+
+// ReceiptResultError 把 receipt.result 映射为 geth 的错误文字，工具常按这些文字分类
+func ReceiptResultError(r string) string {
+	switch r {
+	case "REVERT":
+		return "execution reverted"
+	case "OUT_OF_ENERGY", "OUT_OF_TIME":
+		return "out of gas"
+	case "ILLEGAL_OPERATION":
+		return "invalid opcode"
+	case "STACK_TOO_SMALL":
+		return "stack underflow"
+	case "STACK_TOO_LARGE", "STACK_OVERFLOW":
+		return "stack limit reached"
+	case "TRANSFER_FAILED":
+		return "insufficient balance for transfer"
+	}
+	return strings.ToLower(strings.ReplaceAll(r, "_", " "))
+}
+
+// EthAddressOrEmpty 转成以太坊格式，空串或无效地址返回空串
+func EthAddressOrEmpty(s string) string {
+	if s == "" {
+		return ""
+	}
+	a, err := ToEthAddress(s)
+	if err != nil {
+		return ""
+	}
+	return a
+}
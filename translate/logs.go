@@ -0,0 +1,163 @@
+package translate
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// BloomBytes 以太坊 logsBloom 的长度
+const BloomBytes = 256
+
+// LogFilter eth_getLogs / eth_newFilter 的过滤条件
+type LogFilter struct {
+	FromBlock json.RawMessage   `json:"fromBlock"`
+	ToBlock   json.RawMessage   `json:"toBlock"`
+	BlockHash string            `json:"blockHash"`
+	Address   json.RawMessage   `json:"address"`
+	Topics    []json.RawMessage `json:"topics"`
+
+	addresses map[string]bool // 20 字节 hex，小写不带 0x
+	topics    [][]string      // nil 表示该位置不限
+}
+
+// Log eth_getLogs 返回的一条日志
+type Log struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+// ParseLogFilter address 可以是单个地址或数组（TRON 或以太坊格式），topics 每个位置可以是 null、单个值或备选数组
+func ParseLogFilter(raw json.RawMessage) (*LogFilter, error) {
+	var f LogFilter
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("filter must be an object")
+	}
+	if len(f.Address) > 0 && string(f.Address) != "null" {
+		var list []string
+		var one string
+		if json.Unmarshal(f.Address, &one) == nil {
+			list = []string{one}
+		} else if err := json.Unmarshal(f.Address, &list); err != nil {
+			return nil, fmt.Errorf("address must be a string or an array of strings")
+		}
+		f.addresses = make(map[string]bool, len(list))
+		for _, a := range list {
+			hexAddr, err := ToEthAddress(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", a)
+			}
+			f.addresses[hexAddr[2:]] = true
+		}
+	}
+	for _, t := range f.Topics {
+		if string(t) == "null" {
+			f.topics = append(f.topics, nil)
+			continue
+		}
+		var one string
+		var list []*string
+		var alts []string
+		if json.Unmarshal(t, &one) == nil {
+			alts = []string{NormalizeTopic(one)}
+		} else if err := json.Unmarshal(t, &list); err == nil {
+			for _, s := range list {
+				if s == nil {
+					// 数组里的 null 等同于不限
+					alts = nil
+					break
+				}
+				alts = append(alts, NormalizeTopic(*s))
+			}
+		} else {
+			return nil, fmt.Errorf("topics must be null, a string or an array of strings")
+		}
+		f.topics = append(f.topics, alts)
+	}
+	return &f, nil
+}
+
+// NormalizeTopic 小写、不带 0x
+func NormalizeTopic(s string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+}
+
+// Addresses 过滤的合约地址（20 字节 hex，小写不带 0x），不限地址时为 nil
+func (f *LogFilter) Addresses() []string {
+	if f.addresses == nil {
+		return nil
+	}
+	out := make([]string, 0, len(f.addresses))
+	for a := range f.addresses {
+		out = append(out, a)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Matches address 为 20 字节 hex（不带 0x），topics 带不带 0x 都可以
+func (f *LogFilter) Matches(address string, topics []string) bool {
+	if f.addresses != nil && !f.addresses[strings.ToLower(address)] {
+		return false
+	}
+	if len(f.topics) > len(topics) {
+		return false
+	}
+	for i, alts := range f.topics {
+		if alts == nil {
+			continue
+		}
+		found := false
+		for _, a := range alts {
+			if a == NormalizeTopic(topics[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// LogsBloom 按以太坊的规则（每个地址和 topic 的 keccak256 取 3 个 11 位）计算 logsBloom
+func LogsBloom(logs []Log) string {
+	var bloom [BloomBytes]byte
+	add := func(h string) {
+		b, err := hex.DecodeString(strings.TrimPrefix(h, "0x"))
+		if err != nil || len(b) == 0 {
+			return
+		}
+		k := sha3.NewLegacyKeccak256()
+		k.Write(b)
+		sum := k.Sum(nil)
+		for i := 0; i < 6; i += 2 {
+			bit := (uint(sum[i])<<8 | uint(sum[i+1])) & 2047
+			bloom[BloomBytes-1-bit/8] |= 1 << (bit % 8)
+		}
+	}
+	for _, l := range logs {
+		add(l.Address)
+		for _, t := range l.Topics {
+			add(t)
+		}
+	}
+	return "0x" + hex.EncodeToString(bloom[:])
+}
+
+// ValidBloom 0x 加 256 字节 hex
+func ValidBloom(s string) bool {
+	return IsHexString(s, 2*BloomBytes)
+}
@@ -0,0 +1,20 @@
+package translate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseQuantity 接受十进制或 0x 十六进制字符串
+func ParseQuantity(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseInt(s[2:], 16, 64)
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// HexQuantity 按 JSON-RPC 的 quantity 编码，不带前导零
+func HexQuantity(n int64) string {
+	return "0x" + strconv.FormatInt(n, 16)
+}
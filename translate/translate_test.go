@@ -0,0 +1,194 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// USDT 合约，两种格式对照
+const (
+	usdtBase58 = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	usdtHex    = "41a614f803b6fd780986a42c78ec9c7f77e6ded13c"
+	usdtEth    = "0xa614f803b6fd780986a42c78ec9c7f77e6ded13c"
+)
+
+func TestAddressFormats(t *testing.T) {
+	for _, in := range []string{usdtBase58, usdtHex, usdtEth, " " + usdtBase58 + " "} {
+		b58, err := ToBase58Address(in)
+		if err != nil || b58 != usdtBase58 {
+			t.Errorf("ToBase58Address(%q) = %q, %v", in, b58, err)
+		}
+		eth, err := ToEthAddress(in)
+		if err != nil || eth != usdtEth {
+			t.Errorf("ToEthAddress(%q) = %q, %v", in, eth, err)
+		}
+	}
+}
+
+func TestDecodeAddressInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u", // 校验和不对
+		"TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj60", // 0 不在字母表里
+		"0xa614f803b6fd780986a42c78ec9c7f77e6ded1zz",
+		"42a614f803b6fd780986a42c78ec9c7f77e6ded13c",
+	} {
+		if _, err := DecodeAddress(in); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("DecodeAddress(%q) error = %v, want ErrInvalidAddress", in, err)
+		}
+	}
+}
+
+func TestBase58RoundTrip(t *testing.T) {
+	for _, in := range [][]byte{{0}, {0, 0, 1, 2}, []byte("hello"), bytes.Repeat([]byte{0xff}, 32)} {
+		out, err := Base58Decode(Base58Encode(in))
+		if err != nil || !bytes.Equal(out, in) {
+			t.Errorf("round trip %x = %x, %v", in, out, err)
+		}
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+	}{
+		{"0x0", 0},
+		{"0x1b4", 436},
+		{"0X1B4", 436},
+		{"436", 436},
+		{" 10 ", 10},
+	}
+	for _, c := range cases {
+		got, err := ParseQuantity(c.in)
+		if err != nil || got != c.want {
+			t.Errorf("ParseQuantity(%q) = %d, %v", c.in, got, err)
+		}
+	}
+	if _, err := ParseQuantity("0xzz"); err == nil {
+		t.Error("ParseQuantity(0xzz) should fail")
+	}
+	if got := HexQuantity(436); got != "0x1b4" {
+		t.Errorf("HexQuantity(436) = %q", got)
+	}
+	if got := HexQuantity(0); got != "0x0" {
+		t.Errorf("HexQuantity(0) = %q", got)
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	f, err := ParseLogFilter(json.RawMessage(`{"address":"` + usdtBase58 + `","topics":[["0xDDF252AD",null],null,"0x01"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Addresses(); len(got) != 1 || got[0] != usdtEth[2:] {
+		t.Errorf("addresses = %v", got)
+	}
+	addr := strings.ToUpper(usdtEth[2:])
+	if !f.Matches(addr, []string{"aa", "bb", "01"}) {
+		t.Error("null inside an alternatives array should match any topic")
+	}
+	if f.Matches(addr, []string{"aa", "bb"}) {
+		t.Error("log with fewer topics than the filter matched")
+	}
+	if f.Matches("00"+addr[2:], []string{"aa", "bb", "01"}) {
+		t.Error("other contract matched")
+	}
+	if (&LogFilter{}).Addresses() != nil || !(&LogFilter{}).Matches("ab", nil) {
+		t.Error("empty filter should match everything")
+	}
+	for _, bad := range []string{`[]`, `{"address":1}`, `{"address":"Tnope"}`, `{"topics":[1]}`} {
+		if _, err := ParseLogFilter(json.RawMessage(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestLogsBloom(t *testing.T) {
+	empty := LogsBloom(nil)
+	if !ValidBloom(empty) || strings.Trim(empty[2:], "0") != "" {
+		t.Errorf("empty bloom = %.20s...", empty)
+	}
+	bloom := LogsBloom([]Log{{Address: usdtEth, Topics: []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"}}})
+	b, _ := hex.DecodeString(bloom[2:])
+	bits := 0
+	for _, c := range b {
+		for ; c != 0; c &= c - 1 {
+			bits++
+		}
+	}
+	// 每个值最多置 3 位
+	if bits == 0 || bits > 6 {
+		t.Errorf("%d bits set", bits)
+	}
+	if ValidBloom("0x00") {
+		t.Error("short bloom accepted")
+	}
+}
+
+func TestDecodeRevertReason(t *testing.T) {
+	errData, _ := hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000b" +
+		"6e6f2062616c616e636521000000000000000000000000000000000000000000")
+	if got := DecodeRevertReason(errData); got != "no balance!" {
+		t.Errorf("Error(string) = %q", got)
+	}
+	panicData, _ := hex.DecodeString("4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011")
+	if got := DecodeRevertReason(panicData); got != "panic code 0x11" {
+		t.Errorf("Panic(uint256) = %q", got)
+	}
+	// 长度越界的 offset 不能 panic
+	bad := append([]byte{0x08, 0xc3, 0x79, 0xa0}, bytes.Repeat([]byte{0xff}, 64)...)
+	if got := DecodeRevertReason(bad); got != "" {
+		t.Errorf("malformed data decoded as %q", got)
+	}
+}
+
+func TestTriggerPayload(t *testing.T) {
+	payload, ok, err := TriggerPayload(json.RawMessage(`{"to":"` + usdtEth + `","data":"0x70a08231","value":"0x10"}`))
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+	if payload["owner_address"] != ZeroAddress || payload["contract_address"] != usdtHex || payload["data"] != "70a08231" || payload["call_value"] != int64(16) {
+		t.Errorf("payload = %v", payload)
+	}
+	// 转账和部署交给节点
+	if _, ok, err := TriggerPayload(json.RawMessage(`{"to":"` + usdtEth + `","value":"0x1"}`)); ok || err != nil {
+		t.Errorf("transfer: ok=%v err=%v", ok, err)
+	}
+	if _, _, err := TriggerPayload(json.RawMessage(`{"to":"` + usdtEth + `","data":"0xzz"}`)); err == nil {
+		t.Error("invalid data accepted")
+	}
+}
+
+func TestNestInternalTxs(t *testing.T) {
+	a, b, c := usdtHex, "41"+strings.Repeat("b", 40), "41"+strings.Repeat("c", 40)
+	root := &CallFrame{Type: "CALL", From: EthAddressOrEmpty(c), To: EthAddressOrEmpty(a)}
+	note := hex.EncodeToString([]byte("call"))
+	NestInternalTxs(root, []InternalTx{
+		{CallerAddress: a, TransferToAddress: b, Note: note},
+		{CallerAddress: b, TransferToAddress: c, Note: note},
+		// 调用方回到 a：作为根调用的第二个子调用
+		{CallerAddress: a, TransferToAddress: c, Note: hex.EncodeToString([]byte("suicide"))},
+	})
+	if len(root.Calls) != 2 || len(root.Calls[0].Calls) != 1 || root.Calls[1].Type != "SELFDESTRUCT" {
+		t.Errorf("calls nested wrong: %d top-level", len(root.Calls))
+	}
+	if got := ReceiptResultError("OUT_OF_ENERGY"); got != "out of gas" {
+		t.Errorf("OUT_OF_ENERGY = %q", got)
+	}
+}
+
+func TestBlockHashNumber(t *testing.T) {
+	if n, ok := BlockHashNumber("0x0000000003a2f1b4" + strings.Repeat("ab", 24)); !ok || n != 0x3a2f1b4 {
+		t.Errorf("got %d, %v", n, ok)
+	}
+	if _, ok := BlockHashNumber("0x1234"); ok {
+		t.Error("short hash accepted")
+	}
+}
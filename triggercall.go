@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/yourname/proxy/translate"
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	// TRIGGER_CALL=local 时 eth_call / eth_estimateGas 由代理翻译成 /wallet/triggerconstantcontract，
+	// 节点自带的 jsonrpc 模块对 revert 和 energy 的处理不一致；off 直接透传
+	triggerCallMode = envString("TRIGGER_CALL", "local")
+	// energy 按 1:1 换算成 gas，动态 energy 模型下实际消耗可能更高，可以乘一个系数留余量
+	estimateGasMultiplier = envFloat("ESTIMATE_GAS_MULTIPLIER", 1.0)
+)
+
+type triggerConstantResponse struct {
+	Result struct {
+		Result  bool   `json:"result"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"result"`
+	EnergyUsed     int64    `json:"energy_used"`
+	ConstantResult []string `json:"constant_result"`
+	Transaction    struct {
+		Ret []struct {
+			ContractRet string `json:"contractRet"`
+		} `json:"ret"`
+		RawDataHex string `json:"raw_data_hex"`
+	} `json:"transaction"`
+}
+
+func triggerCallLocal() bool {
+	return triggerCallMode != "off" && len(restUpstreams.list()) > 0
+}
+
+// isLatestBlockParam 参数缺省、latest 或 pending 都在最新状态上执行；triggerconstantcontract 只能读最新状态
+func isLatestBlockParam(params []json.RawMessage, idx int) bool {
+	if len(params) <= idx {
+		return true
+	}
+	var tag string
+	if codec.Unmarshal(params[idx], &tag) != nil {
+		return false
+	}
+	return tag == "latest" || tag == "pending"
+}
+
+// triggerConstant 执行调用，失败时返回已经组装好的 JSON-RPC 错误
+func triggerConstant(ctx context.Context, req JSONRPCRequest, payload map[string]interface{}) (*triggerConstantResponse, *JSONRPCResponse) {
+	var out triggerConstantResponse
+	if err := callRest(ctx, "/wallet/triggerconstantcontract", payload, &out); err != nil {
+		resp := errorResponse(req.ID, err)
+		return nil, &resp
+	}
+	if !out.Result.Result {
+		code := out.Result.Code
+		if code == "" {
+			code = "OTHER_ERROR"
+		}
+		resp := upstreamErrorResponse(up.TronCodeError(code, out.Result.Message), req.ID)
+		return nil, &resp
+	}
+	var result []byte
+	if len(out.ConstantResult) > 0 {
+		result, _ = hex.DecodeString(out.ConstantResult[0])
+	}
+	if len(out.Transaction.Ret) > 0 {
+		switch ret := out.Transaction.Ret[0].ContractRet; ret {
+		case "", "SUCCESS":
+		case "REVERT":
+			resp := revertError(req.ID, result)
+			return nil, &resp
+		default:
+			// OUT_OF_ENERGY、ILLEGAL_OPERATION 等
+			resp := jsonError(req.ID, -32000, strings.ToLower(strings.ReplaceAll(ret, "_", " ")))
+			return nil, &resp
+		}
+	}
+	return &out, nil
+}
+
+// revertError 按 geth 的格式返回：code 3，message 带解码出的原因，data 是原始返回数据
+func revertError(id interface{}, data []byte) JSONRPCResponse {
+	msg := "execution reverted"
+	if reason := translate.DecodeRevertReason(data); reason != "" {
+		msg += ": " + reason
+	}
+	errObj := map[string]interface{}{"code": 3, "message": msg}
+	if len(data) > 0 {
+		errObj["data"] = "0x" + hex.EncodeToString(data)
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: id, Error: errObj}
+}
+
+// localEthCall 返回 false 表示这个调用不适合本地翻译，由调用方转发给节点
+func localEthCall(ctx context.Context, req JSONRPCRequest) (JSONRPCResponse, bool) {
+	if len(req.Params) == 0 {
+		return JSONRPCResponse{}, false
+	}
+	payload, ok, err := translate.TriggerPayload(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error()), true
+	}
+	if !ok {
+		return JSONRPCResponse{}, false
+	}
+	out, errResp := triggerConstant(ctx, req, payload)
+	if errResp != nil {
+		return *errResp, true
+	}
+	result := "0x"
+	if len(out.ConstantResult) > 0 {
+		result += out.ConstantResult[0]
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}, true
+}
+
+// handleEstimateGas 用 triggerconstantcontract 的 energy_used 作为 gas 估算，
+// energy_used 已包含动态 energy 模型的惩罚部分
+func handleEstimateGas(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if !triggerCallLocal() || len(req.Params) == 0 || !isLatestBlockParam(req.Params, 1) {
+		return forwardAndReturn(ctx, req)
+	}
+	payload, ok, err := translate.TriggerPayload(req.Params[0])
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	if !ok {
+		return forwardAndReturn(ctx, req)
+	}
+	out, errResp := triggerConstant(ctx, req, payload)
+	if errResp != nil {
+		return *errResp
+	}
+	gas := int64(math.Ceil(float64(out.EnergyUsed) * estimateGasMultiplier))
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: translate.HexQuantity(gas)}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// TronNode REST (/wallet/*) 返回的区块结构，visible=true 时地址为 base58
+type restBlock struct {
+	BlockID     string `json:"blockID"`
+	BlockHeader struct {
+		RawData struct {
+			Number     int64  `json:"number"`
+			Timestamp  int64  `json:"timestamp"`
+			ParentHash string `json:"parentHash"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+	Transactions []restTransaction `json:"transactions"`
+}
+
+type restTransaction struct {
+	TxID string `json:"txID"`
+	Ret  []struct {
+		ContractRet string `json:"contractRet"`
+	} `json:"ret"`
+	RawData struct {
+		Contract []restContract `json:"contract"`
+	} `json:"raw_data"`
+}
+
+type restContract struct {
+	Type      string `json:"type"`
+	Parameter struct {
+		Value restContractValue `json:"value"`
+	} `json:"parameter"`
+}
+
+type restContractValue struct {
+	OwnerAddress    string `json:"owner_address"`
+	ToAddress       string `json:"to_address"`
+	ContractAddress string `json:"contract_address"`
+	Amount          int64  `json:"amount"`
+	CallValue       int64  `json:"call_value"`
+	AssetName       string `json:"asset_name"`
+	Data            string `json:"data"`
+}
+
+func (tx restTransaction) succeeded() bool {
+	return len(tx.Ret) == 0 || tx.Ret[0].ContractRet == "" || tx.Ret[0].ContractRet == "SUCCESS"
+}
+
+// callRest 调用 TronNode REST 接口并把 JSON 响应解码到 out
+func callRest(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := codec.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := restPool(ctx, payloadBlockNum(payload)).post(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	if resp.Status/100 != 2 {
+		observeUpstreamInvalid(resp.Upstream.label)
+		return upstreamFailure(resp.Upstream, resp.Status, resp.Body)
+	}
+	if err := codec.Unmarshal(resp.Body, out); err != nil {
+		observeUpstreamInvalid(resp.Upstream.label)
+		return upstreamFailure(resp.Upstream, resp.Status, resp.Body)
+	}
+	// 参数错误时节点返回 200 和 {"Error": "..."}
+	if bytes.HasPrefix(bytes.TrimSpace(resp.Body), []byte(`{"Error"`)) {
+		return upstreamFailure(resp.Upstream, resp.Status, resp.Body)
+	}
+	return nil
+}
+
+func getRestBlockByNum(ctx context.Context, num int64) (*restBlock, error) {
+	var block restBlock
+	if err := callRest(ctx, "/wallet/getblockbynum", map[string]interface{}{"num": num, "visible": true}, &block); err != nil {
+		return nil, err
+	}
+	if block.BlockID == "" {
+		return nil, fmt.Errorf("block %d not found", num)
+	}
+	return &block, nil
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+var (
+	// proxy_getTransactionInfoRange 单次最多覆盖的区块数和并发上限
+	txInfoRangeMaxBlocks      = int64(envInt("TXINFO_RANGE_MAX_BLOCKS", 1000))
+	txInfoRangeConcurrency    = envInt("TXINFO_RANGE_CONCURRENCY", 8)
+	txInfoRangeMaxConcurrency = envInt("TXINFO_RANGE_MAX_CONCURRENCY", 32)
+)
+
+// handleGetTransactionInfoRange proxy_getTransactionInfoRange(fromBlock, toBlock, concurrency?)
+// 在代理内部并发调用 gettransactioninfobyblocknum，返回以十进制区块号为键的交易信息数组。
+// 代替客户端几千条的批量请求，结果经过 REST 缓存
+func handleGetTransactionInfoRange(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if len(req.Params) < 2 {
+		return jsonError(req.ID, -32602, "Invalid params: expected [fromBlock, toBlock, concurrency?]")
+	}
+	from, err1 := parseInt64Param(req.Params[0])
+	to, err2 := parseInt64Param(req.Params[1])
+	if err1 != nil || err2 != nil || from < 0 || to < from {
+		return jsonError(req.ID, -32602, "Invalid params: require 0 <= fromBlock <= toBlock")
+	}
+	if to-from+1 > txInfoRangeMaxBlocks {
+		return jsonError(req.ID, -32602, fmt.Sprintf("Invalid params: block range too large (max %d)", txInfoRangeMaxBlocks))
+	}
+	concurrency := txInfoRangeConcurrency
+	if len(req.Params) > 2 && string(req.Params[2]) != "null" {
+		n, err := parseInt64Param(req.Params[2])
+		if err != nil || n <= 0 {
+			return jsonError(req.ID, -32602, "Invalid params: concurrency must be a positive integer")
+		}
+		concurrency = int(n)
+	}
+	// 客户端给的只是提示，不能超过服务端上限
+	if concurrency > txInfoRangeMaxConcurrency {
+		concurrency = txInfoRangeMaxConcurrency
+	}
+
+	n := int(to - from + 1)
+	log.Printf("Transaction info range %d-%d, blocks: %d, concurrency: %d", from, to, n, concurrency)
+	infos := make([]json.RawMessage, n)
+	errs := make([]error, n)
+	workers.run(ctx, n, concurrency, func(i int) {
+		infos[i], errs[i] = getTransactionInfoByBlockNum(ctx, from+int64(i))
+	})
+	if err := ctx.Err(); err != nil {
+		return jsonError(req.ID, -32603, "Request cancelled")
+	}
+
+	result := make(map[string]json.RawMessage, n)
+	for i, err := range errs {
+		num := from + int64(i)
+		if err != nil {
+			log.Printf("Transaction info range error at block %d: %v", num, err)
+			return jsonError(req.ID, -32603, fmt.Sprintf("Internal error at block %d: %v", num, err))
+		}
+		result[strconv.FormatInt(num, 10)] = infos[i]
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: result}
+}
+
+// getTransactionInfoByBlockNum 返回原始 JSON 数组，没有交易的区块节点返回 {}，统一成 []
+func getTransactionInfoByBlockNum(ctx context.Context, num int64) (json.RawMessage, error) {
+	const path = "/wallet/gettransactioninfobyblocknum"
+	body, _ := codec.Marshal(map[string]int64{"num": num})
+	resp, _, err := cachedREST(ctx, path, "", body, func() (*upstreamResponse, error) {
+		return restPool(ctx, num).post(ctx, path, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != 200 {
+		return nil, fmt.Errorf("%s returned HTTP %d", path, resp.Status)
+	}
+	var infos []json.RawMessage
+	if err := codec.Unmarshal(resp.Body, &infos); err != nil {
+		var empty map[string]json.RawMessage
+		if codec.Unmarshal(resp.Body, &empty) != nil || len(empty) > 0 {
+			observeUpstreamInvalid(resp.Upstream.label)
+			return nil, fmt.Errorf("invalid response from TronNode REST")
+		}
+		return json.RawMessage("[]"), nil
+	}
+	return resp.Body, nil
+}
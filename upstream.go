@@ -0,0 +1,500 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	up "github.com/yourname/proxy/upstream"
+)
+
+var (
+	upstreamHealthInterval = envDuration("UPSTREAM_HEALTH_INTERVAL", 10*time.Second)
+	upstreamBackoffBase    = envDuration("UPSTREAM_BACKOFF_BASE", time.Second)
+	upstreamBackoffMax     = envDuration("UPSTREAM_BACKOFF_MAX", time.Minute)
+
+	// 逗号分隔，可配置多个节点
+	jsonrpcUpstreams = newUpstreamPool("jsonrpc", splitList(configValue("TRON_JSONRPC_ENDPOINT")))
+	restUpstreams    = newUpstreamPool("rest", splitList(configValue("TRON_REST_ENDPOINT")))
+
+	healthCheckClient = &http.Client{
+		Transport: expressClient.Transport,
+		Timeout:   envDuration("UPSTREAM_HEALTH_TIMEOUT", 5*time.Second),
+	}
+)
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+type upstream struct {
+	url   string
+	kind  string
+	label string
+	// archive 表示归档节点，见 archive.go
+	role string
+
+	mu       sync.Mutex
+	healthy  bool
+	failures int
+	// 下线后连续成功的次数；transitions 为 UPSTREAM_FLAP_WINDOW 内的上下线时间
+	recoveries  int
+	transitions []time.Time
+	downUntil   time.Time
+	lastCheck   time.Time
+	lastErr     string
+	version     string
+	// 按方法类别统计的延迟 EWMA（毫秒）；pending 为正在进行的请求数（原子操作）
+	latency map[string]float64
+	pending int64
+	// 健康检查得到的最新高度和是否落后于同组节点，见 lag.go（原子操作）
+	head    int64
+	lagging int32
+	// 熔断状态，见 breaker.go
+	breaker up.Breaker
+	// 证书和凭证状态，见 expiry.go
+	certNotAfter time.Time
+	authFailing  bool
+	// 通过 /admin/upstreams/drain 摘除的节点不再接新请求，健康检查照常进行
+	draining bool
+
+	caps upstreamCapabilities
+}
+
+type upstreamResponse struct {
+	Body     []byte
+	Status   int
+	Upstream *upstream
+}
+
+func (u *upstream) available() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.healthy && !time.Now().Before(u.downUntil)
+}
+
+func (u *upstream) retryAt() time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.downUntil
+}
+
+func (u *upstream) markSuccess() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.healthy {
+		// 连续成功足够次数才重新加入轮询，未达到之前不再退避，下一轮健康检查继续探测
+		u.recoveries++
+		if u.recoveries < u.requiredSuccessesLocked(time.Now()) {
+			u.downUntil = time.Time{}
+			return
+		}
+		log.Printf("Upstream %s is healthy again after %d consecutive successes", u.url, u.recoveries)
+		u.transitionLocked(true, "")
+	}
+	if u.circuitLocked(time.Now()) != circuitClosed {
+		log.Printf("Circuit closed for upstream %s", u.url)
+	}
+	u.healthy = true
+	u.failures = 0
+	u.downUntil = time.Time{}
+	u.lastErr = ""
+	u.recoveries = 0
+}
+
+// markFailure 标记节点不可用，连续失败时退避时间指数增长
+func (u *upstream) markFailure(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures++
+	u.recoveries = 0
+	if u.healthy {
+		u.transitionLocked(false, err.Error())
+	}
+	backoff := up.Backoff(upstreamBackoffBase, upstreamBackoffMax, u.failures)
+	u.healthy = false
+	u.downUntil = time.Now().Add(backoff)
+	u.lastErr = err.Error()
+	u.tripLocked()
+	log.Printf("Upstream %s marked down for %s after %d failure(s): %v", u.url, backoff, u.failures, err)
+}
+
+func (u *upstream) post(ctx context.Context, path string, body []byte) (*upstreamResponse, error) {
+	if u.kind == "grpc" {
+		return u.postGRPC(ctx, path, body)
+	}
+	method := upstreamMethod(ctx)
+	var reader io.Reader
+	if method != http.MethodGet {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.url+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setUpstreamHeaders(ctx, req, u)
+	client := upstreamClient
+	if isExpress(ctx) {
+		client = expressClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := readLimited(resp.Body, upstreamMaxResponseBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamResponse{Body: respBody, Status: resp.StatusCode, Upstream: u}, nil
+}
+
+type upstreamPool struct {
+	kind  string
+	role  string
+	nodes atomic.Value // []*upstream，重新加载配置时整体替换
+	next  uint32
+}
+
+func newUpstreamPool(kind string, urls []string) *upstreamPool {
+	p := &upstreamPool{kind: kind}
+	p.setURLs(urls)
+	return p
+}
+
+// name 用于日志和配置重新加载的结果，归档节点加上前缀
+func (p *upstreamPool) name() string {
+	if p.role != "" {
+		return p.role + "-" + p.kind
+	}
+	return p.kind
+}
+
+func (p *upstreamPool) list() []*upstream {
+	nodes, _ := p.nodes.Load().([]*upstream)
+	return nodes
+}
+
+// setURLs 保留仍在列表中的节点及其健康状态和延迟统计，返回新增和移除的 URL。
+// 被移除的节点上正在进行的请求不受影响
+func (p *upstreamPool) setURLs(urls []string) (added, removed []string) {
+	old := make(map[string]*upstream)
+	for _, u := range p.list() {
+		old[u.url] = u
+	}
+	nodes := make([]*upstream, 0, len(urls))
+	for _, raw := range urls {
+		url := strings.TrimRight(raw, "/")
+		if u, ok := old[url]; ok {
+			nodes = append(nodes, u)
+			delete(old, url)
+			continue
+		}
+		nodes = append(nodes, &upstream{url: url, kind: p.kind, role: p.role, label: upstreamLabel(raw), healthy: true})
+		added = append(added, url)
+	}
+	for url := range old {
+		removed = append(removed, url)
+	}
+	sort.Strings(removed)
+	p.nodes.Store(nodes)
+	return added, removed
+}
+
+// pick 在支持该方法的健康节点中按路由策略选择；全部不可用时选最早恢复的节点兜底，
+// 落后的节点只在没有其他节点时兜底
+func (p *upstreamPool) pick(ctx context.Context, method string, tried map[*upstream]bool) *upstream {
+	// 客户端指定的节点不看健康状态，也不换其他节点
+	if u := p.find(upstreamTarget(ctx)); u != nil {
+		if tried[u] {
+			return nil
+		}
+		return u
+	}
+	nodes := p.list()
+	start := int(atomic.AddUint32(&p.next, 1))
+	var candidates []*upstream
+	var fallback *upstream
+	for i := 0; i < len(nodes); i++ {
+		u := nodes[(start+i)%len(nodes)]
+		if tried[u] || u.isDraining() || !u.supports(method) || u.circuit() == circuitOpen {
+			continue
+		}
+		if u.available() && u.servesLag(ctx) {
+			candidates = append(candidates, u)
+			continue
+		}
+		if fallback == nil || u.retryAt().Before(fallback.retryAt()) {
+			fallback = u
+		}
+	}
+	if len(candidates) > 0 {
+		return chooseUpstream(candidates, methodClass(method))
+	}
+	return fallback
+}
+
+func (p *upstreamPool) post(ctx context.Context, path string, body []byte) (*upstreamResponse, error) {
+	if call, ok := grpcRoute(path); ok && p == restUpstreams {
+		if resp, handled, err := postViaGRPC(ctx, call, path, body); handled {
+			return resp, err
+		}
+	}
+	return p.send(ctx, path, "", body)
+}
+
+// send 发送到健康节点，连接错误、超时或 5xx 时换下一个节点重试；所有节点都试过后
+// 对临时故障按退避时间再重试同一批节点，非幂等方法见 shouldRetry
+func (p *upstreamPool) send(ctx context.Context, path, method string, body []byte) (*upstreamResponse, error) {
+	if len(p.list()) == 0 {
+		return nil, fmt.Errorf("no %s upstream configured", p.kind)
+	}
+	attempts := upstreamRetryAttempts
+	if attempts < len(p.list()) {
+		attempts = len(p.list())
+	}
+	tried := make(map[*upstream]bool, len(p.list()))
+	// sendErr 为传输层错误，HTTP 状态码错误时为 nil
+	var lastErr, sendErr error
+	lastStatus, retries := 0, 0
+	blocked := false
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 && !idempotent(method, path) && !requestNotSent(sendErr) {
+			break
+		}
+		u := p.pick(ctx, method, tried)
+		if u == nil && lastErr != nil {
+			// 每个节点都试过了，临时故障退避后重新开始一轮
+			if !shouldRetry(method, path, sendErr, lastStatus) {
+				break
+			}
+			retries++
+			if err := sleepContext(ctx, retryBackoff(retries)); err != nil {
+				return nil, err
+			}
+			tried = make(map[*upstream]bool, len(p.list()))
+			u = p.pick(ctx, method, tried)
+		}
+		if u == nil {
+			if lastErr != nil {
+				break
+			}
+			if blocked || p.circuitOpenAll() {
+				return nil, errCircuitOpen
+			}
+			return nil, errMethodUnsupported
+		}
+		tried[u] = true
+		allowed, probe := u.breakerAllow()
+		if !allowed {
+			// 半开节点已有探测请求在进行
+			blocked = true
+			continue
+		}
+		if attempt > 0 {
+			metricUpstreamRetries.inc(p.kind)
+		}
+		sent := time.Now()
+		u.begin()
+		resp, err := chaosPost(ctx, u, path, method, body)
+		u.end()
+		u.breakerDone(probe)
+		// 客户端取消不算节点故障，也不再重试
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 响应过大不是节点故障，换节点结果也一样
+		if errors.Is(err, errResponseTooLarge) {
+			log.Printf("Response for %s%s from %s exceeds %d bytes", method, path, u.label, upstreamMaxResponseBytes)
+			return nil, err
+		}
+		// 失败也计入延迟，超时的节点自然会被降权
+		u.observeLatency(methodClass(method), time.Since(sent))
+		call := debugUpstreamCall{Kind: p.kind, Upstream: u.label, Method: method, Attempt: attempt + 1, Ms: msSince(sent)}
+		if resp != nil {
+			call.Status = resp.Status
+		}
+		if err != nil {
+			call.Error = err.Error()
+		}
+		debugFrom(ctx).upstream(call)
+		recordAudit(ctx, p.kind, path, method, u, body, resp, err, time.Since(sent))
+		if resp != nil {
+			u.observeAuth(resp.Status)
+		}
+		if err == nil && resp.Status < 500 {
+			observeUpstream(u.label, false)
+			u.markSuccess()
+			return resp, nil
+		}
+		sendErr, lastStatus = err, 0
+		if err == nil {
+			lastStatus = resp.Status
+			err = upstreamFailure(u, resp.Status, resp.Body)
+		}
+		observeUpstream(u.label, true)
+		u.markFailure(err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		// 最后一次尝试碰上半开节点正在探测，没有发出任何请求
+		return nil, errCircuitOpen
+	}
+	return nil, lastErr
+}
+
+type upstreamStatus struct {
+	URL       string    `json:"url"`
+	Kind      string    `json:"kind"`
+	Role      string    `json:"role,omitempty"`
+	Healthy   bool      `json:"healthy"`
+	Failures  int       `json:"failures"`
+	DownUntil time.Time `json:"downUntil,omitempty"`
+	LastCheck time.Time `json:"lastCheck"`
+	LastError string    `json:"lastError,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Circuit   string    `json:"circuit"`
+	// 各方法类别的延迟 EWMA，单位毫秒
+	LatencyMs     map[string]float64 `json:"latencyMs,omitempty"`
+	Pending       int64              `json:"pending"`
+	Head          int64              `json:"head,omitempty"`
+	Lagging       bool               `json:"lagging,omitempty"`
+	CertExpiresAt *time.Time         `json:"certExpiresAt,omitempty"`
+	AuthFailing   bool               `json:"authFailing,omitempty"`
+	Draining      bool               `json:"draining,omitempty"`
+	Flapping      bool               `json:"flapping,omitempty"`
+}
+
+func (p *upstreamPool) status() []upstreamStatus {
+	out := make([]upstreamStatus, 0, len(p.list()))
+	for _, u := range p.list() {
+		u.mu.Lock()
+		out = append(out, upstreamStatus{
+			URL: u.url, Kind: u.kind, Role: u.role, Healthy: u.healthy, Failures: u.failures,
+			DownUntil: u.downUntil, LastCheck: u.lastCheck, LastError: u.lastErr,
+			Version: u.version, Circuit: u.circuitLocked(time.Now()), LatencyMs: copyLatency(u.latency), Pending: u.inflight(),
+			Head: u.headBlock(), Lagging: u.isLagging(),
+			AuthFailing: u.authFailing, Draining: u.draining, Flapping: u.flappingLocked(time.Now()),
+		})
+		if !u.certNotAfter.IsZero() {
+			t := u.certNotAfter
+			out[len(out)-1].CertExpiresAt = &t
+		}
+		u.mu.Unlock()
+	}
+	return out
+}
+
+func copyLatency(m map[string]float64) map[string]float64 {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]float64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func (u *upstream) healthCheck() error {
+	var resp *http.Response
+	var err error
+	switch u.kind {
+	case "grpc":
+		return u.grpcHealthCheck()
+	case "jsonrpc":
+		resp, err = u.healthPost("", `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	case "solidity":
+		resp, err = u.healthPost("/walletsolidity/getnowblock", "{}")
+	default:
+		resp, err = u.healthPost("/wallet/getnowblock", "{}")
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	u.observeAuth(resp.StatusCode)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("health check returned HTTP %d", resp.StatusCode)
+	}
+	if n, ok := parseHeadBlock(u.kind, body); ok {
+		atomic.StoreInt64(&u.head, n)
+	}
+	return nil
+}
+
+func (u *upstream) healthPost(path, payload string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, u.url+path, strings.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setUpstreamHeaders(context.Background(), req, u)
+	return healthCheckClient.Do(req)
+}
+
+func (p *upstreamPool) checkAll() {
+	var wg sync.WaitGroup
+	for _, u := range p.list() {
+		node := u
+		// 退避期内不探测，等到 downUntil 之后再试
+		if time.Now().Before(node.retryAt()) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := node.healthCheck()
+			node.mu.Lock()
+			node.lastCheck = time.Now()
+			node.mu.Unlock()
+			if err != nil {
+				node.markFailure(err)
+				return
+			}
+			node.markSuccess()
+			if node.getVersion() == "" {
+				node.detectVersion(context.Background())
+			}
+			if node.kind == "jsonrpc" && node.capabilitiesStale() {
+				node.probeCapabilities(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+	p.updateLag()
+}
+
+func startHealthChecks() {
+	upstreamHistory.load()
+	for _, p := range allUpstreamPools() {
+		if p.role == "" || len(p.list()) > 0 {
+			log.Printf("Configured %d %s upstream(s)", len(p.list()), p.name())
+		}
+	}
+	go func() {
+		for {
+			for _, p := range allUpstreamPools() {
+				p.checkAll()
+			}
+			time.Sleep(upstreamHealthInterval)
+		}
+	}()
+}
@@ -0,0 +1,15 @@
+package upstream
+
+import "time"
+
+// Backoff 第 n 次（从 1 开始）连续失败后的等待时间：从 base 开始每次翻倍，不超过 max
+func Backoff(base, max time.Duration, n int) time.Duration {
+	d := base
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,69 @@
+package upstream
+
+import "time"
+
+// 熔断状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// BreakerPolicy 连续失败 Failures 次后熔断 OpenDuration，之后每多失败一次翻倍，不超过 OpenMax。Failures 为 0 时不熔断
+type BreakerPolicy struct {
+	Failures     int
+	OpenDuration time.Duration
+	OpenMax      time.Duration
+}
+
+func (p BreakerPolicy) Enabled() bool {
+	return p.Failures > 0
+}
+
+// Breaker 单个节点的熔断状态。连续失败次数由节点自己记录（健康检查的退避也用它），
+// 每次调用时传进来；Breaker 不加锁，调用方和失败次数一起保护
+type Breaker struct {
+	openUntil time.Time
+	probing   bool
+}
+
+// State 达到阈值后熔断到 openUntil，之后进入半开
+func (b *Breaker) State(p BreakerPolicy, failures int, now time.Time) string {
+	if !p.Enabled() || failures < p.Failures {
+		return CircuitClosed
+	}
+	if now.Before(b.openUntil) {
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// Allow 半开状态同一时间只放行一个探测请求，probe=true 时调用方必须调用 Done
+func (b *Breaker) Allow(p BreakerPolicy, failures int, now time.Time) (allowed, probe bool) {
+	switch b.State(p, failures, now) {
+	case CircuitClosed:
+		return true, false
+	case CircuitOpen:
+		return false, false
+	}
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// Done 探测请求结束，结果由调用方另外记进失败次数
+func (b *Breaker) Done() {
+	b.probing = false
+}
+
+// Trip 记下一次失败后调用（failures 已经加一），达到阈值后（包括半开探测失败）重新计算熔断时长。
+// 刚达到阈值时返回 true
+func (b *Breaker) Trip(p BreakerPolicy, failures int, now time.Time) bool {
+	if !p.Enabled() || failures < p.Failures {
+		return false
+	}
+	b.openUntil = now.Add(Backoff(p.OpenDuration, p.OpenMax, failures-p.Failures+1))
+	return failures == p.Failures
+}
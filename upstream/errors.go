@@ -0,0 +1,175 @@
+// Package upstream 解读 TRON 节点的返回：把返回码和各种错误内容映射成 JSON-RPC 错误，
+// 节点失败后的退避计算和熔断状态机。节点池（选点、健康检查、各节点的连接配置）仍在 proxy 包里
+package upstream
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourname/proxy/rpc"
+)
+
+// SnippetSize 错误信息里保留的响应开头长度
+const SnippetSize = 200
+
+// ReturnCodes TRON 的返回码（broadcast、triggerconstantcontract 等的 code 字段）对应的 JSON-RPC 错误码，按 EIP-1474 的约定选用
+var ReturnCodes = map[string]int{
+	"SIGERROR":                        rpc.CodeTxRejected,
+	"CONTRACT_VALIDATE_ERROR":         rpc.CodeInvalidInput,
+	"CONTRACT_EXE_ERROR":              rpc.CodeInvalidInput,
+	"BANDWITH_ERROR":                  rpc.CodeTxRejected,
+	"DUP_TRANSACTION_ERROR":           rpc.CodeTxRejected,
+	"TAPOS_ERROR":                     rpc.CodeTxRejected,
+	"TOO_BIG_TRANSACTION_ERROR":       rpc.CodeTxRejected,
+	"TRANSACTION_EXPIRATION_ERROR":    rpc.CodeTxRejected,
+	"SERVER_BUSY":                     rpc.CodeLimitExceeded,
+	"NO_CONNECTION":                   rpc.CodeUnavailable,
+	"NOT_ENOUGH_EFFECTIVE_CONNECTION": rpc.CodeUnavailable,
+	"BLOCK_UNSOLIDIFIED":              rpc.CodeUnavailable,
+	"OTHER_ERROR":                     rpc.CodeInvalidInput,
+}
+
+// Error 上游返回了可识别的错误，Code 为映射后的 JSON-RPC 错误码，TronCode 为原始返回码（可能为空）
+type Error struct {
+	Code     int
+	TronCode string
+	Message  string
+	// 出错的节点（host）、HTTP 状态和响应开头，放进 error.data，见 From
+	Upstream string
+	Status   int
+	Excerpt  string
+}
+
+func (e *Error) Error() string {
+	if e.TronCode != "" {
+		return e.TronCode + ": " + e.Message
+	}
+	return e.Message
+}
+
+// From 记下返回这个错误的节点和原始响应，响应体只保留经过脱敏的开头部分
+func (e *Error) From(upstream string, status int, body []byte) *Error {
+	e.Upstream = upstream
+	e.Status = status
+	e.Excerpt = Snippet(body)
+	return e
+}
+
+// TronCodeError 按 TRON 返回码生成错误，未知返回码按普通执行错误处理
+func TronCodeError(code, message string) *Error {
+	mapped, ok := ReturnCodes[code]
+	if !ok {
+		mapped = rpc.CodeInvalidInput
+	}
+	message = DecodeMessage(message)
+	if message == "" {
+		message = strings.ToLower(strings.ReplaceAll(code, "_", " "))
+	}
+	return &Error{Code: mapped, TronCode: code, Message: message}
+}
+
+// DecodeMessage 节点返回的错误信息大多是 hex 编码的字符串
+func DecodeMessage(msg string) string {
+	if b, err := hex.DecodeString(msg); err == nil && len(b) > 0 {
+		return string(b)
+	}
+	return msg
+}
+
+// Classify 识别上游返回的非 JSON-RPC 内容：REST 风格的 {"Error": "..."}、
+// 带 code/message 的 TRON 返回、SERVER_BUSY、限流和 HTML 错误页
+func Classify(status int, body []byte) *Error {
+	var obj map[string]interface{}
+	if json.Unmarshal(body, &obj) == nil {
+		if msg, ok := obj["Error"].(string); ok {
+			return RestError(msg)
+		}
+		if e := resultError(obj); e != nil {
+			return e
+		}
+		if inner, ok := obj["result"].(map[string]interface{}); ok {
+			if e := resultError(inner); e != nil {
+				return e
+			}
+		}
+	}
+	text := strings.TrimSpace(string(body))
+	switch {
+	case strings.Contains(text, "SERVER_BUSY"):
+		return TronCodeError("SERVER_BUSY", "")
+	case status == http.StatusTooManyRequests:
+		return &Error{Code: rpc.CodeLimitExceeded, Message: "upstream rate limit exceeded"}
+	case status == http.StatusServiceUnavailable || status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return &Error{Code: rpc.CodeUnavailable, Message: fmt.Sprintf("upstream unavailable (HTTP %d)", status)}
+	}
+	msg := "Invalid response from upstream"
+	if status != 0 && status/100 != 2 {
+		msg += fmt.Sprintf(" (HTTP %d)", status)
+	}
+	if snippet := Snippet(body); snippet != "" {
+		msg += ": " + snippet
+	}
+	return &Error{Code: rpc.CodeInternal, Message: msg}
+}
+
+// RestError java-tron 的 REST 错误形如 "class java.lang.IllegalArgumentException : Invalid address"
+func RestError(msg string) *Error {
+	code := rpc.CodeInvalidInput
+	if strings.HasPrefix(msg, "class ") {
+		if exc, rest, ok := strings.Cut(strings.TrimPrefix(msg, "class "), " : "); ok {
+			msg = strings.TrimSpace(rest)
+			if !strings.Contains(exc, "IllegalArgument") && !strings.Contains(exc, "Invalid") && !strings.Contains(exc, "Validate") {
+				code = rpc.CodeInternal
+			}
+		}
+	}
+	return &Error{Code: code, Message: Redact(msg)}
+}
+
+func resultError(obj map[string]interface{}) *Error {
+	code, ok := obj["code"].(string)
+	if !ok || code == "" || code == "SUCCESS" {
+		return nil
+	}
+	if _, known := ReturnCodes[code]; !known && !strings.HasSuffix(code, "_ERROR") {
+		return nil
+	}
+	msg, _ := obj["message"].(string)
+	return TronCodeError(code, msg)
+}
+
+// Snippet 错误信息里带上响应开头的一小段，HTML 页面只取 title
+func Snippet(body []byte) string {
+	body = bytes.TrimSpace(body)
+	if i := bytes.Index(bytes.ToLower(body), []byte("<title>")); i >= 0 {
+		rest := body[i+len("<title>"):]
+		if j := bytes.Index(bytes.ToLower(rest), []byte("</title>")); j >= 0 {
+			body = bytes.TrimSpace(rest[:j])
+		}
+	}
+	if len(body) > SnippetSize {
+		body = body[:SnippetSize]
+		for len(body) > 0 && !utf8.Valid(body) {
+			body = body[:len(body)-1]
+		}
+	}
+	return Redact(strings.Join(strings.Fields(string(body)), " "))
+}
+
+var (
+	// 日志和错误信息里的 URL 可能带凭证
+	urlUserinfoPattern = regexp.MustCompile(`://[^/@\s"]+@`)
+	urlQueryPattern    = regexp.MustCompile(`(://[^\s"?]+)\?[^\s"]*`)
+)
+
+// Redact 去掉文本里 URL 的用户信息和查询参数
+func Redact(s string) string {
+	s = urlUserinfoPattern.ReplaceAllString(s, "://REDACTED@")
+	return urlQueryPattern.ReplaceAllString(s, "$1?REDACTED")
+}
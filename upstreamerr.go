@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourname/proxy/rpc"
+	up "github.com/yourname/proxy/upstream"
+)
+
+// 按 EIP-1474 的约定选用的错误码
+const (
+	errCodeInvalidInput  = rpc.CodeInvalidInput
+	errCodeTxRejected    = rpc.CodeTxRejected
+	errCodeLimitExceeded = rpc.CodeLimitExceeded
+	errCodeUnavailable   = rpc.CodeUnavailable
+	errCodeInternal      = rpc.CodeInternal
+)
+
+// upstreamError 定义在 upstream 包，返回码映射和错误内容识别也在那里
+type upstreamError = up.Error
+
+func upstreamErrorResponse(e *upstreamError, id interface{}) JSONRPCResponse {
+	resp := jsonError(id, e.Code, e.Error())
+	if e.TronCode != "" {
+		setErrorData(&resp, "tronCode", e.TronCode)
+	}
+	if e.Upstream != "" {
+		setErrorData(&resp, "upstream", e.Upstream)
+	}
+	if e.Status != 0 {
+		setErrorData(&resp, "httpStatus", e.Status)
+	}
+	if e.Excerpt != "" {
+		setErrorData(&resp, "body", e.Excerpt)
+	}
+	return resp
+}
+
+// upstreamFailure 识别节点返回的错误内容，并记下是哪个节点
+func upstreamFailure(u *upstream, status int, body []byte) *upstreamError {
+	label := ""
+	if u != nil {
+		label = u.label
+	}
+	return up.Classify(status, body).From(label, status, body)
+}
+
+// setErrorData 往 error.data 里加一个字段，data 不存在时创建
+func setErrorData(resp *JSONRPCResponse, key string, value interface{}) {
+	e, ok := resp.Error.(map[string]interface{})
+	if !ok {
+		return
+	}
+	data, ok := e["data"].(map[string]interface{})
+	if !ok {
+		data = map[string]interface{}{}
+		e["data"] = data
+	}
+	data[key] = value
+}
+
+// errorResponse err 是 upstreamError 时保留映射后的错误码，其余按内部错误返回
+func errorResponse(id interface{}, err error) JSONRPCResponse {
+	if errors.Is(err, errResponseTooLarge) {
+		return jsonError(id, errCodeResponseTooLarge, errResponseTooLarge.Error())
+	}
+	var ue *upstreamError
+	if errors.As(err, &ue) {
+		return upstreamErrorResponse(ue, id)
+	}
+	return jsonError(id, errCodeInternal, "Internal error: "+err.Error())
+}
+
+// invalidRestResponse 节点返回的内容无法解析为交易信息列表，error.data 带上节点、状态、响应开头和区块号
+func invalidRestResponse(id interface{}, block int64, u *upstream, status int, body []byte) JSONRPCResponse {
+	e := upstreamFailure(u, status, body)
+	if e.Code == errCodeInternal {
+		e.Message = fmt.Sprintf("Invalid response from TronNode REST for block %d", block)
+	}
+	resp := upstreamErrorResponse(e, id)
+	setErrorData(&resp, "block", block)
+	return resp
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// 转发给上游的客户端请求头；X-Forwarded-For 会追加客户端地址
+	upstreamForwardHeaders = forwardHeaderList(splitList(envString("UPSTREAM_FORWARD_HEADERS", "X-Forwarded-For,traceparent,tracestate")))
+
+	// UPSTREAM_AUTH 按节点注入的凭证，逗号分隔的 <节点>=<类型>:<值>，节点为 host[:port]、URL 前缀或 *：
+	//   api.trongrid.io=apikey:KEY          TRON-PRO-API-KEY 请求头
+	//   10.0.0.5:8545=basic:USER:PASSWORD   HTTP basic auth
+	//   node.example.com=bearer:TOKEN       Authorization: Bearer
+	//   *=header:X-Name:VALUE               任意请求头
+	// 同一个节点可以写多条。可通过 /admin/reload 更新
+	upstreamCredentials atomic.Value // []upstreamCredential
+)
+
+// hopByHopHeaders 只对单个连接有效，不能转发（RFC 7230 6.1）
+var hopByHopHeaders = toSet([]string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "Proxy-Connection"})
+
+type upstreamCredential struct {
+	match  string
+	header string
+	value  string
+}
+
+type forwardedHeadersCtxKey struct{}
+
+func init() {
+	loadUpstreamCredentials()
+}
+
+func forwardHeaderList(names []string) []string {
+	var out []string
+	for _, n := range names {
+		n = textproto.CanonicalMIMEHeaderKey(n)
+		if hopByHopHeaders[n] {
+			log.Printf("UPSTREAM_FORWARD_HEADERS: %s is a hop-by-hop header, not forwarded", n)
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func loadUpstreamCredentials() {
+	var creds []upstreamCredential
+	for _, item := range splitList(configValue("UPSTREAM_AUTH")) {
+		match, spec, ok := strings.Cut(item, "=")
+		kind, value, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 || strings.TrimSpace(match) == "" || value == "" {
+			log.Printf("Invalid UPSTREAM_AUTH entry for %q, ignored", match)
+			continue
+		}
+		c := upstreamCredential{match: strings.TrimRight(strings.TrimSpace(match), "/")}
+		switch kind {
+		case "apikey":
+			c.header, c.value = "TRON-PRO-API-KEY", value
+		case "bearer":
+			c.header, c.value = "Authorization", "Bearer "+value
+		case "basic":
+			c.header, c.value = "Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value))
+		case "header":
+			name, v, ok := strings.Cut(value, ":")
+			if !ok || name == "" {
+				log.Printf("Invalid UPSTREAM_AUTH header entry for %q, ignored", match)
+				continue
+			}
+			c.header, c.value = textproto.CanonicalMIMEHeaderKey(name), v
+		default:
+			log.Printf("Unknown UPSTREAM_AUTH type %q for %q, ignored", kind, match)
+			continue
+		}
+		creds = append(creds, c)
+	}
+	upstreamCredentials.Store(creds)
+}
+
+func (c upstreamCredential) matches(u *upstream) bool {
+	if c.match == "*" || c.match == u.label {
+		return true
+	}
+	return strings.Contains(c.match, "://") && strings.HasPrefix(u.url, c.match)
+}
+
+// withForwardedHeaders 在请求入口记下需要转发的客户端请求头
+func withForwardedHeaders(ctx context.Context, r *http.Request) context.Context {
+	if len(upstreamForwardHeaders) == 0 {
+		return ctx
+	}
+	h := http.Header{}
+	// Connection 里列出的也是逐跳头
+	hop := map[string]bool{}
+	for _, v := range r.Header.Values("Connection") {
+		for _, n := range strings.Split(v, ",") {
+			hop[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(n))] = true
+		}
+	}
+	for _, name := range upstreamForwardHeaders {
+		if hop[name] {
+			continue
+		}
+		if name == "X-Forwarded-For" {
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				if prior := r.Header.Get(name); prior != "" {
+					host = prior + ", " + host
+				}
+				h.Set(name, host)
+			}
+			continue
+		}
+		for _, v := range r.Header.Values(name) {
+			h.Add(name, v)
+		}
+	}
+	if len(h) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, forwardedHeadersCtxKey{}, h)
+}
+
+// setUpstreamHeaders 加上转发的客户端请求头和该节点的凭证，凭证覆盖客户端的同名请求头
+func setUpstreamHeaders(ctx context.Context, req *http.Request, u *upstream) {
+	if h, ok := ctx.Value(forwardedHeadersCtxKey{}).(http.Header); ok {
+		for name, values := range h {
+			req.Header[name] = append([]string(nil), values...)
+		}
+	}
+	creds, _ := upstreamCredentials.Load().([]upstreamCredential)
+	for _, c := range creds {
+		if c.matches(u) {
+			req.Header.Set(c.header, c.value)
+		}
+	}
+}
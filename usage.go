@@ -0,0 +1,308 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	usageReportDir     = envString("USAGE_REPORT_DIR", filepath.Join(dataDir, "usage"))
+	usageFlushInterval = envDuration("USAGE_FLUSH_INTERVAL", 5*time.Minute)
+	// METHOD=UNITS，方法名支持 glob，按顺序匹配第一条
+	usageComputeUnits = parseComputeUnits(envString("USAGE_COMPUTE_UNITS",
+		"debug_trace*=20,eth_debugTransactionTrace=20,eth_getLogs=10,proxy_*=10,eth_call=5,eth_estimateGas=5"))
+
+	usage = &usageRecorder{rows: make(map[usageKey]*usageRow)}
+)
+
+type computeUnitRule struct {
+	pattern string
+	units   int64
+}
+
+func parseComputeUnits(s string) []computeUnitRule {
+	var rules []computeUnitRule
+	for _, item := range splitList(s) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("Invalid USAGE_COMPUTE_UNITS entry %q, ignored", item)
+			continue
+		}
+		units, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			log.Printf("Invalid USAGE_COMPUTE_UNITS entry %q, ignored", item)
+			continue
+		}
+		rules = append(rules, computeUnitRule{pattern: strings.TrimSpace(parts[0]), units: units})
+	}
+	return rules
+}
+
+// computeUnits 未匹配任何规则的方法按 1 计
+func computeUnits(method string) int64 {
+	for _, r := range usageComputeUnits {
+		if ok, _ := path.Match(r.pattern, method); ok {
+			return r.units
+		}
+	}
+	return 1
+}
+
+type usageKey struct {
+	Key    string
+	Method string
+}
+
+type usageRow struct {
+	Date         string `json:"date"`
+	Key          string `json:"key"`
+	Method       string `json:"method"`
+	Requests     int64  `json:"requests"`
+	Success      int64  `json:"success"`
+	Errors       int64  `json:"errors"`
+	ComputeUnits int64  `json:"computeUnits"`
+}
+
+// usageRecorder 只在内存中保存当天（UTC）的计数，定期和跨天时写出报表文件
+type usageRecorder struct {
+	mu   sync.Mutex
+	day  string
+	rows map[usageKey]*usageRow
+}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// usageKeyLabel 报表里用 key 的名字，API_KEYS 配置的 key 没有名字，用哈希前缀区分
+func usageKeyLabel(ctx context.Context) string {
+	k := apiKeyFromContext(ctx)
+	if k == nil {
+		return "anonymous"
+	}
+	return apiKeyLabel(k.Name, k.Key)
+}
+
+func apiKeyLabel(name, key string) string {
+	if name != "" && name != "env" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:4])
+}
+
+func recordUsage(ctx context.Context, method string, resp JSONRPCResponse) {
+	if quotaRejected(resp) {
+		return
+	}
+	usage.record(usageKeyLabel(ctx), methodLabel(method), resp.Error == nil, time.Now())
+}
+
+func recordBatchUsage(ctx context.Context, method string, responses []JSONRPCResponse) {
+	key, label, now := usageKeyLabel(ctx), methodLabel(method), time.Now()
+	for _, resp := range responses {
+		if quotaRejected(resp) {
+			continue
+		}
+		usage.record(key, label, resp.Error == nil, now)
+	}
+}
+
+func (u *usageRecorder) record(key, method string, ok bool, now time.Time) {
+	day := usageDay(now)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if day != u.day {
+		u.rollover(day)
+	}
+	k := usageKey{Key: key, Method: method}
+	row := u.rows[k]
+	if row == nil {
+		row = &usageRow{Date: day, Key: key, Method: method}
+		u.rows[k] = row
+	}
+	row.Requests++
+	if ok {
+		row.Success++
+	} else {
+		row.Errors++
+	}
+	units := computeUnits(method)
+	row.ComputeUnits += units
+	quotas.add(key, units, now)
+}
+
+// rollover 写出前一天的最终报表，然后载入新一天已写出的部分（进程重启后继续累计）
+func (u *usageRecorder) rollover(day string) {
+	if u.day != "" {
+		if err := writeUsageReport(u.day, u.snapshotLocked()); err != nil {
+			log.Printf("Error writing usage report for %s: %v", u.day, err)
+		}
+	}
+	u.day = day
+	u.rows = make(map[usageKey]*usageRow)
+	rows, err := readUsageReport(day)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error loading usage report for %s: %v", day, err)
+	}
+	for _, r := range rows {
+		row := r
+		u.rows[usageKey{Key: row.Key, Method: row.Method}] = &row
+	}
+}
+
+func (u *usageRecorder) snapshotLocked() []usageRow {
+	rows := make([]usageRow, 0, len(u.rows))
+	for _, r := range u.rows {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Key != rows[j].Key {
+			return rows[i].Key < rows[j].Key
+		}
+		return rows[i].Method < rows[j].Method
+	})
+	return rows
+}
+
+// report 返回某一天的报表，当天的数据来自内存
+func (u *usageRecorder) report(day string) ([]usageRow, error) {
+	u.mu.Lock()
+	if day == u.day {
+		defer u.mu.Unlock()
+		return u.snapshotLocked(), nil
+	}
+	u.mu.Unlock()
+	return readUsageReport(day)
+}
+
+func (u *usageRecorder) flush() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.day == "" {
+		return
+	}
+	if err := writeUsageReport(u.day, u.snapshotLocked()); err != nil {
+		log.Printf("Error writing usage report for %s: %v", u.day, err)
+	}
+}
+
+func startUsageReports() {
+	if usageReportDir == "" {
+		return
+	}
+	usage.mu.Lock()
+	usage.rollover(usageDay(time.Now()))
+	usage.mu.Unlock()
+	quotas.load(time.Now())
+	if usageFlushInterval <= 0 {
+		return
+	}
+	log.Printf("Writing usage reports to %s every %s", usageReportDir, usageFlushInterval)
+	go func() {
+		for range time.Tick(usageFlushInterval) {
+			usage.flush()
+		}
+	}()
+}
+
+func usageReportPath(day, ext string) string {
+	return filepath.Join(usageReportDir, "usage-"+day+"."+ext)
+}
+
+// writeUsageReport 同时写 JSON 和 CSV，JSON 用于重启后恢复当天计数
+func writeUsageReport(day string, rows []usageRow) error {
+	if usageReportDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(usageReportDir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(usageReportPath(day, "json"), data); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if err := writeUsageCSV(&sb, rows); err != nil {
+		return err
+	}
+	return writeFileAtomic(usageReportPath(day, "csv"), []byte(sb.String()))
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func readUsageReport(day string) ([]usageRow, error) {
+	data, err := os.ReadFile(usageReportPath(day, "json"))
+	if err != nil {
+		return nil, err
+	}
+	var rows []usageRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func writeUsageCSV(w io.Writer, rows []usageRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "key", "method", "requests", "success", "errors", "compute_units"})
+	for _, r := range rows {
+		cw.Write([]string{r.Date, r.Key, r.Method,
+			strconv.FormatInt(r.Requests, 10), strconv.FormatInt(r.Success, 10),
+			strconv.FormatInt(r.Errors, 10), strconv.FormatInt(r.ComputeUnits, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// handleAdminUsage GET /admin/usage?date=YYYY-MM-DD&format=csv|json，date 默认当天（UTC）
+func handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	day := r.URL.Query().Get("date")
+	if day == "" {
+		day = usageDay(time.Now())
+	}
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	rows, err := usage.report(day)
+	if os.IsNotExist(err) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no usage report for %s", day)})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s.csv", day))
+		writeUsageCSV(w, rows)
+		return
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
@@ -0,0 +1,740 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+var (
+	// WEBHOOKS=true 时消费方可以通过 /admin/webhooks（WEBHOOK_RPC=true 时也可以用 proxy_subscribeWebhook）
+	// 注册回调地址，代理跟随新区块把结果 POST 过去。订阅类型：
+	//   blocks   每个新区块的区块头，默认不等确认
+	//   address  addresses 发起或接收的交易，以及 indexed 参数里带这些地址的事件（例如 TRC-20 转入）
+	//   logs     符合 filter（address、topics，格式同 eth_getLogs）的合约事件
+	// 订阅和进度保存在 proxy_state。需要区块监听（BLOCK_WATCH_INTERVAL）；多个实例共用存储时只在一个实例上开启
+	webhooksEnabled         = envString("WEBHOOKS", "false") == "true"
+	webhookRPC              = envString("WEBHOOK_RPC", "false") == "true"
+	webhookMaxSubscriptions = envInt("WEBHOOK_MAX_SUBSCRIPTIONS", 100)
+	// address 和 logs 默认等这么多个确认再推送，注册时可以用 confirmations 覆盖
+	webhookConfirmations = int64(envInt("WEBHOOK_CONFIRMATIONS", 19))
+	webhookTimeout       = envDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	// 回调返回非 2xx 或连接失败时按指数退避重试，间隔最长 5 分钟
+	webhookMaxAttempts  = envInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	webhookRetryBackoff = envDuration("WEBHOOK_RETRY_BACKOFF", 2*time.Second)
+	// 每个订阅待发送的推送数，回调地址长时间不可用时丢弃新的推送
+	webhookQueueSize = envInt("WEBHOOK_QUEUE_SIZE", 1000)
+	// 落后超过这么多区块时（例如停机之后）跳过中间的区块
+	webhookMaxLag = int64(envInt("WEBHOOK_MAX_LAG", 1200))
+
+	// 回调地址默认不能指向回环、内网和链路本地地址（例如 169.254.169.254 的云元数据服务），本地调试时可以打开
+	webhookAllowPrivate = envString("WEBHOOK_ALLOW_PRIVATE", "false") == "true"
+
+	webhookClient = &http.Client{
+		Timeout: webhookTimeout,
+		// 不跟随重定向，避免回调地址把请求转到内部服务
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		// 不走 HTTP_PROXY，连接时再检查一次实际连接的地址，注册后域名改解析到内网（DNS rebinding）也连不上
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}).DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+
+	webhooks = &webhookRegistry{byID: map[string]*webhookSubscription{}}
+
+	metricWebhookDeliveries = newCounterVec("proxy_webhook_deliveries_total",
+		"Webhook delivery attempts by subscription type and result (ok, retry, failed, dropped).", "type", "result")
+)
+
+const webhookStateKey = "webhooks"
+
+const (
+	webhookBlocks  = "blocks"
+	webhookAddress = "address"
+	webhookLogs    = "logs"
+)
+
+// webhookSpec 注册时提交的内容
+type webhookSpec struct {
+	URL           string          `json:"url"`
+	Type          string          `json:"type"`
+	Addresses     []string        `json:"addresses,omitempty"`
+	Filter        json.RawMessage `json:"filter,omitempty"`
+	Confirmations *int64          `json:"confirmations,omitempty"`
+}
+
+// webhookConfig 持久化的部分，Secret 只在注册时返回一次
+type webhookConfig struct {
+	ID            string          `json:"id"`
+	URL           string          `json:"url"`
+	Type          string          `json:"type"`
+	Addresses     []string        `json:"addresses,omitempty"`
+	Filter        json.RawMessage `json:"filter,omitempty"`
+	Confirmations int64           `json:"confirmations"`
+	Secret        string          `json:"secret,omitempty"`
+	Owner         string          `json:"owner,omitempty"`
+	Created       time.Time       `json:"created"`
+	// 下一个要推送的区块，0 表示从注册后的下一个区块开始
+	Next int64 `json:"next"`
+}
+
+type webhookStats struct {
+	Delivered    int64      `json:"delivered"`
+	Failed       int64      `json:"failed"`
+	Dropped      int64      `json:"dropped"`
+	Pending      int        `json:"pending"`
+	LastError    string     `json:"lastError,omitempty"`
+	LastDelivery *time.Time `json:"lastDelivery,omitempty"`
+}
+
+type webhookSubscription struct {
+	webhookConfig
+
+	addresses map[string]bool // 20 字节 hex，小写不带 0x
+	match     *logFilter
+	signer    *signer
+	queue     chan webhookPayload
+	stop      chan struct{}
+
+	delivered, failed, dropped int64
+	mu                         sync.Mutex
+	lastError                  string
+	lastDelivery               time.Time
+}
+
+type webhookView struct {
+	webhookConfig
+	webhookStats
+}
+
+// webhookPayload 推送的内容。id 在同一订阅内唯一，重试和回滚后重新推送时不变，消费方用它去重
+type webhookPayload struct {
+	ID            string       `json:"id"`
+	Subscription  string       `json:"subscription"`
+	Type          string       `json:"type"`
+	BlockNumber   int64        `json:"blockNumber"`
+	BlockHash     string       `json:"blockHash,omitempty"`
+	Timestamp     int64        `json:"timestamp,omitempty"`
+	Confirmations int64        `json:"confirmations"`
+	Header        *blockHeader `json:"header,omitempty"`
+	Transactions  []webhookTx  `json:"transactions,omitempty"`
+	Logs          []ethLog     `json:"logs,omitempty"`
+}
+
+type webhookTx struct {
+	Hash   string `json:"hash"`
+	Type   string `json:"type"`
+	From   string `json:"from"`
+	To     string `json:"to,omitempty"`
+	Amount int64  `json:"amount,omitempty"`
+	Result string `json:"result"`
+}
+
+type webhookRegistry struct {
+	mu     sync.Mutex
+	byID   map[string]*webhookSubscription
+	pollMu sync.Mutex
+	saved  time.Time
+}
+
+func startWebhooks() {
+	if !webhooksEnabled {
+		return
+	}
+	if !watcherRunning() {
+		log.Printf("WEBHOOKS needs the block watcher (BLOCK_WATCH_INTERVAL), webhooks disabled")
+		webhooksEnabled = false
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	webhooks.load(ctx)
+	cancel()
+	var head int64
+	wake := make(chan struct{}, 1)
+	events.subscribe("webhooks", 16, func(e event) {
+		switch d := e.Data.(type) {
+		case newBlockEvent:
+			atomic.StoreInt64(&head, d.Header.Number)
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		case reorgEvent:
+			webhooks.rewind(d.Height)
+		}
+	}, EventNewBlock, EventReorg)
+	go func() {
+		for range wake {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			webhooks.poll(ctx, atomic.LoadInt64(&head))
+			cancel()
+		}
+	}()
+}
+
+// stopWebhooks 退出时保存推送进度，重启后从这里继续
+func stopWebhooks() {
+	if !webhooksEnabled {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	webhooks.save(ctx, true)
+}
+
+func (r *webhookRegistry) load(ctx context.Context) {
+	raw, ok := loadState(ctx, webhookStateKey)
+	var configs []webhookConfig
+	if ok {
+		if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+			log.Printf("Ignoring invalid webhook state: %v", err)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range configs {
+		s, err := newWebhookSubscription(c)
+		if err != nil {
+			log.Printf("Ignoring webhook %s: %v", c.ID, err)
+			continue
+		}
+		r.byID[s.ID] = s
+	}
+	log.Printf("Webhooks enabled, %d subscription(s)", len(r.byID))
+}
+
+// save 注册和删除时立即保存，推送进度最多每 30 秒保存一次
+func (r *webhookRegistry) save(ctx context.Context, force bool) {
+	r.mu.Lock()
+	if !force && time.Since(r.saved) < 30*time.Second {
+		r.mu.Unlock()
+		return
+	}
+	configs := make([]webhookConfig, 0, len(r.byID))
+	for _, s := range r.byID {
+		configs = append(configs, s.webhookConfig)
+	}
+	r.saved = time.Now()
+	r.mu.Unlock()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Created.Before(configs[j].Created) })
+	data, _ := json.Marshal(configs)
+	if err := saveState(ctx, webhookStateKey, string(data)); err != nil {
+		log.Printf("Error saving webhooks: %v", err)
+	}
+}
+
+func (r *webhookRegistry) add(ctx context.Context, spec webhookSpec, owner string) (webhookView, error) {
+	c := webhookConfig{
+		URL:       spec.URL,
+		Type:      spec.Type,
+		Addresses: spec.Addresses,
+		Filter:    spec.Filter,
+		Owner:     owner,
+		Created:   time.Now().UTC(),
+		ID:        "wh_" + randomHex(8),
+		Secret:    randomHex(32),
+	}
+	c.Confirmations = webhookConfirmations
+	if c.Type == webhookBlocks {
+		c.Confirmations = 0
+	}
+	if spec.Confirmations != nil {
+		c.Confirmations = *spec.Confirmations
+	}
+	if err := checkWebhookHost(ctx, c.URL); err != nil {
+		return webhookView{}, err
+	}
+	s, err := newWebhookSubscription(c)
+	if err != nil {
+		return webhookView{}, err
+	}
+	r.mu.Lock()
+	if len(r.byID) >= webhookMaxSubscriptions {
+		r.mu.Unlock()
+		close(s.stop)
+		return webhookView{}, fmt.Errorf("too many webhook subscriptions (max %d)", webhookMaxSubscriptions)
+	}
+	r.byID[s.ID] = s
+	v := s.view()
+	r.mu.Unlock()
+	r.save(ctx, true)
+	log.Printf("Webhook %s registered: %s -> %s", s.ID, s.Type, s.URL)
+	v.Secret = s.Secret
+	return v, nil
+}
+
+// remove owner 非空时只能删除自己注册的订阅
+func (r *webhookRegistry) remove(ctx context.Context, id, owner string) bool {
+	r.mu.Lock()
+	s, ok := r.byID[id]
+	if ok && owner != "" && s.Owner != owner {
+		ok = false
+	}
+	if ok {
+		delete(r.byID, id)
+		close(s.stop)
+	}
+	r.mu.Unlock()
+	if ok {
+		r.save(ctx, true)
+		log.Printf("Webhook %s removed", id)
+	}
+	return ok
+}
+
+func (r *webhookRegistry) list() []webhookView {
+	r.mu.Lock()
+	out := make([]webhookView, 0, len(r.byID))
+	for _, s := range r.byID {
+		out = append(out, s.view())
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+func (r *webhookRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.byID)
+}
+
+// poll 处理各订阅从 Next 到 head 减确认数之间的区块，每个区块的交易和日志只读取一次
+func (r *webhookRegistry) poll(ctx context.Context, head int64) {
+	r.pollMu.Lock()
+	defer r.pollMu.Unlock()
+	r.mu.Lock()
+	from, to := head+1, int64(-1)
+	wantTxs, wantLogs := false, false
+	for _, s := range r.byID {
+		last := head - s.Confirmations
+		if s.Next == 0 {
+			s.Next = last + 1
+		}
+		if last-s.Next+1 > webhookMaxLag {
+			log.Printf("Webhook %s is %d blocks behind, skipping to block %d", s.ID, last-s.Next+1, last-webhookMaxLag+1)
+			s.Next = last - webhookMaxLag + 1
+		}
+		if s.Next > last {
+			continue
+		}
+		if s.Next < from {
+			from = s.Next
+		}
+		if last > to {
+			to = last
+		}
+		wantTxs = wantTxs || s.Type == webhookAddress
+		wantLogs = wantLogs || s.Type != webhookBlocks
+	}
+	r.mu.Unlock()
+	for n := from; n <= to; n++ {
+		h, err := getHeader(ctx, n)
+		if err != nil {
+			log.Printf("Webhook poll stopped at block %d: %v", n, err)
+			break
+		}
+		var block *restBlock
+		if wantTxs {
+			if block, err = getRestBlockByNum(ctx, n); err != nil {
+				log.Printf("Webhook poll stopped at block %d: %v", n, err)
+				break
+			}
+		}
+		var logs []ethLog
+		if wantLogs {
+			if logs, err = blockLogs(ctx, &logFilter{}, n); err != nil {
+				log.Printf("Webhook poll stopped at block %d: %v", n, err)
+				break
+			}
+		}
+		r.dispatch(head, h, block, logs)
+	}
+	r.save(ctx, false)
+}
+
+func (r *webhookRegistry) dispatch(head int64, h blockHeader, block *restBlock, logs []ethLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.byID {
+		if s.Next != h.Number || h.Number > head-s.Confirmations {
+			continue
+		}
+		s.Next = h.Number + 1
+		p := webhookPayload{
+			ID:            fmt.Sprintf("%s-%d", s.ID, h.Number),
+			Subscription:  s.ID,
+			Type:          s.Type,
+			BlockNumber:   h.Number,
+			BlockHash:     h.Hash,
+			Timestamp:     h.Timestamp,
+			Confirmations: head - h.Number,
+		}
+		switch s.Type {
+		case webhookBlocks:
+			header := h
+			p.Header = &header
+		case webhookAddress:
+			p.Transactions = s.matchTransactions(block)
+			p.Logs = s.matchAddressLogs(logs)
+			if len(p.Transactions) == 0 && len(p.Logs) == 0 {
+				continue
+			}
+		case webhookLogs:
+			for _, l := range logs {
+				if s.match.Matches(strings.TrimPrefix(l.Address, "0x"), l.Topics) {
+					p.Logs = append(p.Logs, l)
+				}
+			}
+			if len(p.Logs) == 0 {
+				continue
+			}
+		}
+		s.enqueue(p)
+	}
+}
+
+// rewind 回滚后从 height 重新推送，blocks 订阅先收到一条 reorg 通知
+func (r *webhookRegistry) rewind(height int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.byID {
+		if s.Next <= height {
+			continue
+		}
+		if s.Type == webhookBlocks {
+			s.enqueue(webhookPayload{
+				ID:           fmt.Sprintf("%s-reorg-%d-%d", s.ID, height, time.Now().UnixNano()),
+				Subscription: s.ID,
+				Type:         "reorg",
+				BlockNumber:  height,
+			})
+		}
+		s.Next = height
+	}
+}
+
+func newWebhookSubscription(c webhookConfig) (*webhookSubscription, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+	if c.Confirmations < 0 {
+		return nil, fmt.Errorf("confirmations must not be negative")
+	}
+	s := &webhookSubscription{webhookConfig: c}
+	switch c.Type {
+	case webhookBlocks:
+	case webhookAddress:
+		if len(c.Addresses) == 0 {
+			return nil, fmt.Errorf("addresses is required")
+		}
+		s.addresses = make(map[string]bool, len(c.Addresses))
+		for _, a := range c.Addresses {
+			hexAddr, err := translate.ToEthAddress(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", a)
+			}
+			s.addresses[hexAddr[2:]] = true
+		}
+	case webhookLogs:
+		if len(c.Filter) == 0 {
+			return nil, fmt.Errorf("filter is required")
+		}
+		f, err := translate.ParseLogFilter(c.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.FromBlock) > 0 || len(f.ToBlock) > 0 || f.BlockHash != "" {
+			return nil, fmt.Errorf("filter must not set fromBlock, toBlock or blockHash")
+		}
+		s.match = f
+	default:
+		return nil, fmt.Errorf("type must be blocks, address or logs")
+	}
+	s.signer = &signer{alg: "hmac-sha256", kid: c.ID, secret: []byte(c.Secret)}
+	s.queue = make(chan webhookPayload, webhookQueueSize)
+	s.stop = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// checkWebhookHost 注册时解析回调地址的域名，任何一个地址落在禁止的网段都拒绝
+func checkWebhookHost(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %q", u.Hostname())
+	}
+	for _, a := range addrs {
+		if blockedWebhookIP(a.IP) {
+			return fmt.Errorf("url must not point to a loopback, private or link-local address")
+		}
+	}
+	return nil
+}
+
+func blockedWebhookIP(ip net.IP) bool {
+	if webhookAllowPrivate {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified()
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+func (s *webhookSubscription) matchTransactions(block *restBlock) []webhookTx {
+	if block == nil {
+		return nil
+	}
+	var out []webhookTx
+	for _, tx := range block.Transactions {
+		if len(tx.RawData.Contract) == 0 {
+			continue
+		}
+		c := tx.RawData.Contract[0]
+		v := c.Parameter.Value
+		to, amount := v.ToAddress, v.Amount
+		if to == "" {
+			to, amount = v.ContractAddress, v.CallValue
+		}
+		from, _ := translate.ToEthAddress(v.OwnerAddress)
+		if to != "" {
+			to, _ = translate.ToEthAddress(to)
+		}
+		if !s.addresses[strings.TrimPrefix(from, "0x")] && (to == "" || !s.addresses[to[2:]]) {
+			continue
+		}
+		result := "SUCCESS"
+		if len(tx.Ret) > 0 && tx.Ret[0].ContractRet != "" {
+			result = tx.Ret[0].ContractRet
+		}
+		out = append(out, webhookTx{Hash: "0x" + tx.TxID, Type: c.Type, From: from, To: to, Amount: amount, Result: result})
+	}
+	return out
+}
+
+// matchAddressLogs 事件的 indexed 参数（topic 1 之后）是补齐到 32 字节的地址
+func (s *webhookSubscription) matchAddressLogs(logs []ethLog) []ethLog {
+	var out []ethLog
+	for _, l := range logs {
+		for i := 1; i < len(l.Topics); i++ {
+			t := translate.NormalizeTopic(l.Topics[i])
+			if len(t) == 64 && strings.Trim(t[:24], "0") == "" && s.addresses[t[24:]] {
+				out = append(out, l)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s *webhookSubscription) enqueue(p webhookPayload) {
+	select {
+	case s.queue <- p:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+		metricWebhookDeliveries.inc(s.Type, "dropped")
+		log.Printf("Webhook %s queue full, dropping %s", s.ID, p.ID)
+	}
+}
+
+func (s *webhookSubscription) run() {
+	for {
+		select {
+		case p := <-s.queue:
+			s.send(p)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *webhookSubscription) send(p webhookPayload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	backoff := webhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.post(p.ID, attempt, body)
+		s.mu.Lock()
+		if err == nil {
+			s.lastDelivery = time.Now()
+			s.lastError = ""
+		} else {
+			s.lastError = err.Error()
+		}
+		s.mu.Unlock()
+		if err == nil {
+			atomic.AddInt64(&s.delivered, 1)
+			metricWebhookDeliveries.inc(s.Type, "ok")
+			return
+		}
+		if attempt >= webhookMaxAttempts {
+			atomic.AddInt64(&s.failed, 1)
+			metricWebhookDeliveries.inc(s.Type, "failed")
+			log.Printf("Webhook %s: giving up on %s after %d attempts: %v", s.ID, p.ID, attempt, err)
+			return
+		}
+		metricWebhookDeliveries.inc(s.Type, "retry")
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+		if backoff *= 2; backoff > 5*time.Minute {
+			backoff = 5 * time.Minute
+		}
+	}
+}
+
+// post 签名方式与响应签名相同：X-Proxy-Signature 的 kid 为订阅 ID，请求 ID 的位置是 X-Webhook-Id，
+// HMAC 密钥为注册时返回的 secret
+func (s *webhookSubscription) post(id string, attempt int, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", id)
+	req.Header.Set("X-Webhook-Attempt", fmt.Sprint(attempt))
+	req.Header.Set(signatureHeader, s.signer.sign(time.Now().Unix(), id, digest[:]))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookSubscription) view() webhookView {
+	v := webhookView{webhookConfig: s.webhookConfig}
+	v.Secret = ""
+	v.Delivered = atomic.LoadInt64(&s.delivered)
+	v.Failed = atomic.LoadInt64(&s.failed)
+	v.Dropped = atomic.LoadInt64(&s.dropped)
+	v.Pending = len(s.queue)
+	s.mu.Lock()
+	v.LastError = s.lastError
+	if !s.lastDelivery.IsZero() {
+		t := s.lastDelivery
+		v.LastDelivery = &t
+	}
+	s.mu.Unlock()
+	return v
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleAdminWebhooks GET 列出订阅，POST 注册，DELETE ?id= 删除
+func handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !webhooksEnabled {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "webhooks are disabled, set WEBHOOKS=true"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": webhooks.list()})
+	case http.MethodPost:
+		var spec webhookSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+		v, err := webhooks.add(r.Context(), spec, "")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusCreated, v)
+	case http.MethodDelete:
+		if !webhooks.remove(r.Context(), r.URL.Query().Get("id"), "") {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"removed": true})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET, POST or DELETE required"})
+	}
+}
+
+// handleSubscribeWebhook proxy_subscribeWebhook(spec)，启用了 API key 时订阅归属于调用方的 key
+func handleSubscribeWebhook(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if !webhooksEnabled || !webhookRPC {
+		return jsonError(req.ID, -32601, "Method not found: webhook registration over JSON-RPC is disabled")
+	}
+	var spec webhookSpec
+	if len(req.Params) < 1 || codec.Unmarshal(req.Params[0], &spec) != nil {
+		return jsonError(req.ID, -32602, "Invalid params: expected webhook object {url, type, addresses, filter, confirmations}")
+	}
+	v, err := webhooks.add(ctx, spec, webhookOwner(ctx))
+	if err != nil {
+		return jsonError(req.ID, -32602, "Invalid params: "+err.Error())
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: v}
+}
+
+func handleUnsubscribeWebhook(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	if !webhooksEnabled || !webhookRPC {
+		return jsonError(req.ID, -32601, "Method not found: webhook registration over JSON-RPC is disabled")
+	}
+	var id string
+	if len(req.Params) < 1 || codec.Unmarshal(req.Params[0], &id) != nil {
+		return jsonError(req.ID, -32602, "Invalid params: expected webhook id")
+	}
+	return JSONRPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: webhooks.remove(ctx, id, webhookOwner(ctx))}
+}
+
+// webhookOwner 未启用 API key 时所有调用方都是 anonymous，可以删除彼此的订阅；管理接口注册的订阅只能从管理接口删除。
+// 按 key 本身区分，API_KEYS 配置的 key 名字都是 env，不能用名字
+func webhookOwner(ctx context.Context) string {
+	if k := apiKeyFromContext(ctx); k != nil {
+		sum := sha256.Sum256([]byte(k.Key))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+	return "anonymous"
+}
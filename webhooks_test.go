@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourname/proxy/translate"
+)
+
+func TestWebhookURLValidation(t *testing.T) {
+	for _, u := range []string{"ftp://example.com/hook", "example.com/hook", "http://", "://bad"} {
+		if _, err := newWebhookSubscription(webhookConfig{URL: u, Type: webhookBlocks}); err == nil {
+			t.Errorf("%q accepted", u)
+		}
+	}
+
+	ctx := context.Background()
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.0.10/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		if err := checkWebhookHost(ctx, u); err == nil {
+			t.Errorf("%q accepted", u)
+		}
+	}
+	if err := checkWebhookHost(ctx, "https://203.0.113.5/hook"); err != nil {
+		t.Errorf("public address rejected: %v", err)
+	}
+
+	// 连接时再检查一次，域名改解析到内网也连不上
+	if err := webhookDialControl("tcp", "169.254.169.254:80", nil); err == nil {
+		t.Error("dial to the metadata address allowed")
+	}
+	if err := webhookDialControl("tcp", "203.0.113.5:443", nil); err != nil {
+		t.Errorf("dial to a public address refused: %v", err)
+	}
+}
+
+// API_KEYS 配置的 key 名字都是 env，不同的 key 不能删除彼此的订阅
+func TestWebhookOwnerPerKey(t *testing.T) {
+	withKey := func(key string) context.Context {
+		return context.WithValue(context.Background(), apiKeyCtxKey{}, &apiKey{Key: key, Name: "env"})
+	}
+	a, b := withKey("key-a"), withKey("key-b")
+	if webhookOwner(a) == webhookOwner(b) {
+		t.Errorf("two keys share owner %q", webhookOwner(a))
+	}
+	if webhookOwner(a) != webhookOwner(withKey("key-a")) {
+		t.Error("owner not stable for the same key")
+	}
+}
+
+// webhookReceiver 记录收到的推送，前 failures 次返回 500
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	failures int
+}
+
+func (h *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, r)
+	h.bodies = append(h.bodies, body)
+	if len(h.requests) <= h.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *webhookReceiver) payloads() []webhookPayload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []webhookPayload
+	for _, b := range h.bodies {
+		var p webhookPayload
+		json.Unmarshal(b, &p)
+		out = append(out, p)
+	}
+	return out
+}
+
+// testWebhook 回调地址是本机的 httptest 服务，需要放开内网限制
+func testWebhook(t *testing.T, c webhookConfig) *webhookSubscription {
+	t.Helper()
+	oldPrivate, oldBackoff := webhookAllowPrivate, webhookRetryBackoff
+	webhookAllowPrivate, webhookRetryBackoff = true, 10*time.Millisecond
+	s, err := newWebhookSubscription(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		close(s.stop)
+		webhookAllowPrivate, webhookRetryBackoff = oldPrivate, oldBackoff
+	})
+	return s
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 失败后重试，每次推送的 ID 不变，签名用注册时的 secret 能验证
+func TestWebhookDeliveryRetriesAndSigns(t *testing.T) {
+	recv := &webhookReceiver{failures: 1}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+	s := testWebhook(t, webhookConfig{ID: "wh1", URL: srv.URL + "/hook", Type: webhookBlocks, Secret: "s3cret"})
+
+	s.enqueue(webhookPayload{ID: "wh1-42", Subscription: "wh1", Type: webhookBlocks, BlockNumber: 42})
+	waitFor(t, "delivery", func() bool { return atomic.LoadInt64(&s.delivered) == 1 })
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	if len(recv.requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(recv.requests))
+	}
+	for i, r := range recv.requests {
+		if r.Header.Get("X-Webhook-Id") != "wh1-42" || r.Header.Get("X-Webhook-Attempt") != []string{"1", "2"}[i] {
+			t.Errorf("attempt %d: id %q, attempt %q", i+1, r.Header.Get("X-Webhook-Id"), r.Header.Get("X-Webhook-Attempt"))
+		}
+		fields := map[string]string{}
+		for _, part := range strings.Split(r.Header.Get(signatureHeader), ",") {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) == 2 {
+				fields[kv[0]] = kv[1]
+			}
+		}
+		digest := sha256.Sum256(recv.bodies[i])
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(fields["t"] + ".wh1-42." + hex.EncodeToString(digest[:])))
+		if fields["kid"] != "wh1" || fields["sig"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("attempt %d: signature %q does not verify", i+1, r.Header.Get(signatureHeader))
+		}
+	}
+}
+
+// 回调地址返回重定向时不跟随
+func TestWebhookRedirectNotFollowed(t *testing.T) {
+	var followed int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&followed, 1)
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer srv.Close()
+	s := testWebhook(t, webhookConfig{ID: "wh2", URL: srv.URL, Type: webhookBlocks})
+
+	if err := s.post("wh2-1", 1, []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "302") {
+		t.Errorf("err = %v, want HTTP 302", err)
+	}
+	if atomic.LoadInt32(&followed) != 0 {
+		t.Error("redirect was followed")
+	}
+}
+
+// address 订阅只推送涉及这些地址的交易和事件，回滚后 blocks 订阅先收到 reorg 通知
+func TestWebhookDispatch(t *testing.T) {
+	recv := &webhookReceiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+	owner, _ := translate.DecodeAddress(testCreator)
+	other := "41" + strings.Repeat("22", 20)
+	watched := testWebhook(t, webhookConfig{ID: "addr", URL: srv.URL, Type: webhookAddress, Addresses: []string{testCreator}, Next: 10})
+	blocks := testWebhook(t, webhookConfig{ID: "blk", URL: srv.URL, Type: webhookBlocks, Next: 10})
+	r := &webhookRegistry{byID: map[string]*webhookSubscription{"addr": watched, "blk": blocks}}
+
+	block := &restBlock{Transactions: make([]restTransaction, 2)}
+	for i, from := range []string{hex.EncodeToString(owner), other} {
+		tx := &block.Transactions[i]
+		tx.TxID = strings.Repeat(string("ab"[i]), 64)
+		tx.RawData.Contract = []restContract{{Type: "TransferContract"}}
+		tx.RawData.Contract[0].Parameter.Value = restContractValue{OwnerAddress: from, ToAddress: other, Amount: 7}
+	}
+	padded := "0x" + strings.Repeat("0", 24) + hex.EncodeToString(owner[1:])
+	logs := []ethLog{
+		{Address: "0x" + strings.Repeat("33", 20), Topics: []string{"0xddf2", "0x" + strings.Repeat("0", 64), padded}, LogIndex: "0x0"},
+		{Address: "0x" + strings.Repeat("33", 20), Topics: []string{"0xddf2", "0x" + strings.Repeat("0", 64), "0x" + strings.Repeat("0", 64)}, LogIndex: "0x1"},
+	}
+	r.dispatch(10, blockHeader{Number: 10, Hash: "0x10"}, block, logs)
+	r.rewind(8)
+	waitFor(t, "deliveries", func() bool { return len(recv.payloads()) == 3 })
+
+	byID := map[string]webhookPayload{}
+	for _, p := range recv.payloads() {
+		byID[p.ID] = p
+	}
+	p, ok := byID["addr-10"]
+	if !ok || len(p.Transactions) != 1 || len(p.Logs) != 1 || p.Logs[0].LogIndex != "0x0" {
+		t.Fatalf("address payload = %+v", p)
+	}
+	if want, _ := translate.ToEthAddress(testCreator); p.Transactions[0].From != want || p.Transactions[0].Hash != "0x"+strings.Repeat("a", 64) {
+		t.Errorf("transaction = %+v", p.Transactions[0])
+	}
+	if p, ok := byID["blk-10"]; !ok || p.Header == nil || p.Header.Hash != "0x10" {
+		t.Errorf("block payload = %+v", p)
+	}
+	reorgs := 0
+	for id, p := range byID {
+		if strings.HasPrefix(id, "blk-reorg-8-") && p.Type == "reorg" && p.BlockNumber == 8 {
+			reorgs++
+		}
+	}
+	if reorgs != 1 {
+		t.Errorf("payloads = %v, want one reorg notice for block 8", byID)
+	}
+	if watched.Next != 8 || blocks.Next != 8 {
+		t.Errorf("next = %d, %d after rewind, want 8", watched.Next, blocks.Next)
+	}
+}
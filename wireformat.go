@@ -0,0 +1,348 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Accept: application/msgpack 或 application/x-protobuf 时 JSON-RPC 响应用二进制编码，默认仍是 JSON。
+// 请求体始终是 JSON；请求体过大、限流这类非 200 的错误和 NDJSON 流式响应仍是 JSON，客户端按 Content-Type 区分。
+//
+// MessagePack 的结构与 JSON 相同（单个响应是 map，批量是数组）。protobuf 的结构如下，
+// 结果和 id 用 google.protobuf.Value 表示，整数按 double 编码，超过 2^53 会丢精度（JSON-RPC 的数量都是 hex 字符串，不受影响）：
+//
+//	message Response {
+//	  string jsonrpc = 1;
+//	  google.protobuf.Value id = 2;
+//	  google.protobuf.Value result = 3;
+//	  google.protobuf.Value error = 4;
+//	  google.protobuf.Value _proxy = 5;
+//	}
+//	message BatchResponse { repeated Response responses = 1; }
+var binaryResponses = envString("BINARY_RESPONSES", "true") == "true"
+
+const (
+	formatMsgpack  = "msgpack"
+	formatProtobuf = "protobuf"
+)
+
+// responseFormat 按 Accept 里先出现的类型选择，不支持的或未开启时返回空字符串（JSON）
+func responseFormat(r *http.Request) string {
+	if !binaryResponses {
+		return ""
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch mediaType {
+		case "application/msgpack", "application/x-msgpack", "application/vnd.msgpack":
+			return formatMsgpack
+		case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+			return formatProtobuf
+		case "application/json":
+			return ""
+		}
+	}
+	return ""
+}
+
+// encodedWriter 标记这个响应要用二进制编码，见 sendJSONRPCResponse 和 sendBatchResponse
+type encodedWriter struct {
+	http.ResponseWriter
+	format string
+}
+
+func withResponseFormat(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if f := responseFormat(r); f != "" {
+		return &encodedWriter{ResponseWriter: w, format: f}
+	}
+	return w
+}
+
+func (ew *encodedWriter) send(v interface{}) {
+	var body []byte
+	var err error
+	switch ew.format {
+	case formatMsgpack:
+		ew.Header().Set("Content-Type", "application/msgpack")
+		body, err = appendMsgpack(nil, v)
+	case formatProtobuf:
+		if _, batch := v.([]JSONRPCResponse); batch {
+			ew.Header().Set("Content-Type", "application/x-protobuf; proto=tronproxy.BatchResponse")
+		} else {
+			ew.Header().Set("Content-Type", "application/x-protobuf; proto=tronproxy.Response")
+		}
+		body, err = appendProtoResponses(nil, v)
+	}
+	if err != nil {
+		// 结果里有无法编码的值时退回 JSON
+		ew.Header().Set("Content-Type", "application/json")
+		codec.NewEncoder(ew.ResponseWriter).Encode(v)
+		return
+	}
+	ew.Write(body)
+}
+
+// appendMsgpack 编码 JSON 能表示的值；其他类型先经过一次 JSON 编解码
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if x {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendMsgpackString(b, x), nil
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<63 {
+			return appendMsgpackInt(b, int64(x)), nil
+		}
+		b = append(b, 0xcb)
+		return appendUint64(b, math.Float64bits(x)), nil
+	case int:
+		return appendMsgpackInt(b, int64(x)), nil
+	case int64:
+		return appendMsgpackInt(b, x), nil
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return appendMsgpackInt(b, n), nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendMsgpack(b, f)
+	case json.RawMessage:
+		var decoded interface{}
+		if err := codec.Unmarshal(x, &decoded); err != nil {
+			return nil, err
+		}
+		return appendMsgpack(b, decoded)
+	case []interface{}:
+		b = appendMsgpackHeader(b, len(x), 0x90, 0xdc, 0xdd)
+		for _, item := range x {
+			var err error
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = appendMsgpackHeader(b, len(x), 0x80, 0xde, 0xdf)
+		for k, item := range x {
+			b = appendMsgpackString(b, k)
+			var err error
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case JSONRPCResponse:
+		return appendMsgpack(b, responseFields(x))
+	case []JSONRPCResponse:
+		b = appendMsgpackHeader(b, len(x), 0x90, 0xdc, 0xdd)
+		for _, resp := range x {
+			var err error
+			if b, err = appendMsgpack(b, responseFields(resp)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	decoded, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgpack(b, decoded)
+}
+
+// responseFields 和 JSON 一样省略空的 result、error、_proxy
+func responseFields(resp JSONRPCResponse) map[string]interface{} {
+	m := map[string]interface{}{"jsonrpc": resp.Jsonrpc, "id": resp.ID}
+	if resp.Result != nil {
+		m["result"] = resp.Result
+	}
+	if resp.Error != nil {
+		m["error"] = resp.Error
+	}
+	if resp.Proxy != nil {
+		m["_proxy"] = resp.Proxy
+	}
+	return m
+}
+
+func toGeneric(v interface{}) (interface{}, error) {
+	raw, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := codec.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func appendMsgpackHeader(b []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return append(b, code16, byte(n>>8), byte(n))
+	}
+	return append(b, code32, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n < 128:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= 0 && n <= math.MaxUint32:
+		return append(b, 0xce, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		return append(b, 0xd2, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return appendUint64(append(b, 0xd3), uint64(n))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendProtoResponses(b []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case JSONRPCResponse:
+		return appendProtoResponse(b, x)
+	case []JSONRPCResponse:
+		for _, resp := range x {
+			msg, err := appendProtoResponse(nil, resp)
+			if err != nil {
+				return nil, err
+			}
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendBytes(b, msg)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unexpected response type %T", v)
+}
+
+func appendProtoResponse(b []byte, resp JSONRPCResponse) ([]byte, error) {
+	if resp.Jsonrpc != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, resp.Jsonrpc)
+	}
+	fields := []struct {
+		num protowire.Number
+		v   interface{}
+		set bool
+	}{
+		{2, resp.ID, true},
+		{3, resp.Result, resp.Result != nil},
+		{4, resp.Error, resp.Error != nil},
+		{5, resp.Proxy, resp.Proxy != nil},
+	}
+	for _, f := range fields {
+		if !f.set {
+			continue
+		}
+		value, err := appendProtoValue(nil, f.v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, f.num, protowire.BytesType)
+		b = protowire.AppendBytes(b, value)
+	}
+	return b, nil
+}
+
+// appendProtoValue 按 google.protobuf.Value 的格式编码：null_value = 1, number_value = 2, string_value = 3,
+// bool_value = 4, struct_value = 5 (Struct{map<string, Value> fields = 1}), list_value = 6 (ListValue{repeated Value values = 1})
+func appendProtoValue(b []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		return protowire.AppendVarint(b, 0), nil
+	case bool:
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(x)), nil
+	case string:
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		return protowire.AppendString(b, x), nil
+	case float64:
+		b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(x)), nil
+	case int:
+		return appendProtoValue(b, float64(x))
+	case int64:
+		return appendProtoValue(b, float64(x))
+	case json.Number:
+		f, err := x.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoValue(b, f)
+	case json.RawMessage:
+		var decoded interface{}
+		if err := codec.Unmarshal(x, &decoded); err != nil {
+			return nil, err
+		}
+		return appendProtoValue(b, decoded)
+	case []interface{}:
+		var list []byte
+		for _, item := range x {
+			value, err := appendProtoValue(nil, item)
+			if err != nil {
+				return nil, err
+			}
+			list = protowire.AppendTag(list, 1, protowire.BytesType)
+			list = protowire.AppendBytes(list, value)
+		}
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		return protowire.AppendBytes(b, list), nil
+	case map[string]interface{}:
+		var st []byte
+		for k, item := range x {
+			value, err := appendProtoValue(nil, item)
+			if err != nil {
+				return nil, err
+			}
+			entry := protowire.AppendTag(nil, 1, protowire.BytesType)
+			entry = protowire.AppendString(entry, k)
+			entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, value)
+			st = protowire.AppendTag(st, 1, protowire.BytesType)
+			st = protowire.AppendBytes(st, entry)
+		}
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		return protowire.AppendBytes(b, st), nil
+	}
+	decoded, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendProtoValue(b, decoded)
+}
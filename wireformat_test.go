@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// decodeMsgpack 只认 appendMsgpack 会写出的类型，数字统一成 float64，方便和 JSON 解码的结果比较
+func decodeMsgpack(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("unexpected end of input")
+	}
+	c, b := b[0], b[1:]
+	length := func(n int) (int, []byte, error) {
+		if len(b) < n {
+			return 0, nil, fmt.Errorf("short length")
+		}
+		v := 0
+		for _, x := range b[:n] {
+			v = v<<8 | int(x)
+		}
+		return v, b[n:], nil
+	}
+	var n int
+	var err error
+	switch {
+	case c <= 0x7f:
+		return float64(c), b, nil
+	case c >= 0xe0:
+		return float64(int8(c)), b, nil
+	case c == 0xc0:
+		return nil, b, nil
+	case c == 0xc2, c == 0xc3:
+		return c == 0xc3, b, nil
+	case c == 0xce:
+		n, b, err = length(4)
+		return float64(n), b, err
+	case c == 0xd2:
+		n, b, err = length(4)
+		return float64(int32(n)), b, err
+	case c == 0xd3, c == 0xcb:
+		if len(b) < 8 {
+			return nil, nil, fmt.Errorf("short number")
+		}
+		v := binary.BigEndian.Uint64(b)
+		if c == 0xd3 {
+			return float64(int64(v)), b[8:], nil
+		}
+		return math.Float64frombits(v), b[8:], nil
+	case c&0xe0 == 0xa0, c == 0xd9, c == 0xda, c == 0xdb:
+		switch c {
+		case 0xd9:
+			n, b, err = length(1)
+		case 0xda:
+			n, b, err = length(2)
+		case 0xdb:
+			n, b, err = length(4)
+		default:
+			n = int(c & 0x1f)
+		}
+		if err != nil || len(b) < n {
+			return nil, nil, fmt.Errorf("short string")
+		}
+		return string(b[:n]), b[n:], nil
+	case c&0xf0 == 0x90, c == 0xdc, c == 0xdd:
+		switch c {
+		case 0xdc:
+			n, b, err = length(2)
+		case 0xdd:
+			n, b, err = length(4)
+		default:
+			n = int(c & 0x0f)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		return list, b, nil
+	case c&0xf0 == 0x80, c == 0xde, c == 0xdf:
+		switch c {
+		case 0xde:
+			n, b, err = length(2)
+		case 0xdf:
+			n, b, err = length(4)
+		default:
+			n = int(c & 0x0f)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			var k, v interface{}
+			if k, b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+			if v, b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("map key %v is not a string", k)
+			}
+			m[key] = v
+		}
+		return m, b, nil
+	}
+	return nil, nil, fmt.Errorf("unexpected type byte 0x%02x", c)
+}
+
+// decodeProtoResponse 外层 Response 按字段号拆开，id、result、error、_proxy 用 structpb 解码
+func decodeProtoResponse(t *testing.T, b []byte) map[string]interface{} {
+	t.Helper()
+	out := map[string]interface{}{}
+	names := map[protowire.Number]string{2: "id", 3: "result", 4: "error", 5: "_proxy"}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || typ != protowire.BytesType {
+			t.Fatalf("bad tag in response: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if num == 1 {
+			out["jsonrpc"] = string(value)
+			continue
+		}
+		var v structpb.Value
+		if err := proto.Unmarshal(value, &v); err != nil {
+			t.Fatalf("field %d is not a google.protobuf.Value: %v", num, err)
+		}
+		out[names[num]] = v.AsInterface()
+	}
+	return out
+}
+
+// asJSON 客户端按 JSON 解码同一个值得到的结果
+func asJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func wireTestResponses() []JSONRPCResponse {
+	list := make([]interface{}, 20)
+	for i := range list {
+		list[i] = fmt.Sprintf("0x%x", i)
+	}
+	return []JSONRPCResponse{
+		{Jsonrpc: "2.0", ID: json.RawMessage(`7`), Result: "0x64"},
+		{Jsonrpc: "2.0", ID: "req-1", Result: map[string]interface{}{
+			"number": "0x1", "transactions": list, "big": int64(1) << 40, "negative": -5, "int8": -40, "min": -70000,
+			"ratio": 0.25, "ok": true, "none": nil, "long": strings.Repeat("x", 300), "nested": json.RawMessage(`{"a":[1,{"b":null}]}`),
+		}},
+		{Jsonrpc: "2.0", ID: json.RawMessage(`null`), Error: map[string]interface{}{"code": -32601, "message": "Method not found"}},
+		{Jsonrpc: "2.0", ID: 3, Result: "0x1", Proxy: &proxyMeta{}},
+	}
+}
+
+// MessagePack 解码结果和 JSON 解码结果一致
+func TestMsgpackRoundTrip(t *testing.T) {
+	resps := wireTestResponses()
+	for i, resp := range resps {
+		b, err := appendMsgpack(nil, resp)
+		if err != nil {
+			t.Fatalf("response %d: %v", i, err)
+		}
+		got, rest, err := decodeMsgpack(b)
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("response %d: %v, %d trailing bytes", i, err, len(rest))
+		}
+		if want := asJSON(t, resp); !reflect.DeepEqual(got, want) {
+			t.Errorf("response %d:\n got %v\nwant %v", i, got, want)
+		}
+	}
+	b, err := appendMsgpack(nil, resps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := decodeMsgpack(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := asJSON(t, resps); !reflect.DeepEqual(got, want) {
+		t.Errorf("batch:\n got %v\nwant %v", got, want)
+	}
+}
+
+// protobuf 编码能被标准的 google.protobuf.Value 解出来，批量响应是 repeated Response
+func TestProtobufRoundTrip(t *testing.T) {
+	resps := wireTestResponses()
+	b, err := appendProtoResponses(nil, resps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []interface{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || num != 1 || typ != protowire.BytesType {
+			t.Fatalf("bad BatchResponse field %d", num)
+		}
+		msg, m := protowire.ConsumeBytes(b[n:])
+		if m < 0 {
+			t.Fatal(protowire.ParseError(m))
+		}
+		b = b[n+m:]
+		got = append(got, decodeProtoResponse(t, msg))
+	}
+	want := asJSON(t, resps).([]interface{})
+	if len(got) != len(want) {
+		t.Fatalf("%d responses, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("response %d:\n got %v\nwant %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	cases := map[string]string{
+		"":                                       "",
+		"application/json":                       "",
+		"application/msgpack":                    formatMsgpack,
+		"application/x-protobuf; q=0.9":          formatProtobuf,
+		"application/json, application/msgpack":  "",
+		"text/html, Application/Vnd.MsgPack":     formatMsgpack,
+		"application/cbor, application/protobuf": formatProtobuf,
+	}
+	for accept, want := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", nil)
+		r.Header.Set("Accept", accept)
+		if got := responseFormat(r); got != want {
+			t.Errorf("Accept %q: format %q, want %q", accept, got, want)
+		}
+	}
+}
+
+// 经过 JSON-RPC 处理函数时按 Accept 编码，解码后和 JSON 响应相同
+func TestBinaryResponseOverHTTP(t *testing.T) {
+	withTestNode(t)
+	body := `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},{"jsonrpc":"2.0","id":2,"method":"no_such_method","params":[]}]`
+	var want interface{}
+	if err := json.Unmarshal(postJSONRPC(t, body), &want); err != nil {
+		t.Fatal(err)
+	}
+	for _, accept := range []string{"application/msgpack", "application/x-protobuf"} {
+		req := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(handleJSONRPC).ServeHTTP(rec, req)
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), accept) {
+			t.Errorf("%s: Content-Type %q", accept, rec.Header().Get("Content-Type"))
+			continue
+		}
+		var got interface{}
+		if accept == "application/msgpack" {
+			var err error
+			if got, _, err = decodeMsgpack(rec.Body.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			var list []interface{}
+			b := rec.Body.Bytes()
+			for len(b) > 0 {
+				_, _, n := protowire.ConsumeTag(b)
+				msg, m := protowire.ConsumeBytes(b[n:])
+				if n < 0 || m < 0 {
+					t.Fatal("bad BatchResponse")
+				}
+				b = b[n+m:]
+				list = append(list, decodeProtoResponse(t, msg))
+			}
+			got = list
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %v\nwant %v", accept, got, want)
+		}
+	}
+}
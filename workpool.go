@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// 所有扇出任务共享的全局并发上限，避免多个大批量请求同时压垮上游
+	workers = newWorkerPool(envInt("WORKER_POOL_SIZE", 32))
+	// 单个批量请求最多占用的 worker 数
+	batchConcurrency = envInt("BATCH_CONCURRENCY", 16)
+)
+
+type workerPool struct {
+	slots chan struct{}
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+func (p *workerPool) busy() int {
+	return len(p.slots)
+}
+
+func (p *workerPool) size() int {
+	return cap(p.slots)
+}
+
+type workerCtxKey struct{}
+
+// inWorker 标记 ctx 已经占用了一个全局 worker，fn 内嵌套调用 run 时必须传入这个 ctx，
+// 嵌套的任务只受 limit 限制，否则全局 worker 耗尽时会互相等待而死锁
+func inWorker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, workerCtxKey{}, true)
+}
+
+// run 并发执行 fn(0..n-1)，本次调用最多 limit 个并发，同时受全局 worker 数限制。
+// ctx 取消后不再调度新任务，已开始的任务执行完才返回。
+func (p *workerPool) run(ctx context.Context, n, limit int, fn func(i int)) error {
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	slots := p.slots
+	if ctx.Value(workerCtxKey{}) != nil {
+		slots = nil
+	}
+	local := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+schedule:
+	for i := 0; i < n; i++ {
+		select {
+		case local <- struct{}{}:
+		case <-ctx.Done():
+			break schedule
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				<-local
+				break schedule
+			}
+		}
+		idx := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if slots != nil {
+					<-slots
+				}
+				<-local
+			}()
+			fn(idx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}